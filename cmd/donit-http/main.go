@@ -11,6 +11,21 @@ import (
 var (
 	port  = flag.Int("port", 5088, "the port the server listens on")
 	dbURL = flag.String("db", "localhost", "the address of the database ([mongodb://][user:pass@]host1[:port1][,host2[:port2],...][/database][?options])")
+
+	metricsAddr = flag.String("metrics", server.DefaultConfig.MetricsAddr, "the address ([host]:port) serving the Prometheus metrics, empty to disable")
+	logFormat   = flag.String("log-format", server.DefaultConfig.LogFormat, "the format of the log records (logfmt or json)")
+	logLevel    = flag.String("log-level", server.DefaultConfig.LogLevel, "the minimum level of the logged records (debug, info or error)")
+
+	reminderInterval = flag.Duration("reminder-interval", server.DefaultConfig.ReminderInterval, "how often the due reminders are checked")
+	deliveryAttempts = flag.Int("delivery-attempts", server.DefaultConfig.DeliveryAttempts, "the number of attempts of a notification delivery before it is dead-lettered")
+	smtpAddr         = flag.String("smtp", server.DefaultConfig.SMTPAddr, "the address (host:port) of the SMTP server sending the email notifications, empty to disable")
+	smtpFrom         = flag.String("smtp-from", server.DefaultConfig.SMTPFrom, "the sender address of the email notifications")
+	smtpUsername     = flag.String("smtp-username", "", "the SMTP username, empty to send without authenticating")
+	smtpPassword     = flag.String("smtp-password", "", "the SMTP password")
+
+	notificationRetention = flag.Duration("notification-retention", server.DefaultConfig.NotificationRetention, "how long the notifications are kept in the inboxes, 0 to keep them forever")
+	searchRebuildInterval = flag.Duration("search-rebuild-interval", server.DefaultConfig.SearchRebuildInterval, "how often the search index is rebuilt from the database, 0 to only build it on start")
+	idempotencyRetention  = flag.Duration("idempotency-retention", server.DefaultConfig.IdempotencyRetention, "how long the responses of the POST requests with an Idempotency-Key header are replayed to their retries, 0 to ignore the header")
 )
 
 func main() {
@@ -20,6 +35,24 @@ func main() {
 		Port:   *port,
 		DBURL:  *dbURL,
 		DBName: server.DefaultConfig.DBName,
+
+		MetricsAddr: *metricsAddr,
+		LogFormat:   *logFormat,
+		LogLevel:    *logLevel,
+
+		ReminderInterval: *reminderInterval,
+		ReminderLookback: server.DefaultConfig.ReminderLookback,
+		DeliveryAttempts: *deliveryAttempts,
+		DeliveryBackoff:  server.DefaultConfig.DeliveryBackoff,
+		WebhookTimeout:   server.DefaultConfig.WebhookTimeout,
+		SMTPAddr:         *smtpAddr,
+		SMTPFrom:         *smtpFrom,
+		SMTPUsername:     *smtpUsername,
+		SMTPPassword:     *smtpPassword,
+
+		NotificationRetention: *notificationRetention,
+		SearchRebuildInterval: *searchRebuildInterval,
+		IdempotencyRetention:  *idempotencyRetention,
 	}
 	s, err := server.New(conf)
 	if err != nil {
@@ -0,0 +1,95 @@
+// Command donit-import imports the tasks and habits of an iCalendar or CSV
+// file into a goal, the way the import endpoint of the API does. The
+// changes are recorded as made by the owner of the goal. The server learns
+// about them at its next search index rebuild rather than by their events
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/iocat/donit/handler"
+	"github.com/iocat/donit/internal/achieving/importer"
+	"github.com/iocat/donit/server"
+	"gopkg.in/mgo.v2"
+)
+
+var (
+	dbURL    = flag.String("db", "localhost", "the address of the database ([mongodb://][user:pass@]host1[:port1][,host2[:port2],...][/database][?options])")
+	username = flag.String("user", "", "the owner of the goal")
+	goalID   = flag.String("goal", "", "the ID of the goal the tasks are added to")
+	format   = flag.String("format", "", "the format of the file (ics or csv), by default the one of its extension")
+	dryRun   = flag.Bool("dry-run", false, "only report what would be imported")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s -user username -goal id [flags] file\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || len(*username) == 0 || len(*goalID) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[0], err)
+		os.Exit(1)
+	}
+}
+
+func run(path string) error {
+	f := *format
+	if len(f) == 0 {
+		f = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	session, err := mgo.DialWithTimeout(*dbURL, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("connect to the database: %s", err)
+	}
+	defer session.Close()
+	if err = handler.Setup(session.DB(server.DefaultConfig.DBName), nil); err != nil {
+		return err
+	}
+	user, err := handler.StoreActing(*username).RetrieveUser(*username)
+	if err != nil {
+		return err
+	}
+	goal, err := user.RetrieveGoal(*goalID)
+	if err != nil {
+		return err
+	}
+	rows, err := importer.Parse(file, f, goal.Location())
+	if err != nil {
+		return err
+	}
+	report, err := importer.Import(goal, f, rows, *dryRun)
+	if err != nil {
+		return err
+	}
+	for _, r := range report.Rows {
+		fmt.Printf("%d\t%s\t%s\t%s\n", r.Line, r.Status, r.Name, r.ID)
+		for _, e := range r.Errors {
+			fmt.Printf("\terror: %s\n", e.Message)
+		}
+		for _, w := range r.Warnings {
+			fmt.Printf("\twarning: %s\n", w)
+		}
+	}
+	fmt.Printf("created %d, ready %d, duplicates %d, invalid %d\n",
+		report.Created, report.Ready, report.Duplicates, report.Invalid)
+	if report.Invalid != 0 {
+		return fmt.Errorf("%d invalid rows", report.Invalid)
+	}
+	return nil
+}
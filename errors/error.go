@@ -18,6 +18,7 @@ import (
 	stderr "errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	docerr "github.com/iocat/donit/internal/achieving/errors"
 )
@@ -30,27 +31,38 @@ const (
 	codeResourceDuplicate
 	codeBadData
 	codeAuth
+	codeForbidden
+	codeRateLimited
+	codeConflict
+	codeKeyReused
 )
 
 type code int
 
-// HTTPStatus returns the http status code associated with the error
+// HTTPStatus returns the http status code associated with the error, the
+// unknown codes are internal errors
 func (c code) HTTPStatus() int {
 	switch c {
 	case codeAuth:
-		return http.StatusBadRequest
+		return http.StatusUnauthorized
+	case codeForbidden:
+		return http.StatusForbidden
+	case codeRateLimited:
+		return http.StatusTooManyRequests
+	case codeKeyReused:
+		return http.StatusUnprocessableEntity
 	case codeInternal:
 		return http.StatusInternalServerError
 	case codeResourceNotFound:
 		return http.StatusNotFound
 	case codeMethodNotAllowed:
 		return http.StatusMethodNotAllowed
-	case codeResourceDuplicate:
+	case codeResourceDuplicate, codeConflict:
 		return http.StatusConflict
 	case codeBadData, codeDecodeJSON:
 		return http.StatusBadRequest
 	default:
-		panic(stderr.New("unknown http status code for this error"))
+		return http.StatusInternalServerError
 	}
 }
 
@@ -67,6 +79,20 @@ var (
 	ErrDecodeJSON = newError(codeDecodeJSON, "unable to decode the JSON message")
 	// ErrDuplicateResource represents a resource duplicated error
 	ErrDuplicateResource = newError(codeResourceDuplicate, "resource duplicated")
+	// ErrAuthenticationRequired represents a request which needs
+	// credentials but was sent without
+	ErrAuthenticationRequired = newError(codeAuth, "authentication required")
+	// ErrAuthentication represents wrong credentials
+	ErrAuthentication = newError(codeAuth, "invalid username or password")
+	// ErrForbidden represents an authenticated caller acting on a resource
+	// it has no right to
+	ErrForbidden = newError(codeForbidden, "forbidden")
+	// ErrRequestInProgress represents a request retried while the first
+	// one with the same idempotency key is still being served
+	ErrRequestInProgress = newError(codeConflict, "a request with the same idempotency key is in progress")
+	// ErrIdempotencyKeyReused represents an idempotency key sent with
+	// another request than the first one
+	ErrIdempotencyKeyReused = newError(codeKeyReused, "the idempotency key was used for another request")
 )
 
 // Error represents a handler error
@@ -74,6 +100,17 @@ type Error struct {
 	Status string `json:"_status"`
 	Code   code   `json:"code,omitempty"`
 	Reason string `json:"reason,omitempty"`
+	// RequestID identifies the request which failed, it is the key to
+	// find the matching server logs
+	RequestID string `json:"requestId,omitempty"`
+	// InvalidParams lists the invalid fields, only the problem
+	// representation exposes them
+	InvalidParams []InvalidParam `json:"-"`
+	// RetryAfter is how long a rate limited client should wait
+	RetryAfter time.Duration `json:"-"`
+	// Undone lists the IDs of the changes undone before the request
+	// failed to undo the next one
+	Undone []string `json:"undone,omitempty"`
 }
 
 func (err Error) Error() string {
@@ -95,6 +132,29 @@ func NewAuthentication(reason interface{}) error {
 	return newError(codeAuth, reason)
 }
 
+// NewForbidden creates a new authorization error
+func NewForbidden(reason interface{}) error {
+	return newError(codeForbidden, reason)
+}
+
+// NewRateLimited creates an error telling the client to slow down and retry
+// after the duration
+func NewRateLimited(retryAfter time.Duration) error {
+	err := newError(codeRateLimited, "too many requests")
+	err.RetryAfter = retryAfter
+	return err
+}
+
+// WithUndone adds to the error the IDs of the changes undone before it
+func WithUndone(err error, undone []string) error {
+	e, ok := ParseDocumentError(err).(Error)
+	if !ok {
+		e = newError(codeInternal, err)
+	}
+	e.Undone = undone
+	return e
+}
+
 func newError(c code, reason interface{}) Error {
 	r := ""
 	switch reason := reason.(type) {
@@ -117,12 +177,24 @@ func ParseDocumentError(err error) error {
 	switch {
 	case docerr.IsDuplicated(err):
 		return newError(codeResourceDuplicate, err)
+	case docerr.IsConflict(err):
+		return newError(codeConflict, err)
 	case docerr.IsValidate(err):
-		return newError(codeBadData, err)
+		e := newError(codeBadData, err)
+		for _, f := range docerr.ValidateFields(err) {
+			e.InvalidParams = append(e.InvalidParams, InvalidParam{
+				Name:   f.Name,
+				Rule:   f.Rule,
+				Reason: f.Message,
+			})
+		}
+		return e
 	case docerr.IsNotFound(err):
 		return newError(codeResourceNotFound, err)
 	case err == docerr.ErrAuthentication:
 		return newError(codeAuth, err)
+	case err == docerr.ErrForbidden:
+		return newError(codeForbidden, err)
 	default:
 		return err
 
@@ -0,0 +1,171 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errors
+
+// ProblemContentType is the media type of the RFC 7807 error responses
+const ProblemContentType = "application/problem+json"
+
+// ProblemTypeBase prefixes the problem type URIs. The URIs are relative to
+// the API root where the catalog is served
+const ProblemTypeBase = "/problems/"
+
+// ProblemType documents a kind of error returned by the API. The Code is
+// stable and meant to be matched on by clients
+type ProblemType struct {
+	Code        string `json:"code"`
+	Type        string `json:"type"`
+	Title       string `json:"title"`
+	Status      int    `json:"status"`
+	Description string `json:"description"`
+}
+
+type catalogEntry struct {
+	name        string
+	title       string
+	description string
+}
+
+// catalog documents every error code. A code's name must never change
+// once released
+var catalog = map[code]catalogEntry{
+	codeDecodeJSON: {
+		name:        "invalid_json",
+		title:       "Invalid JSON",
+		description: "The request body is not valid JSON or a value has the wrong type.",
+	},
+	codeMethodNotAllowed: {
+		name:        "method_not_allowed",
+		title:       "Method not allowed",
+		description: "The resource does not support the request method.",
+	},
+	codeInternal: {
+		name:        "internal",
+		title:       "Internal server error",
+		description: "The server failed to process the request, retrying later may succeed.",
+	},
+	codeResourceNotFound: {
+		name:        "not_found",
+		title:       "Resource not found",
+		description: "The resource identified by the URL does not exist.",
+	},
+	codeResourceDuplicate: {
+		name:        "duplicate",
+		title:       "Resource duplicated",
+		description: "A resource with the same identifier already exists.",
+	},
+	codeBadData: {
+		name:        "invalid_data",
+		title:       "Invalid data",
+		description: "Some request values are invalid, invalid-params lists every invalid field with the rule it breaks.",
+	},
+	codeAuth: {
+		name:        "authentication_failed",
+		title:       "Authentication failed",
+		description: "The request has no credentials or the username or the password is wrong. The WWW-Authenticate header tells how to authenticate.",
+	},
+	codeForbidden: {
+		name:        "forbidden",
+		title:       "Forbidden",
+		description: "The authenticated user is not allowed to act on the resource.",
+	},
+	codeRateLimited: {
+		name:        "rate_limited",
+		title:       "Too many requests",
+		description: "The client sent too many requests, the Retry-After header tells when to retry.",
+	},
+	codeConflict: {
+		name:        "conflict",
+		title:       "Conflict",
+		description: "The resource was changed since the state the request relies on, or the first request with the same Idempotency-Key header is still being served.",
+	},
+	codeKeyReused: {
+		name:        "idempotency_key_reused",
+		title:       "Idempotency key reused",
+		description: "The Idempotency-Key header was sent earlier with another request, a key only identifies the retries of the same request.",
+	},
+}
+
+// Name returns the stable machine readable name of the code
+func (c code) Name() string {
+	return catalog[c].name
+}
+
+func (c code) problemType() ProblemType {
+	e := catalog[c]
+	return ProblemType{
+		Code:        e.name,
+		Type:        ProblemTypeBase + e.name,
+		Title:       e.title,
+		Status:      c.HTTPStatus(),
+		Description: e.description,
+	}
+}
+
+// Catalog returns the documentation of every error code, ordered by code
+func Catalog() []ProblemType {
+	types := make([]ProblemType, 0, len(catalog))
+	for c := code(1); int(c) <= len(catalog); c++ {
+		types = append(types, c.problemType())
+	}
+	return types
+}
+
+// LookupProblemType returns the documentation of the error code name
+func LookupProblemType(name string) (ProblemType, bool) {
+	for c, e := range catalog {
+		if e.name == name {
+			return c.problemType(), true
+		}
+	}
+	return ProblemType{}, false
+}
+
+// InvalidParam describes an invalid request field
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Rule   string `json:"rule"`
+	Reason string `json:"reason"`
+}
+
+// Problem is the RFC 7807 representation of an Error
+type Problem struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	Code          string         `json:"code"`
+	RequestID     string         `json:"requestId,omitempty"`
+	InvalidParams []InvalidParam `json:"invalid-params,omitempty"`
+	// Undone lists the IDs of the changes undone before an undo failed
+	Undone []string `json:"undone,omitempty"`
+}
+
+// Problem converts the error into its RFC 7807 representation. The instance
+// is the URI of the request which failed
+func (err Error) Problem(instance string) Problem {
+	t := err.Code.problemType()
+	return Problem{
+		Type:          t.Type,
+		Title:         t.Title,
+		Status:        t.Status,
+		Detail:        err.Reason,
+		Instance:      instance,
+		Code:          t.Code,
+		RequestID:     err.RequestID,
+		InvalidParams: err.InvalidParams,
+		Undone:        err.Undone,
+	}
+}
@@ -3,13 +3,14 @@ package handler
 import (
 	"net/http"
 
+	"github.com/iocat/donit/errors"
 	"github.com/iocat/donit/handler/internal/utils"
 	"github.com/iocat/donit/internal/achieving"
 	"github.com/iocat/donit/internal/achieving/validator"
 )
 
 // handler will receive a key if getResourceKey is marked true
-func decorateAchievableHandler(getResourceKey bool, handler func(achieving.Goal, string, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+func decorateAchievableHandler(getResourceKey bool, perm permission, handler func(achieving.Goal, string, http.ResponseWriter, *http.Request)) http.HandlerFunc {
 	var keyGeneratorFunc func() []string
 	if getResourceKey {
 		keyGeneratorFunc = Achievable.resourceKeyNames
@@ -18,7 +19,7 @@ func decorateAchievableHandler(getResourceKey bool, handler func(achieving.Goal,
 	}
 
 	// getParentResource gets the parent resource of the Achievable
-	var getParentResource = func(username string, id string) (achieving.Goal, error) {
+	var getParentResource = func(store achieving.UserStore, username string, id string) (achieving.Goal, error) {
 		user, err := store.RetrieveUser(username)
 		if err != nil {
 			return nil, err
@@ -38,13 +39,19 @@ func decorateAchievableHandler(getResourceKey bool, handler func(achieving.Goal,
 		// get the username and the goal id
 		ids, err = utils.MuxGetParams(r, keyGeneratorFunc()...)
 		if err != nil {
-			utils.HandleError(err, w)
+			utils.HandleError(err, w, r)
 			return
 		}
 		username, goalid := ids[0], ids[1]
-		goal, err := getParentResource(username, goalid)
+		goal, err := getParentResource(requestStore(r), username, goalid)
 		if err != nil {
-			utils.HandleError(err, w)
+			utils.HandleError(err, w, r)
+			return
+		}
+		// the access is resolved through the members of the goal, the
+		// goals the caller cannot see are not found
+		if err = authorizeGoal(r, perm, goal); err != nil {
+			utils.HandleError(err, w, r)
 			return
 		}
 		// Get the resource id by request
@@ -57,26 +64,26 @@ func decorateAchievableHandler(getResourceKey bool, handler func(achieving.Goal,
 }
 
 // CreateAchievable creates an achievable task
-var CreateAchievable = decorateAchievableHandler(false, createAchievable)
+var CreateAchievable = decorateAchievableHandler(false, write, createAchievable)
 
 // UpdateAchievable updates an achievable task
-var UpdateAchievable = decorateAchievableHandler(true, updateAchievable)
+var UpdateAchievable = decorateAchievableHandler(true, write, updateAchievable)
 
 // DeleteAchievable deletes an achievable task
-var DeleteAchievable = decorateAchievableHandler(true, deleteAchievable)
+var DeleteAchievable = decorateAchievableHandler(true, write, deleteAchievable)
 
 // AllAchievables reads a list of achievable tasks
-var AllAchievables = decorateAchievableHandler(false, allAchievables)
+var AllAchievables = decorateAchievableHandler(false, read, allAchievables)
 
 func createAchievable(goal achieving.Goal, _ string, w http.ResponseWriter, r *http.Request) {
 	ach, err := validator.Validate(r.Body, Achievable.interpreter())
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	_, err = goal.AddAchievable(ach.(achieving.Achievable))
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	utils.WriteJSONtoHTTP(nil, w, http.StatusCreated)
@@ -85,21 +92,21 @@ func createAchievable(goal achieving.Goal, _ string, w http.ResponseWriter, r *h
 func updateAchievable(goal achieving.Goal, achid string, w http.ResponseWriter, r *http.Request) {
 	ach, err := validator.Validate(r.Body, Achievable.interpreter())
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	err = goal.UpdateAchievable(ach.(achieving.Achievable), achid)
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	utils.WriteJSONtoHTTP(nil, w, http.StatusOK)
 }
 
-func deleteAchievable(goal achieving.Goal, achid string, w http.ResponseWriter, _ *http.Request) {
+func deleteAchievable(goal achieving.Goal, achid string, w http.ResponseWriter, r *http.Request) {
 	err := goal.RemoveAchievable(achid)
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	utils.WriteJSONtoHTTP(nil, w, http.StatusOK)
@@ -108,13 +115,29 @@ func deleteAchievable(goal achieving.Goal, achid string, w http.ResponseWriter,
 func allAchievables(goal achieving.Goal, _ string, w http.ResponseWriter, r *http.Request) {
 	l, o, err := utils.GetLimitAndOffset(r)
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
-
-	achs, err := goal.RetrieveAchievables(l, o)
+	tag, err := listTag(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	var achs []achieving.Achievable
+	switch order := r.Form.Get("order"); {
+	case len(order) == 0 && len(tag) == 0:
+		achs, err = goal.RetrieveAchievables(l, o)
+	case len(order) == 0:
+		achs, err = goal.RetrieveTaggedAchievables(tag, l, o)
+	case order == "topological" && len(tag) == 0:
+		achs, err = goal.RetrieveOrderedAchievables(l, o)
+	case order == "topological":
+		err = errors.NewBadData("the tasks filtered by tag are not ordered")
+	default:
+		err = errors.ErrBadData
+	}
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	utils.WriteJSONtoHTTP(achs, w, http.StatusOK)
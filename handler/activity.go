@@ -0,0 +1,116 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/iocat/donit/errors"
+	"github.com/iocat/donit/handler/internal/utils"
+	"github.com/iocat/donit/internal/achieving"
+)
+
+var (
+	// GoalActivityURL is the URL of the activity log of a goal and its
+	// achievables
+	GoalActivityURL = Goal.URL() + "/activity"
+	// RevertURL is the URL a goal, or one of its achievables, is reverted
+	// to the revision of an activity at
+	RevertURL = GoalActivityURL + "/{activity}/revert"
+	// UserActivityURL is the URL of the activity log of a user
+	UserActivityURL = User.URL() + "/activity"
+	// UndoURL is the URL the latest changes of a user are undone at
+	UndoURL = User.URL() + "/undo"
+)
+
+// GoalActivity lists the changes of a goal and its achievables to the
+// users who can edit it, the revisions hold what the goal no longer shows
+var GoalActivity = decorateGoalItemHandler(false, "", write, goalActivity)
+
+// RevertGoal reverts a goal or one of its achievables to a revision
+var RevertGoal = decorateGoalHandler(true, ownerOnly, revertGoal)
+
+// UserActivity lists the changes of the user's data and goals and the
+// changes the user made
+var UserActivity = decorateUserHandler(true, ownerOnly, userActivity)
+
+// Undo undoes the latest changes the user made
+var Undo = decorateUserHandler(true, ownerOnly, undo)
+
+func goalActivity(goal achieving.Goal, _, _ string, w http.ResponseWriter, r *http.Request) {
+	l, o, err := utils.GetLimitAndOffset(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	as, err := goal.RetrieveActivity(l, o)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(as, w, http.StatusOK)
+}
+
+func revertGoal(user achieving.User, gid string, w http.ResponseWriter, r *http.Request) {
+	ids, err := utils.MuxGetParams(r, "activity")
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	if err = user.RevertGoal(gid, ids[0]); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func userActivity(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	l, o, err := utils.GetLimitAndOffset(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	as, err := store.RetrieveActivity(username, l, o)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(as, w, http.StatusOK)
+}
+
+func undo(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	count := 1
+	if s := r.URL.Query().Get("count"); len(s) != 0 {
+		var err error
+		if count, err = strconv.Atoi(s); err != nil {
+			utils.HandleError(errors.ErrBadData, w, r)
+			return
+		}
+	}
+	as, err := store.Undo(username, count)
+	if err != nil {
+		if len(as) != 0 {
+			undone := make([]string, 0, len(as))
+			for _, a := range as {
+				undone = append(undone, a.ActivityID())
+			}
+			err = errors.WithUndone(err, undone)
+		}
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(as, w, http.StatusOK)
+}
@@ -0,0 +1,99 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/iocat/donit/errors"
+	"github.com/iocat/donit/handler/internal/utils"
+	"github.com/iocat/donit/internal/achieving"
+	"github.com/iocat/donit/internal/achieving/archive"
+	docerr "github.com/iocat/donit/internal/achieving/errors"
+)
+
+var (
+	// ExportURL is the URL of the archive of a user's account
+	ExportURL = User.URL() + "/export"
+	// AccountImportURL is the URL recreating an account from an archive
+	AccountImportURL = User.URL() + "/import"
+)
+
+// maxArchiveSize is the maximum size of an imported archive
+const maxArchiveSize = 32 << 20
+
+// accounts exports and imports the accounts
+var accounts *archive.Archive
+
+// Export streams the archive of the user's account
+var Export = decorateUserHandler(true, ownerOnly, exportAccount)
+
+// ImportAccount recreates the account of an archive: a new account is
+// created with the password, an existing one is restored by its owner
+var ImportAccount = decorateUserHandler(true, read, importAccount)
+
+func exportAccount(_ achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	acc, err := accounts.Load(username, now)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	w.Header().Set("Content-Type", archive.ContentType)
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="donit-%s-%s.zip"`, username, now.UTC().Format("20060102")))
+	w.WriteHeader(http.StatusOK)
+	// NOTE: the errors writing the response are the client's
+	_ = acc.Write(w)
+}
+
+func importAccount(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	_, err := store.RetrieveUser(username)
+	create := docerr.IsNotFound(err)
+	if err != nil && !create {
+		utils.HandleError(err, w, r)
+		return
+	}
+	var password string
+	if create {
+		password, err = getPassword(r)
+	} else {
+		err = authorize(r, write, username)
+	}
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxArchiveSize))
+	if err != nil {
+		utils.HandleError(errors.NewBadData(fmt.Sprintf("read the archive: %s", err)), w, r)
+		return
+	}
+	acc, err := archive.Read(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		utils.HandleError(errors.NewBadData(err), w, r)
+		return
+	}
+	summary, err := accounts.Import(store, acc, username, password, create)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(summary, w, http.StatusOK)
+}
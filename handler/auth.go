@@ -0,0 +1,159 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/iocat/donit/errors"
+	"github.com/iocat/donit/handler/internal/utils"
+	"github.com/iocat/donit/internal/achieving"
+	docerr "github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/auth"
+)
+
+const (
+	// maxAuthFailures is the number of wrong passwords allowed for a
+	// username from a client address in authFailureWindow
+	maxAuthFailures   = 5
+	authFailureWindow = 15 * time.Minute
+)
+
+var authLimiter = auth.NewLimiter(maxAuthFailures, authFailureWindow)
+
+// permission is the right a handler requires on the resource of the URL
+type permission int
+
+const (
+	// read lets through whoever can see the resource
+	read permission = iota
+	// write only lets the owner of the resource through, and the owners and
+	// the editors of a shared goal
+	write
+	// ownerOnly only lets the owner of the resource through, even to read
+	// it, and the owners of a shared goal
+	ownerOnly
+	// authenticated lets any authenticated caller through, the store checks
+	// what the caller may change
+	authenticated
+)
+
+// Authenticate identifies the caller using the HTTP basic authentication
+// credentials. The requests without credentials go through anonymously
+// while the ones with wrong credentials are rejected
+func Authenticate(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if err := authenticate(r, requestStore(r), username, password); err != nil {
+			utils.HandleError(err, w, r)
+			return
+		}
+		h.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), username)))
+	})
+}
+
+// authenticate checks the password of the user
+func authenticate(r *http.Request, store achieving.UserStore, username, password string) error {
+	return throttled(r, username, func() error {
+		_, err := store.Authenticate(username, password)
+		return err
+	})
+}
+
+// throttled runs the operation checking the user's password unless the
+// client failed too many times recently. The failures are counted per client
+// address and username so that the wrong passwords sent from elsewhere do
+// not lock the user out
+func throttled(r *http.Request, username string, op func() error) error {
+	key := clientAddr(r) + " " + username
+	if retryAfter, ok := authLimiter.Allow(key); !ok {
+		return errors.NewRateLimited(retryAfter)
+	}
+	err := op()
+	switch {
+	case err == docerr.ErrAuthentication:
+		authLimiter.Fail(key)
+	case err == nil:
+		authLimiter.Reset(key)
+	}
+	return err
+}
+
+// clientAddr returns the IP address of the client
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// caller returns the authenticated caller's username or an empty string
+func caller(r *http.Request) string {
+	username, _ := auth.Caller(r.Context())
+	return username
+}
+
+// authorize checks that the caller has the permission on the resources
+// owned by the owner
+func authorize(r *http.Request, perm permission, owner string) error {
+	if perm == read {
+		return nil
+	}
+	username, ok := auth.Caller(r.Context())
+	if !ok {
+		return errors.ErrAuthenticationRequired
+	}
+	if perm == authenticated {
+		return nil
+	}
+	if username != owner {
+		return errors.ErrForbidden
+	}
+	return nil
+}
+
+// authorizeGoal checks that the caller has the permission on the goal and on
+// its achievables. The members of a shared goal have the permissions of
+// their role whoever owns the URL, and the goals the caller cannot see are
+// not found
+func authorizeGoal(r *http.Request, perm permission, goal achieving.Goal) error {
+	username, ok := auth.Caller(r.Context())
+	if perm == read {
+		if !goal.VisibleTo(username) {
+			return errors.ErrNotFound
+		}
+		return nil
+	}
+	if !ok {
+		return errors.ErrAuthenticationRequired
+	}
+	switch {
+	case goal.CanManage(username),
+		perm == write && goal.CanEdit(username),
+		perm == authenticated && goal.VisibleTo(username):
+		return nil
+	case !goal.VisibleTo(username):
+		return errors.ErrNotFound
+	default:
+		return errors.ErrForbidden
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"net/http"
+
+	"github.com/iocat/donit/handler/internal/utils"
+	"github.com/iocat/donit/internal/achieving"
+	"github.com/iocat/donit/internal/achieving/batch"
+	"github.com/iocat/donit/internal/logger"
+)
+
+// BatchURL is the URL the batches of operations on a user's goals and
+// achievables are run at
+var BatchURL = User.URL() + "/batch"
+
+// maxBatchSize is the size of a batch at most
+const maxBatchSize = 1 << 20
+
+// RunBatch runs a batch of operations on the goals and the achievables of a
+// user, the members of the shared goals change them as their role allows
+var RunBatch = decorateUserHandler(true, authenticated, runBatch)
+
+func runBatch(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	var req batch.Request
+	if err := utils.DecodeJSON(http.MaxBytesReader(w, r.Body, maxBatchSize), &req); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	user, err := store.RetrieveUser(username)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	report, err := batch.Run(user, username, caller(r), req.Operations,
+		Goal.interpreter(), Achievable.interpreter())
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	log := logger.FromContext(r.Context())
+	for i := range report.Results {
+		if res := &report.Results[i]; res.Status >= http.StatusInternalServerError {
+			log.Error("batch operation failed", "operation", i, "err", res.Cause())
+		}
+	}
+	utils.WriteJSONtoHTTP(report, w, http.StatusOK)
+}
@@ -0,0 +1,88 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"net/http"
+
+	"github.com/iocat/donit/handler/internal/utils"
+	"github.com/iocat/donit/internal/achieving"
+	docerr "github.com/iocat/donit/internal/achieving/errors"
+)
+
+var (
+	// BlockedURL is the URL of the users a user blocks
+	BlockedURL = User.URL() + "/blocked"
+	// BlockeeURL is the URL of a user blocked by another
+	BlockeeURL = BlockedURL + "/{blocked}"
+)
+
+// Block makes the user block another one
+var Block = decorateUserHandler(true, write, blockUser)
+
+// Unblock makes the user stop blocking another one
+var Unblock = decorateUserHandler(true, write, unblockUser)
+
+// Blocked lists the users the user blocks
+var Blocked = decorateUserHandler(true, ownerOnly, allBlocked)
+
+func blockee(r *http.Request) (string, error) {
+	ids, err := utils.MuxGetParams(r, "blocked")
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+func blockUser(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	blocked, err := blockee(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	// blocking someone twice changes nothing
+	if err = store.Block(username, blocked); err != nil && !docerr.IsDuplicated(err) {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func unblockUser(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	blocked, err := blockee(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	if err = store.Unblock(username, blocked); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func allBlocked(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	l, o, err := utils.GetLimitAndOffset(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	usernames, err := store.RetrieveBlocked(username, l, o)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(usernames, w, http.StatusOK)
+}
@@ -0,0 +1,121 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/iocat/donit/errors"
+	"github.com/iocat/donit/handler/internal/utils"
+	"github.com/iocat/donit/internal/achieving"
+	"github.com/iocat/donit/internal/achieving/calendar"
+	"github.com/iocat/donit/internal/ical"
+)
+
+var (
+	// CalendarURL is the URL of a user's iCalendar feed
+	CalendarURL = User.URL() + "/calendar.ics"
+	// CalendarTokenURL is the URL of the token granting access to a
+	// user's feed
+	CalendarTokenURL = User.URL() + "/calendar/token"
+)
+
+// feed and feedTokens are the iCalendar feeds and their tokens
+var (
+	feed       *calendar.Feed
+	feedTokens *calendar.Tokens
+)
+
+// Calendar serves the user's reminders as an iCalendar feed to the owner or
+// to the holders of the feed's token
+var Calendar = decorateUserHandler(true, read, calendarFeed)
+
+// RotateCalendarToken creates a new token for the user's feed
+var RotateCalendarToken = decorateUserHandler(true, write, rotateCalendarToken)
+
+// RevokeCalendarToken revokes the token of the user's feed
+var RevokeCalendarToken = decorateUserHandler(true, write, revokeCalendarToken)
+
+// authorizeFeed checks that the caller is the owner of the feed or holds its
+// token. The calendar applications cannot authenticate, they send the token
+// in the query string
+func authorizeFeed(r *http.Request, username string) error {
+	if caller(r) == username {
+		return nil
+	}
+	token := r.URL.Query().Get("token")
+	ok, err := feedTokens.Check(username, token)
+	if err != nil {
+		return err
+	}
+	switch {
+	case ok:
+		return nil
+	case len(token) == 0 && len(caller(r)) == 0:
+		return errors.ErrAuthenticationRequired
+	default:
+		return errors.ErrForbidden
+	}
+}
+
+func calendarFeed(_ achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	if err := authorizeFeed(r, username); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	var tasksAsEvents bool
+	switch r.URL.Query().Get("tasks") {
+	case "", "todos":
+	case "events":
+		tasksAsEvents = true
+	default:
+		utils.HandleError(errors.ErrBadData, w, r)
+		return
+	}
+	c, err := feed.Calendar(username)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	c.TasksAsEvents = tasksAsEvents
+	w.Header().Set("Content-Type", ical.ContentType)
+	// the feed URL holds the token, keep it out of the shared caches
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.WriteHeader(http.StatusOK)
+	// NOTE: the errors writing the response are the client's
+	_ = c.Write(w, time.Now())
+}
+
+func rotateCalendarToken(_ achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	token, err := feedTokens.Rotate(username)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(calendar.Access{
+		Token: token,
+		URL:   strings.Replace(CalendarURL, "{user}", username, 1) + "?token=" + token,
+	}, w, http.StatusOK)
+}
+
+func revokeCalendarToken(_ achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	if err := feedTokens.Revoke(username); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
@@ -0,0 +1,158 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"net/http"
+	"path"
+
+	"github.com/iocat/donit/handler/internal/utils"
+	"github.com/iocat/donit/internal/achieving"
+	docerr "github.com/iocat/donit/internal/achieving/errors"
+	json "github.com/iocat/donit/internal/achieving/jsoninterpreter"
+	"github.com/iocat/donit/internal/achieving/validator"
+)
+
+var (
+	// GoalCommentsURL is the URL of the comments on a goal
+	GoalCommentsURL = Goal.URL() + "/comments"
+	// GoalCommentURL is the URL of a comment on a goal
+	GoalCommentURL = GoalCommentsURL + "/{comment}"
+	// AchievableCommentsURL is the URL of the comments on an achievable
+	AchievableCommentsURL = Achievable.URL() + "/comments"
+	// AchievableCommentURL is the URL of a comment on an achievable
+	AchievableCommentURL = AchievableCommentsURL + "/{comment}"
+	// GoalReactionsURL is the URL of the reactions on a goal
+	GoalReactionsURL = Goal.URL() + "/reactions"
+	// GoalReactionURL is the URL of the caller's reaction on a goal with
+	// an emoji
+	GoalReactionURL = GoalReactionsURL + "/{emoji}"
+	// AchievableReactionsURL is the URL of the reactions on an achievable
+	AchievableReactionsURL = Achievable.URL() + "/reactions"
+	// AchievableReactionURL is the URL of the caller's reaction on an
+	// achievable with an emoji
+	AchievableReactionURL = AchievableReactionsURL + "/{emoji}"
+)
+
+// commentInterpreter decodes the comments
+var commentInterpreter = json.NewComment()
+
+var (
+	// AddGoalComment comments on a goal
+	AddGoalComment = decorateGoalItemHandler(false, "", authenticated, addComment)
+	// UpdateGoalComment edits a comment on a goal
+	UpdateGoalComment = decorateGoalItemHandler(false, "comment", authenticated, updateComment)
+	// DeleteGoalComment deletes a comment on a goal
+	DeleteGoalComment = decorateGoalItemHandler(false, "comment", authenticated, deleteComment)
+	// GoalComments lists the threads of comments on a goal
+	GoalComments = decorateGoalItemHandler(false, "", read, allComments)
+	// AddAchievableComment comments on an achievable
+	AddAchievableComment = decorateGoalItemHandler(true, "", authenticated, addComment)
+	// UpdateAchievableComment edits a comment on an achievable
+	UpdateAchievableComment = decorateGoalItemHandler(true, "comment", authenticated, updateComment)
+	// DeleteAchievableComment deletes a comment on an achievable
+	DeleteAchievableComment = decorateGoalItemHandler(true, "comment", authenticated, deleteComment)
+	// AchievableComments lists the threads of comments on an achievable
+	AchievableComments = decorateGoalItemHandler(true, "", read, allComments)
+
+	// ReactOnGoal reacts with an emoji on a goal
+	ReactOnGoal = decorateGoalItemHandler(false, "emoji", authenticated, react)
+	// UnreactOnGoal removes the caller's reaction on a goal
+	UnreactOnGoal = decorateGoalItemHandler(false, "emoji", authenticated, unreact)
+	// GoalReactions counts the reactions on a goal
+	GoalReactions = decorateGoalItemHandler(false, "", read, allReactions)
+	// ReactOnAchievable reacts with an emoji on an achievable
+	ReactOnAchievable = decorateGoalItemHandler(true, "emoji", authenticated, react)
+	// UnreactOnAchievable removes the caller's reaction on an achievable
+	UnreactOnAchievable = decorateGoalItemHandler(true, "emoji", authenticated, unreact)
+	// AchievableReactions counts the reactions on an achievable
+	AchievableReactions = decorateGoalItemHandler(true, "", read, allReactions)
+)
+
+func addComment(goal achieving.Goal, achid, _ string, w http.ResponseWriter, r *http.Request) {
+	c, err := validator.Validate(r.Body, commentInterpreter)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	id, err := goal.AddComment(caller(r), c.(achieving.Comment), achid)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTPWithLocation(path.Join(r.URL.EscapedPath(), id),
+		nil, w, http.StatusCreated)
+}
+
+func updateComment(goal achieving.Goal, _, id string, w http.ResponseWriter, r *http.Request) {
+	c, err := validator.Validate(r.Body, commentInterpreter)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	if err = goal.UpdateComment(caller(r), c.(achieving.Comment), id); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func deleteComment(goal achieving.Goal, _, id string, w http.ResponseWriter, r *http.Request) {
+	if err := goal.DeleteComment(caller(r), id); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func allComments(goal achieving.Goal, achid, _ string, w http.ResponseWriter, r *http.Request) {
+	l, o, err := utils.GetLimitAndOffset(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	cs, err := goal.RetrieveComments(achid, l, o)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(cs, w, http.StatusOK)
+}
+
+func react(goal achieving.Goal, achid, emoji string, w http.ResponseWriter, r *http.Request) {
+	// reacting twice with the same emoji changes nothing
+	if err := goal.React(caller(r), emoji, achid); err != nil && !docerr.IsDuplicated(err) {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func unreact(goal achieving.Goal, achid, emoji string, w http.ResponseWriter, r *http.Request) {
+	if err := goal.Unreact(caller(r), emoji, achid); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func allReactions(goal achieving.Goal, achid, _ string, w http.ResponseWriter, r *http.Request) {
+	rs, err := goal.RetrieveReactions(achid, caller(r))
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(rs, w, http.StatusOK)
+}
@@ -15,28 +15,32 @@
 package handler
 
 import (
-	"log"
 	"net/http"
-	"os"
 
 	"github.com/iocat/donit/errors"
 	"github.com/iocat/donit/handler/internal/utils"
 )
 
-var errLog = log.New(os.Stdout, "", log.Ldate|log.Ltime)
-
 var (
 	// NotFound is a default handler for non-supported method or path
 	NotFound = http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
-			utils.WriteJSONtoHTTP(errors.ErrNotFound, w, http.StatusNotFound)
+			utils.HandleError(errors.ErrNotFound, w, r)
 		},
 	)
 
 	// NotAllowed is a default handler for not-allowed method
 	NotAllowed = http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
-			utils.WriteJSONtoHTTP(errors.ErrMethodNotAllowed, w, http.StatusNotFound)
+			utils.HandleError(errors.ErrMethodNotAllowed, w, r)
+		},
+	)
+
+	// InternalError is the handler answering the requests which failed
+	// unexpectedly
+	InternalError = http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			utils.HandleError(errors.ErrInternal, w, r)
 		},
 	)
 )
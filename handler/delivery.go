@@ -0,0 +1,48 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"net/http"
+
+	"github.com/iocat/donit/handler/internal/utils"
+	"github.com/iocat/donit/internal/achieving"
+	"github.com/iocat/donit/internal/notify"
+)
+
+// DeliveriesURL is the URL of the delivery log of an achievable's reminders
+var DeliveriesURL = Achievable.URL() + "/deliveries"
+
+// Deliveries lists the deliveries of the reminders of an achievable task
+var Deliveries = decorateAchievableHandler(true, ownerOnly, allDeliveries)
+
+func allDeliveries(goal achieving.Goal, achid string, w http.ResponseWriter, r *http.Request) {
+	l, o, err := utils.GetLimitAndOffset(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	// Make sure the task belongs to the goal
+	if _, err = goal.RetrieveAchievable(achid); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	ds, err := notify.History(deliveries, achid, l, o)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(ds, w, http.StatusOK)
+}
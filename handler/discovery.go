@@ -0,0 +1,95 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/iocat/donit/errors"
+	"github.com/iocat/donit/handler/internal/utils"
+	"github.com/iocat/donit/internal/achieving"
+	"github.com/iocat/donit/internal/achieving/discovery"
+)
+
+var (
+	// ExploreURL is the URL of the feed of the public goals
+	ExploreURL = "/explore/goals"
+	// ProfileURL is the URL of the public profile of a user
+	ProfileURL = User.URL() + "/profile"
+)
+
+// explorer explores the public goals and the users
+var explorer *discovery.Explorer
+
+// Explore lists the public goals the caller discovers
+var Explore = http.HandlerFunc(explore)
+
+// SearchUsers searches the users the caller discovers
+var SearchUsers = http.HandlerFunc(searchUsers)
+
+// ReadProfile reads the public profile of a user
+var ReadProfile = decorateUserHandler(true, read, readProfile)
+
+func explore(w http.ResponseWriter, r *http.Request) {
+	l, o, err := utils.GetLimitAndOffset(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	order := r.Form.Get("sort")
+	switch order {
+	case "":
+		order = discovery.SortRecent
+	case discovery.SortRecent, discovery.SortPopular:
+	default:
+		utils.HandleError(errors.NewBadData("the sort is either recent or popular"), w, r)
+		return
+	}
+	gs, err := explorer.Goals(order, caller(r), l, o, time.Now())
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(gs, w, http.StatusOK)
+}
+
+func searchUsers(w http.ResponseWriter, r *http.Request) {
+	l, o, err := utils.GetLimitAndOffset(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	q := r.Form.Get("q")
+	if len(q) == 0 {
+		utils.HandleError(errors.NewBadData("the searched text is missing"), w, r)
+		return
+	}
+	ps, err := explorer.Users(q, caller(r), l, o)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(ps, w, http.StatusOK)
+}
+
+func readProfile(_ achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	p, err := explorer.Profile(username, caller(r))
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(p, w, http.StatusOK)
+}
@@ -0,0 +1,65 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"net/http"
+
+	"github.com/iocat/donit/handler/internal/utils"
+	"github.com/iocat/donit/internal/achieving/apispec"
+)
+
+const (
+	// OpenAPIURL is the URL of the OpenAPI document
+	OpenAPIURL = "/openapi.json"
+	// DocsURL is the URL of the interactive documentation
+	DocsURL = "/docs"
+)
+
+// OpenAPI serves the OpenAPI document
+func OpenAPI(doc *apispec.Document) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.WriteJSONtoHTTP(doc, w, http.StatusOK)
+	})
+}
+
+// docsPage renders the OpenAPI document with Swagger UI
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>donit API</title>
+	<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@3/swagger-ui.css">
+</head>
+<body>
+	<div id="swagger-ui"></div>
+	<script src="https://unpkg.com/swagger-ui-dist@3/swagger-ui-bundle.js"></script>
+	<script>
+		window.onload = function() {
+			SwaggerUIBundle({url: "` + OpenAPIURL + `", dom_id: "#swagger-ui"});
+		};
+	</script>
+</body>
+</html>
+`
+
+// Docs serves the interactive API documentation
+var Docs = http.HandlerFunc(
+	func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(docsPage))
+	},
+)
@@ -0,0 +1,107 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"net/http"
+
+	"github.com/iocat/donit/handler/internal/utils"
+	"github.com/iocat/donit/internal/achieving"
+	docerr "github.com/iocat/donit/internal/achieving/errors"
+)
+
+var (
+	// FollowersURL is the URL of a user's followers
+	FollowersURL = User.URL() + "/followers"
+	// FollowingURL is the URL of the users a user follows
+	FollowingURL = User.URL() + "/following"
+	// FolloweeURL is the URL of a user followed by another
+	FolloweeURL = FollowingURL + "/{followee}"
+)
+
+// Follow makes the user follow the followee
+var Follow = decorateUserHandler(true, write, follow)
+
+// Unfollow makes the user stop following the followee
+var Unfollow = decorateUserHandler(true, write, unfollow)
+
+// Followers lists the followers of the user
+var Followers = decorateUserHandler(true, read, allFollowers)
+
+// Following lists the users the user follows
+var Following = decorateUserHandler(true, read, allFollowing)
+
+func followee(r *http.Request) (string, error) {
+	ids, err := utils.MuxGetParams(r, "followee")
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+func follow(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	followee, err := followee(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	// following someone twice changes nothing
+	if err = store.Follow(username, followee); err != nil && !docerr.IsDuplicated(err) {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func unfollow(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	followee, err := followee(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	if err = store.Unfollow(username, followee); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func allFollowers(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	l, o, err := utils.GetLimitAndOffset(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	usernames, err := store.RetrieveFollowers(username, l, o)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(usernames, w, http.StatusOK)
+}
+
+func allFollowing(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	l, o, err := utils.GetLimitAndOffset(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	usernames, err := store.RetrieveFollowing(username, l, o)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(usernames, w, http.StatusOK)
+}
@@ -6,18 +6,19 @@ import (
 
 	"github.com/iocat/donit/internal/achieving/validator"
 
+	"github.com/iocat/donit/errors"
 	"github.com/iocat/donit/handler/internal/utils"
 	"github.com/iocat/donit/internal/achieving"
 )
 
-func decorateGoalHandler(getResourceKey bool, handler func(achieving.User, string, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+func decorateGoalHandler(getResourceKey bool, perm permission, handler func(achieving.User, string, http.ResponseWriter, *http.Request)) http.HandlerFunc {
 	var keyGeneratorFunc func() []string
 	if getResourceKey {
 		keyGeneratorFunc = Goal.resourceKeyNames
 	} else {
 		keyGeneratorFunc = Goal.collectionKeyNames
 	}
-	var getParentResource = func(username string) (achieving.User, error) {
+	var getParentResource = func(store achieving.UserStore, username string) (achieving.User, error) {
 		user, err := store.RetrieveUser(username)
 		if err != nil {
 			return nil, err
@@ -28,38 +29,107 @@ func decorateGoalHandler(getResourceKey bool, handler func(achieving.User, strin
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ids, err := utils.MuxGetParams(r, keyGeneratorFunc()...)
 		if err != nil {
-			utils.HandleError(err, w)
+			utils.HandleError(err, w, r)
 			return
 		}
 		username := ids[0]
-		user, err := getParentResource(username)
+		// the access to a goal is resolved through its members
+		if !getResourceKey {
+			if err = authorize(r, perm, username); err != nil {
+				utils.HandleError(err, w, r)
+				return
+			}
+		}
+		user, err := getParentResource(requestStore(r), username)
 		if err != nil {
-			utils.HandleError(err, w)
+			utils.HandleError(err, w, r)
 			return
 		}
 		var gid string
 		if getResourceKey {
 			gid = ids[1]
+			// the handlers reading the goal check it is visible
+			if perm != read {
+				goal, err := user.RetrieveGoal(gid)
+				if err != nil {
+					utils.HandleError(err, w, r)
+					return
+				}
+				if err = authorizeGoal(r, perm, goal); err != nil {
+					utils.HandleError(err, w, r)
+					return
+				}
+			}
 		}
 		handler(user, gid, w, r)
 	})
 }
 
-var CreateGoal = decorateGoalHandler(false, createGoal)
-var UpdateGoal = decorateGoalHandler(true, updateGoal)
-var DeleteGoal = decorateGoalHandler(true, deleteGoal)
-var ReadGoal = decorateGoalHandler(true, readGoal)
-var AllGoals = decorateGoalHandler(false, allGoals)
+// decorateGoalItemHandler decorates the handlers of what is attached to a
+// goal, or to an achievable if onAchievable is set: the comments, the
+// reactions and the members. The key names the path parameter identifying
+// the item, if any. The handler gets the goal, the achievable ID, empty for
+// the goal, and the key
+func decorateGoalItemHandler(onAchievable bool, key string, perm permission,
+	handler func(achieving.Goal, string, string, http.ResponseWriter, *http.Request)) http.Handler {
+	names := Goal.resourceKeyNames()
+	if onAchievable {
+		names = Achievable.resourceKeyNames()
+	}
+	if len(key) != 0 {
+		names = append(names, key)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids, err := utils.MuxGetParams(r, names...)
+		if err != nil {
+			utils.HandleError(err, w, r)
+			return
+		}
+		username, goalid := ids[0], ids[1]
+		user, err := requestStore(r).RetrieveUser(username)
+		if err != nil {
+			utils.HandleError(err, w, r)
+			return
+		}
+		goal, err := user.RetrieveGoal(goalid)
+		if err != nil {
+			utils.HandleError(err, w, r)
+			return
+		}
+		if err = authorizeGoal(r, perm, goal); err != nil {
+			utils.HandleError(err, w, r)
+			return
+		}
+		var achid, id string
+		if onAchievable {
+			achid = ids[2]
+			if _, err = goal.RetrieveAchievable(achid); err != nil {
+				utils.HandleError(err, w, r)
+				return
+			}
+		}
+		if len(key) != 0 {
+			id = ids[len(ids)-1]
+		}
+		handler(goal, achid, id, w, r)
+	})
+}
+
+var CreateGoal = decorateGoalHandler(false, write, createGoal)
+var UpdateGoal = decorateGoalHandler(true, write, updateGoal)
+var DeleteGoal = decorateGoalHandler(true, ownerOnly, deleteGoal)
+var ReadGoal = decorateGoalHandler(true, read, readGoal)
+var AllGoals = decorateGoalHandler(false, read, allGoals)
 
 func createGoal(user achieving.User, _ string, w http.ResponseWriter, r *http.Request) {
 	goal, err := validator.Validate(r.Body, Goal.interpreter())
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	id, err := user.CreateGoal(goal.(achieving.Goal))
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	utils.WriteJSONtoHTTPWithLocation(path.Join(r.URL.EscapedPath(), id),
@@ -69,12 +139,12 @@ func createGoal(user achieving.User, _ string, w http.ResponseWriter, r *http.Re
 func updateGoal(user achieving.User, goalid string, w http.ResponseWriter, r *http.Request) {
 	goal, err := validator.Validate(r.Body, Goal.interpreter())
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	err = user.UpdateGoal(goal.(achieving.Goal), goalid)
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	// TODO: Figure out how to set the resource location
@@ -85,7 +155,7 @@ func deleteGoal(user achieving.User, gid string, w http.ResponseWriter, r *http.
 
 	err := user.DeleteGoal(gid)
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
@@ -94,7 +164,12 @@ func deleteGoal(user achieving.User, gid string, w http.ResponseWriter, r *http.
 func readGoal(user achieving.User, gid string, w http.ResponseWriter, r *http.Request) {
 	goal, err := user.RetrieveGoal(gid)
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
+		return
+	}
+	// Hide the existence of the goals the caller cannot see
+	if !goal.VisibleTo(caller(r)) {
+		utils.HandleError(errors.ErrNotFound, w, r)
 		return
 	}
 	utils.WriteJSONtoHTTP(goal, w, http.StatusOK)
@@ -103,15 +178,18 @@ func readGoal(user achieving.User, gid string, w http.ResponseWriter, r *http.Re
 func allGoals(user achieving.User, _ string, w http.ResponseWriter, r *http.Request) {
 	l, o, err := utils.GetLimitAndOffset(r)
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
-
-	gs, err := user.RetrieveGoals(l, o)
+	tag, err := listTag(r)
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
+		return
+	}
+	gs, err := user.RetrieveGoalsVisibleTo(caller(r), tag, l, o)
+	if err != nil {
+		utils.HandleError(err, w, r)
 		return
 	}
-
 	utils.WriteJSONtoHTTP(gs, w, http.StatusOK)
 }
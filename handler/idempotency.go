@@ -0,0 +1,187 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/iocat/donit/errors"
+	"github.com/iocat/donit/handler/internal/utils"
+	"github.com/iocat/donit/internal/idempotency"
+	"github.com/iocat/donit/internal/logger"
+	"github.com/iocat/donit/internal/requestid"
+)
+
+// maxIdempotentSize bounds the body of a request sent with an idempotency
+// key, which is read before the request is served to fingerprint it, and the
+// body of the response kept for its retries
+const maxIdempotentSize = maxArchiveSize
+
+// keys stores the idempotency keys and the responses replayed to the
+// retries, nil ignores the Idempotency-Key header
+var keys *idempotency.Store
+
+// SetupIdempotency sets up the store of the idempotency keys
+func SetupIdempotency(s *idempotency.Store) {
+	keys = s
+}
+
+// Idempotent answers the retries of a request sent with the same
+// Idempotency-Key header by the same caller with the response of the first
+// one. The key cannot be used for another request until it expires. The
+// requests failing with a server error are not kept so that they can be
+// retried. The keys of the anonymous requests, which create the accounts,
+// are the ones of the account created
+func Idempotent(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotency.Header)
+		if keys == nil || len(key) == 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if !idempotency.ValidKey(key) {
+			utils.HandleError(errors.NewBadData(fmt.Sprintf(
+				"the %s header is 1 to %d printable characters", idempotency.Header, idempotency.MaxKeyLength)), w, r)
+			return
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentSize))
+		if err != nil {
+			utils.HandleError(errors.NewBadData(fmt.Sprintf("read the request body: %s", err)), w, r)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		username := caller(r)
+		if len(username) == 0 {
+			var ok bool
+			if username, ok = newAccount(body); !ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		kept, err := keys.Begin(username, key, idempotency.Fingerprint(r, body), time.Now())
+		switch {
+		case err == idempotency.ErrMismatch:
+			utils.HandleError(errors.ErrIdempotencyKeyReused, w, r)
+			return
+		case err == idempotency.ErrInProgress:
+			utils.HandleError(errors.ErrRequestInProgress, w, r)
+			return
+		case err != nil:
+			utils.HandleError(err, w, r)
+			return
+		case kept != nil:
+			replay(w, kept)
+			return
+		}
+
+		rec := &responseKeeper{ResponseWriter: w}
+		served := false
+		defer func() {
+			var err error
+			switch {
+			case !served:
+				// the handler panicked, its internal server error is
+				// retried
+				err = keys.Release(username, key)
+			case rec.status >= http.StatusInternalServerError ||
+				rec.status == http.StatusTooManyRequests || rec.overflow:
+				err = keys.Release(username, key)
+			default:
+				err = keys.Complete(username, key, &idempotency.Response{
+					Status: rec.status,
+					Header: rec.header,
+					Body:   rec.body.Bytes(),
+				})
+			}
+			if err != nil {
+				logger.FromContext(r.Context()).Error("keep the idempotent response", "err", err)
+			}
+		}()
+		h.ServeHTTP(rec, r)
+		served = true
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+	})
+}
+
+// newAccount returns the namespace of the keys of an anonymous request, the
+// username of the account the request creates. It cannot be the one of an
+// existing user since the usernames are alphanumeric. The requests without
+// a username have no namespace and are served as if they had no key
+func newAccount(body []byte) (string, bool) {
+	var u struct {
+		Username string `json:"username"`
+	}
+	if err := json.Unmarshal(body, &u); err != nil || len(u.Username) == 0 {
+		return "", false
+	}
+	return "new:" + u.Username, true
+}
+
+// replay writes a kept response
+func replay(w http.ResponseWriter, res *idempotency.Response) {
+	for name, values := range res.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(idempotency.ReplayedHeader, "true")
+	w.WriteHeader(res.Status)
+	w.Write(res.Body)
+}
+
+// responseKeeper writes the response through and keeps a copy of it
+type responseKeeper struct {
+	http.ResponseWriter
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	overflow bool
+}
+
+// WriteHeader keeps the status and the headers but the request ID, which
+// identifies the first request rather than the retries
+func (rk *responseKeeper) WriteHeader(status int) {
+	if rk.status != 0 {
+		return
+	}
+	rk.status = status
+	rk.header = make(http.Header)
+	for name, values := range rk.Header() {
+		if name != requestid.Header {
+			rk.header[name] = append([]string(nil), values...)
+		}
+	}
+	rk.ResponseWriter.WriteHeader(status)
+}
+
+// Write implies a 200 status if the handler did not write any
+func (rk *responseKeeper) Write(b []byte) (int, error) {
+	if rk.status == 0 {
+		rk.WriteHeader(http.StatusOK)
+	}
+	if rk.body.Len()+len(b) > maxIdempotentSize {
+		rk.overflow = true
+	} else if !rk.overflow {
+		rk.body.Write(b)
+	}
+	return rk.ResponseWriter.Write(b)
+}
@@ -0,0 +1,82 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/iocat/donit/errors"
+	"github.com/iocat/donit/handler/internal/utils"
+	"github.com/iocat/donit/internal/achieving"
+	"github.com/iocat/donit/internal/achieving/importer"
+)
+
+// ImportURL is the URL importing the tasks of a file into a goal
+var ImportURL = Achievable.BaseURL() + "/import"
+
+// maxImportSize is the maximum size of an imported file
+const maxImportSize = 4 << 20
+
+// ImportAchievables imports the tasks and habits of an iCalendar or CSV file
+// into the goal
+var ImportAchievables = decorateAchievableHandler(false, write, importAchievables)
+
+// importFormat returns the format of the imported file: the format query
+// parameter or else the body's content type
+func importFormat(r *http.Request) (string, error) {
+	if f := r.URL.Query().Get("format"); len(f) != 0 {
+		return f, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return "", errors.NewBadData("the format of the file is unknown, set the format query parameter")
+	}
+	switch mediaType {
+	case "text/calendar":
+		return importer.ICS, nil
+	case "text/csv":
+		return importer.CSV, nil
+	default:
+		return "", errors.NewBadData("unsupported content type " + mediaType)
+	}
+}
+
+func importAchievables(goal achieving.Goal, _ string, w http.ResponseWriter, r *http.Request) {
+	format, err := importFormat(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	var dryRun bool
+	if s := r.URL.Query().Get("dryRun"); len(s) != 0 {
+		if dryRun, err = strconv.ParseBool(s); err != nil {
+			utils.HandleError(errors.ErrBadData, w, r)
+			return
+		}
+	}
+	rows, err := importer.Parse(http.MaxBytesReader(w, r.Body, maxImportSize), format, goal.Location())
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	report, err := importer.Import(goal, format, rows, dryRun)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(report, w, http.StatusOK)
+}
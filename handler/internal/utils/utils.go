@@ -18,11 +18,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/iocat/donit/errors"
+	"github.com/iocat/donit/internal/auth"
+	"github.com/iocat/donit/internal/logger"
+	"github.com/iocat/donit/internal/requestid"
 )
 
 // WriteJSONtoHTTP writes the object to the HTTP response with the provided http code
@@ -30,6 +35,11 @@ import (
 // TODO: log JSON error
 func WriteJSONtoHTTP(obj interface{}, w http.ResponseWriter, c int) {
 	const jsonContentType = "application/json; charset=utf-8"
+	writeJSONtoHTTP(obj, w, c, jsonContentType)
+}
+
+// writeJSONtoHTTP writes the object to the HTTP response as the content type
+func writeJSONtoHTTP(obj interface{}, w http.ResponseWriter, c int, contentType string) {
 	// writeJSON writes the data to the output buffer
 	var writeJSON = func(obj interface{}, w io.Writer) error {
 		enc := json.NewEncoder(w)
@@ -39,7 +49,7 @@ func WriteJSONtoHTTP(obj interface{}, w http.ResponseWriter, c int) {
 		}
 		return nil
 	}
-	w.Header().Set("Content-Type", jsonContentType)
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(c)
 	if obj == nil {
 		return
@@ -55,18 +65,66 @@ func WriteJSONtoHTTPWithLocation(loc string, obj interface{}, w http.ResponseWri
 	WriteJSONtoHTTP(obj, w, c)
 }
 
-// HandleError is an utility function to handle the error
-func HandleError(err error, w http.ResponseWriter) {
+// HandleError is an utility function to handle the error, the error is
+// logged with the request's logger and tagged with the request ID
+func HandleError(err error, w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
 	err = errors.ParseDocumentError(err)
 	// handle local package's error
 	if err, ok := err.(errors.Error); ok {
-		// NOTE: Temporarily write to stdout
-		fmt.Println(err)
-		WriteJSONtoHTTP(err, w, err.Code.HTTPStatus())
+		status := err.Code.HTTPStatus()
+		if status >= http.StatusInternalServerError {
+			log.Error("request failed", "status", status, "err", err)
+		} else {
+			log.Info("request rejected", "status", status, "err", err)
+		}
+		err.RequestID = requestid.FromContext(r.Context())
+		switch status {
+		case http.StatusUnauthorized:
+			w.Header().Set("WWW-Authenticate", auth.Challenge)
+		case http.StatusTooManyRequests:
+			seconds := int(math.Ceil(err.RetryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		}
+		writeError(err, w, r, status)
 		return
 	}
-	fmt.Println(err)
-	WriteJSONtoHTTP(nil, w, http.StatusInternalServerError)
+	log.Error("request failed", "status", http.StatusInternalServerError, "err", err)
+	internal := errors.ErrInternal
+	internal.RequestID = requestid.FromContext(r.Context())
+	writeError(internal, w, r, http.StatusInternalServerError)
+}
+
+// writeError writes the error as a RFC 7807 problem to the clients accepting
+// it and in the legacy shape to the others
+func writeError(err errors.Error, w http.ResponseWriter, r *http.Request, c int) {
+	if !AcceptsProblem(r) {
+		WriteJSONtoHTTP(err, w, c)
+		return
+	}
+	writeJSONtoHTTP(err.Problem(r.URL.RequestURI()), w, c, errors.ProblemContentType)
+}
+
+// AcceptsProblem returns whether the client lists application/problem+json
+// in its Accept header
+func AcceptsProblem(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		params := strings.Split(accepted, ";")
+		if strings.TrimSpace(params[0]) != errors.ProblemContentType {
+			continue
+		}
+		for _, p := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+			if len(kv) != 2 || kv[0] != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(kv[1], 64); err == nil && q == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
 }
 
 // DecodeJSON reads the Reader and reflects the value into
@@ -78,7 +136,8 @@ func DecodeJSON(r io.Reader, obj interface{}) error {
 	return nil
 }
 
-// GetLimitAndOffset gets the limit and the offset form values
+// GetLimitAndOffset gets the limit and the offset form values, in that
+// order
 func GetLimitAndOffset(r *http.Request) (int, int, error) {
 	var offs, lim int
 	var err error
@@ -97,7 +156,7 @@ func GetLimitAndOffset(r *http.Request) (int, int, error) {
 	} else if lim, err = strconv.Atoi(strl); err != nil {
 		return -1, -1, errors.ErrBadData
 	}
-	return offs, lim, nil
+	return lim, offs, nil
 }
 
 // MuxGetParams gets the request's parameter from the HTTP request's URL
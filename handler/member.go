@@ -0,0 +1,130 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"net/http"
+
+	"github.com/iocat/donit/handler/internal/utils"
+	"github.com/iocat/donit/internal/achieving"
+	json "github.com/iocat/donit/internal/achieving/jsoninterpreter"
+	"github.com/iocat/donit/internal/achieving/validator"
+)
+
+var (
+	// MembersURL is the URL of the members of a goal
+	MembersURL = Goal.URL() + "/members"
+	// MemberURL is the URL of a member of a goal
+	MemberURL = MembersURL + "/{member}"
+	// MembershipsURL is the URL of the goals a user is a member of or
+	// invited to
+	MembershipsURL = User.URL() + "/memberships"
+	// MembershipURL is the URL of the membership of a user in a goal
+	MembershipURL = MembershipsURL + "/{goal}"
+)
+
+// memberInterpreter decodes the members
+var memberInterpreter = json.NewMember()
+
+// AllMembers lists the members of a goal and the users invited to it
+var AllMembers = decorateGoalItemHandler(false, "", read, allMembers)
+
+// SetMember invites a user to a goal or changes the role of a member
+var SetMember = decorateGoalItemHandler(false, "member", ownerOnly, setMember)
+
+// RemoveMember removes a member or an invite, the members can leave
+var RemoveMember = decorateGoalItemHandler(false, "member", authenticated, removeMember)
+
+// Memberships lists the goals the user is a member of or invited to
+var Memberships = decorateUserHandler(true, ownerOnly, allMemberships)
+
+// AcceptMembership accepts the user's invite to a goal
+var AcceptMembership = decorateUserHandler(true, write, acceptMembership)
+
+// LeaveMembership declines the user's invite to a goal or leaves it
+var LeaveMembership = decorateUserHandler(true, write, leaveMembership)
+
+func allMembers(goal achieving.Goal, _, _ string, w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSONtoHTTP(goal.RetrieveMembers(), w, http.StatusOK)
+}
+
+func setMember(goal achieving.Goal, _, username string, w http.ResponseWriter, r *http.Request) {
+	m, err := validator.Validate(r.Body, memberInterpreter)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	if err = goal.SetMember(caller(r), m.(achieving.Member), username); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func removeMember(goal achieving.Goal, _, username string, w http.ResponseWriter, r *http.Request) {
+	if err := goal.RemoveMember(caller(r), username); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func membershipGoal(r *http.Request) (string, error) {
+	ids, err := utils.MuxGetParams(r, "goal")
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+func allMemberships(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	l, o, err := utils.GetLimitAndOffset(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	ms, err := store.RetrieveMemberships(username, r.Form.Get("status"), l, o)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(ms, w, http.StatusOK)
+}
+
+func acceptMembership(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	goal, err := membershipGoal(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	if err = store.AcceptMembership(username, goal); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func leaveMembership(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	goal, err := membershipGoal(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	if err = store.LeaveMembership(username, goal); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
@@ -0,0 +1,41 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/instrument"
+	"github.com/iocat/donit/internal/metrics"
+)
+
+var (
+	storeDuration = metrics.DefaultRegistry.NewHistogramVec(
+		"donit_store_operation_duration_seconds",
+		"Time spent in achieving store operations.",
+		metrics.DefaultBuckets, "operation")
+	storeErrors = metrics.DefaultRegistry.NewCounterVec(
+		"donit_store_operation_errors_total",
+		"Number of achieving store operations that returned an error.",
+		"operation")
+)
+
+// storeMetrics records the timing and the failures of every store operation
+var storeMetrics = instrument.ObserverFunc(func(op string, took time.Duration, err error) {
+	storeDuration.Observe(took.Seconds(), op)
+	if err != nil {
+		storeErrors.Inc(op)
+	}
+})
@@ -0,0 +1,222 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/iocat/donit/errors"
+	"github.com/iocat/donit/handler/internal/utils"
+	"github.com/iocat/donit/internal/achieving"
+	"github.com/iocat/donit/internal/notify"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	// NotificationsURL is the URL of a user's notification inbox
+	NotificationsURL = User.URL() + "/notifications"
+	// NotificationURL is the URL of a notification
+	NotificationURL = NotificationsURL + "/{notification}"
+	// ReadNotificationsURL marks all the notifications read
+	ReadNotificationsURL = NotificationsURL + "/read"
+	// NotificationStreamURL streams the new notifications as server-sent
+	// events
+	NotificationStreamURL = NotificationsURL + "/stream"
+)
+
+// streamWindow is how long a notification stream stays open. It ends before
+// the server's write timeout, the EventSource clients reconnect after
+// streamRetry with the Last-Event-ID header and get what they missed
+const (
+	streamWindow = 8 * time.Second
+	streamRetry  = time.Second
+)
+
+// inbox and hub are the in-app notification inbox and the hub pushing its
+// new notifications
+var (
+	inbox *notify.Inbox
+	hub   *notify.Hub
+)
+
+// SetupNotifications sets up the notification inbox of the handlers
+func SetupNotifications(in *notify.Inbox, h *notify.Hub) {
+	inbox, hub = in, h
+}
+
+// Notifications lists the notifications of the user
+var Notifications = decorateUserHandler(true, ownerOnly, allNotifications)
+
+// ReadNotifications marks all the notifications of the user read
+var ReadNotifications = decorateUserHandler(true, ownerOnly, readAllNotifications)
+
+// UpdateNotification marks a notification read or unread
+var UpdateNotification = decorateUserHandler(true, ownerOnly, updateNotification)
+
+// DeleteNotification removes a notification from the inbox
+var DeleteNotification = decorateUserHandler(true, ownerOnly, deleteNotification)
+
+// NotificationStream pushes the new notifications of the user
+var NotificationStream = decorateUserHandler(true, ownerOnly, streamNotifications)
+
+// notificationID gets the notification id of the URL
+func notificationID(r *http.Request) (bson.ObjectId, error) {
+	ids, err := utils.MuxGetParams(r, "notification")
+	if err != nil {
+		return "", err
+	}
+	if !bson.IsObjectIdHex(ids[0]) {
+		return "", errors.NewBadData(fmt.Sprintf("%s is not a valid resource id", ids[0]))
+	}
+	return bson.ObjectIdHex(ids[0]), nil
+}
+
+func allNotifications(_ achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	l, o, err := utils.GetLimitAndOffset(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	var unread bool
+	if s := r.Form.Get("unread"); len(s) != 0 {
+		if unread, err = strconv.ParseBool(s); err != nil {
+			utils.HandleError(errors.NewBadData("unread must be a boolean"), w, r)
+			return
+		}
+	}
+	items, err := inbox.List(username, unread, l, o)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(items, w, http.StatusOK)
+}
+
+func readAllNotifications(_ achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	if _, err := inbox.MarkAllRead(username); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+// notificationState is the modifiable state of a notification
+type notificationState struct {
+	Read *bool `json:"read"`
+}
+
+func updateNotification(_ achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	id, err := notificationID(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	var state notificationState
+	if err = utils.DecodeJSON(r.Body, &state); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	if state.Read == nil {
+		utils.HandleError(errors.NewBadData("read is required"), w, r)
+		return
+	}
+	if err = inbox.MarkRead(username, id, *state.Read); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func deleteNotification(_ achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	id, err := notificationID(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	if err = inbox.Remove(username, id); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+// streamNotifications streams the new notifications of the user as
+// server-sent events, starting with the ones following Last-Event-ID
+func streamNotifications(_ achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.HandleError(errors.NewInternal("streaming is not supported"), w, r)
+		return
+	}
+	// Subscribe before catching up so that nothing falls in between
+	items, cancel := hub.Subscribe(username)
+	defer cancel()
+	var last bson.ObjectId
+	var missed []notify.Item
+	if id := r.Header.Get("Last-Event-ID"); bson.IsObjectIdHex(id) {
+		var err error
+		last = bson.ObjectIdHex(id)
+		if missed, err = inbox.Since(username, last); err != nil {
+			utils.HandleError(err, w, r)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry/time.Millisecond)
+	send := func(item notify.Item) error {
+		// the items pushed by the hub may have been caught up already
+		if len(last) != 0 && item.ID.Hex() <= last.Hex() {
+			return nil
+		}
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", item.ID.Hex(), item.Kind, data); err != nil {
+			return err
+		}
+		last = item.ID
+		flusher.Flush()
+		return nil
+	}
+	for _, item := range missed {
+		if err := send(item); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	window := time.NewTimer(streamWindow)
+	defer window.Stop()
+	for {
+		select {
+		case item := <-items:
+			if err := send(item); err != nil {
+				return
+			}
+		case <-window.C:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"net/http"
+
+	"github.com/iocat/donit/errors"
+	"github.com/iocat/donit/handler/internal/utils"
+)
+
+// ProblemsURL is the URL of the error catalog, the problem type URIs are
+// resolved against it
+const ProblemsURL = "/problems"
+
+var (
+	// Problems lists every documented error code
+	Problems = http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			utils.WriteJSONtoHTTP(errors.Catalog(), w, http.StatusOK)
+		},
+	)
+
+	// ProblemType documents a single error code
+	ProblemType = http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			ids, err := utils.MuxGetParams(r, "problem")
+			if err != nil {
+				utils.HandleError(err, w, r)
+				return
+			}
+			t, ok := errors.LookupProblemType(ids[0])
+			if !ok {
+				utils.HandleError(errors.ErrNotFound, w, r)
+				return
+			}
+			utils.WriteJSONtoHTTP(t, w, http.StatusOK)
+		},
+	)
+)
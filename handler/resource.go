@@ -16,15 +16,21 @@ package handler
 
 import (
 	"context"
-	"fmt"
-	"os"
+	"net/http"
 	"path/filepath"
 	"time"
 
 	"gopkg.in/mgo.v2"
 
 	"github.com/iocat/donit/internal/achieving"
+	"github.com/iocat/donit/internal/achieving/archive"
+	"github.com/iocat/donit/internal/achieving/calendar"
+	"github.com/iocat/donit/internal/achieving/discovery"
+	"github.com/iocat/donit/internal/achieving/instrument"
 	json "github.com/iocat/donit/internal/achieving/jsoninterpreter"
+	"github.com/iocat/donit/internal/events"
+	"github.com/iocat/donit/internal/logger"
+	"github.com/iocat/donit/internal/notify"
 )
 
 // Endpoint serializes the HTTP endpoint
@@ -84,6 +90,30 @@ func getResourceFromContext(ctx context.Context) interface{} {
 
 var store achieving.UserStore
 
+// StoreActing returns the store set up on the database by Setup, which
+// records its changes as made by the user. It lets the commands change the
+// data the way the handlers do
+func StoreActing(username string) achieving.UserStore {
+	return store.Acting(username)
+}
+
+// requestStore returns the store observed on behalf of the request: every
+// operation is measured and logged along with the request ID, and the
+// changes are logged as made by the caller
+func requestStore(r *http.Request) achieving.UserStore {
+	log := logger.FromContext(r.Context())
+	return instrument.NewUserStore(store.Acting(caller(r)), instrument.Observers{
+		storeMetrics,
+		instrument.ObserverFunc(func(op string, took time.Duration, err error) {
+			if err != nil {
+				log.Debug("store operation failed", "op", op, "duration", took, "err", err)
+				return
+			}
+			log.Debug("store operation", "op", op, "duration", took)
+		}),
+	})
+}
+
 var collections = []*mgo.Collection{
 	User:       nil,
 	Goal:       nil,
@@ -96,6 +126,11 @@ var interpreters = []json.Interpreter{
 	Achievable: nil,
 }
 
+// Collection gets the collection storing the endpoint's resources
+func (e Endpoint) Collection() *mgo.Collection {
+	return e.collection()
+}
+
 func (e Endpoint) collection() *mgo.Collection {
 	return collections[e]
 }
@@ -104,15 +139,27 @@ func (e Endpoint) interpreter() json.Interpreter {
 	return interpreters[e]
 }
 
-var userInterpreter, goalInterpreter, achievableInterpreter json.Interpreter
+// deliveries is the collection of the notification deliveries
+var deliveries *mgo.Collection
 
-func init() {
-	sess, err := mgo.DialWithTimeout("localhost:27017", 5*time.Second)
-	if err != nil {
-		fmt.Printf("set up database: %s", err)
-		os.Exit(1)
-	}
-	db := sess.DB("donit")
+// storeCollections are the collections of the store, the ones of the
+// endpoints among them
+var storeCollections json.Collections
+
+// Follows gets the collection of the follow relationships
+func Follows() *mgo.Collection {
+	return storeCollections.Follows
+}
+
+// Workspaces gets the collection of the workspaces
+func Workspaces() *mgo.Collection {
+	return storeCollections.Workspaces
+}
+
+// Setup sets up the resources of the handlers on the database, it must be
+// called before serving any request. The store publishes the events of its
+// mutations to pub
+func Setup(db *mgo.Database, pub events.Publisher) error {
 	collections = []*mgo.Collection{
 		User:       db.C("users"),
 		Goal:       db.C("goals"),
@@ -123,5 +170,17 @@ func init() {
 		Goal:       json.NewGoal(Achievable.collection()),
 		Achievable: json.NewAchievable(),
 	}
-	store = json.NewStore(User.collection(), Goal.collection(), Achievable.collection())
+	storeCollections = json.NewCollections(db, User.collection(), Goal.collection(), Achievable.collection())
+	if err := json.EnsureIndexes(storeCollections); err != nil {
+		return err
+	}
+	store = json.NewStore(storeCollections, pub)
+	deliveries = db.C(notify.DeliveryCollection)
+	feed = calendar.NewFeed(User.collection(), Goal.collection(), Achievable.collection())
+	feedTokens = calendar.NewTokens(db.C(calendar.TokenCollection))
+	explorer = discovery.New(User.collection(), Goal.collection(), Achievable.collection(),
+		storeCollections.Follows, storeCollections.Blocks)
+	accounts = archive.New(User.collection(), Goal.collection(), Achievable.collection(),
+		storeCollections.Follows, storeCollections.Tags, deliveries)
+	return nil
 }
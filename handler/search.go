@@ -0,0 +1,93 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"net/http"
+
+	"github.com/iocat/donit/errors"
+	"github.com/iocat/donit/handler/internal/utils"
+	"github.com/iocat/donit/internal/achieving"
+	"github.com/iocat/donit/internal/search"
+)
+
+// SearchURL is the URL searching a user's goals and achievables
+var SearchURL = User.URL() + "/search"
+
+// searchIndex is the index of the goals and the achievables
+var searchIndex search.Index
+
+// SetupSearch sets up the search index of the handlers
+func SetupSearch(index search.Index) {
+	searchIndex = index
+}
+
+// Search searches the goals and the achievables of the user the caller can
+// see
+var Search = decorateUserHandler(true, read, searchUser)
+
+func searchUser(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	user, err := store.RetrieveUser(username)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	l, o, err := utils.GetLimitAndOffset(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	q := search.Query{
+		Owner:  username,
+		Text:   r.Form.Get("q"),
+		Status: r.Form.Get("status"),
+		Limit:  l,
+		Offset: o,
+	}
+	if len(q.Text) == 0 {
+		utils.HandleError(errors.NewBadData("the searched text is missing"), w, r)
+		return
+	}
+	switch t := r.Form.Get("type"); t {
+	case "":
+	case search.KindGoal:
+		q.Kind = search.KindGoal
+	case search.TypeHabit, search.TypeTask:
+		q.Type = t
+	default:
+		utils.HandleError(errors.NewBadData("the type is one of goal, habit and task"), w, r)
+		return
+	}
+	// the others see the documents of the goals RetrieveGoalsVisibleTo
+	// lists to them
+	if viewer := caller(r); viewer != username {
+		v, err := user.VisibilityTo(viewer)
+		if err != nil {
+			utils.HandleError(err, w, r)
+			return
+		}
+		q.Viewer = &search.Viewer{
+			Shared:     v.Shared,
+			Following:  v.Following,
+			Workspaces: v.Workspaces,
+		}
+	}
+	res, err := searchIndex.Search(q)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(res, w, http.StatusOK)
+}
@@ -0,0 +1,152 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"net/http"
+	"path"
+
+	"github.com/iocat/donit/errors"
+	"github.com/iocat/donit/handler/internal/utils"
+	"github.com/iocat/donit/internal/achieving"
+	json "github.com/iocat/donit/internal/achieving/jsoninterpreter"
+	"github.com/iocat/donit/internal/achieving/validator"
+)
+
+var (
+	// TagsURL is the URL of a user's catalogue of tags
+	TagsURL = User.URL() + "/tags"
+	// TagURL is the URL of a tag of the catalogue
+	TagURL = TagsURL + "/{tag}"
+	// TagMergeURL is the URL merging a tag into another one
+	TagMergeURL = TagURL + "/merge"
+)
+
+// tagInterpreter decodes the tags
+var tagInterpreter = json.NewTag()
+
+// AllTags lists the user's catalogue of tags
+var AllTags = decorateUserHandler(true, ownerOnly, allTags)
+
+// CreateTag adds a tag to the catalogue
+var CreateTag = decorateUserHandler(true, write, createTag)
+
+// UpdateTag renames or recolors a tag
+var UpdateTag = decorateUserHandler(true, write, updateTag)
+
+// DeleteTag deletes a tag
+var DeleteTag = decorateUserHandler(true, write, deleteTag)
+
+// MergeTag merges a tag into another one
+var MergeTag = decorateUserHandler(true, write, mergeTag)
+
+func tagID(r *http.Request) (string, error) {
+	ids, err := utils.MuxGetParams(r, "tag")
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// listTag reads the tag query parameter filtering the goal and achievable
+// lists, empty if the lists are not filtered
+func listTag(r *http.Request) (string, error) {
+	if err := r.ParseForm(); err != nil {
+		return "", errors.ErrBadData
+	}
+	return r.Form.Get("tag"), nil
+}
+
+func allTags(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	l, o, err := utils.GetLimitAndOffset(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	tags, err := store.RetrieveTags(username, l, o)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(tags, w, http.StatusOK)
+}
+
+func createTag(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	tag, err := validator.Validate(r.Body, tagInterpreter)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	id, err := store.CreateTag(username, tag.(achieving.Tag))
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTPWithLocation(path.Join(r.URL.EscapedPath(), id),
+		nil, w, http.StatusCreated)
+}
+
+func updateTag(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	id, err := tagID(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	tag, err := validator.Validate(r.Body, tagInterpreter)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	if err = store.UpdateTag(username, tag.(achieving.Tag), id); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func deleteTag(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	id, err := tagID(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	if err = store.DeleteTag(username, id); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func mergeTag(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	id, err := tagID(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	if err = r.ParseForm(); err != nil {
+		utils.HandleError(errors.ErrBadData, w, r)
+		return
+	}
+	into := r.Form.Get("into")
+	if len(into) == 0 {
+		utils.HandleError(errors.NewBadData("the tag to merge into is missing"), w, r)
+		return
+	}
+	if err = store.MergeTag(username, id, into); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
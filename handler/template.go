@@ -0,0 +1,209 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/iocat/donit/errors"
+	"github.com/iocat/donit/handler/internal/utils"
+	"github.com/iocat/donit/internal/achieving"
+	json "github.com/iocat/donit/internal/achieving/jsoninterpreter"
+	"github.com/iocat/donit/internal/achieving/validator"
+)
+
+var (
+	// TemplatesURL is the URL of the catalogue of the public templates
+	TemplatesURL = "/templates"
+	// TemplateURL is the URL of a template
+	TemplateURL = TemplatesURL + "/{template}"
+	// TemplateGoalsURL is the URL of the goals created from a template
+	TemplateGoalsURL = TemplateURL + "/goals"
+	// UserTemplatesURL is the URL of the templates of a user
+	UserTemplatesURL = User.URL() + "/templates"
+	// GoalTemplateURL is the URL a goal is saved as a template at
+	GoalTemplateURL = Goal.URL() + "/template"
+	// CloneURL is the URL a goal is cloned at
+	CloneURL = Goal.URL() + "/clone"
+)
+
+// templateInterpreter decodes the templates
+var templateInterpreter = json.NewTemplate()
+
+// SaveTemplate saves a goal of the user as a template
+var SaveTemplate = decorateUserHandler(true, ownerOnly, saveTemplate)
+
+// UserTemplates lists the templates of a user, the others see the public
+// ones
+var UserTemplates = decorateUserHandler(true, read, userTemplates)
+
+// PublicTemplates lists the public templates
+var PublicTemplates = http.HandlerFunc(publicTemplates)
+
+// ReadTemplate reads a template of the caller or a public one
+var ReadTemplate = http.HandlerFunc(readTemplate)
+
+// UpdateTemplate changes a template of the caller
+var UpdateTemplate = decorateCallerHandler(updateTemplate)
+
+// DeleteTemplate deletes a template of the caller
+var DeleteTemplate = decorateCallerHandler(deleteTemplate)
+
+// InstantiateTemplate creates a goal of the caller from a template
+var InstantiateTemplate = decorateCallerHandler(instantiateTemplate)
+
+// CloneGoal copies a goal the caller can see to the caller's goals
+var CloneGoal = decorateUserHandler(true, authenticated, cloneGoal)
+
+func templateID(r *http.Request) (string, error) {
+	ids, err := utils.MuxGetParams(r, "template")
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// goalLocation returns the URL of the user's goal, the usernames are
+// alphanumeric
+func goalLocation(username, id string) string {
+	return path.Join(strings.Replace(Goal.BaseURL(), "{user}", username, 1), id)
+}
+
+func saveTemplate(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	goal, err := membershipGoal(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	t, err := validator.Validate(r.Body, templateInterpreter)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	id, err := store.SaveTemplate(username, t.(achieving.Template), goal)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTPWithLocation(path.Join(TemplatesURL, id), nil, w, http.StatusCreated)
+}
+
+func userTemplates(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	l, o, err := utils.GetLimitAndOffset(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	ts, err := store.RetrieveTemplates(username, caller(r), l, o)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(ts, w, http.StatusOK)
+}
+
+func publicTemplates(w http.ResponseWriter, r *http.Request) {
+	l, o, err := utils.GetLimitAndOffset(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	ts, err := requestStore(r).RetrievePublicTemplates(r.Form.Get("q"), l, o)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(ts, w, http.StatusOK)
+}
+
+func readTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := templateID(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	t, err := requestStore(r).RetrieveTemplate(caller(r), id)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(t, w, http.StatusOK)
+}
+
+func updateTemplate(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	id, err := templateID(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	t, err := validator.Validate(r.Body, templateInterpreter)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	if err = store.UpdateTemplate(username, t.(achieving.Template), id); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func deleteTemplate(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	id, err := templateID(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	if err = store.DeleteTemplate(username, id); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func instantiateTemplate(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	id, err := templateID(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	if err = r.ParseForm(); err != nil {
+		utils.HandleError(errors.ErrBadData, w, r)
+		return
+	}
+	gid, err := store.InstantiateTemplate(username, id, r.Form.Get("start"))
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTPWithLocation(goalLocation(username, gid), nil, w, http.StatusCreated)
+}
+
+func cloneGoal(store achieving.UserStore, owner string, w http.ResponseWriter, r *http.Request) {
+	goal, err := membershipGoal(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	username := caller(r)
+	id, err := store.CloneGoal(username, owner, goal)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTPWithLocation(goalLocation(username, id), nil, w, http.StatusCreated)
+}
@@ -22,7 +22,7 @@ func getPassword(r *http.Request) (string, error) {
 	return password, nil
 }
 
-func decorateUserHandler(getResourceKey bool, handler func(achieving.UserStore, string, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+func decorateUserHandler(getResourceKey bool, perm permission, handler func(achieving.UserStore, string, http.ResponseWriter, *http.Request)) http.HandlerFunc {
 	var keyGeneratorFunc func() []string
 	if getResourceKey {
 		keyGeneratorFunc = User.resourceKeyNames
@@ -33,23 +33,28 @@ func decorateUserHandler(getResourceKey bool, handler func(achieving.UserStore,
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ids, err := utils.MuxGetParams(r, keyGeneratorFunc()...)
 		if err != nil {
-			utils.HandleError(err, w)
+			utils.HandleError(err, w, r)
 			return
 		}
 		var username string
 		if getResourceKey {
 			username = ids[0]
+			if err = authorize(r, perm, username); err != nil {
+				utils.HandleError(err, w, r)
+				return
+			}
 		}
-		handler(store, username, w, r)
+		handler(requestStore(r), username, w, r)
 	})
 }
 
-var CreateUser = decorateUserHandler(false, createUser)
-var ReadUser = decorateUserHandler(true, readUser)
-var DeleteUser = decorateUserHandler(true, deleteUser)
-var UpdateUser = decorateUserHandler(true, updateUser)
-var Auth = decorateUserHandler(true, authUser)
-var PasswordChange = decorateUserHandler(true, changePassword)
+// NOTE: the handlers asking for the password check it themselves
+var CreateUser = decorateUserHandler(false, read, createUser)
+var ReadUser = decorateUserHandler(true, read, readUser)
+var DeleteUser = decorateUserHandler(true, read, deleteUser)
+var UpdateUser = decorateUserHandler(true, write, updateUser)
+var Auth = decorateUserHandler(true, read, authUser)
+var PasswordChange = decorateUserHandler(true, read, changePassword)
 
 func changePassword(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
 	getChangePassword := func(param string, r *http.Request) (string, error) {
@@ -65,18 +70,20 @@ func changePassword(store achieving.UserStore, username string, w http.ResponseW
 	// get two passwords
 	oldpass, err := getChangePassword("old", r)
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	newpass, err := getChangePassword("new", r)
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	// Change the password
-	err = store.ChangePassword(username, oldpass, newpass)
+	err = throttled(r, username, func() error {
+		return store.ChangePassword(username, oldpass, newpass)
+	})
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
@@ -85,33 +92,33 @@ func changePassword(store achieving.UserStore, username string, w http.ResponseW
 func authUser(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
 	password, err := getPassword(r)
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
-	ok, err := store.Authenticate(username, password)
+	err = authenticate(r, store, username, password)
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
-	utils.WriteJSONtoHTTP(ok, w, http.StatusOK)
+	utils.WriteJSONtoHTTP(true, w, http.StatusOK)
 }
 
 // createUser creates a new user
 func createUser(store achieving.UserStore, _ string, w http.ResponseWriter, r *http.Request) {
 	obj, err := validator.Validate(r.Body, User.interpreter())
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	usr := obj.(achieving.User)
 	password, err := getPassword(r)
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	username, err := store.CreateNewUser(usr, password)
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	utils.WriteJSONtoHTTPWithLocation(path.Join(r.URL.EscapedPath(), username), nil, w, http.StatusCreated)
@@ -119,9 +126,15 @@ func createUser(store achieving.UserStore, _ string, w http.ResponseWriter, r *h
 
 // readUser reads the user data
 func readUser(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	// the others only see the public profile, never the email or the
+	// settings
+	if caller(r) != username {
+		readProfile(store, username, w, r)
+		return
+	}
 	user, err := store.RetrieveUser(username)
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	_ = User.interpreter().Encode(w, user)
@@ -131,12 +144,14 @@ func readUser(store achieving.UserStore, username string, w http.ResponseWriter,
 func deleteUser(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
 	password, err := getPassword(r)
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
-	err = store.DeleteUser(username, password)
+	err = throttled(r, username, func() error {
+		return store.DeleteUser(username, password)
+	})
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
@@ -146,13 +161,13 @@ func deleteUser(store achieving.UserStore, username string, w http.ResponseWrite
 func updateUser(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
 	obj, err := validator.Validate(r.Body, User.interpreter())
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	usr := obj.(achieving.User)
 	err = store.UpdateUser(usr, username)
 	if err != nil {
-		utils.HandleError(err, w)
+		utils.HandleError(err, w, r)
 		return
 	}
 	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
@@ -0,0 +1,207 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"net/http"
+	"path"
+
+	"github.com/iocat/donit/errors"
+	"github.com/iocat/donit/handler/internal/utils"
+	"github.com/iocat/donit/internal/achieving"
+	json "github.com/iocat/donit/internal/achieving/jsoninterpreter"
+	"github.com/iocat/donit/internal/achieving/validator"
+)
+
+var (
+	// WorkspacesURL is the URL of the caller's workspaces
+	WorkspacesURL = "/workspaces"
+	// WorkspaceURL is the URL of a workspace
+	WorkspaceURL = WorkspacesURL + "/{workspace}"
+	// WorkspaceMemberURL is the URL of a member of a workspace
+	WorkspaceMemberURL = WorkspaceURL + "/members/{member}"
+	// WorkspaceGoalsURL is the URL of the goals of a workspace
+	WorkspaceGoalsURL = WorkspaceURL + "/goals"
+)
+
+var (
+	// workspaceInterpreter decodes the workspaces
+	workspaceInterpreter = json.NewWorkspace()
+	// workspaceMemberInterpreter decodes the members of the workspaces
+	workspaceMemberInterpreter = json.NewWorkspaceMember()
+)
+
+// decorateCallerHandler passes the authenticated caller to the handler
+func decorateCallerHandler(handler func(achieving.UserStore, string, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username := caller(r)
+		if len(username) == 0 {
+			utils.HandleError(errors.ErrAuthenticationRequired, w, r)
+			return
+		}
+		handler(requestStore(r), username, w, r)
+	})
+}
+
+// decorateWorkspaceHandler retrieves the workspace on behalf of the caller,
+// the workspaces the caller is not a member of are not found
+func decorateWorkspaceHandler(handler func(achieving.Workspace, string, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return decorateCallerHandler(func(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+		ids, err := utils.MuxGetParams(r, "workspace")
+		if err != nil {
+			utils.HandleError(err, w, r)
+			return
+		}
+		ws, err := store.RetrieveWorkspace(username, ids[0])
+		if err != nil {
+			utils.HandleError(err, w, r)
+			return
+		}
+		handler(ws, username, w, r)
+	})
+}
+
+// CreateWorkspace creates a workspace, the caller is its first admin
+var CreateWorkspace = decorateCallerHandler(createWorkspace)
+
+// AllWorkspaces lists the caller's workspaces
+var AllWorkspaces = decorateCallerHandler(allWorkspaces)
+
+// ReadWorkspace reads a workspace of the caller
+var ReadWorkspace = decorateWorkspaceHandler(readWorkspace)
+
+// UpdateWorkspace renames a workspace, the admins can
+var UpdateWorkspace = decorateWorkspaceHandler(updateWorkspace)
+
+// DeleteWorkspace deletes a workspace without goals, the admins can
+var DeleteWorkspace = decorateWorkspaceHandler(deleteWorkspace)
+
+// SetWorkspaceMember adds a user to a workspace or changes the role of a
+// member, the admins can
+var SetWorkspaceMember = decorateWorkspaceHandler(setWorkspaceMember)
+
+// RemoveWorkspaceMember removes a member from a workspace, the admins can
+// and the members can leave
+var RemoveWorkspaceMember = decorateWorkspaceHandler(removeWorkspaceMember)
+
+// WorkspaceGoals lists the goals of a workspace the caller can see
+var WorkspaceGoals = decorateWorkspaceHandler(workspaceGoals)
+
+func createWorkspace(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	ws, err := validator.Validate(r.Body, workspaceInterpreter)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	id, err := store.CreateWorkspace(username, ws.(achieving.Workspace))
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTPWithLocation(path.Join(r.URL.EscapedPath(), id),
+		nil, w, http.StatusCreated)
+}
+
+func allWorkspaces(store achieving.UserStore, username string, w http.ResponseWriter, r *http.Request) {
+	l, o, err := utils.GetLimitAndOffset(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	ws, err := store.RetrieveWorkspaces(username, l, o)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(ws, w, http.StatusOK)
+}
+
+func readWorkspace(ws achieving.Workspace, _ string, w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSONtoHTTP(ws, w, http.StatusOK)
+}
+
+func updateWorkspace(ws achieving.Workspace, _ string, w http.ResponseWriter, r *http.Request) {
+	update, err := validator.Validate(r.Body, workspaceInterpreter)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	if err = ws.Update(update.(achieving.Workspace)); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func deleteWorkspace(ws achieving.Workspace, _ string, w http.ResponseWriter, r *http.Request) {
+	if err := ws.Delete(); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func workspaceMember(r *http.Request) (string, error) {
+	ids, err := utils.MuxGetParams(r, "member")
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+func setWorkspaceMember(ws achieving.Workspace, _ string, w http.ResponseWriter, r *http.Request) {
+	username, err := workspaceMember(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	m, err := validator.Validate(r.Body, workspaceMemberInterpreter)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	if err = ws.SetMember(m.(achieving.WorkspaceMember), username); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func removeWorkspaceMember(ws achieving.Workspace, _ string, w http.ResponseWriter, r *http.Request) {
+	username, err := workspaceMember(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	if err = ws.RemoveMember(username); err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(nil, w, http.StatusNoContent)
+}
+
+func workspaceGoals(ws achieving.Workspace, _ string, w http.ResponseWriter, r *http.Request) {
+	l, o, err := utils.GetLimitAndOffset(r)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	gs, err := ws.RetrieveGoals(l, o)
+	if err != nil {
+		utils.HandleError(err, w, r)
+		return
+	}
+	utils.WriteJSONtoHTTP(gs, w, http.StatusOK)
+}
@@ -0,0 +1,439 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apispec
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iocat/donit/errors"
+	"github.com/iocat/donit/internal/achieving/archive"
+	"github.com/iocat/donit/internal/achieving/batch"
+	"github.com/iocat/donit/internal/achieving/calendar"
+	"github.com/iocat/donit/internal/achieving/discovery"
+	"github.com/iocat/donit/internal/achieving/importer"
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+	"github.com/iocat/donit/internal/achieving/internal/activity"
+	"github.com/iocat/donit/internal/achieving/internal/comment"
+	"github.com/iocat/donit/internal/achieving/internal/goal"
+	"github.com/iocat/donit/internal/achieving/internal/member"
+	"github.com/iocat/donit/internal/achieving/internal/tag"
+	"github.com/iocat/donit/internal/achieving/internal/template"
+	"github.com/iocat/donit/internal/achieving/internal/user"
+	"github.com/iocat/donit/internal/achieving/internal/workspace"
+	"github.com/iocat/donit/internal/idempotency"
+	"github.com/iocat/donit/internal/notify"
+	"github.com/iocat/donit/internal/search"
+)
+
+// Model names a JSON body exchanged by the API
+type Model string
+
+const (
+	// NoModel is an empty body
+	NoModel Model = ""
+	// UserModel is a user's data
+	UserModel Model = "User"
+	// GoalModel is a goal
+	GoalModel Model = "Goal"
+	// AchievableModel is an achievable task or habit
+	AchievableModel Model = "Achievable"
+	// BooleanModel is a JSON boolean
+	BooleanModel Model = "Boolean"
+	// ProblemTypeModel documents an error code
+	ProblemTypeModel Model = "ProblemType"
+	// DocumentModel is an OpenAPI document
+	DocumentModel Model = "OpenAPI"
+	// DeliveryModel is a notification delivery and its attempts
+	DeliveryModel Model = "Delivery"
+	// NotificationModel is a notification of the in-app inbox
+	NotificationModel Model = "Notification"
+	// NotificationStateModel is the modifiable state of a notification
+	NotificationStateModel Model = "NotificationState"
+	// UsernameModel is a username
+	UsernameModel Model = "Username"
+	// EventStreamModel is a stream of server-sent events
+	EventStreamModel Model = "EventStream"
+	// CalendarModel is an iCalendar document
+	CalendarModel Model = "Calendar"
+	// CalendarAccessModel is a token of a calendar feed
+	CalendarAccessModel Model = "CalendarAccess"
+	// ImportFileModel is an iCalendar or CSV file of tasks
+	ImportFileModel Model = "ImportFile"
+	// ImportReportModel is the outcome of an import
+	ImportReportModel Model = "ImportReport"
+	// ArchiveModel is the archive of an account
+	ArchiveModel Model = "Archive"
+	// ArchiveSummaryModel tells what the import of an archive recreated
+	ArchiveSummaryModel Model = "ArchiveSummary"
+	// TagModel is a tag of a user's catalogue
+	TagModel Model = "Tag"
+	// SearchResultsModel is a page of the hits of a search
+	SearchResultsModel Model = "SearchResults"
+	// ProfileModel is the public profile of a user
+	ProfileModel Model = "Profile"
+	// ExploredGoalModel is a public goal and its owner
+	ExploredGoalModel Model = "ExploredGoal"
+	// CommentModel is a comment and, when listed, its replies
+	CommentModel Model = "Comment"
+	// ReactionModel is the number of the reactions with an emoji
+	ReactionModel Model = "Reaction"
+	// MemberModel is a member of a shared goal or an invite to it
+	MemberModel Model = "Member"
+	// WorkspaceModel is a workspace and its members
+	WorkspaceModel Model = "Workspace"
+	// WorkspaceMemberModel is a member of a workspace
+	WorkspaceMemberModel Model = "WorkspaceMember"
+	// TemplateModel is a goal template and its achievables
+	TemplateModel Model = "Template"
+	// ActivityModel is a change of the activity log
+	ActivityModel Model = "Activity"
+	// BatchModel is a batch of operations on goals and achievables
+	BatchModel Model = "Batch"
+	// BatchReportModel is the outcome of a batch
+	BatchReportModel Model = "BatchReport"
+)
+
+// contentTypes are the media types of the models which are not JSON
+var contentTypes = map[Model][]string{
+	EventStreamModel: {"text/event-stream"},
+	CalendarModel:    {"text/calendar"},
+	ImportFileModel:  {"text/calendar", "text/csv"},
+	ArchiveModel:     {archive.ContentType},
+}
+
+// content returns the content of a body of the model
+func content(m Model, list bool, problems *[]string, where string) map[string]MediaType {
+	types, ok := contentTypes[m]
+	if !ok {
+		types = []string{"application/json"}
+	}
+	c := make(map[string]MediaType, len(types))
+	for _, t := range types {
+		c[t] = MediaType{Schema: modelSchema(m, list, problems, where)}
+	}
+	return c
+}
+
+// models maps the object models to their Go type
+var models = map[Model]reflect.Type{
+	UserModel:            reflect.TypeOf(user.User{}),
+	GoalModel:            reflect.TypeOf(goal.Goal{}),
+	AchievableModel:      reflect.TypeOf(achievable.Achievable{}),
+	ProblemTypeModel:     reflect.TypeOf(errors.ProblemType{}),
+	DeliveryModel:        reflect.TypeOf(notify.Delivery{}),
+	NotificationModel:    reflect.TypeOf(notify.Item{}),
+	CalendarAccessModel:  reflect.TypeOf(calendar.Access{}),
+	ImportReportModel:    reflect.TypeOf(importer.Report{}),
+	ArchiveSummaryModel:  reflect.TypeOf(archive.Summary{}),
+	TagModel:             reflect.TypeOf(tag.Tag{}),
+	SearchResultsModel:   reflect.TypeOf(search.Results{}),
+	ProfileModel:         reflect.TypeOf(discovery.Profile{}),
+	ExploredGoalModel:    reflect.TypeOf(discovery.Goal{}),
+	CommentModel:         reflect.TypeOf(comment.Thread{}),
+	ReactionModel:        reflect.TypeOf(comment.Count{}),
+	MemberModel:          reflect.TypeOf(member.Member{}),
+	WorkspaceModel:       reflect.TypeOf(workspace.Workspace{}),
+	WorkspaceMemberModel: reflect.TypeOf(workspace.Member{}),
+	TemplateModel:        reflect.TypeOf(template.Template{}),
+	ActivityModel:        reflect.TypeOf(activity.Entry{}),
+	BatchModel:           reflect.TypeOf(batch.Request{}),
+	BatchReportModel:     reflect.TypeOf(batch.Report{}),
+	NotificationStateModel: reflect.TypeOf(struct {
+		Read bool `json:"read" valid:"required"`
+	}{}),
+	"Error":   reflect.TypeOf(errors.Error{}),
+	"Problem": reflect.TypeOf(errors.Problem{}),
+}
+
+// pathParams documents the path parameters, every parameter of the routes
+// must be documented here
+var pathParams = map[string]Parameter{
+	"user": {
+		Description: "The username",
+		Schema:      &Schema{Type: "string", Pattern: "^[a-zA-Z0-9]+$", MaxLength: intPtr(30)},
+	},
+	"goal": {
+		Description: "The goal ID",
+		Schema:      &Schema{Type: "string", Pattern: ObjectIDPattern},
+	},
+	"achievable": {
+		Description: "The achievable task ID",
+		Schema:      &Schema{Type: "string", Pattern: ObjectIDPattern},
+	},
+	"notification": {
+		Description: "The notification ID",
+		Schema:      &Schema{Type: "string", Pattern: ObjectIDPattern},
+	},
+	"tag": {
+		Description: "The tag ID",
+		Schema:      &Schema{Type: "string", Pattern: ObjectIDPattern},
+	},
+	"followee": {
+		Description: "The username of the followed user",
+		Schema:      &Schema{Type: "string", Pattern: "^[a-zA-Z0-9]+$", MaxLength: intPtr(30)},
+	},
+	"comment": {
+		Description: "The comment ID",
+		Schema:      &Schema{Type: "string", Pattern: ObjectIDPattern},
+	},
+	"emoji": {
+		Description: "The emoji of the reaction, URL-encoded",
+		Schema:      &Schema{Type: "string", MaxLength: intPtr(32)},
+	},
+	"member": {
+		Description: "The username of the member",
+		Schema:      &Schema{Type: "string", Pattern: "^[a-zA-Z0-9]+$", MaxLength: intPtr(30)},
+	},
+	"workspace": {
+		Description: "The workspace ID",
+		Schema:      &Schema{Type: "string", Pattern: ObjectIDPattern},
+	},
+	"template": {
+		Description: "The template ID",
+		Schema:      &Schema{Type: "string", Pattern: ObjectIDPattern},
+	},
+	"activity": {
+		Description: "The activity ID",
+		Schema:      &Schema{Type: "string", Pattern: ObjectIDPattern},
+	},
+	"blocked": {
+		Description: "The username of the blocked user",
+		Schema:      &Schema{Type: "string", Pattern: "^[a-zA-Z0-9]+$", MaxLength: intPtr(30)},
+	},
+	"problem": {
+		Description: "The error code",
+		Schema:      &Schema{Type: "string"},
+	},
+}
+
+// Param is a query parameter
+type Param struct {
+	Name        string
+	Description string
+	// Type is the JSON schema type of the parameter, string by default
+	Type     string
+	Required bool
+}
+
+var (
+	// Limit is the maximum number of elements to list
+	Limit = Param{Name: "limit", Description: "The maximum number of elements returned", Type: "integer"}
+	// Offset is the number of elements to skip
+	Offset = Param{Name: "offset", Description: "The number of elements skipped", Type: "integer"}
+	// Password is the user's password
+	Password = Param{Name: "password", Description: "The user's password", Required: true}
+	// TagFilter is the filter of the lists by tag
+	TagFilter = Param{Name: "tag", Description: "The ID of the tag the elements are tagged with"}
+)
+
+// Route documents an API route
+type Route struct {
+	Method string
+	// Path is the mux path template
+	Path string
+	// ID is the unique operation ID
+	ID      string
+	Summary string
+	Tag     string
+	// Auth tells whether the route needs the caller to authenticate
+	Auth  bool
+	Query []Param
+	// Request is the model of the JSON request body
+	Request Model
+	// Response is the model of the JSON response body, an array of the
+	// model if List is set
+	Response Model
+	List     bool
+	// Status is the status code of a successful response
+	Status int
+}
+
+var pathParam = regexp.MustCompile(`{([^}:]+)(:[^}]*)?}`)
+
+// Generate generates the OpenAPI document of the routes
+func Generate(info Info, routes []Route) (*Document, error) {
+	var problems []string
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+			SecuritySchemes: map[string]SecurityScheme{
+				"basic": {Type: "http", Scheme: "basic"},
+			},
+		},
+	}
+	for name, t := range models {
+		doc.Components.Schemas[string(name)] = schemaOf(t, &problems)
+	}
+	doc.Components.Schemas[string(DocumentModel)] = &Schema{
+		Type:        "object",
+		Description: "An OpenAPI 3 document",
+	}
+
+	ids := make(map[string]bool)
+	for _, r := range routes {
+		where := r.Method + " " + r.Path
+		if len(r.ID) == 0 || len(r.Summary) == 0 {
+			problems = append(problems, where+": missing operation ID or summary")
+		}
+		if ids[r.ID] {
+			problems = append(problems, where+": duplicated operation ID "+r.ID)
+		}
+		ids[r.ID] = true
+
+		path := pathParam.ReplaceAllString(r.Path, "{$1}")
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = make(PathItem)
+			doc.Paths[path] = item
+		}
+		method := strings.ToLower(r.Method)
+		if _, ok := item[method]; ok {
+			problems = append(problems, where+": registered twice")
+		}
+		item[method] = operation(r, &problems)
+	}
+	if len(problems) != 0 {
+		sort.Strings(problems)
+		return doc, fmt.Errorf("api spec: %s", strings.Join(problems, "; "))
+	}
+	return doc, nil
+}
+
+// operation documents the route
+func operation(r Route, problems *[]string) *Operation {
+	where := r.Method + " " + r.Path
+	op := &Operation{
+		OperationID: r.ID,
+		Summary:     r.Summary,
+		Responses:   make(map[string]Response),
+	}
+	if len(r.Tag) != 0 {
+		op.Tags = []string{r.Tag}
+	}
+	if r.Auth {
+		op.Security = []map[string][]string{{"basic": {}}}
+	}
+	for _, m := range pathParam.FindAllStringSubmatch(r.Path, -1) {
+		p, ok := pathParams[m[1]]
+		if !ok {
+			*problems = append(*problems, where+": undocumented path parameter "+m[1])
+			p.Schema = &Schema{Type: "string"}
+		}
+		p.Name, p.In, p.Required = m[1], "path", true
+		op.Parameters = append(op.Parameters, p)
+	}
+	for _, q := range r.Query {
+		typ := q.Type
+		if len(typ) == 0 {
+			typ = "string"
+		}
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:        q.Name,
+			In:          "query",
+			Description: q.Description,
+			Required:    q.Required,
+			Schema:      &Schema{Type: typ},
+		})
+	}
+	if r.Method == http.MethodPost {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name: idempotency.Header,
+			In:   "header",
+			Description: "Identifies the retries of the request, which get the response of the first one. " +
+				"The key cannot be reused for another request. Without credentials, the key belongs to the username of the account created",
+			Schema: &Schema{Type: "string", MaxLength: intPtr(idempotency.MaxKeyLength)},
+		})
+	}
+	if r.Request != NoModel {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  content(r.Request, false, problems, where),
+		}
+	}
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	ok := Response{Description: http.StatusText(status)}
+	if r.Response != NoModel {
+		ok.Content = content(r.Response, r.List, problems, where)
+	}
+	if status == http.StatusCreated {
+		ok.Headers = map[string]Header{
+			"Location": {Description: "The URL of the created resource", Schema: &Schema{Type: "string"}},
+		}
+	}
+	if r.Method == http.MethodPost {
+		if ok.Headers == nil {
+			ok.Headers = make(map[string]Header)
+		}
+		ok.Headers[idempotency.ReplayedHeader] = Header{
+			Description: "Set to true when the response is the one of an earlier request with the same idempotency key",
+			Schema:      &Schema{Type: "string"},
+		}
+	}
+	op.Responses[strconv.Itoa(status)] = ok
+	op.Responses["default"] = Response{
+		Description: "An error, as a RFC 7807 problem if the client accepts application/problem+json",
+		Content: map[string]MediaType{
+			"application/json":        {Schema: &Schema{Ref: "#/components/schemas/Error"}},
+			errors.ProblemContentType: {Schema: &Schema{Ref: "#/components/schemas/Problem"}},
+		},
+	}
+	return op
+}
+
+func modelSchema(m Model, list bool, problems *[]string, where string) *Schema {
+	var s *Schema
+	switch m {
+	case BooleanModel:
+		s = &Schema{Type: "boolean"}
+	case UsernameModel:
+		s = &Schema{Type: "string", Pattern: "^[a-zA-Z0-9]+$", MaxLength: intPtr(30)}
+	case EventStreamModel:
+		s = &Schema{Type: "string", Description: "Server-sent events, identified by the ID of their notification"}
+	case CalendarModel:
+		s = &Schema{Type: "string", Description: "An iCalendar (RFC 5545) document"}
+	case ArchiveModel:
+		s = &Schema{Type: "string", Format: "binary", Description: "A zip archive of the account's JSON files"}
+	case ImportFileModel:
+		s = &Schema{Type: "string", Description: "An iCalendar file of VTODO and VEVENT components or a CSV file " +
+			"with the columns id, name, description, status, due, duration, rrule and exdates"}
+	case DocumentModel:
+		s = &Schema{Ref: "#/components/schemas/" + string(m)}
+	default:
+		if _, ok := models[m]; !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: unknown model %q", where, m))
+		}
+		s = &Schema{Ref: "#/components/schemas/" + string(m)}
+	}
+	if list {
+		return &Schema{Type: "array", Items: s}
+	}
+	return s
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -0,0 +1,215 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apispec
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+	"github.com/iocat/donit/internal/achieving/internal/goal"
+	"github.com/iocat/donit/internal/achieving/internal/member"
+	"github.com/iocat/donit/internal/achieving/internal/user"
+	"github.com/iocat/donit/internal/achieving/internal/workspace"
+	"github.com/iocat/donit/internal/notify"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// enums lists the values accepted by the custom validators of the
+// validator package
+var enums = map[string][]string{
+	"goalAccessField":      {goal.AccessPrivate, goal.AccessForFollowers, goal.AccessPublic, goal.AccessWorkspace},
+	"validateUserStatus":   {user.Offline, user.OnlineAvailable, user.Busy},
+	"validateStatus":       {achievable.Done, achievable.NotDone, achievable.InProgress},
+	"cycle":                {achievable.EveryDay, achievable.EveryWeekAndCustom, achievable.EveryMonthAndCustom},
+	"notificationChannels": {notify.ChannelWebhook, notify.ChannelEmail, notify.ChannelInApp},
+	"memberRole":           {member.RoleOwner, member.RoleEditor, member.RoleViewer},
+	"workspaceRole":        {workspace.RoleAdmin, workspace.RoleMember},
+}
+
+// rules applies the govalidator rules without parameters to the schema
+var rules = map[string]func(*Schema){
+	"required": func(*Schema) {},
+	"optional": func(*Schema) {},
+	"email":    func(s *Schema) { s.Format = "email" },
+	"url":      func(s *Schema) { s.Format = "uri" },
+	"alpha":    func(s *Schema) { s.Pattern = "^[a-zA-Z]*$" },
+	"alphanum": func(s *Schema) { s.Pattern = "^[a-zA-Z0-9]*$" },
+	"hexadecimal": func(s *Schema) {
+		if len(s.Pattern) == 0 {
+			s.Pattern = "^[0-9a-fA-F]*$"
+		}
+	},
+	"utfletternum": func(s *Schema) { s.Pattern = `^[\p{L}\p{N}]*$` },
+	"hexcolor":     func(s *Schema) { s.Pattern = "^#?([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$" },
+	"daysInWeekOrMonth": func(s *Schema) {
+		s.Description = "The set of days the habit repeats on: 0 (Sunday) to 6 (Saturday) " +
+			"for a weekly cycle, 1 to 31 for a monthly cycle"
+	},
+	"rrule": func(s *Schema) {
+		s.Description = "An RFC 5545 recurrence rule, for example FREQ=MONTHLY;BYDAY=-1FR"
+	},
+	"date": func(s *Schema) { s.Format = "date" },
+	"dates": func(s *Schema) {
+		if s.Items != nil {
+			s.Items.Format = "date"
+		}
+	},
+	"timezone": func(s *Schema) {
+		s.Description = "An IANA time zone name, for example Europe/Paris"
+	},
+	"webhookURL": func(s *Schema) {
+		s.Format = "uri"
+		s.Description = "An https URL of a public host"
+	},
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	objectIDType = reflect.TypeOf(bson.ObjectId(""))
+	rawJSONType  = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// ObjectIDPattern matches the hexadecimal resource identifiers
+const ObjectIDPattern = "^[0-9a-fA-F]{24}$"
+
+// schemaOf returns the schema of the type, the problems found in the valid
+// tags are appended to problems
+func schemaOf(t reflect.Type, problems *[]string) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64", Description: "A duration in nanoseconds"}
+	case objectIDType:
+		return &Schema{Type: "string", Pattern: ObjectIDPattern}
+	case rawJSONType:
+		// the raw JSON values are free form
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem(), problems)
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaOf(t.Elem(), problems)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaOf(t.Elem(), problems)}
+	case reflect.Struct:
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		addFields(s, t, problems)
+		sort.Strings(s.Required)
+		return s
+	default:
+		// interfaces and the like are free form
+		return &Schema{}
+	}
+}
+
+// addFields adds the JSON fields of the struct type to the object schema,
+// the fields of the embedded structs are flattened like encoding/json does
+func addFields(s *Schema, t reflect.Type, problems *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" || (len(f.PkgPath) != 0 && !f.Anonymous) {
+			continue
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && len(name) == 0 && ft.Kind() == reflect.Struct {
+			addFields(s, ft, problems)
+			continue
+		}
+		if len(name) == 0 {
+			name = f.Name
+		}
+		fs := schemaOf(f.Type, problems)
+		if applyValidTag(fs, f.Tag.Get("valid"), problems, t.Name()+"."+f.Name) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = fs
+	}
+}
+
+// applyValidTag documents the govalidator rules of the tag in the schema and
+// returns whether the field is required. The rules the generator does not
+// know about are reported as problems
+func applyValidTag(s *Schema, tag string, problems *[]string, field string) bool {
+	if len(tag) == 0 || tag == "-" {
+		return false
+	}
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		if rule == "required" {
+			required = true
+		}
+		if values, ok := enums[rule]; ok {
+			if s.Type == "array" {
+				s.Items.Enum = values
+			} else {
+				s.Enum = values
+			}
+			continue
+		}
+		if apply, ok := rules[rule]; ok {
+			apply(s)
+			continue
+		}
+		if name, min, max, ok := parseRange(rule); ok && (name == "length" || name == "stringlength") {
+			s.MinLength, s.MaxLength = &min, &max
+			continue
+		}
+		*problems = append(*problems, fmt.Sprintf("%s: undocumented validation rule %q", field, rule))
+	}
+	return required
+}
+
+// parseRange parses rules such as length(1|30)
+func parseRange(rule string) (name string, min, max int, ok bool) {
+	open := strings.Index(rule, "(")
+	if open < 0 || !strings.HasSuffix(rule, ")") {
+		return "", 0, 0, false
+	}
+	bounds := strings.Split(rule[open+1:len(rule)-1], "|")
+	if len(bounds) != 2 {
+		return "", 0, 0, false
+	}
+	min, err := strconv.Atoi(bounds[0])
+	if err != nil {
+		return "", 0, 0, false
+	}
+	max, err = strconv.Atoi(bounds[1])
+	if err != nil {
+		return "", 0, 0, false
+	}
+	return rule[:open], min, max, true
+}
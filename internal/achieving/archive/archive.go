@@ -0,0 +1,216 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package archive exports the data of an account as a versioned archive and
+// recreates accounts from the archives, to back them up or move them between
+// donit instances
+package archive
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/internal/follow"
+	"github.com/iocat/donit/internal/achieving/internal/goal"
+	"github.com/iocat/donit/internal/achieving/internal/tag"
+	"github.com/iocat/donit/internal/achieving/internal/user"
+	"github.com/iocat/donit/internal/notify"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// Format identifies the donit archives
+	Format = "donit-archive"
+	// Version is the version of the archives written, the archives of
+	// this version and the previous ones can be imported
+	Version = 1
+	// ContentType is the media type of the archives, zip files
+	ContentType = "application/zip"
+)
+
+// The files of an archive
+const (
+	manifestFile  = "manifest.json"
+	profileFile   = "profile.json"
+	goalsFile     = "goals.json"
+	tagsFile      = "tags.json"
+	followingFile = "following.json"
+	historyFile   = "history.json"
+)
+
+// Manifest describes an archive
+type Manifest struct {
+	Format   string    `json:"format"`
+	Version  int       `json:"version"`
+	Username string    `json:"username"`
+	Exported time.Time `json:"exported"`
+}
+
+// Account is the data of an account. The pictures are the URLs of the
+// profile and the goals, donit stores none
+type Account struct {
+	Manifest Manifest
+	// Profile is the user's data and settings
+	Profile user.User
+	// Goals are the goals and their achievables
+	Goals []goal.Goal
+	// Tags is the catalogue of the tags of the goals and the achievables
+	Tags []tag.Tag
+	// Following are the usernames of the users the user follows
+	Following []string
+	// History are the deliveries of the reminders of the achievables
+	History []notify.Delivery
+}
+
+// Archive exports and imports the accounts
+type Archive struct {
+	users       *mgo.Collection
+	goals       *mgo.Collection
+	achievables *mgo.Collection
+	follows     *mgo.Collection
+	tags        *mgo.Collection
+	deliveries  *mgo.Collection
+}
+
+// New creates an archive over the collections of the accounts' data
+func New(users, goals, achievables, follows, tags, deliveries *mgo.Collection) *Archive {
+	return &Archive{
+		users:       users,
+		goals:       goals,
+		achievables: achievables,
+		follows:     follows,
+		tags:        tags,
+		deliveries:  deliveries,
+	}
+}
+
+// Load loads the account of the user
+func (a *Archive) Load(username string, now time.Time) (*Account, error) {
+	acc := &Account{
+		Manifest: Manifest{
+			Format:   Format,
+			Version:  Version,
+			Username: username,
+			Exported: now.UTC(),
+		},
+	}
+	if err := acc.Profile.Retrieve(a.users, username); err != nil {
+		return nil, err
+	}
+	var err error
+	if acc.Goals, err = acc.Profile.RetriveGoals(a.goals, a.achievables, 0, 0); err != nil {
+		return nil, fmt.Errorf("find the goals of %s: %s", username, err)
+	}
+	if acc.Tags, err = tag.List(a.tags, username, 0, 0); err != nil {
+		return nil, fmt.Errorf("find the tags of %s: %s", username, err)
+	}
+	var subjects []string
+	for _, g := range acc.Goals {
+		for _, t := range g.ToDo {
+			subjects = append(subjects, t.ID.Hex())
+		}
+	}
+	fs, err := follow.Following(a.follows, username, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("find the users %s follows: %s", username, err)
+	}
+	for _, f := range fs {
+		acc.Following = append(acc.Following, f.Followee)
+	}
+	err = a.deliveries.Find(bson.M{
+		"notification.subject": bson.M{"$in": subjects},
+	}).Sort("created").All(&acc.History)
+	if err != nil {
+		return nil, fmt.Errorf("find the deliveries of %s: %s", username, err)
+	}
+	return acc, nil
+}
+
+// Write writes the account as a zip archive of JSON files
+func (acc *Account) Write(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{manifestFile, acc.Manifest},
+		{profileFile, acc.Profile},
+		{goalsFile, acc.Goals},
+		{tagsFile, acc.Tags},
+		{followingFile, acc.Following},
+		{historyFile, acc.History},
+	}
+	for _, f := range files {
+		h := &zip.FileHeader{Name: f.name, Method: zip.Deflate}
+		h.SetModTime(acc.Manifest.Exported)
+		fw, err := zw.CreateHeader(h)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "\t")
+		if err = enc.Encode(f.v); err != nil {
+			return fmt.Errorf("write %s: %s", f.name, err)
+		}
+	}
+	return zw.Close()
+}
+
+// Read reads an archive. The manifest is required, the other files are
+// optional
+func Read(r io.ReaderAt, size int64) (*Account, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not a zip archive: %s", err)
+	}
+	acc := &Account{}
+	files := map[string]interface{}{
+		manifestFile:  &acc.Manifest,
+		profileFile:   &acc.Profile,
+		goalsFile:     &acc.Goals,
+		tagsFile:      &acc.Tags,
+		followingFile: &acc.Following,
+		historyFile:   &acc.History,
+	}
+	read := make(map[string]bool)
+	for _, f := range zr.File {
+		v, ok := files[f.Name]
+		if !ok || read[f.Name] {
+			continue
+		}
+		read[f.Name] = true
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("open %s: %s", f.Name, err)
+		}
+		err = json.NewDecoder(rc).Decode(v)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %s", f.Name, err)
+		}
+	}
+	switch {
+	case !read[manifestFile]:
+		return nil, fmt.Errorf("the archive has no %s", manifestFile)
+	case acc.Manifest.Format != Format:
+		return nil, fmt.Errorf("not a %s", Format)
+	case acc.Manifest.Version < 1 || acc.Manifest.Version > Version:
+		return nil, fmt.Errorf("unsupported archive version %d", acc.Manifest.Version)
+	}
+	return acc, nil
+}
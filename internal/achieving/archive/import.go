@@ -0,0 +1,280 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"fmt"
+
+	"github.com/iocat/donit/internal/achieving"
+	"github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+	concr "github.com/iocat/donit/internal/achieving/internal/concreteachieving"
+	"github.com/iocat/donit/internal/achieving/internal/goal"
+	"github.com/iocat/donit/internal/achieving/internal/tag"
+	"github.com/iocat/donit/internal/achieving/validator"
+	"github.com/iocat/donit/internal/notify"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Summary tells what an import recreated
+type Summary struct {
+	Username string `json:"username"`
+	// Created tells whether the account was created by the import
+	Created     bool `json:"created"`
+	Goals       int  `json:"goals"`
+	Tags        int  `json:"tags"`
+	Achievables int  `json:"achievables"`
+	Following   int  `json:"following"`
+	History     int  `json:"history"`
+}
+
+// check validates the account's data as the API does, the names of the
+// invalid fields are prefixed with their path in the archive
+func (acc *Account) check() error {
+	var invalid []errors.Field
+	add := func(prefix string, obj interface{}) error {
+		fields, err := validator.Fields(obj)
+		if err != nil {
+			return err
+		}
+		for _, f := range fields {
+			f.Name = prefix + f.Name
+			f.Message = prefix + f.Message
+			invalid = append(invalid, f)
+		}
+		return nil
+	}
+	if err := add(profileFile+": ", &concr.User{User: acc.Profile}); err != nil {
+		return err
+	}
+	for i, t := range acc.Tags {
+		prefix := fmt.Sprintf("%s: [%d].", tagsFile, i)
+		if err := add(prefix, &concr.Tag{Tag: t}); err != nil {
+			return err
+		}
+	}
+	for i, g := range acc.Goals {
+		prefix := fmt.Sprintf("%s: [%d].", goalsFile, i)
+		if err := add(prefix, &concr.Goal{Goal: g}); err != nil {
+			return err
+		}
+		for j, t := range g.ToDo {
+			prefix := fmt.Sprintf("%s: [%d].achievables[%d].", goalsFile, i, j)
+			if t.RepeatReminder != nil {
+				if err := t.RepeatReminder.Normalize(); err != nil {
+					invalid = append(invalid, errors.Field{
+						Name:    prefix + "repeatedReminder",
+						Rule:    "rrule",
+						Message: prefix + "repeatedReminder: " + err.Error(),
+					})
+				}
+			}
+			if err := add(prefix, &concr.Achievable{Achievable: t}); err != nil {
+				return err
+			}
+		}
+	}
+	if len(invalid) != 0 {
+		return errors.NewValidateFields(invalid)
+	}
+	return nil
+}
+
+// Import recreates the account of the archive as the user's. The account is
+// created with the password if create is set, the profile and the settings
+// of an existing account are replaced. The goals are added to the account's
+// ones, with new IDs, and the followed users which exist are followed
+func (a *Archive) Import(store achieving.UserStore, acc *Account, username, password string, create bool) (*Summary, error) {
+	acc.Profile.Username = username
+	if err := acc.check(); err != nil {
+		return nil, err
+	}
+	s := &Summary{Username: username, Created: create}
+	profile := concr.NewUser(nil, nil)
+	profile.User = acc.Profile
+	var err error
+	if create {
+		_, err = store.CreateNewUser(profile, password)
+	} else {
+		err = store.UpdateUser(profile, username)
+	}
+	if err != nil {
+		return nil, err
+	}
+	u, err := store.RetrieveUser(username)
+	if err != nil {
+		return nil, err
+	}
+
+	// ids maps the IDs of the archive to the new ones
+	ids := make(map[string]string)
+	if s.Tags, err = importTags(store, acc.Tags, username, ids); err != nil {
+		return s, err
+	}
+	for _, g := range acc.Goals {
+		todo := g.ToDo
+		cg := concr.NewGoal(nil)
+		cg.Goal = g
+		cg.ID, cg.ToDo = "", nil
+		cg.Tags = remapAll(ids, g.Tags)
+		// the workspaces are not archived, their goals come back private
+		if len(cg.Workspace) != 0 {
+			cg.Workspace = ""
+			cg.Accessibility = goal.AccessPrivate
+		}
+		gid, err := u.CreateGoal(cg)
+		if err != nil {
+			return s, err
+		}
+		ids[g.ID.Hex()] = gid
+		s.Goals++
+		created, err := u.RetrieveGoal(gid)
+		if err != nil {
+			return s, err
+		}
+		// the tasks are added first and related to each other once
+		// they all have their new IDs
+		var related []achievable.Achievable
+		for _, t := range todo {
+			oldID := t.ID.Hex()
+			// the members of the shared goals are not archived
+			t.Assignee = ""
+			if len(t.Parent) != 0 || len(t.BlockedBy) != 0 {
+				related = append(related, t)
+			}
+			t.ID, t.NextOccurrence = "", nil
+			t.Parent, t.BlockedBy = "", nil
+			t.Tags = remapAll(ids, t.Tags)
+			tid, err := created.AddAchievable(&concr.Achievable{Achievable: t})
+			if err != nil {
+				return s, err
+			}
+			ids[oldID] = tid
+			s.Achievables++
+		}
+		for _, t := range related {
+			id := ids[t.ID.Hex()]
+			t.ID, t.NextOccurrence = "", nil
+			t.Parent = remap(ids, t.Parent)
+			t.BlockedBy = remapAll(ids, t.BlockedBy)
+			t.Tags = remapAll(ids, t.Tags)
+			if err := created.UpdateAchievable(&concr.Achievable{Achievable: t}, id); err != nil {
+				return s, err
+			}
+		}
+	}
+
+	for _, followee := range acc.Following {
+		err := store.Follow(username, followee)
+		switch {
+		case err == nil:
+			s.Following++
+		case errors.IsNotFound(err) || errors.IsDuplicated(err) || errors.IsValidate(err):
+			// the user is not on this instance, is followed already
+			// or is the user
+		default:
+			return s, err
+		}
+	}
+
+	n, err := a.restore(acc.History, ids, username, acc.Profile.Email)
+	s.History = n
+	return s, err
+}
+
+// remap maps the ID of an archived task to the ID of the imported one, the
+// tasks which are not in the archive are left out
+func remap(ids map[string]string, id bson.ObjectId) bson.ObjectId {
+	if len(id) == 0 {
+		return ""
+	}
+	if nid, ok := ids[id.Hex()]; ok {
+		return bson.ObjectIdHex(nid)
+	}
+	return ""
+}
+
+// remapAll maps the IDs of the archive to the new ones, leaving out the ones
+// which are not in the archive
+func remapAll(ids map[string]string, old []bson.ObjectId) []bson.ObjectId {
+	var mapped []bson.ObjectId
+	for _, id := range old {
+		if id = remap(ids, id); len(id) != 0 {
+			mapped = append(mapped, id)
+		}
+	}
+	return mapped
+}
+
+// importTags adds the tags of the archive to the user's catalogue, the tags
+// named as one of the catalogue are that one
+func importTags(store achieving.UserStore, ts []tag.Tag, username string, ids map[string]string) (int, error) {
+	existing, err := store.RetrieveTags(username, 0, 0)
+	if err != nil {
+		return 0, err
+	}
+	byName := make(map[string]string, len(existing))
+	for _, t := range existing {
+		if t, ok := t.(*concr.Tag); ok {
+			byName[t.Name] = t.ID.Hex()
+		}
+	}
+	n := 0
+	for _, t := range ts {
+		if id, ok := byName[t.Name]; ok {
+			ids[t.ID.Hex()] = id
+			continue
+		}
+		id, err := store.CreateTag(username, &concr.Tag{Tag: t})
+		if err != nil {
+			return n, err
+		}
+		ids[t.ID.Hex()], byName[t.Name] = id, id
+		n++
+	}
+	return n, nil
+}
+
+// restore inserts the deliveries of the archive's history which are about
+// the imported achievables. The pending deliveries are left out, they are
+// not history and would be sent again
+func (a *Archive) restore(history []notify.Delivery, ids map[string]string, username, email string) (int, error) {
+	n := 0
+	for _, d := range history {
+		subject, ok := ids[d.Notification.Subject]
+		if !ok || d.Status == notify.StatusPending {
+			continue
+		}
+		d.ID = bson.NewObjectId()
+		// the key only dedupes the enqueued notifications, the restored
+		// ones are never enqueued again
+		d.Key = "archive:" + d.ID.Hex()
+		d.Recipient = notify.Recipient{Username: username, Email: email}
+		d.Notification.Subject = subject
+		data := make(map[string]string, len(d.Notification.Data))
+		for k, v := range d.Notification.Data {
+			if id, ok := ids[v]; ok {
+				v = id
+			}
+			data[k] = v
+		}
+		d.Notification.Data = data
+		if err := a.deliveries.Insert(d); err != nil {
+			return n, fmt.Errorf("restore delivery: %s", err)
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,280 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package batch runs a list of changes of a user's goals and achievables
+// sent in one request
+package batch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	httperr "github.com/iocat/donit/errors"
+	"github.com/iocat/donit/internal/achieving"
+	"github.com/iocat/donit/internal/achieving/errors"
+	jsoni "github.com/iocat/donit/internal/achieving/jsoninterpreter"
+	"github.com/iocat/donit/internal/achieving/validator"
+)
+
+const (
+	// Create creates a goal or an achievable
+	Create = "create"
+	// Update replaces a goal or an achievable
+	Update = "update"
+	// Delete deletes a goal or an achievable
+	Delete = "delete"
+)
+
+const (
+	// Goal is the resource of the operations on the goals
+	Goal = "goal"
+	// Achievable is the resource of the operations on the achievables
+	Achievable = "achievable"
+)
+
+// MaxOperations is the number of operations of a batch at most
+const MaxOperations = 100
+
+// Operation is a change of a goal or an achievable
+type Operation struct {
+	// Op is create, update or delete
+	Op string `json:"op" valid:"required"`
+	// Resource is goal or achievable
+	Resource string `json:"resource" valid:"required"`
+	// Goal is the ID of the goal changed or of the goal of the achievable,
+	// or $n for the goal created by the nth operation of the batch
+	// counting from 0. The goals created have none
+	Goal string `json:"goal,omitempty" valid:"-"`
+	// Achievable is the ID of the achievable updated or deleted
+	Achievable string `json:"achievable,omitempty" valid:"-"`
+	// Data is the goal or the achievable created or updated, as the
+	// single requests take it
+	Data json.RawMessage `json:"data,omitempty" valid:"-"`
+}
+
+// Request is a batch of operations
+type Request struct {
+	Operations []Operation `json:"operations" valid:"required"`
+}
+
+// Result is the outcome of an operation
+type Result struct {
+	// Status is the HTTP status code the single request would have
+	// answered
+	Status int `json:"status"`
+	// ID is the ID of the goal or the achievable created
+	ID string `json:"id,omitempty"`
+	// Error tells why the operation failed
+	Error *httperr.Error `json:"error,omitempty"`
+
+	cause error
+}
+
+// Cause returns the error the operation failed with, the internal errors
+// are not detailed to the clients
+func (r *Result) Cause() error {
+	return r.cause
+}
+
+// Report is the outcome of a batch, the results are in the order of the
+// operations
+type Report struct {
+	Succeeded int      `json:"succeeded"`
+	Failed    int      `json:"failed"`
+	Results   []Result `json:"results"`
+}
+
+// runner runs the operations of a batch on the owner's goals on behalf of
+// the caller
+type runner struct {
+	owner              achieving.User
+	username, caller   string
+	goals, achievables jsoni.Interpreter
+	ops                []Operation
+	results            []Result
+}
+
+// Run runs the operations on the goals of the user on behalf of the caller,
+// one after the other. The storage has no transactions so a batch is not
+// all-or-nothing: each operation succeeds or fails on its own, the ones
+// referring to a goal the batch failed to create fail as well. The
+// interpreters decode the goals and the achievables
+func Run(owner achieving.User, username, caller string, ops []Operation, goals, achievables jsoni.Interpreter) (*Report, error) {
+	if len(ops) == 0 || len(ops) > MaxOperations {
+		return nil, errors.NewValidate(fmt.Sprintf("a batch has between 1 and %d operations", MaxOperations))
+	}
+	r := &runner{
+		owner:       owner,
+		username:    username,
+		caller:      caller,
+		goals:       goals,
+		achievables: achievables,
+		ops:         ops,
+		results:     make([]Result, 0, len(ops)),
+	}
+	report := &Report{}
+	for i := range ops {
+		id, status, err := r.run(&ops[i])
+		res := result(id, status, err)
+		if res.Error == nil {
+			report.Succeeded++
+		} else {
+			report.Failed++
+		}
+		r.results = append(r.results, res)
+	}
+	report.Results = r.results
+	return report, nil
+}
+
+// result makes the result of an operation, the errors are reported as the
+// single requests report them
+func result(id string, status int, err error) Result {
+	if err == nil {
+		return Result{Status: status, ID: id}
+	}
+	if jsoni.IsErrInvalidJSONType(err) {
+		err = httperr.ErrDecodeJSON
+	}
+	e, ok := httperr.ParseDocumentError(err).(httperr.Error)
+	if !ok {
+		e = httperr.ErrInternal
+	}
+	return Result{Status: e.Code.HTTPStatus(), Error: &e, cause: err}
+}
+
+// run runs the operation and returns the ID of the resource created and the
+// status of the success
+func (r *runner) run(op *Operation) (string, int, error) {
+	switch op.Resource {
+	case Goal:
+		return r.goal(op)
+	case Achievable:
+		return r.achievable(op)
+	default:
+		return "", 0, errors.NewValidate(fmt.Sprintf("unknown resource %q, expect %s or %s", op.Resource, Goal, Achievable))
+	}
+}
+
+func (r *runner) goal(op *Operation) (string, int, error) {
+	if op.Op == Create {
+		// only the owner creates goals
+		if r.caller != r.username {
+			return "", 0, errors.ErrForbidden
+		}
+		g, err := r.decode(op.Data, r.goals)
+		if err != nil {
+			return "", 0, err
+		}
+		id, err := r.owner.CreateGoal(g.(achieving.Goal))
+		return id, http.StatusCreated, err
+	}
+	switch op.Op {
+	case Update:
+		id, _, err := r.retrieve(op.Goal, achieving.Goal.CanEdit)
+		if err != nil {
+			return "", 0, err
+		}
+		g, err := r.decode(op.Data, r.goals)
+		if err != nil {
+			return "", 0, err
+		}
+		return "", http.StatusNoContent, r.owner.UpdateGoal(g.(achieving.Goal), id)
+	case Delete:
+		id, _, err := r.retrieve(op.Goal, achieving.Goal.CanManage)
+		if err != nil {
+			return "", 0, err
+		}
+		return "", http.StatusNoContent, r.owner.DeleteGoal(id)
+	default:
+		return "", 0, unknownOp(op.Op)
+	}
+}
+
+func (r *runner) achievable(op *Operation) (string, int, error) {
+	_, g, err := r.retrieve(op.Goal, achieving.Goal.CanEdit)
+	if err != nil {
+		return "", 0, err
+	}
+	switch op.Op {
+	case Create:
+		a, err := r.decode(op.Data, r.achievables)
+		if err != nil {
+			return "", 0, err
+		}
+		id, err := g.AddAchievable(a.(achieving.Achievable))
+		return id, http.StatusCreated, err
+	case Update:
+		a, err := r.decode(op.Data, r.achievables)
+		if err != nil {
+			return "", 0, err
+		}
+		return "", http.StatusNoContent, g.UpdateAchievable(a.(achieving.Achievable), op.Achievable)
+	case Delete:
+		return "", http.StatusNoContent, g.RemoveAchievable(op.Achievable)
+	default:
+		return "", 0, unknownOp(op.Op)
+	}
+}
+
+func unknownOp(op string) error {
+	return errors.NewValidate(fmt.Sprintf("unknown operation %q, expect %s, %s or %s", op, Create, Update, Delete))
+}
+
+// decode decodes and validates the data of the operation
+func (r *runner) decode(data json.RawMessage, interpreter jsoni.Interpreter) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, errors.NewValidate("the operation has no data")
+	}
+	return validator.Validate(bytes.NewReader(data), interpreter)
+}
+
+// goalID resolves the references to the goals created by the batch
+func (r *runner) goalID(ref string) (string, error) {
+	if !strings.HasPrefix(ref, "$") {
+		return ref, nil
+	}
+	n, err := strconv.Atoi(ref[1:])
+	if err != nil || n < 0 || n >= len(r.results) {
+		return "", errors.NewValidate(fmt.Sprintf("%s does not refer to an earlier operation", ref))
+	}
+	if r.ops[n].Resource != Goal || r.ops[n].Op != Create || r.results[n].Error != nil {
+		return "", errors.NewValidate(fmt.Sprintf("the operation %s created no goal", ref))
+	}
+	return r.results[n].ID, nil
+}
+
+// retrieve retrieves the goal the caller can see, the caller must be allowed
+// to change it as well
+func (r *runner) retrieve(ref string, allowed func(achieving.Goal, string) bool) (string, achieving.Goal, error) {
+	id, err := r.goalID(ref)
+	if err != nil {
+		return "", nil, err
+	}
+	g, err := r.owner.RetrieveGoal(id)
+	if err != nil {
+		return "", nil, err
+	}
+	switch {
+	case !g.VisibleTo(r.caller):
+		return "", nil, errors.NewNotFound("goal", fmt.Sprintf("%s,%s", r.username, id))
+	case !allowed(g, r.caller):
+		return "", nil, errors.ErrForbidden
+	}
+	return id, g, nil
+}
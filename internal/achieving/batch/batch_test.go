@@ -0,0 +1,136 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	stderr "errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	httperr "github.com/iocat/donit/errors"
+	"github.com/iocat/donit/internal/achieving/errors"
+)
+
+func TestResult(t *testing.T) {
+	internal := stderr.New("the connection is closed")
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		// hidden tells whether the error is not detailed to the clients
+		hidden bool
+	}{
+		{"created", nil, http.StatusCreated, false},
+		{"invalid", errors.NewValidate("the name is required"), http.StatusBadRequest, false},
+		{"not found", errors.NewNotFound("goal", "alice,1"), http.StatusNotFound, false},
+		{"forbidden", errors.ErrForbidden, http.StatusForbidden, false},
+		{"conflict", errors.NewConflict("goal", "alice,1"), http.StatusConflict, false},
+		{"internal", internal, http.StatusInternalServerError, true},
+	}
+	for _, test := range tests {
+		res := result("1", http.StatusCreated, test.err)
+		if res.Status != test.status || res.Cause() != test.err {
+			t.Errorf("%s: status %d, cause %v, want status %d, cause %v", test.name, res.Status, res.Cause(), test.status, test.err)
+		}
+		switch {
+		case test.err == nil:
+			if res.Error != nil || res.ID != "1" {
+				t.Errorf("%s: error %v, ID %q, want no error and the ID 1", test.name, res.Error, res.ID)
+			}
+		case res.Error == nil || len(res.ID) != 0:
+			t.Errorf("%s: error %v, ID %q, want an error and no ID", test.name, res.Error, res.ID)
+		case test.hidden != reflect.DeepEqual(*res.Error, httperr.ErrInternal):
+			t.Errorf("%s: error %v, want it detailed %t", test.name, *res.Error, !test.hidden)
+		}
+	}
+}
+
+func TestGoalID(t *testing.T) {
+	r := &runner{
+		ops: []Operation{
+			{Op: Create, Resource: Goal},
+			{Op: Create, Resource: Goal},
+			{Op: Update, Resource: Goal, Goal: "g0"},
+			{Op: Create, Resource: Achievable, Goal: "g0"},
+		},
+		results: []Result{
+			{Status: http.StatusCreated, ID: "g0"},
+			result("", 0, errors.NewValidate("the name is required")),
+			{Status: http.StatusNoContent},
+			{Status: http.StatusCreated, ID: "a0"},
+		},
+	}
+	tests := []struct {
+		ref, want string
+	}{
+		{"g9", "g9"},
+		{"$0", "g0"},
+		{"$1", ""},
+		{"$2", ""},
+		{"$3", ""},
+		{"$4", ""},
+		{"$-1", ""},
+		{"$", ""},
+		{"$first", ""},
+	}
+	for _, test := range tests {
+		id, err := r.goalID(test.ref)
+		if id != test.want || (err == nil) != (len(test.want) != 0) {
+			t.Errorf("goalID(%q) = %q, %v, want %q", test.ref, id, err, test.want)
+		}
+		if err != nil && !errors.IsValidate(err) {
+			t.Errorf("goalID(%q) error = %v, want a validation error", test.ref, err)
+		}
+	}
+}
+
+// TestRunFailures runs operations failing before the store is reached
+func TestRunFailures(t *testing.T) {
+	if _, err := Run(nil, "alice", "alice", nil, nil, nil); !errors.IsValidate(err) {
+		t.Errorf("Run() of no operations error = %v, want a validation error", err)
+	}
+	if _, err := Run(nil, "alice", "alice", make([]Operation, MaxOperations+1), nil, nil); !errors.IsValidate(err) {
+		t.Errorf("Run() of %d operations error = %v, want a validation error", MaxOperations+1, err)
+	}
+	ops := []Operation{
+		{Op: Create, Resource: "tag"},
+		{Op: "move", Resource: Goal, Goal: "g1"},
+		{Op: Create, Resource: Goal},
+		{Op: Create, Resource: Achievable, Goal: "$2"},
+		{Op: Update, Resource: Goal, Goal: "$4"},
+		{Op: Delete, Resource: Achievable, Goal: "$x"},
+	}
+	want := []int{
+		http.StatusBadRequest,
+		http.StatusBadRequest,
+		http.StatusForbidden,
+		http.StatusBadRequest,
+		http.StatusBadRequest,
+		http.StatusBadRequest,
+	}
+	report, err := Run(nil, "alice", "bob", ops, nil, nil)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if report.Succeeded != 0 || report.Failed != len(ops) || len(report.Results) != len(ops) {
+		t.Fatalf("report %+v, want %d failures", report, len(ops))
+	}
+	for i, res := range report.Results {
+		if res.Status != want[i] || res.Error == nil {
+			t.Errorf("operation %d: status %d, error %v, want status %d", i, res.Status, res.Error, want[i])
+		}
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package calendar renders the reminders of the users' tasks and habits as
+// iCalendar feeds the calendar applications can subscribe to
+package calendar
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+	"github.com/iocat/donit/internal/achieving/internal/goal"
+	"github.com/iocat/donit/internal/achieving/internal/user"
+	"github.com/iocat/donit/internal/ical"
+	"github.com/iocat/donit/internal/rrule"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// productID identifies donit as the producer of the feeds
+	productID = "-//iocat//donit//EN"
+	// refreshInterval is how often the subscribers are asked to refresh
+	// the feeds
+	refreshInterval = time.Hour
+)
+
+// Feed loads the calendars of the users
+type Feed struct {
+	users       *mgo.Collection
+	goals       *mgo.Collection
+	achievables *mgo.Collection
+}
+
+// NewFeed creates a feed over the user, goal and achievable collections
+func NewFeed(users, goals, achievables *mgo.Collection) *Feed {
+	return &Feed{
+		users:       users,
+		goals:       goals,
+		achievables: achievables,
+	}
+}
+
+// Calendar is the calendar of a user: every task with a reminder and every
+// habit of the user's goals
+type Calendar struct {
+	Username string
+	// TasksAsEvents renders the tasks as events rather than to-dos, for
+	// the calendar applications ignoring the to-dos
+	TasksAsEvents bool
+
+	location *time.Location
+	goals    map[bson.ObjectId]goal.Goal
+	items    []achievable.Achievable
+}
+
+// Calendar loads the calendar of the user
+func (f *Feed) Calendar(username string) (*Calendar, error) {
+	var u user.User
+	if err := u.Retrieve(f.users, username); err != nil {
+		return nil, err
+	}
+	var gs []goal.Goal
+	if err := f.goals.Find(bson.M{"username": username}).All(&gs); err != nil {
+		return nil, fmt.Errorf("find the goals of %s: %s", username, err)
+	}
+	c := &Calendar{
+		Username: username,
+		location: u.Location(),
+		goals:    make(map[bson.ObjectId]goal.Goal, len(gs)),
+	}
+	ids := make([]bson.ObjectId, 0, len(gs))
+	for _, g := range gs {
+		c.goals[g.ID] = g
+		ids = append(ids, g.ID)
+	}
+	err := f.achievables.Find(bson.M{
+		"_goal": bson.M{"$in": ids},
+		"$or": []bson.M{
+			{"reminder": bson.M{"$exists": true}},
+			{"repreatedReminder": bson.M{"$exists": true}},
+		},
+	}).Sort("_id").All(&c.items)
+	if err != nil {
+		return nil, fmt.Errorf("find the reminders of %s: %s", username, err)
+	}
+	return c, nil
+}
+
+// Write writes the calendar as an iCalendar document, now is its time stamp
+func (c *Calendar) Write(w io.Writer, now time.Time) error {
+	iw := ical.NewWriter(w)
+	iw.Begin("VCALENDAR")
+	iw.Line("VERSION", "2.0")
+	iw.Text("PRODID", productID)
+	iw.Line("CALSCALE", "GREGORIAN")
+	iw.Line("METHOD", "PUBLISH")
+	iw.Text("X-WR-CALNAME", "donit: "+c.Username)
+	iw.Text("X-WR-TIMEZONE", c.location.String())
+	iw.Line("REFRESH-INTERVAL", ical.FormatDuration(refreshInterval), "VALUE=DURATION")
+	iw.Line("X-PUBLISHED-TTL", ical.FormatDuration(refreshInterval))
+	for _, a := range c.items {
+		g := c.goals[a.Goal]
+		switch {
+		case a.IsHabit():
+			c.writeHabit(iw, g, a, now)
+		case c.TasksAsEvents:
+			c.writeTaskEvent(iw, g, a, now)
+		default:
+			c.writeTask(iw, g, a, now)
+		}
+	}
+	iw.End("VCALENDAR")
+	return iw.Flush()
+}
+
+// writeCommon writes the properties shared by the components of an
+// achievable
+func (c *Calendar) writeCommon(iw *ical.Writer, g goal.Goal, a achievable.Achievable, now time.Time) {
+	iw.Text("UID", a.ID.Hex()+"@donit")
+	iw.Line("DTSTAMP", ical.FormatUTC(now))
+	iw.Text("SUMMARY", a.Name)
+	description := g.Name
+	if len(a.Description) != 0 {
+		description = a.Description + "\n\n" + g.Name
+	}
+	iw.Text("DESCRIPTION", description)
+	iw.Text("CATEGORIES", g.Name)
+	if g.Accessibility != goal.AccessPublic {
+		iw.Line("CLASS", "PRIVATE")
+	}
+}
+
+// writeAlarm writes an alarm going off when the achievable is due
+func writeAlarm(iw *ical.Writer, name string) {
+	iw.Begin("VALARM")
+	iw.Line("ACTION", "DISPLAY")
+	iw.Text("DESCRIPTION", name)
+	iw.Line("TRIGGER", ical.FormatDuration(0))
+	iw.End("VALARM")
+}
+
+// writeTimes writes a DATE-TIME property listing the times in the
+// calendar's time zone, in UTC when the user has not set any
+func (c *Calendar) writeTimes(iw *ical.Writer, name string, ts ...time.Time) {
+	values := make([]string, 0, len(ts))
+	for _, t := range ts {
+		if c.location == time.UTC {
+			values = append(values, ical.FormatUTC(t))
+		} else {
+			values = append(values, ical.FormatLocal(t.In(c.location)))
+		}
+	}
+	if c.location == time.UTC {
+		iw.Line(name, strings.Join(values, ","))
+		return
+	}
+	iw.Line(name, strings.Join(values, ","), "TZID="+c.location.String())
+}
+
+// writeTask writes the task as a to-do, due when its reminder ends
+func (c *Calendar) writeTask(iw *ical.Writer, g goal.Goal, a achievable.Achievable, now time.Time) {
+	iw.Begin("VTODO")
+	c.writeCommon(iw, g, a, now)
+	c.writeTimes(iw, "DTSTART", a.Reminder.At)
+	c.writeTimes(iw, "DUE", a.Reminder.At.Add(a.Reminder.Duration))
+	switch a.Status {
+	case achievable.Done:
+		iw.Line("STATUS", "COMPLETED")
+		iw.Line("PERCENT-COMPLETE", "100")
+	case achievable.InProgress:
+		iw.Line("STATUS", "IN-PROCESS")
+	default:
+		iw.Line("STATUS", "NEEDS-ACTION")
+	}
+	if !a.HasAchieved() {
+		writeAlarm(iw, a.Name)
+	}
+	iw.End("VTODO")
+}
+
+// writeTaskEvent writes the task as an event lasting the duration of its
+// reminder. The events cannot be completed, the done tasks are marked in
+// their summary
+func (c *Calendar) writeTaskEvent(iw *ical.Writer, g goal.Goal, a achievable.Achievable, now time.Time) {
+	if a.HasAchieved() {
+		a.Name = "✓ " + a.Name
+	}
+	iw.Begin("VEVENT")
+	c.writeCommon(iw, g, a, now)
+	c.writeTimes(iw, "DTSTART", a.Reminder.At)
+	iw.Line("DURATION", ical.FormatDuration(a.Reminder.Duration))
+	iw.Line("STATUS", "CONFIRMED")
+	iw.Line("TRANSP", "TRANSPARENT")
+	if !a.HasAchieved() {
+		writeAlarm(iw, a.Name)
+	}
+	iw.End("VEVENT")
+}
+
+// writeHabit writes the habit as an event recurring by its rule. The habits
+// which never occur are left out
+func (c *Calendar) writeHabit(iw *ical.Writer, g goal.Goal, a achievable.Achievable, now time.Time) {
+	rr := a.RepeatReminder
+	rule, err := rr.Recurrence()
+	if err != nil {
+		// NOTE: the stored habits were validated, a broken one is skipped
+		return
+	}
+	start, err := rrule.ParseDate(rr.StartDate)
+	if err != nil {
+		// The rules of the habits without a start date do not depend on
+		// it, they start the day the habit was created
+		start = rrule.DateOf(a.ID.Time().In(c.location))
+	}
+	at := func(d rrule.Date) time.Time {
+		return c.at(d, rr.TimeInDay)
+	}
+	// DTSTART is the first occurrence since it always counts as one
+	var first time.Time
+	rule.Each(start, start, at, func(_ rrule.Date, t time.Time) bool {
+		first = t
+		return false
+	})
+	if first.IsZero() {
+		return
+	}
+	if rule.UntilDate {
+		// UNTIL is a DATE-TIME like DTSTART: the end of its day
+		u := rrule.DateOf(rule.Until)
+		rule.Until = c.at(u, 24*time.Hour-time.Second).UTC()
+		rule.UntilDate = false
+	}
+
+	iw.Begin("VEVENT")
+	c.writeCommon(iw, g, a, now)
+	c.writeTimes(iw, "DTSTART", first)
+	iw.Line("DURATION", ical.FormatDuration(rr.Duration))
+	iw.Line("RRULE", rule.String())
+	var exdates []time.Time
+	for _, s := range rr.ExDates {
+		if d, err := rrule.ParseDate(s); err == nil {
+			exdates = append(exdates, c.at(d, rr.TimeInDay))
+		}
+	}
+	if len(exdates) != 0 {
+		c.writeTimes(iw, "EXDATE", exdates...)
+	}
+	iw.Line("STATUS", "CONFIRMED")
+	iw.Line("TRANSP", "TRANSPARENT")
+	writeAlarm(iw, a.Name)
+	iw.End("VEVENT")
+}
+
+// at returns the instant of the time in day on the date, in the calendar's
+// time zone
+func (c *Calendar) at(d rrule.Date, inDay time.Duration) time.Time {
+	hour := int(inDay / time.Hour)
+	min := int(inDay % time.Hour / time.Minute)
+	sec := int(inDay % time.Minute / time.Second)
+	return achievable.LocalTime(d.Year, d.Month, d.Day, hour, min, sec, c.location)
+}
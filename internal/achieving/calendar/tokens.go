@@ -0,0 +1,108 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calendar
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/errors"
+	"gopkg.in/mgo.v2"
+)
+
+// TokenCollection is the name of the collection of the feed tokens
+const TokenCollection = "calendarTokens"
+
+// tokenSize is the number of random bytes of a token
+const tokenSize = 32
+
+// storedToken is the hashed feed token of a user
+type storedToken struct {
+	Username string    `bson:"_id"`
+	Hash     string    `bson:"hash"`
+	Created  time.Time `bson:"created"`
+}
+
+// Access is a token of a user's feed and the URL of the feed using it
+type Access struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// Tokens stores the tokens granting access to the users' feeds. The
+// calendar applications cannot authenticate, the token in the feed's URL
+// does. Only the hashes of the tokens are stored
+type Tokens struct {
+	col *mgo.Collection
+}
+
+// NewTokens creates the token store on the collection
+func NewTokens(col *mgo.Collection) *Tokens {
+	return &Tokens{col: col}
+}
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Rotate creates a new token for the user's feed, the previous token stops
+// working
+func (t *Tokens) Rotate(username string) (string, error) {
+	b := make([]byte, tokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate a calendar token: %s", err)
+	}
+	token := hex.EncodeToString(b)
+	_, err := t.col.UpsertId(username, storedToken{
+		Username: username,
+		Hash:     hash(token),
+		Created:  time.Now(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("store the calendar token of %s: %s", username, err)
+	}
+	return token, nil
+}
+
+// Revoke revokes the token of the user's feed
+func (t *Tokens) Revoke(username string) error {
+	if err := t.col.RemoveId(username); err != nil {
+		if err == mgo.ErrNotFound {
+			return errors.NewNotFound("calendar token", username)
+		}
+		return err
+	}
+	return nil
+}
+
+// Check returns whether the token grants access to the user's feed
+func (t *Tokens) Check(username, token string) (bool, error) {
+	if len(token) == 0 {
+		return false, nil
+	}
+	var st storedToken
+	if err := t.col.FindId(username).One(&st); err != nil {
+		if err == mgo.ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return subtle.ConstantTimeCompare([]byte(st.Hash), []byte(hash(token))) == 1, nil
+}
@@ -0,0 +1,352 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package discovery lets the users discover the public goals and the other
+// users. The private accounts and the users blocking each other are left
+// out of what a user discovers
+package discovery
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/achieving/internal/block"
+	"github.com/iocat/donit/internal/achieving/internal/follow"
+	"github.com/iocat/donit/internal/achieving/internal/goal"
+	"github.com/iocat/donit/internal/achieving/internal/user"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// The orders of the explore feed
+const (
+	// SortRecent lists the latest updated goals first
+	SortRecent = "recent"
+	// SortPopular lists the goals of the most followed users first
+	SortPopular = "popular"
+)
+
+const (
+	// popularWindow is how recently the goals competing for popularity
+	// were updated
+	popularWindow = 30 * 24 * time.Hour
+	// popularCandidates is the number of the latest updated goals ranked
+	// by popularity
+	popularCandidates = 500
+)
+
+// Stats counts the relationships and the public goals of a user
+type Stats struct {
+	Followers   int `json:"followers"`
+	Following   int `json:"following"`
+	PublicGoals int `json:"publicGoals"`
+}
+
+// Profile is the public view of a user, it leaves out the email, the
+// notification settings and the other private settings
+type Profile struct {
+	Username   string  `json:"username"`
+	Firstname  string  `json:"firstName,omitempty"`
+	Lastname   string  `json:"lastName,omitempty"`
+	PictureURL *string `json:"pictureUrl,omitempty"`
+	Status     string  `json:"status,omitempty"`
+	Private    bool    `json:"private"`
+	// Stats are left out of the user search results
+	Stats *Stats `json:"stats,omitempty"`
+}
+
+func profile(u user.User) Profile {
+	return Profile{
+		Username:   u.Username,
+		Firstname:  u.Firstname,
+		Lastname:   u.Lastname,
+		PictureURL: u.PictureURL,
+		Status:     u.Status,
+		Private:    u.Private,
+	}
+}
+
+// Goal is a public goal of the explore feed
+type Goal struct {
+	// Owner is the username of the owner of the goal
+	Owner string `json:"owner"`
+	goal.Goal
+}
+
+// Explorer explores the public goals and the users
+type Explorer struct {
+	users       *mgo.Collection
+	goals       *mgo.Collection
+	achievables *mgo.Collection
+	follows     *mgo.Collection
+	blocks      *mgo.Collection
+}
+
+// New creates an explorer over the collections of the users' data
+func New(users, goals, achievables, follows, blocks *mgo.Collection) *Explorer {
+	return &Explorer{
+		users:       users,
+		goals:       goals,
+		achievables: achievables,
+		follows:     follows,
+		blocks:      blocks,
+	}
+}
+
+// hidden lists the users the viewer does not discover: the private accounts
+// and the users blocking or blocked by the viewer. An empty viewer is an
+// anonymous user
+func (x *Explorer) hidden(viewer string) ([]string, error) {
+	var hidden []string
+	if err := x.users.Find(bson.M{"private": true}).Distinct("username", &hidden); err != nil {
+		return nil, fmt.Errorf("find the private accounts: %s", err)
+	}
+	if len(viewer) == 0 {
+		return hidden, nil
+	}
+	blocked, err := block.Hidden(x.blocks, viewer)
+	if err != nil {
+		return nil, fmt.Errorf("find the blocks of %s: %s", viewer, err)
+	}
+	return append(hidden, blocked...), nil
+}
+
+// Profile returns the profile of the user as the viewer sees it. The users
+// blocking each other do not see each other, the viewers not following a
+// private account only see its username
+func (x *Explorer) Profile(username, viewer string) (*Profile, error) {
+	var u user.User
+	if err := u.Retrieve(x.users, username); err != nil {
+		return nil, err
+	}
+	if viewer == username {
+		return x.profile(u)
+	}
+	if len(viewer) != 0 {
+		blocked, err := block.Between(x.blocks, username, viewer)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return nil, errors.NewNotFound("user", username)
+		}
+	}
+	if u.Private {
+		following := false
+		if len(viewer) != 0 {
+			var err error
+			if following, err = follow.IsFollowing(x.follows, viewer, username); err != nil {
+				return nil, err
+			}
+		}
+		if !following {
+			return &Profile{Username: u.Username, Private: true}, nil
+		}
+	}
+	return x.profile(u)
+}
+
+// profile returns the whole profile of the user, along with the stats
+func (x *Explorer) profile(u user.User) (*Profile, error) {
+	p := profile(u)
+	var s Stats
+	var err error
+	if s.Followers, err = x.follows.Find(bson.M{"followee": u.Username}).Count(); err != nil {
+		return nil, err
+	}
+	if s.Following, err = x.follows.Find(bson.M{"follower": u.Username}).Count(); err != nil {
+		return nil, err
+	}
+	s.PublicGoals, err = x.goals.Find(bson.M{
+		"username":      u.Username,
+		"accessibility": goal.AccessPublic,
+	}).Count()
+	if err != nil {
+		return nil, err
+	}
+	p.Stats = &s
+	return &p, nil
+}
+
+// Users searches the users the viewer discovers by username and name, every
+// word of the text begins their username, their first name or their last
+// name
+func (x *Explorer) Users(text, viewer string, limit, offset int) ([]Profile, error) {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil, errors.NewValidate("the searched text is empty")
+	}
+	hidden, err := x.hidden(viewer)
+	if err != nil {
+		return nil, err
+	}
+	match := make([]bson.M, 0, len(words))
+	for _, w := range words {
+		prefix := bson.RegEx{Pattern: "^" + regexp.QuoteMeta(w), Options: "i"}
+		match = append(match, bson.M{"$or": []bson.M{
+			{"username": prefix},
+			{"firstName": prefix},
+			{"lastName": prefix},
+		}})
+	}
+	q := x.users.Find(bson.M{
+		"$and":     match,
+		"username": bson.M{"$nin": hidden},
+	}).Sort("username")
+	if limit > 0 {
+		q.Limit(limit)
+	}
+	if offset > 0 {
+		q.Skip(offset)
+	}
+	var us []user.User
+	if err := q.All(&us); err != nil {
+		return nil, err
+	}
+	ps := make([]Profile, 0, len(us))
+	for _, u := range us {
+		ps = append(ps, profile(u))
+	}
+	return ps, nil
+}
+
+// Goals lists the public goals the viewer discovers in the order
+func (x *Explorer) Goals(order, viewer string, limit, offset int, now time.Time) ([]Goal, error) {
+	hidden, err := x.hidden(viewer)
+	if err != nil {
+		return nil, err
+	}
+	query := bson.M{
+		"accessibility": goal.AccessPublic,
+		"username":      bson.M{"$nin": hidden},
+	}
+	var gs []goal.Goal
+	switch order {
+	case SortRecent:
+		q := x.goals.Find(query).Sort("-lastUpdated")
+		if limit > 0 {
+			q.Limit(limit)
+		}
+		if offset > 0 {
+			q.Skip(offset)
+		}
+		if err := q.All(&gs); err != nil {
+			return nil, err
+		}
+	case SortPopular:
+		query["lastUpdated"] = bson.M{"$gte": now.Add(-popularWindow)}
+		err := x.goals.Find(query).Sort("-lastUpdated").Limit(popularCandidates).All(&gs)
+		if err != nil {
+			return nil, err
+		}
+		if gs, err = x.popular(gs); err != nil {
+			return nil, err
+		}
+		gs = page(gs, limit, offset)
+	default:
+		return nil, errors.NewValidate(fmt.Sprintf("unknown order %q", order))
+	}
+	return x.entries(gs, now)
+}
+
+// popular sorts the goals by the number of followers of their owner, the
+// goals keep their order otherwise
+func (x *Explorer) popular(gs []goal.Goal) ([]goal.Goal, error) {
+	var owners []string
+	for _, g := range gs {
+		owners = append(owners, g.Username)
+	}
+	var counts []struct {
+		Username  string `bson:"_id"`
+		Followers int    `bson:"followers"`
+	}
+	err := x.follows.Pipe([]bson.M{
+		{"$match": bson.M{"followee": bson.M{"$in": owners}}},
+		{"$group": bson.M{"_id": "$followee", "followers": bson.M{"$sum": 1}}},
+	}).All(&counts)
+	if err != nil {
+		return nil, fmt.Errorf("count the followers: %s", err)
+	}
+	followers := make(map[string]int, len(counts))
+	for _, c := range counts {
+		followers[c.Username] = c.Followers
+	}
+	sort.Stable(byFollowers{gs, followers})
+	return gs, nil
+}
+
+type byFollowers struct {
+	gs        []goal.Goal
+	followers map[string]int
+}
+
+func (b byFollowers) Len() int      { return len(b.gs) }
+func (b byFollowers) Swap(i, j int) { b.gs[i], b.gs[j] = b.gs[j], b.gs[i] }
+func (b byFollowers) Less(i, j int) bool {
+	return b.followers[b.gs[i].Username] > b.followers[b.gs[j].Username]
+}
+
+func page(gs []goal.Goal, limit, offset int) []goal.Goal {
+	if offset < 0 {
+		offset = 0
+	} else if offset > len(gs) {
+		offset = len(gs)
+	}
+	gs = gs[offset:]
+	if limit > 0 && limit < len(gs) {
+		gs = gs[:limit]
+	}
+	return gs
+}
+
+// entries loads the achievables of the goals, in the time zone of their
+// owners, and tracks their progress. The goals left behind by the deleted
+// users are left out
+func (x *Explorer) entries(gs []goal.Goal, now time.Time) ([]Goal, error) {
+	locations := make(map[string]*time.Location)
+	entries := make([]Goal, 0, len(gs))
+	for _, g := range gs {
+		loc, ok := locations[g.Username]
+		if !ok {
+			var u user.User
+			err := u.Retrieve(x.users, g.Username)
+			switch {
+			case err == nil:
+				loc = u.Location()
+			case !errors.IsNotFound(err):
+				return nil, err
+			}
+			locations[g.Username] = loc
+		}
+		if loc == nil {
+			// the goal of a deleted user
+			continue
+		}
+		var err error
+		if g.ToDo, err = g.RetrieveAchievables(x.achievables, 0, 0); err != nil {
+			return nil, err
+		}
+		for i := range g.ToDo {
+			g.ToDo[i].Localize(now, loc)
+		}
+		goal.Link(g.ToDo)
+		g.Track(now)
+		entries = append(entries, Goal{Owner: g.Username, Goal: g})
+	}
+	return entries, nil
+}
@@ -17,15 +17,33 @@ package errors
 import (
 	stderr "errors"
 	"fmt"
+	"strings"
 )
 
 // ErrAuthentication represents an authentication error due to
 // wrong password
 var ErrAuthentication = stderr.New("invalid username or password")
 
+// ErrForbidden represents a user changing a resource only others may
+// change, for example someone else's comment
+var ErrForbidden = stderr.New("the user is not allowed to change the resource")
+
 // Validate represents validation error
 type Validate struct {
 	Reason string
+	// Fields lists every invalid field, it is empty when the error is not
+	// about a particular field
+	Fields []Field
+}
+
+// Field describes why a field is invalid
+type Field struct {
+	// Name is the JSON name of the field
+	Name string
+	// Rule is the validation rule the field breaks (required, email...)
+	Rule string
+	// Message is the human readable message
+	Message string
 }
 
 // Error implements the error interface
@@ -41,6 +59,30 @@ func NewValidate(reason string) error {
 	}
 }
 
+// NewValidateFields returns a Validate error listing every invalid field
+func NewValidateFields(fields []Field) error {
+	messages := make([]string, 0, len(fields))
+	for _, f := range fields {
+		messages = append(messages, f.Message)
+	}
+	return &Validate{
+		Reason: strings.Join(messages, "; "),
+		Fields: fields,
+	}
+}
+
+// ValidateFields returns the invalid fields of a Validate error
+func ValidateFields(err error) []Field {
+	switch err := err.(type) {
+	case Validate:
+		return err.Fields
+	case *Validate:
+		return err.Fields
+	default:
+		return nil
+	}
+}
+
 // IsValidate returns whether the error is the validation error or not
 func IsValidate(err error) bool {
 	switch err.(type) {
@@ -108,3 +150,33 @@ func IsDuplicated(err error) bool {
 		return false
 	}
 }
+
+// Conflict represents a change refused because the resource was changed
+// since the state the change relies on
+type Conflict struct {
+	ResourceName string
+	IdentifiedBy string
+}
+
+// Error implements the error interface
+func (c Conflict) Error() string {
+	return fmt.Sprintf("resource %s identified by %s was changed since", c.ResourceName, c.IdentifiedBy)
+}
+
+// NewConflict creates a resource conflict error
+func NewConflict(name string, key string) error {
+	return &Conflict{
+		ResourceName: name,
+		IdentifiedBy: key,
+	}
+}
+
+// IsConflict returns whether the error is a conflict or not
+func IsConflict(err error) bool {
+	switch err.(type) {
+	case Conflict, *Conflict:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+	"github.com/iocat/donit/internal/ical"
+)
+
+// The columns of the CSV files, only the name is required
+const (
+	columnID          = "id"
+	columnName        = "name"
+	columnDescription = "description"
+	columnStatus      = "status"
+	// columnDue is the time the task is reminded at, the first
+	// occurrence of a habit
+	columnDue = "due"
+	// columnDuration is a Go (1h30m) or an iCalendar (PT1H30M) duration
+	columnDuration = "duration"
+	columnRule     = "rrule"
+	// columnExDates lists the dates the habit is skipped on, separated by
+	// spaces or semicolons
+	columnExDates = "exdates"
+)
+
+var columns = map[string]bool{
+	columnID: true, columnName: true, columnDescription: true, columnStatus: true,
+	columnDue: true, columnDuration: true, columnRule: true, columnExDates: true,
+}
+
+// dueLayouts are the layouts of the due times, the ones without an offset
+// are in the owner's time zone
+var dueLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseCSV reads the rows of a CSV file, its header names the columns
+func parseCSV(r io.Reader, loc *time.Location) ([]Row, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("the header is missing")
+		}
+		return nil, err
+	}
+	index := make(map[string]int, len(header))
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if !columns[h] {
+			return nil, fmt.Errorf("unknown column %q", h)
+		}
+		if _, ok := index[h]; ok {
+			return nil, fmt.Errorf("column %q is repeated", h)
+		}
+		index[h] = i
+	}
+	if _, ok := index[columnName]; !ok {
+		return nil, fmt.Errorf("the %q column is missing", columnName)
+	}
+	var rows []Row
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(column string) string {
+			if i, ok := index[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		rows = append(rows, record2Row(line, field, loc))
+	}
+}
+
+// record2Row reads the achievable of a record
+func record2Row(line int, field func(string) string, loc *time.Location) Row {
+	a := &achievable.Achievable{
+		Name:        field(columnName),
+		Description: field(columnDescription),
+	}
+	r := Row{Line: line, Name: a.Name, achievable: a}
+	r.ExternalID = field(columnID)
+	if len(r.ExternalID) == 0 {
+		r.ExternalID = fingerprint(a.Name, field(columnDue), field(columnRule))
+	}
+	a.ExternalID = r.ExternalID
+	st := strings.Replace(strings.ToUpper(field(columnStatus)), " ", "_", -1)
+	var ok bool
+	if a.Status, ok = status(st); !ok {
+		r.invalid("status", "validateStatus", "unknown status %q", field(columnStatus))
+	}
+
+	var duration time.Duration
+	if s := field(columnDuration); len(s) != 0 {
+		var err error
+		if duration, err = time.ParseDuration(s); err != nil {
+			if duration, err = ical.ParseDuration(s); err != nil {
+				r.invalid("duration", "", "invalid duration %q", s)
+			}
+		}
+	}
+	rule := field(columnRule)
+	var due time.Time
+	if s := field(columnDue); len(s) != 0 {
+		var err error
+		if due, err = parseDue(s, loc); err != nil {
+			r.invalid("due", "", "%s", err)
+		}
+	} else if len(rule) != 0 {
+		r.invalid("due", "required", "a recurring task needs the due time of its first occurrence")
+	}
+	var exdates []time.Time
+	for _, s := range strings.FieldsFunc(field(columnExDates), func(c rune) bool { return c == ' ' || c == ';' }) {
+		t, err := time.ParseInLocation("2006-01-02", s, loc)
+		if err != nil {
+			r.invalid("exdates", "dates", "invalid date %q", s)
+			continue
+		}
+		exdates = append(exdates, t)
+	}
+	if r.Status == Invalid || due.IsZero() {
+		return r
+	}
+	schedule(&r, due, duration, rule, exdates, loc)
+	return r
+}
+
+// parseDue parses the due time in one of dueLayouts
+func parseDue(s string, loc *time.Location) (time.Time, error) {
+	for _, layout := range dueLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid due time %q, expected for example 2006-01-02 15:04", s)
+}
@@ -0,0 +1,199 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+)
+
+func TestParseCSVHeader(t *testing.T) {
+	tests := []struct {
+		in      string
+		columns bool
+	}{
+		{"", false},
+		{"name\n", true},
+		{" Name , DUE,RRule\n", true},
+		{"name,colour\n", false},
+		{"name,due,Name\n", false},
+		{"id,due\n", false},
+	}
+	for _, test := range tests {
+		_, err := parseCSV(strings.NewReader(test.in), ict)
+		if (err == nil) != test.columns {
+			t.Errorf("parseCSV(%q) error = %v, want an error %t", test.in, err, !test.columns)
+		}
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name        string
+		in          string
+		line        int
+		want        string
+		description string
+		status      string
+		errors      []string
+		warnings    int
+		reminder    *achievable.Reminder
+		repeat      *achievable.RepeatReminder
+	}{{
+		name:        "quoted comma and quotes",
+		in:          "name,description\n\"Buy milk, eggs\",\"say \"\"now\"\"\"\n",
+		line:        2,
+		want:        "Buy milk, eggs",
+		description: `say "now"`,
+		status:      achievable.NotDone,
+	}, {
+		name:        "quoted line break",
+		in:          "name,description\n\"Read\",\"two\nlines\"\n",
+		line:        2,
+		want:        "Read",
+		description: "two\nlines",
+		status:      achievable.NotDone,
+	}, {
+		name:   "short record",
+		in:     "id,name,description\nx1,Run\n",
+		line:   2,
+		want:   "Run",
+		status: achievable.NotDone,
+	}, {
+		name:   "status with a space",
+		in:     "name,status\nRun,in progress\n",
+		line:   2,
+		want:   "Run",
+		status: achievable.InProgress,
+	}, {
+		name:   "unknown status",
+		in:     "name,status\nRun,later\n",
+		line:   2,
+		want:   "Run",
+		errors: []string{"status"},
+	}, {
+		name:     "due in the owner's zone",
+		in:       "name,due,duration\nRun,2016-01-05 09:30,PT1H30M\n",
+		line:     2,
+		want:     "Run",
+		status:   achievable.NotDone,
+		reminder: &achievable.Reminder{At: time.Date(2016, 1, 5, 9, 30, 0, 0, ict), Duration: 90 * time.Minute},
+	}, {
+		name:     "due with an offset",
+		in:       "name,due,duration\nRun,2016-01-05T09:30:00Z,45m\n",
+		line:     2,
+		want:     "Run",
+		status:   achievable.NotDone,
+		reminder: &achievable.Reminder{At: time.Date(2016, 1, 5, 9, 30, 0, 0, time.UTC), Duration: 45 * time.Minute},
+	}, {
+		name:   "invalid due and duration",
+		in:     "name,due,duration\nRun,tomorrow,long\n",
+		line:   2,
+		want:   "Run",
+		errors: []string{"duration", "due"},
+	}, {
+		name: "habit",
+		in:   "name,due,duration,rrule,exdates\nRun,2016-01-04 06:15,30m,FREQ=WEEKLY;BYDAY=MO;INTERVAL=1,2016-01-11; 2016-01-18\n",
+		line: 2, want: "Run", status: achievable.NotDone,
+		repeat: &achievable.RepeatReminder{
+			Rule:      "FREQ=WEEKLY;BYDAY=MO",
+			StartDate: "2016-01-04",
+			TimeInDay: 6*time.Hour + 15*time.Minute,
+			Duration:  30 * time.Minute,
+			ExDates:   []string{"2016-01-11", "2016-01-18"},
+		},
+	}, {
+		name:   "habit without a due time",
+		in:     "name,rrule\nRun,FREQ=DAILY\n",
+		line:   2,
+		want:   "Run",
+		errors: []string{"due"},
+	}, {
+		name:   "invalid exdate",
+		in:     "name,due,rrule,exdates\nRun,2016-01-04,FREQ=DAILY,01/11/2016\n",
+		line:   2,
+		want:   "Run",
+		errors: []string{"exdates"},
+	}, {
+		name:     "unsupported rule",
+		in:       "name,due,rrule\nRun,2016-01-04 06:00,FREQ=HOURLY\n",
+		line:     2,
+		want:     "Run",
+		status:   achievable.NotDone,
+		warnings: 1,
+		reminder: &achievable.Reminder{At: time.Date(2016, 1, 4, 6, 0, 0, 0, ict)},
+	}, {
+		name:   "second record",
+		in:     "name\nRun\n\"Swim\"\n",
+		line:   3,
+		want:   "Swim",
+		status: achievable.NotDone,
+	}}
+	for _, test := range tests {
+		rows, err := parseCSV(strings.NewReader(test.in), ict)
+		if err != nil {
+			t.Errorf("%s: parseCSV() error = %v", test.name, err)
+			continue
+		}
+		r := rows[len(rows)-1]
+		a := r.achievable
+		if r.Line != test.line || r.Name != test.want || a.Name != test.want || a.Description != test.description {
+			t.Errorf("%s: line %d, name %q, description %q, want line %d, name %q, description %q",
+				test.name, r.Line, a.Name, a.Description, test.line, test.want, test.description)
+		}
+		if !reflect.DeepEqual(fields(r), test.errors) {
+			t.Errorf("%s: errors %v, want errors on %v", test.name, r.Errors, test.errors)
+			continue
+		}
+		if len(test.errors) != 0 {
+			continue
+		}
+		if a.Status != test.status || len(r.Warnings) != test.warnings {
+			t.Errorf("%s: status %q, warnings %q, want status %q, %d warnings",
+				test.name, a.Status, r.Warnings, test.status, test.warnings)
+		}
+		if !reflect.DeepEqual(a.Reminder, test.reminder) {
+			t.Errorf("%s: reminder %+v, want %+v", test.name, a.Reminder, test.reminder)
+		}
+		if !reflect.DeepEqual(a.RepeatReminder, test.repeat) {
+			t.Errorf("%s: repeated reminder %+v, want %+v", test.name, a.RepeatReminder, test.repeat)
+		}
+	}
+}
+
+func TestCSVExternalID(t *testing.T) {
+	in := "id,name,due\n" +
+		"t-1,Run,2016-01-04\n" +
+		",Swim,2016-01-04\n" +
+		",Swim,2016-01-04\n" +
+		",Swim,2016-01-05\n"
+	rows, err := parseCSV(strings.NewReader(in), ict)
+	if err != nil {
+		t.Fatalf("parseCSV() error = %v", err)
+	}
+	if id := rows[0].ExternalID; id != "t-1" || rows[0].achievable.ExternalID != id {
+		t.Errorf("external ID %q, want t-1", id)
+	}
+	if rows[1].ExternalID != rows[2].ExternalID {
+		t.Errorf("the same rows have the external IDs %q and %q", rows[1].ExternalID, rows[2].ExternalID)
+	}
+	if rows[1].ExternalID == rows[3].ExternalID {
+		t.Errorf("the rows due on different days share the external ID %q", rows[1].ExternalID)
+	}
+}
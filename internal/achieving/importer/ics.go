@@ -0,0 +1,140 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+	"github.com/iocat/donit/internal/ical"
+)
+
+// parseICS reads the VTODO and VEVENT components of an iCalendar file
+func parseICS(r io.Reader, loc *time.Location) ([]Row, error) {
+	cal, err := ical.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	if cal.Name != "VCALENDAR" {
+		return nil, fmt.Errorf("expected a VCALENDAR, got a %s", cal.Name)
+	}
+	var rows []Row
+	for _, c := range cal.Components {
+		if c.Name == "VTODO" || c.Name == "VEVENT" {
+			rows = append(rows, component(c, loc))
+		}
+	}
+	return rows, nil
+}
+
+// text returns the unescaped TEXT value of the property, if any
+func text(c *ical.Component, name string) string {
+	p, ok := c.Get(name)
+	if !ok {
+		return ""
+	}
+	return ical.UnescapeText(p.Value)
+}
+
+// component reads the achievable of a VTODO or a VEVENT. DTSTART, or DUE
+// for the to-dos without a start, is the reminder and the time until DUE,
+// DTEND or for DURATION its duration
+func component(c *ical.Component, loc *time.Location) Row {
+	a := &achievable.Achievable{
+		Name:        text(c, "SUMMARY"),
+		Description: text(c, "DESCRIPTION"),
+	}
+	r := Row{Line: c.Line, Name: a.Name, achievable: a}
+	r.ExternalID = text(c, "UID")
+	if len(r.ExternalID) == 0 {
+		r.ExternalID = fingerprint(c.Name, a.Name, text(c, "DTSTART"), text(c, "DUE"), text(c, "RRULE"))
+	}
+	a.ExternalID = r.ExternalID
+	if _, ok := c.Get("RECURRENCE-ID"); ok {
+		r.invalid("", "", "the changes to single occurrences are not supported")
+		return r
+	}
+	st := text(c, "STATUS")
+	if st == "CANCELLED" {
+		r.invalid("status", "validateStatus", "the %s is cancelled", c.Name)
+		return r
+	}
+	var ok bool
+	if a.Status, ok = status(st); !ok {
+		r.invalid("status", "validateStatus", "unknown status %q", st)
+		return r
+	}
+
+	end := "DTEND"
+	if c.Name == "VTODO" {
+		end = "DUE"
+	}
+	var start, until time.Time
+	var err error
+	if p, ok := c.Get("DTSTART"); ok {
+		if start, _, err = p.Time(loc); err != nil {
+			r.invalid("reminder", "", "%s", err)
+			return r
+		}
+	}
+	if p, ok := c.Get(end); ok {
+		if until, _, err = p.Time(loc); err != nil {
+			r.invalid("reminder", "", "%s", err)
+			return r
+		}
+	}
+	var duration time.Duration
+	switch p, ok := c.Get("DURATION"); {
+	case ok:
+		if duration, err = ical.ParseDuration(p.Value); err != nil {
+			r.invalid("reminder", "", "%s", err)
+			return r
+		}
+	case !start.IsZero() && !until.IsZero():
+		duration = until.Sub(start)
+	}
+	if start.IsZero() {
+		start = until
+	}
+
+	rules := c.All("RRULE")
+	if len(rules) > 1 {
+		r.warn("only the first of the %d recurrence rules is imported", len(rules))
+	}
+	var rule string
+	if len(rules) != 0 {
+		rule = rules[0].Value
+	}
+	if start.IsZero() {
+		if len(rule) != 0 {
+			r.invalid("repeatedReminder", "", "a recurring %s needs a DTSTART", c.Name)
+		}
+		// a task without any reminder
+		return r
+	}
+	var exdates []time.Time
+	for _, p := range c.All("EXDATE") {
+		ts, _, err := p.Times(loc)
+		if err != nil {
+			r.invalid("repeatedReminder", "", "%s", err)
+			return r
+		}
+		exdates = append(exdates, ts...)
+	}
+	schedule(&r, start, duration, rule, exdates, loc)
+	return r
+}
@@ -0,0 +1,182 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+)
+
+// calendar wraps the lines of the components in a VCALENDAR
+func calendar(lines ...string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" + strings.Join(lines, "\r\n") + "\r\nEND:VCALENDAR\r\n"
+}
+
+func TestParseICS(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       []string
+		want     string
+		id       string
+		status   string
+		errors   int
+		warnings int
+		reminder *achievable.Reminder
+		repeat   *achievable.RepeatReminder
+	}{{
+		name: "to-do due",
+		in: []string{"BEGIN:VTODO", "UID:todo-1", "SUMMARY:Pay the rent\\, on time",
+			"STATUS:IN-PROCESS", "DUE:20160105T093000Z", "END:VTODO"},
+		want: "Pay the rent, on time", id: "todo-1", status: achievable.InProgress,
+		reminder: &achievable.Reminder{At: time.Date(2016, 1, 5, 9, 30, 0, 0, time.UTC)},
+	}, {
+		name: "folded summary",
+		in: []string{"BEGIN:VTODO", "UID:todo-2", "SUMMARY:Water the", "  plants", "STATUS:COMPLETED",
+			"END:VTODO"},
+		want: "Water the plants", id: "todo-2", status: achievable.Done,
+	}, {
+		name: "event with an end",
+		in: []string{"BEGIN:VEVENT", "UID:event-1", "SUMMARY:Meet", "DTSTART:20160105T090000",
+			"DTEND:20160105T103000", "END:VEVENT"},
+		want: "Meet", id: "event-1", status: achievable.NotDone,
+		reminder: &achievable.Reminder{At: time.Date(2016, 1, 5, 9, 0, 0, 0, ict), Duration: 90 * time.Minute},
+	}, {
+		name: "duration over the end",
+		in: []string{"BEGIN:VEVENT", "UID:event-2", "SUMMARY:Meet", "DTSTART:20160105T090000",
+			"DTEND:20160105T103000", "DURATION:PT15M", "END:VEVENT"},
+		want: "Meet", id: "event-2", status: achievable.NotDone,
+		reminder: &achievable.Reminder{At: time.Date(2016, 1, 5, 9, 0, 0, 0, ict), Duration: 15 * time.Minute},
+	}, {
+		name: "end before the start",
+		in: []string{"BEGIN:VEVENT", "UID:event-3", "SUMMARY:Meet", "DTSTART:20160105T090000",
+			"DTEND:20160105T080000", "END:VEVENT"},
+		want: "Meet", id: "event-3", status: achievable.NotDone, warnings: 1,
+		reminder: &achievable.Reminder{At: time.Date(2016, 1, 5, 9, 0, 0, 0, ict)},
+	}, {
+		name: "habit",
+		in: []string{"BEGIN:VEVENT", "UID:event-4", "SUMMARY:Run", "DTSTART:20160103T230000Z",
+			"DURATION:PT30M", "RRULE:FREQ=WEEKLY;BYDAY=MO,WE", "EXDATE:20160105T230000Z,20160112T230000Z",
+			"END:VEVENT"},
+		want: "Run", id: "event-4", status: achievable.NotDone,
+		repeat: &achievable.RepeatReminder{
+			Rule:      "FREQ=WEEKLY;BYDAY=MO,WE",
+			StartDate: "2016-01-04",
+			TimeInDay: 6 * time.Hour,
+			Duration:  30 * time.Minute,
+			ExDates:   []string{"2016-01-06", "2016-01-13"},
+		},
+	}, {
+		name: "several rules",
+		in: []string{"BEGIN:VEVENT", "UID:event-5", "SUMMARY:Run", "DTSTART;VALUE=DATE:20160104",
+			"RRULE:FREQ=DAILY", "RRULE:FREQ=WEEKLY", "END:VEVENT"},
+		want: "Run", id: "event-5", status: achievable.NotDone, warnings: 1,
+		repeat: &achievable.RepeatReminder{Rule: "FREQ=DAILY", StartDate: "2016-01-04"},
+	}, {
+		name: "unsupported rule",
+		in: []string{"BEGIN:VEVENT", "UID:event-6", "SUMMARY:Run", "DTSTART:20160104T060000",
+			"RRULE:FREQ=MINUTELY", "END:VEVENT"},
+		want: "Run", id: "event-6", status: achievable.NotDone, warnings: 1,
+		reminder: &achievable.Reminder{At: time.Date(2016, 1, 4, 6, 0, 0, 0, ict)},
+	}, {
+		name: "rule without a start",
+		in:   []string{"BEGIN:VTODO", "UID:todo-3", "SUMMARY:Run", "RRULE:FREQ=DAILY", "END:VTODO"},
+		want: "Run", id: "todo-3", errors: 1,
+	}, {
+		name: "cancelled",
+		in: []string{"BEGIN:VEVENT", "UID:event-7", "SUMMARY:Meet", "STATUS:CANCELLED",
+			"DTSTART:20160105T090000", "END:VEVENT"},
+		want: "Meet", id: "event-7", errors: 1,
+	}, {
+		name: "changed occurrence",
+		in: []string{"BEGIN:VEVENT", "UID:event-4", "SUMMARY:Run", "RECURRENCE-ID:20160106T230000Z",
+			"DTSTART:20160107T000000Z", "END:VEVENT"},
+		want: "Run", id: "event-4", errors: 1,
+	}, {
+		name: "invalid start",
+		in: []string{"BEGIN:VEVENT", "UID:event-8", "SUMMARY:Meet", "DTSTART:next monday",
+			"END:VEVENT"},
+		want: "Meet", id: "event-8", errors: 1,
+	}}
+	for _, test := range tests {
+		rows, err := parseICS(strings.NewReader(calendar(test.in...)), ict)
+		if err != nil {
+			t.Errorf("%s: parseICS() error = %v", test.name, err)
+			continue
+		}
+		if len(rows) != 1 {
+			t.Errorf("%s: %d rows, want 1", test.name, len(rows))
+			continue
+		}
+		r, a := rows[0], rows[0].achievable
+		if r.Line != 3 || r.Name != test.want || a.Name != test.want || r.ExternalID != test.id || a.ExternalID != test.id {
+			t.Errorf("%s: line %d, name %q, external ID %q, want line 3, name %q, external ID %q",
+				test.name, r.Line, r.Name, r.ExternalID, test.want, test.id)
+		}
+		if len(r.Errors) != test.errors {
+			t.Errorf("%s: errors %v, want %d errors", test.name, r.Errors, test.errors)
+			continue
+		}
+		if test.errors != 0 {
+			if r.Status != Invalid {
+				t.Errorf("%s: status %q, want %q", test.name, r.Status, Invalid)
+			}
+			continue
+		}
+		if a.Status != test.status || len(r.Warnings) != test.warnings {
+			t.Errorf("%s: status %q, warnings %q, want status %q, %d warnings",
+				test.name, a.Status, r.Warnings, test.status, test.warnings)
+		}
+		if !reflect.DeepEqual(a.Reminder, test.reminder) {
+			t.Errorf("%s: reminder %+v, want %+v", test.name, a.Reminder, test.reminder)
+		}
+		if !reflect.DeepEqual(a.RepeatReminder, test.repeat) {
+			t.Errorf("%s: repeated reminder %+v, want %+v", test.name, a.RepeatReminder, test.repeat)
+		}
+	}
+}
+
+func TestParseICSComponents(t *testing.T) {
+	in := calendar(
+		"BEGIN:VTIMEZONE", "TZID:Asia/Ho_Chi_Minh", "END:VTIMEZONE",
+		"BEGIN:VTODO", "SUMMARY:Run", "DUE:20160105T093000Z", "END:VTODO",
+		"BEGIN:VJOURNAL", "SUMMARY:Notes", "END:VJOURNAL",
+		"BEGIN:VTODO", "SUMMARY:Run", "DUE:20160105T093000Z", "END:VTODO",
+		"BEGIN:VEVENT", "SUMMARY:Run", "DTSTART:20160105T093000Z", "END:VEVENT",
+	)
+	rows, err := parseICS(strings.NewReader(in), ict)
+	if err != nil {
+		t.Fatalf("parseICS() error = %v", err)
+	}
+	var lines []int
+	for _, r := range rows {
+		lines = append(lines, r.Line)
+	}
+	if want := []int{6, 13, 17}; !reflect.DeepEqual(lines, want) {
+		t.Fatalf("rows on the lines %v, want %v", lines, want)
+	}
+	if rows[0].ExternalID != rows[1].ExternalID {
+		t.Errorf("the same to-dos have the external IDs %q and %q", rows[0].ExternalID, rows[1].ExternalID)
+	}
+	if rows[0].ExternalID == rows[2].ExternalID {
+		t.Errorf("a to-do and an event share the external ID %q", rows[0].ExternalID)
+	}
+	if _, err := parseICS(strings.NewReader("BEGIN:VTODO\r\nSUMMARY:Run\r\nEND:VTODO\r\n"), ict); err == nil {
+		t.Errorf("parseICS() of a VTODO outside a VCALENDAR, want an error")
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package importer imports the tasks and habits of iCalendar and CSV files
+// into a goal
+package importer
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving"
+	"github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+	concr "github.com/iocat/donit/internal/achieving/internal/concreteachieving"
+	"github.com/iocat/donit/internal/achieving/validator"
+)
+
+const (
+	// ICS is the iCalendar format, its VTODO and VEVENT are imported
+	ICS = "ics"
+	// CSV is the CSV format, one task per row under a header naming the
+	// columns
+	CSV = "csv"
+)
+
+const (
+	// Created is a row imported as a new achievable
+	Created = "created"
+	// Ready is a row a dry run would have imported
+	Ready = "ready"
+	// Duplicate is a row imported before, it is skipped
+	Duplicate = "duplicate"
+	// Invalid is a row failing the validation, it is skipped
+	Invalid = "invalid"
+)
+
+// Issue is a problem of a row
+type Issue struct {
+	// Field is the JSON name of the achievable's field at fault, if any
+	Field   string `json:"field,omitempty"`
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message"`
+}
+
+// Row is an achievable read from the file and the outcome of its import
+type Row struct {
+	// Line is the line of the component in the iCalendar files and the
+	// number of the record, the header being the first, in the CSV files
+	Line       int    `json:"line"`
+	ExternalID string `json:"externalId"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	// ID is the ID of the created achievable
+	ID     string  `json:"id,omitempty"`
+	Errors []Issue `json:"errors,omitempty"`
+	// Warnings tell what could not be imported as is
+	Warnings []string `json:"warnings,omitempty"`
+
+	achievable *achievable.Achievable
+}
+
+// invalid marks the row invalid because of the issue
+func (r *Row) invalid(field, rule, format string, args ...interface{}) {
+	r.Status = Invalid
+	r.Errors = append(r.Errors, Issue{
+		Field:   field,
+		Rule:    rule,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (r *Row) warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Report is the outcome of an import
+type Report struct {
+	Format     string `json:"format"`
+	DryRun     bool   `json:"dryRun"`
+	Created    int    `json:"created"`
+	Ready      int    `json:"ready"`
+	Duplicates int    `json:"duplicates"`
+	Invalid    int    `json:"invalid"`
+	Rows       []Row  `json:"rows"`
+}
+
+// Parse reads the rows of the file in the format. The floating times and
+// the dates without a time zone are in loc. Only a malformed file is an
+// error, the invalid rows are reported as such
+func Parse(r io.Reader, format string, loc *time.Location) ([]Row, error) {
+	var (
+		rows []Row
+		err  error
+	)
+	switch format {
+	case ICS:
+		rows, err = parseICS(r, loc)
+	case CSV:
+		rows, err = parseCSV(r, loc)
+	default:
+		return nil, errors.NewValidate(fmt.Sprintf("unknown import format %q", format))
+	}
+	if err != nil {
+		return nil, errors.NewValidate(fmt.Sprintf("read the %s file: %s", format, err))
+	}
+	for i := range rows {
+		check(&rows[i])
+	}
+	return rows, nil
+}
+
+// check validates the achievable of the row as the API does
+func check(r *Row) {
+	if r.Status == Invalid {
+		return
+	}
+	a := r.achievable
+	if a.RepeatReminder != nil {
+		if err := a.RepeatReminder.Normalize(); err != nil {
+			r.invalid("repeatedReminder", "rrule", "%s", err)
+			return
+		}
+	}
+	fields, err := validator.Fields(&concr.Achievable{Achievable: *a})
+	if err != nil {
+		r.invalid("", "", "%s", err)
+		return
+	}
+	for _, f := range fields {
+		r.invalid(f.Name, f.Rule, "%s", f.Message)
+	}
+}
+
+// Import adds the valid rows to the goal, a dry run only reports what would
+// be added. The rows whose external ID is already in the goal, or earlier
+// in the file, are skipped so that importing a file again adds nothing
+func Import(g achieving.Goal, format string, rows []Row, dryRun bool) (*Report, error) {
+	seen, err := externalIDs(g)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Format: format, DryRun: dryRun, Rows: rows}
+	for i := range rows {
+		r := &rows[i]
+		switch {
+		case r.Status == Invalid:
+			report.Invalid++
+		case seen[r.ExternalID]:
+			r.Status = Duplicate
+			report.Duplicates++
+		case dryRun:
+			seen[r.ExternalID] = true
+			r.Status = Ready
+			report.Ready++
+		default:
+			id, err := g.AddAchievable(&concr.Achievable{Achievable: *r.achievable})
+			if err != nil {
+				if errors.IsValidate(err) {
+					r.invalid("", "", "%s", err)
+					report.Invalid++
+					continue
+				}
+				return report, err
+			}
+			seen[r.ExternalID] = true
+			r.Status, r.ID = Created, id
+			report.Created++
+		}
+	}
+	return report, nil
+}
+
+// externalIDs returns the external IDs of the goal's achievables
+func externalIDs(g achieving.Goal) (map[string]bool, error) {
+	as, err := g.RetrieveAchievables(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool, len(as))
+	for _, a := range as {
+		if a, ok := a.(*concr.Achievable); ok && len(a.ExternalID) != 0 {
+			ids[a.ExternalID] = true
+		}
+	}
+	return ids, nil
+}
+
+// status maps the status of the imported tasks to the achievables' one
+func status(s string) (string, bool) {
+	switch s {
+	case "", "NEEDS-ACTION", "TENTATIVE", "CONFIRMED", achievable.NotDone:
+		return achievable.NotDone, true
+	case "IN-PROCESS", achievable.InProgress:
+		return achievable.InProgress, true
+	case "COMPLETED", achievable.Done:
+		return achievable.Done, true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var ict = time.FixedZone("ICT", 7*60*60)
+
+// fields lists the fields of the row's errors
+func fields(r Row) []string {
+	var fs []string
+	for _, e := range r.Errors {
+		fs = append(fs, e.Field)
+	}
+	return fs
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		format, in string
+	}{
+		{"xlsx", "name\nRun\n"},
+		{CSV, ""},
+		{CSV, "name,color\nRun,red\n"},
+		{ICS, "BEGIN:VCALENDAR\r\n"},
+		{ICS, "BEGIN:VTODO\r\nSUMMARY:Run\r\nEND:VTODO\r\n"},
+	}
+	for _, test := range tests {
+		if rows, err := Parse(strings.NewReader(test.in), test.format, ict); err == nil {
+			t.Errorf("Parse(%q, %s) = %d rows, want an error", test.in, test.format, len(rows))
+		}
+	}
+}
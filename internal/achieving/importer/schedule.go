@@ -0,0 +1,64 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+	"github.com/iocat/donit/internal/rrule"
+)
+
+// fingerprint identifies the rows without an ID by their content
+func fingerprint(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// schedule sets the reminder of the row's achievable: a habit if the rule
+// can be represented, a task reminded once at start otherwise. The habits
+// are reminded at the wall clock of start in the owner's time zone loc
+func schedule(r *Row, start time.Time, duration time.Duration, rule string, exdates []time.Time, loc *time.Location) {
+	if duration < 0 {
+		r.warn("the end is before the start, the duration is dropped")
+		duration = 0
+	}
+	a := r.achievable
+	if len(rule) != 0 {
+		parsed, err := rrule.Parse(rule)
+		if err == nil {
+			local := start.In(loc)
+			h, m, s := local.Clock()
+			a.RepeatReminder = &achievable.RepeatReminder{
+				Rule:      parsed.String(),
+				StartDate: rrule.DateOf(local).String(),
+				TimeInDay: time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second,
+				Duration:  duration,
+			}
+			for _, t := range exdates {
+				a.RepeatReminder.ExDates = append(a.RepeatReminder.ExDates, rrule.DateOf(t.In(loc)).String())
+			}
+			return
+		}
+		r.warn("the recurrence %q is not supported (%s), only the first occurrence is imported", rule, err)
+	}
+	a.Reminder = &achievable.Reminder{
+		At:       start,
+		Duration: duration,
+	}
+}
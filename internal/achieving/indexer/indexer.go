@@ -0,0 +1,260 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package indexer keeps the search index in sync with the goals and the
+// achievables of the store
+package indexer
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+	"github.com/iocat/donit/internal/achieving/internal/goal"
+	"github.com/iocat/donit/internal/events"
+	"github.com/iocat/donit/internal/logger"
+	"github.com/iocat/donit/internal/search"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// backlog is the number of events waiting to be handled, the index is
+// rebuilt when events are dropped
+const backlog = 256
+
+// Indexer indexes the goals and the achievables when the events of the store
+// tell they changed. The index is rebuilt from the collections when the
+// indexer starts and every interval, which catches up with the changes made
+// by the other processes
+type Indexer struct {
+	index       search.Index
+	goals       *mgo.Collection
+	achievables *mgo.Collection
+	interval    time.Duration
+	log         *logger.Logger
+
+	events chan events.Event
+	// stale is set to 1 when events were dropped
+	stale int32
+}
+
+// New creates an indexer rebuilding the index every interval, zero only
+// builds it when the indexer starts
+func New(index search.Index, goals, achievables *mgo.Collection, interval time.Duration, log *logger.Logger) *Indexer {
+	return &Indexer{
+		index:       index,
+		goals:       goals,
+		achievables: achievables,
+		interval:    interval,
+		log:         log,
+		events:      make(chan events.Event, backlog),
+	}
+}
+
+// Handle queues the event to be handled by Run, it never blocks so that it
+// can subscribe to an events.Bus
+func (ix *Indexer) Handle(e events.Event) {
+	select {
+	case ix.events <- e:
+	default:
+		atomic.StoreInt32(&ix.stale, 1)
+		ix.log.Error("indexer backlog is full, the index will be rebuilt", "kind", string(e.Kind))
+	}
+}
+
+// Run builds the index then handles the queued events until stop is closed
+func (ix *Indexer) Run(stop <-chan struct{}) {
+	ix.rebuild()
+	var tick <-chan time.Time
+	if ix.interval > 0 {
+		ticker := time.NewTicker(ix.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick:
+			ix.rebuild()
+		case e := <-ix.events:
+			if atomic.CompareAndSwapInt32(&ix.stale, 1, 0) {
+				ix.rebuild()
+			}
+			if err := ix.handle(e); err != nil {
+				ix.log.Error("index event", "kind", string(e.Kind), "goal", e.Goal, "err", err)
+			}
+		}
+	}
+}
+
+func (ix *Indexer) rebuild() {
+	start := time.Now()
+	n, err := ix.Rebuild()
+	if err != nil {
+		ix.log.Error("rebuild the search index", "err", err)
+		return
+	}
+	ix.log.Debug("rebuilt the search index", "documents", n, "duration", time.Since(start))
+}
+
+// Rebuild replaces the index with the goals and the achievables of the
+// collections and returns the number of documents indexed
+func (ix *Indexer) Rebuild() (int, error) {
+	var docs []search.Document
+	goals := make(map[bson.ObjectId]goal.Goal)
+	var g goal.Goal
+	iter := ix.goals.Find(nil).Iter()
+	for iter.Next(&g) {
+		goals[g.ID] = g
+		docs = append(docs, goalDocument(g))
+		g = goal.Goal{}
+	}
+	if err := iter.Close(); err != nil {
+		return 0, fmt.Errorf("read the goals: %s", err)
+	}
+	var a achievable.Achievable
+	iter = ix.achievables.Find(nil).Iter()
+	for iter.Next(&a) {
+		// the achievables left behind by a deleted goal are not found
+		if g, ok := goals[a.Goal]; ok {
+			docs = append(docs, achievableDocument(g, a))
+		}
+		a = achievable.Achievable{}
+	}
+	if err := iter.Close(); err != nil {
+		return 0, fmt.Errorf("read the achievables: %s", err)
+	}
+	return len(docs), ix.index.Replace(docs)
+}
+
+func (ix *Indexer) handle(e events.Event) error {
+	switch e.Kind {
+	case events.GoalCreated, events.GoalUpdated, events.GoalCompleted:
+		return ix.goal(e.Goal)
+	case events.GoalDeleted:
+		return ix.index.DeleteGoal(e.Goal)
+	case events.AchievableCreated, events.AchievableUpdated, events.AchievableCompleted:
+		return ix.achievable(e.Goal, e.Achievable)
+	case events.AchievableDeleted:
+		return ix.index.Delete(e.Achievable)
+	}
+	return nil
+}
+
+// find finds the document by id, it returns false if there is none
+func find(col *mgo.Collection, id string, v interface{}) (bool, error) {
+	if !bson.IsObjectIdHex(id) {
+		return false, fmt.Errorf("invalid id %q", id)
+	}
+	if err := col.FindId(bson.ObjectIdHex(id)).One(v); err != nil {
+		if err == mgo.ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// goal indexes the goal and its achievables, which are as visible as it is
+func (ix *Indexer) goal(id string) error {
+	var g goal.Goal
+	ok, err := find(ix.goals, id, &g)
+	if err != nil || !ok {
+		// deleted since
+		return err
+	}
+	if err := ix.index.Put(goalDocument(g)); err != nil {
+		return err
+	}
+	as, err := g.RetrieveAchievables(ix.achievables, 0, 0)
+	if err != nil {
+		return err
+	}
+	for _, a := range as {
+		if err := ix.index.Put(achievableDocument(g, a)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// achievable indexes the achievable
+func (ix *Indexer) achievable(gid, id string) error {
+	var g goal.Goal
+	ok, err := find(ix.goals, gid, &g)
+	if err != nil || !ok {
+		return err
+	}
+	var a achievable.Achievable
+	if ok, err = find(ix.achievables, id, &a); err != nil || !ok {
+		return err
+	}
+	return ix.index.Put(achievableDocument(g, a))
+}
+
+// access returns who can see the documents of the goal, the way
+// goal.Visible tells
+func access(g goal.Goal) string {
+	switch g.Accessibility {
+	case goal.AccessPublic:
+		return search.AccessPublic
+	case goal.AccessForFollowers:
+		return search.AccessFollowers
+	case goal.AccessWorkspace:
+		return search.AccessWorkspace
+	}
+	return ""
+}
+
+func workspaceID(g goal.Goal) string {
+	if len(g.Workspace) == 0 {
+		return ""
+	}
+	return g.Workspace.Hex()
+}
+
+func goalDocument(g goal.Goal) search.Document {
+	return search.Document{
+		ID:          g.ID.Hex(),
+		Kind:        search.KindGoal,
+		Owner:       g.Username,
+		Goal:        g.ID.Hex(),
+		Status:      g.Status,
+		Access:      access(g),
+		Workspace:   workspaceID(g),
+		Name:        g.Name,
+		Description: g.Description,
+	}
+}
+
+func achievableDocument(g goal.Goal, a achievable.Achievable) search.Document {
+	doc := search.Document{
+		ID:          a.ID.Hex(),
+		Kind:        search.KindAchievable,
+		Owner:       g.Username,
+		Goal:        g.ID.Hex(),
+		Type:        search.TypeTask,
+		Status:      a.Status,
+		Access:      access(g),
+		Workspace:   workspaceID(g),
+		Name:        a.Name,
+		Description: a.Description,
+	}
+	if a.IsHabit() {
+		doc.Type = search.TypeHabit
+	}
+	return doc
+}
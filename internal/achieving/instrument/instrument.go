@@ -0,0 +1,475 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package instrument decorates the achieving interfaces so that every
+// operation on the store is reported to an Observer
+package instrument
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving"
+)
+
+// Observer gets notified after every store operation completes
+type Observer interface {
+	// Observe receives the operation name (for example "User.CreateGoal"),
+	// the time it took and the error it returned, if any
+	Observe(op string, took time.Duration, err error)
+}
+
+// ObserverFunc adapts a function to the Observer interface
+type ObserverFunc func(op string, took time.Duration, err error)
+
+// Observe implements Observer
+func (f ObserverFunc) Observe(op string, took time.Duration, err error) {
+	f(op, took, err)
+}
+
+// Observers fans an observation out to every observer in the list
+type Observers []Observer
+
+// Observe implements Observer
+func (os Observers) Observe(op string, took time.Duration, err error) {
+	for _, o := range os {
+		o.Observe(op, took, err)
+	}
+}
+
+// observe reports the operation started at start to the observer
+func observe(o Observer, op string, start time.Time, err error) {
+	o.Observe(op, time.Since(start), err)
+}
+
+// NewUserStore wraps the store so that the store, the users it returns and
+// their goals are all observed by o
+func NewUserStore(s achieving.UserStore, o Observer) achieving.UserStore {
+	return &userStore{
+		UserStore: s,
+		o:         o,
+	}
+}
+
+type userStore struct {
+	achieving.UserStore
+	o Observer
+}
+
+func (s *userStore) RetrieveUser(username string) (u achieving.User, err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.RetrieveUser", start, err) }(time.Now())
+	u, err = s.UserStore.RetrieveUser(username)
+	if err != nil {
+		return nil, err
+	}
+	return &user{User: u, o: s.o}, nil
+}
+
+func (s *userStore) CreateNewUser(u achieving.User, password string) (username string, err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.CreateNewUser", start, err) }(time.Now())
+	return s.UserStore.CreateNewUser(u, password)
+}
+
+func (s *userStore) DeleteUser(username, password string) (err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.DeleteUser", start, err) }(time.Now())
+	return s.UserStore.DeleteUser(username, password)
+}
+
+func (s *userStore) UpdateUser(u achieving.User, username string) (err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.UpdateUser", start, err) }(time.Now())
+	return s.UserStore.UpdateUser(u, username)
+}
+
+// Acting keeps the store acting on behalf of the user instrumented
+func (s *userStore) Acting(username string) achieving.UserStore {
+	return &userStore{UserStore: s.UserStore.Acting(username), o: s.o}
+}
+
+func (s *userStore) RetrieveActivity(username string, limit, offset int) (as []achieving.Activity, err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.RetrieveActivity", start, err) }(time.Now())
+	return s.UserStore.RetrieveActivity(username, limit, offset)
+}
+
+func (s *userStore) Undo(username string, count int) (as []achieving.Activity, err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.Undo", start, err) }(time.Now())
+	return s.UserStore.Undo(username, count)
+}
+
+func (s *userStore) Authenticate(username, password string) (ok bool, err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.Authenticate", start, err) }(time.Now())
+	return s.UserStore.Authenticate(username, password)
+}
+
+func (s *userStore) ChangePassword(username, oldpass, newpass string) (err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.ChangePassword", start, err) }(time.Now())
+	return s.UserStore.ChangePassword(username, oldpass, newpass)
+}
+
+func (s *userStore) Follow(follower, followee string) (err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.Follow", start, err) }(time.Now())
+	return s.UserStore.Follow(follower, followee)
+}
+
+func (s *userStore) Unfollow(follower, followee string) (err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.Unfollow", start, err) }(time.Now())
+	return s.UserStore.Unfollow(follower, followee)
+}
+
+func (s *userStore) RetrieveFollowers(username string, limit, offset int) (us []string, err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.RetrieveFollowers", start, err) }(time.Now())
+	return s.UserStore.RetrieveFollowers(username, limit, offset)
+}
+
+func (s *userStore) RetrieveFollowing(username string, limit, offset int) (us []string, err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.RetrieveFollowing", start, err) }(time.Now())
+	return s.UserStore.RetrieveFollowing(username, limit, offset)
+}
+
+func (s *userStore) Block(blocker, blocked string) (err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.Block", start, err) }(time.Now())
+	return s.UserStore.Block(blocker, blocked)
+}
+
+func (s *userStore) Unblock(blocker, blocked string) (err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.Unblock", start, err) }(time.Now())
+	return s.UserStore.Unblock(blocker, blocked)
+}
+
+func (s *userStore) RetrieveBlocked(username string, limit, offset int) (us []string, err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.RetrieveBlocked", start, err) }(time.Now())
+	return s.UserStore.RetrieveBlocked(username, limit, offset)
+}
+
+func (s *userStore) AcceptMembership(username, goal string) (err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.AcceptMembership", start, err) }(time.Now())
+	return s.UserStore.AcceptMembership(username, goal)
+}
+
+func (s *userStore) LeaveMembership(username, goal string) (err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.LeaveMembership", start, err) }(time.Now())
+	return s.UserStore.LeaveMembership(username, goal)
+}
+
+func (s *userStore) RetrieveMemberships(username, status string, limit, offset int) (ms []achieving.Member, err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.RetrieveMemberships", start, err) }(time.Now())
+	return s.UserStore.RetrieveMemberships(username, status, limit, offset)
+}
+
+func (s *userStore) CreateTag(username string, t achieving.Tag) (id string, err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.CreateTag", start, err) }(time.Now())
+	return s.UserStore.CreateTag(username, t)
+}
+
+func (s *userStore) UpdateTag(username string, t achieving.Tag, id string) (err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.UpdateTag", start, err) }(time.Now())
+	return s.UserStore.UpdateTag(username, t, id)
+}
+
+func (s *userStore) DeleteTag(username, id string) (err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.DeleteTag", start, err) }(time.Now())
+	return s.UserStore.DeleteTag(username, id)
+}
+
+func (s *userStore) MergeTag(username, from, into string) (err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.MergeTag", start, err) }(time.Now())
+	return s.UserStore.MergeTag(username, from, into)
+}
+
+func (s *userStore) RetrieveTags(username string, limit, offset int) (ts []achieving.Tag, err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.RetrieveTags", start, err) }(time.Now())
+	return s.UserStore.RetrieveTags(username, limit, offset)
+}
+
+func (s *userStore) CreateWorkspace(username string, w achieving.Workspace) (id string, err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.CreateWorkspace", start, err) }(time.Now())
+	return s.UserStore.CreateWorkspace(username, w)
+}
+
+func (s *userStore) RetrieveWorkspace(username, id string) (w achieving.Workspace, err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.RetrieveWorkspace", start, err) }(time.Now())
+	w, err = s.UserStore.RetrieveWorkspace(username, id)
+	if err != nil {
+		return nil, err
+	}
+	return &workspace{Workspace: w, o: s.o}, nil
+}
+
+func (s *userStore) RetrieveWorkspaces(username string, limit, offset int) (ws []achieving.Workspace, err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.RetrieveWorkspaces", start, err) }(time.Now())
+	ws, err = s.UserStore.RetrieveWorkspaces(username, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ws {
+		ws[i] = &workspace{Workspace: ws[i], o: s.o}
+	}
+	return ws, nil
+}
+
+func (s *userStore) SaveTemplate(username string, t achieving.Template, goal string) (id string, err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.SaveTemplate", start, err) }(time.Now())
+	return s.UserStore.SaveTemplate(username, t, goal)
+}
+
+func (s *userStore) RetrieveTemplate(username, id string) (t achieving.Template, err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.RetrieveTemplate", start, err) }(time.Now())
+	return s.UserStore.RetrieveTemplate(username, id)
+}
+
+func (s *userStore) RetrieveTemplates(author, username string, limit, offset int) (ts []achieving.Template, err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.RetrieveTemplates", start, err) }(time.Now())
+	return s.UserStore.RetrieveTemplates(author, username, limit, offset)
+}
+
+func (s *userStore) RetrievePublicTemplates(text string, limit, offset int) (ts []achieving.Template, err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.RetrievePublicTemplates", start, err) }(time.Now())
+	return s.UserStore.RetrievePublicTemplates(text, limit, offset)
+}
+
+func (s *userStore) UpdateTemplate(username string, t achieving.Template, id string) (err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.UpdateTemplate", start, err) }(time.Now())
+	return s.UserStore.UpdateTemplate(username, t, id)
+}
+
+func (s *userStore) DeleteTemplate(username, id string) (err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.DeleteTemplate", start, err) }(time.Now())
+	return s.UserStore.DeleteTemplate(username, id)
+}
+
+func (s *userStore) InstantiateTemplate(username, id, date string) (gid string, err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.InstantiateTemplate", start, err) }(time.Now())
+	return s.UserStore.InstantiateTemplate(username, id, date)
+}
+
+func (s *userStore) CloneGoal(username, owner, goal string) (id string, err error) {
+	defer func(start time.Time) { observe(s.o, "UserStore.CloneGoal", start, err) }(time.Now())
+	return s.UserStore.CloneGoal(username, owner, goal)
+}
+
+type user struct {
+	achieving.User
+	o Observer
+}
+
+// MarshalJSON keeps the JSON form of the wrapped user
+func (u *user) MarshalJSON() ([]byte, error) {
+	return json.Marshal(u.User)
+}
+
+func (u *user) CreateGoal(g achieving.Goal) (id string, err error) {
+	defer func(start time.Time) { observe(u.o, "User.CreateGoal", start, err) }(time.Now())
+	return u.User.CreateGoal(g)
+}
+
+func (u *user) DeleteGoal(id string) (err error) {
+	defer func(start time.Time) { observe(u.o, "User.DeleteGoal", start, err) }(time.Now())
+	return u.User.DeleteGoal(id)
+}
+
+func (u *user) UpdateGoal(g achieving.Goal, id string) (err error) {
+	defer func(start time.Time) { observe(u.o, "User.UpdateGoal", start, err) }(time.Now())
+	return u.User.UpdateGoal(g, id)
+}
+
+func (u *user) RetrieveGoal(id string) (g achieving.Goal, err error) {
+	defer func(start time.Time) { observe(u.o, "User.RetrieveGoal", start, err) }(time.Now())
+	g, err = u.User.RetrieveGoal(id)
+	if err != nil {
+		return nil, err
+	}
+	return &goal{Goal: g, o: u.o}, nil
+}
+
+func (u *user) RetrieveGoals(limit, offset int) (gs []achieving.Goal, err error) {
+	defer func(start time.Time) { observe(u.o, "User.RetrieveGoals", start, err) }(time.Now())
+	gs, err = u.User.RetrieveGoals(limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	for i := range gs {
+		gs[i] = &goal{Goal: gs[i], o: u.o}
+	}
+	return gs, nil
+}
+
+func (u *user) RetrieveGoalsVisibleTo(username, tag string, limit, offset int) (gs []achieving.Goal, err error) {
+	defer func(start time.Time) { observe(u.o, "User.RetrieveGoalsVisibleTo", start, err) }(time.Now())
+	gs, err = u.User.RetrieveGoalsVisibleTo(username, tag, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	for i := range gs {
+		gs[i] = &goal{Goal: gs[i], o: u.o}
+	}
+	return gs, nil
+}
+
+func (u *user) VisibilityTo(username string) (v achieving.Visibility, err error) {
+	defer func(start time.Time) { observe(u.o, "User.VisibilityTo", start, err) }(time.Now())
+	return u.User.VisibilityTo(username)
+}
+
+func (u *user) RetrieveTaggedGoals(tag string, limit, offset int) (gs []achieving.Goal, err error) {
+	defer func(start time.Time) { observe(u.o, "User.RetrieveTaggedGoals", start, err) }(time.Now())
+	gs, err = u.User.RetrieveTaggedGoals(tag, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	for i := range gs {
+		gs[i] = &goal{Goal: gs[i], o: u.o}
+	}
+	return gs, nil
+}
+
+func (u *user) RevertGoal(id, activity string) (err error) {
+	defer func(start time.Time) { observe(u.o, "User.RevertGoal", start, err) }(time.Now())
+	return u.User.RevertGoal(id, activity)
+}
+
+type goal struct {
+	achieving.Goal
+	o Observer
+}
+
+// MarshalJSON keeps the JSON form of the wrapped goal
+func (g *goal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(g.Goal)
+}
+
+func (g *goal) AddAchievable(a achieving.Achievable) (id string, err error) {
+	defer func(start time.Time) { observe(g.o, "Goal.AddAchievable", start, err) }(time.Now())
+	return g.Goal.AddAchievable(a)
+}
+
+func (g *goal) RemoveAchievable(id string) (err error) {
+	defer func(start time.Time) { observe(g.o, "Goal.RemoveAchievable", start, err) }(time.Now())
+	return g.Goal.RemoveAchievable(id)
+}
+
+func (g *goal) UpdateAchievable(a achieving.Achievable, id string) (err error) {
+	defer func(start time.Time) { observe(g.o, "Goal.UpdateAchievable", start, err) }(time.Now())
+	return g.Goal.UpdateAchievable(a, id)
+}
+
+func (g *goal) RetrieveAchievables(limit, offset int) (as []achieving.Achievable, err error) {
+	defer func(start time.Time) { observe(g.o, "Goal.RetrieveAchievables", start, err) }(time.Now())
+	return g.Goal.RetrieveAchievables(limit, offset)
+}
+
+func (g *goal) RetrieveOrderedAchievables(limit, offset int) (as []achieving.Achievable, err error) {
+	defer func(start time.Time) { observe(g.o, "Goal.RetrieveOrderedAchievables", start, err) }(time.Now())
+	return g.Goal.RetrieveOrderedAchievables(limit, offset)
+}
+
+func (g *goal) RetrieveTaggedAchievables(tag string, limit, offset int) (as []achieving.Achievable, err error) {
+	defer func(start time.Time) { observe(g.o, "Goal.RetrieveTaggedAchievables", start, err) }(time.Now())
+	return g.Goal.RetrieveTaggedAchievables(tag, limit, offset)
+}
+
+func (g *goal) RetrieveAchievable(id string) (a achieving.Achievable, err error) {
+	defer func(start time.Time) { observe(g.o, "Goal.RetrieveAchievable", start, err) }(time.Now())
+	return g.Goal.RetrieveAchievable(id)
+}
+
+func (g *goal) SetMember(by string, m achieving.Member, username string) (err error) {
+	defer func(start time.Time) { observe(g.o, "Goal.SetMember", start, err) }(time.Now())
+	return g.Goal.SetMember(by, m, username)
+}
+
+func (g *goal) RemoveMember(by, username string) (err error) {
+	defer func(start time.Time) { observe(g.o, "Goal.RemoveMember", start, err) }(time.Now())
+	return g.Goal.RemoveMember(by, username)
+}
+
+func (g *goal) AddComment(author string, c achieving.Comment, achievable string) (id string, err error) {
+	defer func(start time.Time) { observe(g.o, "Goal.AddComment", start, err) }(time.Now())
+	return g.Goal.AddComment(author, c, achievable)
+}
+
+func (g *goal) UpdateComment(author string, c achieving.Comment, id string) (err error) {
+	defer func(start time.Time) { observe(g.o, "Goal.UpdateComment", start, err) }(time.Now())
+	return g.Goal.UpdateComment(author, c, id)
+}
+
+func (g *goal) DeleteComment(username, id string) (err error) {
+	defer func(start time.Time) { observe(g.o, "Goal.DeleteComment", start, err) }(time.Now())
+	return g.Goal.DeleteComment(username, id)
+}
+
+func (g *goal) RetrieveComments(achievable string, limit, offset int) (cs []achieving.Comment, err error) {
+	defer func(start time.Time) { observe(g.o, "Goal.RetrieveComments", start, err) }(time.Now())
+	return g.Goal.RetrieveComments(achievable, limit, offset)
+}
+
+func (g *goal) React(username, emoji, achievable string) (err error) {
+	defer func(start time.Time) { observe(g.o, "Goal.React", start, err) }(time.Now())
+	return g.Goal.React(username, emoji, achievable)
+}
+
+func (g *goal) Unreact(username, emoji, achievable string) (err error) {
+	defer func(start time.Time) { observe(g.o, "Goal.Unreact", start, err) }(time.Now())
+	return g.Goal.Unreact(username, emoji, achievable)
+}
+
+func (g *goal) RetrieveReactions(achievable, viewer string) (rs []achieving.Reaction, err error) {
+	defer func(start time.Time) { observe(g.o, "Goal.RetrieveReactions", start, err) }(time.Now())
+	return g.Goal.RetrieveReactions(achievable, viewer)
+}
+
+func (g *goal) RetrieveActivity(limit, offset int) (as []achieving.Activity, err error) {
+	defer func(start time.Time) { observe(g.o, "Goal.RetrieveActivity", start, err) }(time.Now())
+	return g.Goal.RetrieveActivity(limit, offset)
+}
+
+type workspace struct {
+	achieving.Workspace
+	o Observer
+}
+
+// MarshalJSON keeps the JSON form of the wrapped workspace
+func (w *workspace) MarshalJSON() ([]byte, error) {
+	return json.Marshal(w.Workspace)
+}
+
+func (w *workspace) Update(u achieving.Workspace) (err error) {
+	defer func(start time.Time) { observe(w.o, "Workspace.Update", start, err) }(time.Now())
+	return w.Workspace.Update(u)
+}
+
+func (w *workspace) Delete() (err error) {
+	defer func(start time.Time) { observe(w.o, "Workspace.Delete", start, err) }(time.Now())
+	return w.Workspace.Delete()
+}
+
+func (w *workspace) SetMember(m achieving.WorkspaceMember, username string) (err error) {
+	defer func(start time.Time) { observe(w.o, "Workspace.SetMember", start, err) }(time.Now())
+	return w.Workspace.SetMember(m, username)
+}
+
+func (w *workspace) RemoveMember(username string) (err error) {
+	defer func(start time.Time) { observe(w.o, "Workspace.RemoveMember", start, err) }(time.Now())
+	return w.Workspace.RemoveMember(username)
+}
+
+func (w *workspace) RetrieveGoals(limit, offset int) (gs []achieving.Goal, err error) {
+	defer func(start time.Time) { observe(w.o, "Workspace.RetrieveGoals", start, err) }(time.Now())
+	gs, err = w.Workspace.RetrieveGoals(limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	for i := range gs {
+		gs[i] = &goal{Goal: gs[i], o: w.o}
+	}
+	return gs, nil
+}
@@ -14,6 +14,8 @@
 
 package achieving
 
+import "time"
+
 // Achievable represents an achieveable task
 type Achievable interface {
 	HasAchieved() bool
@@ -32,6 +34,63 @@ type Goal interface {
 
 	// RetriveAchievableTask gets a list of achievable task
 	RetrieveAchievables(limit, offset int) ([]Achievable, error)
+	// RetrieveOrderedAchievables gets a list of achievable tasks in
+	// dependency order: the blockers and the subtasks come before the
+	// tasks they block
+	RetrieveOrderedAchievables(limit, offset int) ([]Achievable, error)
+	// RetrieveTaggedAchievables gets a list of the achievable tasks tagged
+	// with the tag
+	RetrieveTaggedAchievables(tag string, limit, offset int) ([]Achievable, error)
+	// RetrieveAchievable gets an achievable task of the goal
+	RetrieveAchievable(string) (Achievable, error)
+
+	// AddComment adds the author's comment on the goal, or on its
+	// achievable if achievable is not empty
+	AddComment(author string, c Comment, achievable string) (string, error)
+	// UpdateComment changes the text of a comment, only its author can
+	UpdateComment(author string, c Comment, id string) error
+	// DeleteComment deletes a comment on behalf of the user, either its
+	// author or the owner of the goal
+	DeleteComment(username, id string) error
+	// RetrieveComments lists the threads of comments on the goal, or on
+	// its achievable if achievable is not empty, the oldest first
+	RetrieveComments(achievable string, limit, offset int) ([]Comment, error)
+
+	// React makes the user react with the emoji on the goal, or on its
+	// achievable if achievable is not empty
+	React(username, emoji, achievable string) error
+	// Unreact removes the user's reaction with the emoji
+	Unreact(username, emoji, achievable string) error
+	// RetrieveReactions counts the reactions on the goal, or on its
+	// achievable if achievable is not empty, per emoji. The emojis the
+	// viewer reacted with are flagged
+	RetrieveReactions(achievable, viewer string) ([]Reaction, error)
+
+	// VisibleTo returns whether the user can see the goal and its
+	// achievables. An empty username is an anonymous user
+	VisibleTo(username string) bool
+	// CanEdit returns whether the user can change the goal and its
+	// achievables: its owners and its editors
+	CanEdit(username string) bool
+	// CanManage returns whether the user can delete the goal and manage its
+	// members: its owners
+	CanManage(username string) bool
+
+	// SetMember invites the user to the goal with the role of the member,
+	// or changes the role of a member, on behalf of an owner
+	SetMember(by string, m Member, username string) error
+	// RemoveMember removes a member or an invite on behalf of an owner or
+	// of the member leaving
+	RemoveMember(by, username string) error
+	// RetrieveMembers lists the members of the goal and the users invited
+	// to it
+	RetrieveMembers() []Member
+	// RetrieveActivity lists the changes of the goal and its achievables,
+	// the latest first
+	RetrieveActivity(limit, offset int) ([]Activity, error)
+	// Location returns the owner's time zone, the habits' days and times
+	// are in it
+	Location() *time.Location
 }
 
 // User represents am user object, which should be containing the user data
@@ -43,11 +102,107 @@ type User interface {
 	DeleteGoal(string) error
 	// UpdateGoal updates a goal
 	UpdateGoal(Goal, string) error
-	// RetrieveGoal retrieves a goal
+	// RetrieveGoal retrieves a goal, the goal is returned whoever can see
+	// it, use Goal.VisibleTo to check
 	RetrieveGoal(string) (Goal, error)
 
 	// RetrieveGoals get all the goal from this user
 	RetrieveGoals(limit, offset int) ([]Goal, error)
+	// RetrieveTaggedGoals gets the goals tagged with the tag
+	RetrieveTaggedGoals(tag string, limit, offset int) ([]Goal, error)
+	// RetrieveGoalsVisibleTo gets the goals the user can see, tagged with
+	// the tag if not empty. Unlike filtering with Goal.VisibleTo, the limit
+	// and the offset count the visible goals only
+	RetrieveGoalsVisibleTo(username, tag string, limit, offset int) ([]Goal, error)
+	// VisibilityTo returns what tells the goals the other user can see
+	// apart, the way RetrieveGoalsVisibleTo does
+	VisibilityTo(username string) (Visibility, error)
+	// RevertGoal reverts the goal or one of its achievables to the
+	// revision the activity of the goal's log left it in
+	RevertGoal(goal, activity string) error
+}
+
+// Visibility tells which goals of a user another user can see beside the
+// public ones
+type Visibility struct {
+	// Shared are the IDs of the goals shared with the other user
+	Shared []string
+	// Following tells whether the other user follows the user
+	Following bool
+	// Workspaces are the IDs of the other user's workspaces
+	Workspaces []string
+}
+
+// Tag represents a tag of the user's catalogue, attached to goals and
+// achievables
+type Tag interface {
+	// TagName returns the name of the tag
+	TagName() string
+}
+
+// Comment represents a comment on a goal or on one of its achievables
+type Comment interface {
+	// CommentText returns the text of the comment
+	CommentText() string
+}
+
+// Reaction represents the number of the reactions with an emoji
+type Reaction interface {
+	// ReactionEmoji returns the emoji
+	ReactionEmoji() string
+}
+
+// Member represents a user a goal is shared with
+type Member interface {
+	// MemberRole returns the role of the member: OWNER, EDITOR or VIEWER
+	MemberRole() string
+}
+
+// Workspace represents a group of users and the goals they create in it, as
+// seen by one of its members
+type Workspace interface {
+	// WorkspaceName returns the name of the workspace
+	WorkspaceName() string
+	// Update renames the workspace and changes its description, only its
+	// admins can
+	Update(Workspace) error
+	// Delete deletes the workspace once it has no goals, only its admins
+	// can
+	Delete() error
+	// SetMember adds the user to the workspace with the role of the
+	// member, or changes the role of a member, only its admins can
+	SetMember(m WorkspaceMember, username string) error
+	// RemoveMember removes the user from the workspace on behalf of an
+	// admin or of the user leaving
+	RemoveMember(username string) error
+	// RetrieveWorkspaceMembers lists the members of the workspace
+	RetrieveWorkspaceMembers() []WorkspaceMember
+	// RetrieveGoals lists the goals of the workspace the member can see,
+	// the latest updated first
+	RetrieveGoals(limit, offset int) ([]Goal, error)
+}
+
+// WorkspaceMember represents a member of a workspace
+type WorkspaceMember interface {
+	// WorkspaceRole returns the role of the member: ADMIN or MEMBER
+	WorkspaceRole() string
+}
+
+// Template represents a goal and its achievables saved to create goals
+// alike
+type Template interface {
+	// TemplateName returns the name of the template
+	TemplateName() string
+}
+
+// Activity represents a change of the activity log: the creation, the
+// update, the deletion or the revert of a user, a goal or an achievable
+type Activity interface {
+	// ActivityAction returns the action: CREATED, UPDATED, DELETED or
+	// REVERTED
+	ActivityAction() string
+	// ActivityID returns the ID of the change
+	ActivityID() string
 }
 
 // UserStore represents a storage of user, it does not contain the user data
@@ -62,8 +217,92 @@ type UserStore interface {
 	// UpdateUser updates the user information
 	UpdateUser(User, string) error
 
-	// Authenticate authenticates the username and password
+	// Acting returns the store recording the user as the author of the
+	// changes made through it
+	Acting(username string) UserStore
+	// RetrieveActivity lists the changes of the user's data and goals and
+	// the changes the user made, the latest first
+	RetrieveActivity(username string, limit, offset int) ([]Activity, error)
+	// Undo undoes the count latest changes the user made within the undo
+	// window and returns them, it fails with a conflict if one of the
+	// resources was changed since. It stops at the first change it fails to
+	// undo and returns the changes undone before along with the error
+	Undo(username string, count int) ([]Activity, error)
+
+	// Authenticate authenticates the username and password, a wrong
+	// username or password is reported as errors.ErrAuthentication
 	Authenticate(string, string) (bool, error)
 	// ChangePassword changes a user password
 	ChangePassword(string, string, string) error
+
+	// Follow makes the first user follow the second one
+	Follow(follower, followee string) error
+	// Unfollow makes the first user stop following the second one
+	Unfollow(follower, followee string) error
+	// RetrieveFollowers lists the usernames of the user's followers
+	RetrieveFollowers(username string, limit, offset int) ([]string, error)
+	// RetrieveFollowing lists the usernames of the users the user follows
+	RetrieveFollowing(username string, limit, offset int) ([]string, error)
+
+	// Block makes the first user block the second one, they stop
+	// following each other
+	Block(blocker, blocked string) error
+	// Unblock makes the first user stop blocking the second one
+	Unblock(blocker, blocked string) error
+	// RetrieveBlocked lists the usernames of the users the user blocks
+	RetrieveBlocked(username string, limit, offset int) ([]string, error)
+
+	// AcceptMembership accepts the user's invite to the goal
+	AcceptMembership(username, goal string) error
+	// LeaveMembership declines the user's invite to the goal or makes the
+	// user leave it
+	LeaveMembership(username, goal string) error
+	// RetrieveMemberships lists the goals the user is a member of or
+	// invited to with the status, INVITED or ACTIVE, or both if empty
+	RetrieveMemberships(username, status string, limit, offset int) ([]Member, error)
+
+	// CreateWorkspace creates a workspace, the user is its first admin
+	CreateWorkspace(username string, w Workspace) (string, error)
+	// RetrieveWorkspace retrieves a workspace on behalf of the user, the
+	// workspaces the user is not a member of are not found
+	RetrieveWorkspace(username, workspace string) (Workspace, error)
+	// RetrieveWorkspaces lists the workspaces of the user
+	RetrieveWorkspaces(username string, limit, offset int) ([]Workspace, error)
+
+	// SaveTemplate saves the user's goal and its achievables as a template
+	SaveTemplate(username string, t Template, goal string) (string, error)
+	// RetrieveTemplate retrieves a template on behalf of the user, the
+	// templates of the others are only found if they are public
+	RetrieveTemplate(username, template string) (Template, error)
+	// RetrieveTemplates lists the author's templates on behalf of the
+	// user, the others only see the public ones
+	RetrieveTemplates(author, username string, limit, offset int) ([]Template, error)
+	// RetrievePublicTemplates lists the public templates whose name
+	// contains the text, the most used first
+	RetrievePublicTemplates(text string, limit, offset int) ([]Template, error)
+	// UpdateTemplate renames the user's template, changes its description
+	// and publishes or unpublishes it
+	UpdateTemplate(username string, t Template, template string) error
+	// DeleteTemplate deletes the user's template
+	DeleteTemplate(username, template string) error
+	// InstantiateTemplate creates a goal of the user from the template,
+	// the reminders rebased on the start date formatted as 2006-01-02,
+	// today if empty
+	InstantiateTemplate(username, template, start string) (string, error)
+	// CloneGoal copies the owner's goal the user can see and its
+	// achievables to the user's goals, everything left to be done
+	CloneGoal(username, owner, goal string) (string, error)
+
+	// CreateTag adds a tag to the user's catalogue
+	CreateTag(username string, t Tag) (string, error)
+	// UpdateTag renames or recolors a tag, the tagged goals and achievables
+	// keep it
+	UpdateTag(username string, t Tag, id string) error
+	// DeleteTag deletes a tag, the tagged goals and achievables lose it
+	DeleteTag(username, id string) error
+	// MergeTag merges the first tag into the second one, the goals and the
+	// achievables tagged with the first one are tagged with the second one
+	MergeTag(username, from, into string) error
+	// RetrieveTags lists the user's catalogue of tags
+	RetrieveTags(username string, limit, offset int) ([]Tag, error)
 }
@@ -18,6 +18,7 @@ package achievable
 import (
 	"time"
 
+	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -40,11 +41,66 @@ type Reminder struct {
 type Achievable struct {
 	ID             bson.ObjectId   `bson:"_id,omitempty" json:"id,omitempty" valid:"optional,hexadecimal"`
 	Goal           bson.ObjectId   `bson:"_goal,omitempty" json:"-" valid:"optional"`
-	Name           string          `bson:"name" json:"name" valid:"name" valid:"required,utfletternum,stringlength(1|100)"`
+	Name           string          `bson:"name" json:"name" valid:"required,utfletternum,stringlength(1|100)"`
 	Description    string          `bson:"description,omitempty" json:"description,omitempty" valid:"optional,stringlength(1|400)"`
 	Status         string          `bson:"status" json:"status" valid:"validateStatus"`
 	Reminder       *Reminder       `bson:"reminder,omitempty" json:"reminder,omitempty" valid:"optional"`
 	RepeatReminder *RepeatReminder `bson:"repreatedReminder,omitempty" json:"repeatedReminder,omitempty" valid:"optional"`
+	// Milestone is the ID of the goal's milestone the achievable is a step
+	// of, if any
+	Milestone bson.ObjectId `bson:"milestone,omitempty" json:"milestone,omitempty" valid:"optional,hexadecimal"`
+	// Parent is the ID of the task the achievable is a subtask of, if any
+	Parent bson.ObjectId `bson:"parent,omitempty" json:"parent,omitempty" valid:"optional,hexadecimal"`
+	// BlockedBy are the IDs of the tasks of the goal to be done before
+	// the achievable
+	BlockedBy []bson.ObjectId `bson:"blockedBy,omitempty" json:"blockedBy,omitempty" valid:"-"`
+	// Tags are the IDs of the tags of the user's catalogue the achievable
+	// is tagged with
+	Tags []bson.ObjectId `bson:"tags,omitempty" json:"tags,omitempty" valid:"-"`
+	// Assignee is the owner of the goal or the member the achievable is
+	// assigned to, if any
+	Assignee string `bson:"assignee,omitempty" json:"assignee,omitempty" valid:"optional,alphanum,length(1|30)"`
+	// Actionable tells whether the achievable can be worked on now, it is
+	// computed for the responses
+	Actionable bool `bson:"-" json:"actionable" valid:"-"`
+	// ExternalID identifies the task in the calendar or the file it was
+	// imported from, the tasks already imported are skipped
+	ExternalID string `bson:"externalId,omitempty" json:"externalId,omitempty" valid:"optional,stringlength(1|255)"`
+	// NextOccurrence is the next reminder in the owner's time zone, it is
+	// computed for the responses
+	NextOccurrence *Occurrence `bson:"-" json:"nextOccurrence,omitempty" valid:"-"`
+	// NextReminder is when the reminder scheduler next looks at the habit,
+	// Unscheduled until it computes it. The tasks have none
+	NextReminder time.Time `bson:"nextReminder,omitempty" json:"-" valid:"-"`
+}
+
+// Unscheduled is the next reminder of the habits created or changed since
+// the reminder scheduler last looked at them, it is always due
+var Unscheduled = time.Unix(0, 0).UTC()
+
+// Unschedule lets the reminder scheduler compute the next reminder of the
+// habits of the goals again, after their owner changed time zone
+func Unschedule(ac *mgo.Collection, goals []bson.ObjectId) error {
+	if len(goals) == 0 {
+		return nil
+	}
+	_, err := ac.UpdateAll(bson.M{
+		"_goal":             bson.M{"$in": goals},
+		"repreatedReminder": bson.M{"$exists": true},
+	}, bson.M{"$set": bson.M{"nextReminder": Unscheduled}})
+	return err
+}
+
+// TaggedWith returns whether the achievable is tagged with any of the tags
+func (a *Achievable) TaggedWith(tags []bson.ObjectId) bool {
+	for _, t := range a.Tags {
+		for _, id := range tags {
+			if t == id {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // IsHabit returns whether this is a habit
@@ -16,7 +16,10 @@ package achievable
 
 import (
 	"fmt"
+	"sort"
 	"time"
+
+	"github.com/iocat/donit/internal/rrule"
 )
 
 const (
@@ -60,12 +63,20 @@ func ValidateCycle(v, _ interface{}) bool {
 }
 
 // ValidateDaysInWeekOrMonth validates the DaysInWeekOrMonth field
-// The day range should be in (0,31]
-func ValidateDaysInWeekOrMonth(v, _ interface{}) bool {
+// The days of a weekly cycle are in [Sunday,Saturday], the days of a monthly
+// cycle in [1,31]
+func ValidateDaysInWeekOrMonth(v, o interface{}) bool {
+	min, max := 0, 31
+	switch r := o.(type) {
+	case RepeatReminder:
+		min, max = dayRange(r.Cycle)
+	case *RepeatReminder:
+		min, max = dayRange(r.Cycle)
+	}
 	switch v := v.(type) {
 	case map[int]bool:
 		for k := range v {
-			if k <= 0 || k > 31 {
+			if k < min || k > max {
 				return false
 			}
 		}
@@ -75,10 +86,209 @@ func ValidateDaysInWeekOrMonth(v, _ interface{}) bool {
 	}
 }
 
-// RepeatReminder represents a reminder for habit
+// dayRange returns the range of the days of the cycle
+func dayRange(cycle string) (int, int) {
+	switch cycle {
+	case EveryWeekAndCustom:
+		return Sunday, Saturday
+	case EveryMonthAndCustom:
+		return 1, 31
+	default:
+		return 0, 31
+	}
+}
+
+// ValidateRule validates the recurrence rule field
+func ValidateRule(v, _ interface{}) bool {
+	switch v := v.(type) {
+	case string:
+		_, err := rrule.Parse(v)
+		return err == nil
+	default:
+		panic(fmt.Errorf("wrong validate type, got %T, expected a string", v))
+	}
+}
+
+// ValidateDate validates a date formatted as 2006-01-02
+func ValidateDate(v, _ interface{}) bool {
+	switch v := v.(type) {
+	case string:
+		_, err := rrule.ParseDate(v)
+		return err == nil
+	default:
+		panic(fmt.Errorf("wrong validate type, got %T, expected a string", v))
+	}
+}
+
+// ValidateDates validates a list of dates formatted as 2006-01-02
+func ValidateDates(v, o interface{}) bool {
+	switch v := v.(type) {
+	case []string:
+		for _, d := range v {
+			if !ValidateDate(d, o) {
+				return false
+			}
+		}
+		return true
+	default:
+		panic(fmt.Errorf("wrong validate type, got %T, expected a string slice", v))
+	}
+}
+
+// RepeatReminder represents a reminder for habit. The recurrence is either
+// the RFC 5545 rule or, for the clients which predate it, the cycle and its
+// days. Both are kept in sync when the rule can be expressed by a cycle
 type RepeatReminder struct {
-	Cycle             string        `bson:"cycle" json:"cycle" valid:"required,cycle"`
-	DaysInWeekOrMonth map[int]bool  `bson:"days" json:"repeat_on" valid:"required,daysInWeekOrMonth"`
+	Cycle             string        `bson:"cycle,omitempty" json:"cycle,omitempty" valid:"optional,cycle"`
+	DaysInWeekOrMonth map[int]bool  `bson:"days,omitempty" json:"repeat_on,omitempty" valid:"optional,daysInWeekOrMonth"`
 	TimeInDay         time.Duration `bson:"remindAt" json:"remindAt" valid:"-"`
 	Duration          time.Duration `bson:"duration" json:"duration" valid:"-"`
+
+	// Rule is the RRULE of the habit, for example
+	// FREQ=MONTHLY;BYDAY=-1FR for the last Friday of every month
+	Rule string `bson:"rrule,omitempty" json:"rrule,omitempty" valid:"optional,rrule"`
+	// StartDate is the DTSTART date of the rule in the owner's time zone,
+	// the intervals and the COUNT start from it
+	StartDate string `bson:"startDate,omitempty" json:"startDate,omitempty" valid:"optional,date"`
+	// ExDates are the dates the habit is skipped on (EXDATE)
+	ExDates []string `bson:"exdates,omitempty" json:"exdates,omitempty" valid:"optional,dates"`
+}
+
+// epoch is the start date of the habits without one: their rules have
+// neither COUNT nor INTERVAL so the start date does not matter
+var epoch = rrule.Date{Year: 1970, Month: time.January, Day: 1}
+
+// LegacyRule converts a cycle and its days to the equivalent rule. The days
+// out of the cycle's range never matched any date, they are dropped
+func LegacyRule(cycle string, days map[int]bool) (*rrule.Rule, error) {
+	r := &rrule.Rule{Interval: 1, WeekStart: time.Monday}
+	min, max := dayRange(cycle)
+	var ds []int
+	for d, ok := range days {
+		if ok && d >= min && d <= max {
+			ds = append(ds, d)
+		}
+	}
+	sort.Ints(ds)
+	switch cycle {
+	case EveryDay:
+		r.Freq = rrule.Daily
+	case EveryWeekAndCustom:
+		r.Freq = rrule.Weekly
+		for _, d := range ds {
+			r.ByDay = append(r.ByDay, rrule.Weekday{Day: time.Weekday(d)})
+		}
+	case EveryMonthAndCustom:
+		r.Freq = rrule.Monthly
+		r.ByMonthDay = ds
+	default:
+		return nil, fmt.Errorf("unknown cycle %q", cycle)
+	}
+	if cycle != EveryDay && len(ds) == 0 {
+		return nil, fmt.Errorf("the %s cycle repeats on no day", cycle)
+	}
+	return r, nil
+}
+
+// legacy converts the rule to a cycle and its days, it returns false if no
+// cycle is equivalent to the rule
+func legacy(r *rrule.Rule) (string, map[int]bool, bool) {
+	if r.Interval != 1 || r.Count != 0 || !r.Until.IsZero() || len(r.ByMonth) != 0 ||
+		r.WeekStart != time.Monday {
+		return "", nil, false
+	}
+	days := make(map[int]bool)
+	switch {
+	case r.Freq == rrule.Daily && len(r.ByDay) == 0 && len(r.ByMonthDay) == 0:
+		return EveryDay, nil, true
+	case r.Freq == rrule.Weekly && len(r.ByDay) != 0:
+		for _, w := range r.ByDay {
+			days[int(w.Day)] = true
+		}
+		return EveryWeekAndCustom, days, true
+	case r.Freq == rrule.Monthly && len(r.ByDay) == 0 && len(r.ByMonthDay) != 0:
+		for _, d := range r.ByMonthDay {
+			if d < 0 {
+				return "", nil, false
+			}
+			days[d] = true
+		}
+		return EveryMonthAndCustom, days, true
+	default:
+		return "", nil, false
+	}
+}
+
+// Recurrence returns the recurrence rule of the habit, converted from its
+// cycle if it has no rule
+func (r *RepeatReminder) Recurrence() (*rrule.Rule, error) {
+	if len(r.Rule) != 0 {
+		return rrule.Parse(r.Rule)
+	}
+	return LegacyRule(r.Cycle, r.DaysInWeekOrMonth)
+}
+
+// start returns the DTSTART date of the habit
+func (r *RepeatReminder) start() rrule.Date {
+	if d, err := rrule.ParseDate(r.StartDate); err == nil {
+		return d
+	}
+	return epoch
+}
+
+// Normalize checks the recurrence of the habit and syncs the rule and the
+// cycle: the habits set with a cycle get the equivalent rule and the habits
+// set with a rule get the equivalent cycle, if any
+func (r *RepeatReminder) Normalize() error {
+	if len(r.Rule) == 0 && len(r.Cycle) == 0 {
+		return fmt.Errorf("the habit needs either a rule or a cycle")
+	}
+	rule, err := r.Recurrence()
+	if err != nil {
+		return err
+	}
+	if (rule.Count != 0 || rule.Interval > 1) && len(r.StartDate) == 0 {
+		return fmt.Errorf("a rule with a COUNT or an INTERVAL needs a start date")
+	}
+	if rule.Freq == rrule.Weekly && len(rule.ByDay) == 0 && len(r.StartDate) == 0 {
+		return fmt.Errorf("a weekly rule without BYDAY needs a start date")
+	}
+	r.Rule = rule.String()
+	if cycle, days, ok := legacy(rule); ok {
+		r.Cycle, r.DaysInWeekOrMonth = cycle, days
+	} else {
+		r.Cycle, r.DaysInWeekOrMonth = "", nil
+	}
+	return nil
+}
+
+// Occurrences returns the times in (from, to] the habit is reminded of. The
+// days and the time in day are the wall clock of the location, so that the
+// reminders keep their local time across the daylight saving time changes
+func (r *RepeatReminder) Occurrences(from, to time.Time, loc *time.Location) []time.Time {
+	rule, err := r.Recurrence()
+	if err != nil {
+		return nil
+	}
+	hour := int(r.TimeInDay / time.Hour)
+	min := int(r.TimeInDay % time.Hour / time.Minute)
+	sec := int(r.TimeInDay % time.Minute / time.Second)
+	at := func(d rrule.Date) time.Time {
+		return LocalTime(d.Year, d.Month, d.Day, hour, min, sec, loc)
+	}
+	skipped := make(map[string]bool, len(r.ExDates))
+	for _, d := range r.ExDates {
+		skipped[d] = true
+	}
+	var ts []time.Time
+	rule.Each(r.start(), rrule.DateOf(from.In(loc)), at, func(d rrule.Date, t time.Time) bool {
+		if t.After(to) {
+			return false
+		}
+		if t.After(from) && !skipped[d.String()] {
+			ts = append(ts, t)
+		}
+		return true
+	})
+	return ts
 }
@@ -0,0 +1,99 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package achievable
+
+import "time"
+
+// nextOccurrenceHorizon is how far the next occurrence of a habit is looked
+// for, a monthly habit on the 31st skips two months at most
+const nextOccurrenceHorizon = 366
+
+// LocalTime returns the instant the wall clock shows the time in the
+// location. The times skipped by a daylight saving time gap are moved
+// forward by the length of the gap (2:30 becomes 3:30 when the clocks jump
+// from 2:00 to 3:00) and the times repeated by an overlap are the first of
+// the two instants, as RFC 5545 prescribes
+func LocalTime(year int, month time.Month, day, hour, min, sec int, loc *time.Location) time.Time {
+	wall := time.Date(year, month, day, hour, min, sec, 0, time.UTC)
+	// The offsets in effect a day before and after the wall time surround
+	// any transition affecting it
+	_, before := wall.Add(-24 * time.Hour).In(loc).Zone()
+	_, after := wall.Add(24 * time.Hour).In(loc).Zone()
+	early := wall.Add(-time.Duration(before) * time.Second).In(loc)
+	late := wall.Add(-time.Duration(after) * time.Second).In(loc)
+	shows := func(t time.Time) bool {
+		y, m, d := t.Date()
+		h, mi, s := t.Clock()
+		wy, wm, wd := wall.Date()
+		wh, wmi, ws := wall.Clock()
+		return y == wy && m == wm && d == wd && h == wh && mi == wmi && s == ws
+	}
+	switch {
+	case shows(early) && shows(late):
+		if late.Before(early) {
+			return late
+		}
+		return early
+	case shows(late):
+		return late
+	default:
+		// shows(early) or the gap, where the offset before the
+		// transition moves the time forward
+		return early
+	}
+}
+
+// Occurrence is the time of a reminder both in UTC and in the user's time
+// zone
+type Occurrence struct {
+	UTC      time.Time `json:"utc"`
+	Local    time.Time `json:"local"`
+	Timezone string    `json:"timezone"`
+}
+
+// NewOccurrence creates the occurrence of the time in the location
+func NewOccurrence(t time.Time, loc *time.Location) *Occurrence {
+	return &Occurrence{
+		UTC:      t.UTC(),
+		Local:    t.In(loc),
+		Timezone: loc.String(),
+	}
+}
+
+// Next returns the first reminder of the achievable after now, the days and
+// the time in day of the habits are the ones of the location. It returns
+// false if the achievable is not reminded anymore
+func (a *Achievable) Next(now time.Time, loc *time.Location) (time.Time, bool) {
+	switch {
+	case a.IsHabit():
+		ts := a.RepeatReminder.Occurrences(now, now.AddDate(0, 0, nextOccurrenceHorizon), loc)
+		if len(ts) == 0 {
+			return time.Time{}, false
+		}
+		return ts[0], true
+	case a.Reminder != nil && a.Reminder.At.After(now) && !a.HasAchieved():
+		return a.Reminder.At, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// Localize sets the next occurrence of the achievable in the location
+func (a *Achievable) Localize(now time.Time, loc *time.Location) {
+	a.NextOccurrence = nil
+	if t, ok := a.Next(now, loc); ok {
+		a.NextOccurrence = NewOccurrence(t, loc)
+	}
+}
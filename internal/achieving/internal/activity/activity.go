@@ -0,0 +1,254 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package activity contains the activity log: an append-only trail of the
+// changes made to the users, the goals and the achievables
+package activity
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+	"github.com/iocat/donit/internal/achieving/internal/goal"
+	"github.com/iocat/donit/internal/achieving/internal/member"
+	"github.com/iocat/donit/internal/achieving/internal/user"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Collection is the name of the collection of the activity log
+const Collection = "activities"
+
+const (
+	// Created is the action of the resources created
+	Created = "CREATED"
+	// Updated is the action of the resources updated
+	Updated = "UPDATED"
+	// Deleted is the action of the resources deleted
+	Deleted = "DELETED"
+	// Reverted is the action of the resources reverted to a former
+	// revision
+	Reverted = "REVERTED"
+	// Undone is the action of the changes undoing one of the latest
+	// changes of their actor
+	Undone = "UNDONE"
+)
+
+const (
+	// ResourceUser is the resource of the entries about a user
+	ResourceUser = "USER"
+	// ResourceGoal is the resource of the entries about a goal
+	ResourceGoal = "GOAL"
+	// ResourceAchievable is the resource of the entries about an achievable
+	ResourceAchievable = "ACHIEVABLE"
+)
+
+// Entry is a change of a resource, entries are never changed once recorded
+type Entry struct {
+	ID bson.ObjectId `bson:"_id" json:"id"`
+	// Owner is the user the resource belongs to
+	Owner string `bson:"owner" json:"owner"`
+	// Actor is the user who made the change, the owner or a member of a
+	// shared goal
+	Actor      string        `bson:"actor" json:"actor"`
+	Time       time.Time     `bson:"time" json:"time"`
+	Action     string        `bson:"action" json:"action"`
+	Resource   string        `bson:"resource" json:"resource"`
+	Goal       bson.ObjectId `bson:"_goal,omitempty" json:"goal,omitempty"`
+	Achievable bson.ObjectId `bson:"_achievable,omitempty" json:"achievable,omitempty"`
+	// Before is the resource before the change, none if it was created
+	Before *Revision `bson:"before,omitempty" json:"before,omitempty"`
+	// After is the resource after the change, none if it was deleted
+	After *Revision `bson:"after,omitempty" json:"after,omitempty"`
+	// Changes are the fields the change set, unset or changed
+	Changes []Change `bson:"changes,omitempty" json:"changes,omitempty"`
+	// Revision is the entry the resource was reverted to, if reverted
+	Revision bson.ObjectId `bson:"revision,omitempty" json:"revision,omitempty"`
+	// Undo is the entry the change undid, if an undo
+	Undo bson.ObjectId `bson:"undo,omitempty" json:"undo,omitempty"`
+}
+
+// Revision is the state of a resource, one of the fields is set
+type Revision struct {
+	User       *user.User             `bson:"user,omitempty" json:"user,omitempty"`
+	Goal       *goal.Goal             `bson:"goal,omitempty" json:"goal,omitempty"`
+	Achievable *achievable.Achievable `bson:"achievable,omitempty" json:"achievable,omitempty"`
+	// Members are the members of a goal deleted, put back along with it
+	// if the deletion is undone
+	Members []member.Member `bson:"members,omitempty" json:"-"`
+}
+
+// Change is a field of the resource set, unset or changed, as stored
+type Change struct {
+	Field  string      `bson:"field" json:"field"`
+	Before interface{} `bson:"before,omitempty" json:"before,omitempty"`
+	After  interface{} `bson:"after,omitempty" json:"after,omitempty"`
+}
+
+// UserRevision makes the revision of the user
+func UserRevision(u user.User) *Revision {
+	return &Revision{User: &u}
+}
+
+// GoalRevision makes the revision of the goal, its achievables are left out
+func GoalRevision(g goal.Goal) *Revision {
+	g.ToDo, g.Members, g.MemberProgress = nil, nil, nil
+	return &Revision{Goal: &g}
+}
+
+// AchievableRevision makes the revision of the achievable
+func AchievableRevision(a achievable.Achievable) *Revision {
+	return &Revision{Achievable: &a}
+}
+
+// document returns the stored fields of the revision
+func (r *Revision) document() (bson.M, error) {
+	doc := bson.M{}
+	if r == nil {
+		return doc, nil
+	}
+	var v interface{}
+	switch {
+	case r.User != nil:
+		v = r.User
+	case r.Goal != nil:
+		v = r.Goal
+	case r.Achievable != nil:
+		v = r.Achievable
+	default:
+		return doc, nil
+	}
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err = bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// diff lists the fields changed from the revision before to the one after,
+// in the order of their names
+func diff(before, after *Revision) ([]Change, error) {
+	b, err := before.document()
+	if err != nil {
+		return nil, err
+	}
+	a, err := after.document()
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]string, 0, len(b)+len(a))
+	for f := range b {
+		fields = append(fields, f)
+	}
+	for f := range a {
+		if _, ok := b[f]; !ok {
+			fields = append(fields, f)
+		}
+	}
+	sort.Strings(fields)
+	var changes []Change
+	for _, f := range fields {
+		if f == "_id" || reflect.DeepEqual(b[f], a[f]) {
+			continue
+		}
+		changes = append(changes, Change{Field: f, Before: b[f], After: a[f]})
+	}
+	return changes, nil
+}
+
+// EnsureIndexes creates the indexes of the activity collection
+func EnsureIndexes(col *mgo.Collection) error {
+	for _, index := range []mgo.Index{
+		{Key: []string{"owner", "-time"}},
+		{Key: []string{"actor", "-time"}},
+		{Key: []string{"_goal", "-time"}},
+		{Key: []string{"_achievable", "-time"}},
+	} {
+		if err := col.EnsureIndex(index); err != nil {
+			return fmt.Errorf("ensure activity index %v: %s", index.Key, err)
+		}
+	}
+	return nil
+}
+
+// Record appends the entry to the log, the actor is the owner if unknown
+func Record(col *mgo.Collection, e *Entry) error {
+	changes, err := diff(e.Before, e.After)
+	if err != nil {
+		return fmt.Errorf("diff the %s revisions: %s", e.Resource, err)
+	}
+	e.ID, e.Time, e.Changes = bson.NewObjectId(), time.Now(), changes
+	if len(e.Actor) == 0 {
+		e.Actor = e.Owner
+	}
+	if err = col.Insert(e); err != nil {
+		return fmt.Errorf("record the activity: %s", err)
+	}
+	return nil
+}
+
+func list(col *mgo.Collection, query bson.M, limit, offset int) ([]Entry, error) {
+	q := col.Find(query).Sort("-time", "-_id")
+	if limit > 0 {
+		q.Limit(limit)
+	}
+	if offset > 0 {
+		q.Skip(offset)
+	}
+	var es []Entry
+	if err := q.All(&es); err != nil {
+		return nil, err
+	}
+	return es, nil
+}
+
+// OfGoal lists the entries of the goal and of its achievables, the
+// latest first
+func OfGoal(col *mgo.Collection, g bson.ObjectId, limit, offset int) ([]Entry, error) {
+	return list(col, bson.M{"_goal": g}, limit, offset)
+}
+
+// OfUser lists the entries of the user's resources and of the changes the
+// user made, the latest first
+func OfUser(col *mgo.Collection, username string, limit, offset int) ([]Entry, error) {
+	return list(col, bson.M{
+		"$or": []bson.M{
+			{"owner": username},
+			{"actor": username},
+		},
+	}, limit, offset)
+}
+
+// Retrieve gets an entry of the goal's log
+func Retrieve(col *mgo.Collection, g, id bson.ObjectId) (Entry, error) {
+	var e Entry
+	err := col.Find(bson.M{
+		"_id":   id,
+		"_goal": g,
+	}).One(&e)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return e, errors.NewNotFound("activity", fmt.Sprintf("%s,%s", g.Hex(), id.Hex()))
+		}
+		return e, err
+	}
+	return e, nil
+}
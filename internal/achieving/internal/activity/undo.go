@@ -0,0 +1,164 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package activity
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/errors"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// UndoWindow is how long the changes can be undone after they are made
+const UndoWindow = 15 * time.Minute
+
+// MaxUndo is the number of changes undone at once at most
+const MaxUndo = 20
+
+// undoable returns whether the change can be undone: the undos are not
+// undone and no user is created or deleted by an undo
+func (e *Entry) undoable() bool {
+	if e.Action == Undone {
+		return false
+	}
+	return e.Resource != ResourceUser || e.Action == Updated || e.Action == Reverted
+}
+
+// resource returns the query of the entries of the change's resource, the
+// entries of a goal created are the entries of its achievables as well
+func (e *Entry) resource() bson.M {
+	switch {
+	case e.Resource == ResourceGoal && e.Action == Created:
+		return bson.M{"_goal": e.Goal}
+	case e.Resource == ResourceGoal:
+		return bson.M{"resource": ResourceGoal, "_goal": e.Goal}
+	case e.Resource == ResourceAchievable:
+		return bson.M{"resource": ResourceAchievable, "_achievable": e.Achievable}
+	default:
+		return bson.M{"resource": ResourceUser, "owner": e.Owner}
+	}
+}
+
+// key identifies the change's resource in the errors
+func (e *Entry) key() string {
+	switch e.Resource {
+	case ResourceGoal:
+		return fmt.Sprintf("%s,%s", e.Owner, e.Goal.Hex())
+	case ResourceAchievable:
+		return fmt.Sprintf("%s,%s", e.Goal.Hex(), e.Achievable.Hex())
+	default:
+		return e.Owner
+	}
+}
+
+// before returns whether the entry was recorded before the other one
+func (e *Entry) before(o *Entry) bool {
+	if e.Time.Equal(o.Time) {
+		return e.ID < o.ID
+	}
+	return e.Time.Before(o.Time)
+}
+
+// Undoable returns the count latest changes the actor made within the undo
+// window and has not undone yet, the latest first. It fails with a conflict
+// if the resource of one of them was changed since by another change
+func Undoable(col *mgo.Collection, actor string, count int, now time.Time) ([]Entry, error) {
+	var recent []Entry
+	err := col.Find(bson.M{
+		"actor": actor,
+		"time":  bson.M{"$gte": now.Add(-UndoWindow)},
+	}).Sort("-time", "-_id").All(&recent)
+	if err != nil {
+		return nil, err
+	}
+	es := latest(recent, count)
+	undoing := make(map[bson.ObjectId]bool, len(es))
+	for _, e := range es {
+		undoing[e.ID] = true
+	}
+	for i := range es {
+		if err = unchanged(col, &es[i], undoing); err != nil {
+			return nil, err
+		}
+	}
+	return es, nil
+}
+
+// latest returns the count latest of the recent entries not undone yet that
+// can be undone, the recent entries being the latest first
+func latest(recent []Entry, count int) []Entry {
+	var es []Entry
+	undone := make(map[bson.ObjectId]bool)
+	// the undos are recorded after the changes they undo, so they are met
+	// first
+	for _, e := range recent {
+		if len(es) == count {
+			break
+		}
+		if len(e.Undo) != 0 {
+			undone[e.Undo] = true
+		}
+		if undone[e.ID] || !e.undoable() {
+			continue
+		}
+		es = append(es, e)
+	}
+	return es
+}
+
+// unchanged fails with a conflict if the resource of the change was changed
+// since
+func unchanged(col *mgo.Collection, e *Entry, undoing map[bson.ObjectId]bool) error {
+	q := e.resource()
+	q["time"] = bson.M{"$gte": e.Time}
+	q["_id"] = bson.M{"$ne": e.ID}
+	var later []Entry
+	if err := col.Find(q).All(&later); err != nil {
+		return err
+	}
+	if changed(e, later, undoing) {
+		return errors.NewConflict(strings.ToLower(e.Resource), e.key())
+	}
+	return nil
+}
+
+// changed returns whether the later entries of the change's resource
+// changed it since, the changes being undone along and the changes undone
+// since aside
+func changed(e *Entry, later []Entry, undoing map[bson.ObjectId]bool) bool {
+	since := make(map[bson.ObjectId]bool, len(later))
+	undone := make(map[bson.ObjectId]bool)
+	for i := range later {
+		if e.before(&later[i]) {
+			since[later[i].ID] = true
+		}
+		if len(later[i].Undo) != 0 {
+			undone[later[i].Undo] = true
+		}
+	}
+	for _, l := range later {
+		switch {
+		case !since[l.ID], undoing[l.ID]:
+		// a change and its undo leave the resource as it was
+		case undone[l.ID], len(l.Undo) != 0 && since[l.Undo]:
+		default:
+			return true
+		}
+	}
+	return false
+}
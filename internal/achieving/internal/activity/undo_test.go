@@ -0,0 +1,133 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package activity
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	t0 = time.Date(2016, time.March, 1, 9, 0, 0, 0, time.UTC)
+	g1 = bson.ObjectIdHex("56d55a2c0000000000000001")
+	a1 = bson.ObjectIdHex("56d55a2c0000000000000002")
+)
+
+// entry is a change of the goal g1 by alice, minutes after t0
+func entry(id, action string, minutes int) Entry {
+	return Entry{
+		ID:       bson.ObjectId(id),
+		Owner:    "alice",
+		Actor:    "alice",
+		Time:     t0.Add(time.Duration(minutes) * time.Minute),
+		Action:   action,
+		Resource: ResourceGoal,
+		Goal:     g1,
+	}
+}
+
+// undo is the undo of the change with the ID
+func undo(id, of string, minutes int) Entry {
+	e := entry(id, Undone, minutes)
+	e.Undo = bson.ObjectId(of)
+	return e
+}
+
+func userEntry(id, action string, minutes int) Entry {
+	e := entry(id, action, minutes)
+	e.Resource, e.Goal = ResourceUser, ""
+	return e
+}
+
+func TestLatest(t *testing.T) {
+	tests := []struct {
+		name   string
+		recent []Entry
+		count  int
+		want   []string
+	}{
+		{"none", nil, 1, nil},
+		{"count", []Entry{entry("e3", Updated, 3), entry("e2", Created, 2), entry("e1", Updated, 1)}, 2, []string{"e3", "e2"}},
+		{"fewer than the count", []Entry{entry("e1", Deleted, 1)}, 5, []string{"e1"}},
+		{"undone", []Entry{undo("u3", "e3", 4), entry("e3", Updated, 3), entry("e2", Updated, 2), entry("e1", Updated, 1)}, 2, []string{"e2", "e1"}},
+		{"undone twice", []Entry{undo("u2", "e2", 4), undo("u3", "e3", 3), entry("e3", Updated, 2), entry("e2", Updated, 1)}, 2, nil},
+		{"users", []Entry{userEntry("e3", Deleted, 3), userEntry("e2", Updated, 2), userEntry("e1", Created, 1)}, 3, []string{"e2"}},
+		{"reverted", []Entry{entry("e2", Reverted, 2), userEntry("e1", Reverted, 1)}, 2, []string{"e2", "e1"}},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, e := range latest(test.recent, test.count) {
+			got = append(got, string(e.ID))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: latest() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestChanged(t *testing.T) {
+	e := entry("e5", Updated, 5)
+	tests := []struct {
+		name    string
+		later   []Entry
+		undoing []string
+		want    bool
+	}{
+		{"none", nil, nil, false},
+		{"changed since", []Entry{entry("e6", Updated, 6)}, nil, true},
+		{"changed by another user", []Entry{func() Entry { l := entry("e6", Updated, 6); l.Actor = "bob"; return l }()}, nil, true},
+		{"undone along", []Entry{entry("e6", Updated, 6), entry("e7", Deleted, 7)}, []string{"e6", "e7"}, false},
+		{"one not undone along", []Entry{entry("e6", Updated, 6), entry("e7", Updated, 7)}, []string{"e7"}, true},
+		{"undone since", []Entry{entry("e6", Updated, 6), undo("u6", "e6", 7)}, nil, false},
+		{"earlier change undone since", []Entry{undo("u4", "e4", 6)}, nil, true},
+		{"same time, recorded before", []Entry{entry("e4", Updated, 5)}, nil, false},
+		{"same time, recorded after", []Entry{entry("e6", Updated, 5)}, nil, true},
+	}
+	for _, test := range tests {
+		undoing := map[bson.ObjectId]bool{e.ID: true}
+		for _, id := range test.undoing {
+			undoing[bson.ObjectId(id)] = true
+		}
+		if got := changed(&e, test.later, undoing); got != test.want {
+			t.Errorf("%s: changed() = %t, want %t", test.name, got, test.want)
+		}
+	}
+}
+
+func TestResource(t *testing.T) {
+	achievable := entry("e1", Updated, 1)
+	achievable.Resource, achievable.Achievable = ResourceAchievable, a1
+	tests := []struct {
+		e    Entry
+		want bson.M
+		key  string
+	}{
+		{entry("e1", Created, 1), bson.M{"_goal": g1}, "alice," + g1.Hex()},
+		{entry("e1", Deleted, 1), bson.M{"resource": ResourceGoal, "_goal": g1}, "alice," + g1.Hex()},
+		{achievable, bson.M{"resource": ResourceAchievable, "_achievable": a1}, g1.Hex() + "," + a1.Hex()},
+		{userEntry("e1", Updated, 1), bson.M{"resource": ResourceUser, "owner": "alice"}, "alice"},
+	}
+	for _, test := range tests {
+		if got := test.e.resource(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("resource() of a %s %s = %v, want %v", test.e.Resource, test.e.Action, got, test.want)
+		}
+		if got := test.e.key(); got != test.key {
+			t.Errorf("key() of a %s %s = %q, want %q", test.e.Resource, test.e.Action, got, test.key)
+		}
+	}
+}
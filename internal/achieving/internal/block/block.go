@@ -0,0 +1,142 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package block contains the users blocking other users
+package block
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/errors"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Collection is the name of the collection of the blocks
+const Collection = "blocks"
+
+// Block represents a user blocking another one: neither of them discovers
+// the other and the blocked user cannot follow the blocker
+type Block struct {
+	ID      bson.ObjectId `bson:"_id" json:"-"`
+	Blocker string        `bson:"blocker" json:"blocker"`
+	Blocked string        `bson:"blocked" json:"blocked"`
+	Created time.Time     `bson:"created" json:"created"`
+}
+
+// EnsureIndexes creates the indexes of the block collection, a user blocks
+// another one at most once
+func EnsureIndexes(col *mgo.Collection) error {
+	indexes := []mgo.Index{
+		{Key: []string{"blocker", "blocked"}, Unique: true},
+		{Key: []string{"blocked"}},
+	}
+	for _, index := range indexes {
+		if err := col.EnsureIndex(index); err != nil {
+			return fmt.Errorf("ensure block index %v: %s", index.Key, err)
+		}
+	}
+	return nil
+}
+
+// Create makes the blocker block the blocked user. Blocking someone twice is
+// reported as a duplicate
+func Create(col *mgo.Collection, blocker, blocked string) error {
+	if blocker == blocked {
+		return errors.NewValidate("users cannot block themselves")
+	}
+	err := col.Insert(Block{
+		ID:      bson.NewObjectId(),
+		Blocker: blocker,
+		Blocked: blocked,
+		Created: time.Now(),
+	})
+	if err != nil {
+		if mgo.IsDup(err) {
+			return errors.NewDuplicated("block", fmt.Sprintf("%s,%s", blocker, blocked))
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete makes the blocker stop blocking the blocked user
+func Delete(col *mgo.Collection, blocker, blocked string) error {
+	err := col.Remove(bson.M{
+		"blocker": blocker,
+		"blocked": blocked,
+	})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return errors.NewNotFound("block", fmt.Sprintf("%s,%s", blocker, blocked))
+		}
+		return err
+	}
+	return nil
+}
+
+// Between returns whether either user blocks the other
+func Between(col *mgo.Collection, a, b string) (bool, error) {
+	n, err := col.Find(bson.M{
+		"$or": []bson.M{
+			{"blocker": a, "blocked": b},
+			{"blocker": b, "blocked": a},
+		},
+	}).Count()
+	if err != nil {
+		return false, err
+	}
+	return n != 0, nil
+}
+
+// Hidden lists the users hidden from the user: the users the user blocks and
+// the users blocking the user
+func Hidden(col *mgo.Collection, username string) ([]string, error) {
+	var bs []Block
+	err := col.Find(bson.M{
+		"$or": []bson.M{
+			{"blocker": username},
+			{"blocked": username},
+		},
+	}).All(&bs)
+	if err != nil {
+		return nil, err
+	}
+	hidden := make([]string, 0, len(bs))
+	for _, b := range bs {
+		if b.Blocker == username {
+			hidden = append(hidden, b.Blocked)
+		} else {
+			hidden = append(hidden, b.Blocker)
+		}
+	}
+	return hidden, nil
+}
+
+// Blocked lists the users the user blocks, the latest first
+func Blocked(col *mgo.Collection, username string, limit, offset int) ([]Block, error) {
+	q := col.Find(bson.M{"blocker": username}).Sort("-created")
+	if limit > 0 {
+		q.Limit(limit)
+	}
+	if offset > 0 {
+		q.Skip(offset)
+	}
+	var bs []Block
+	if err := q.All(&bs); err != nil {
+		return nil, err
+	}
+	return bs, nil
+}
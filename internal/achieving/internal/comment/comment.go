@@ -0,0 +1,270 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package comment contains the comments and the reactions on the goals and
+// on their achievables
+package comment
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/errors"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Collection is the name of the collection of the comments
+const Collection = "comments"
+
+// Comment is a comment on a goal or on one of its achievables. The replies
+// are comments on the same goal or achievable
+type Comment struct {
+	ID         bson.ObjectId `bson:"_id,omitempty" json:"id,omitempty" valid:"optional,hexadecimal"`
+	Goal       bson.ObjectId `bson:"_goal" json:"-" valid:"-"`
+	Achievable bson.ObjectId `bson:"_achievable,omitempty" json:"achievable,omitempty" valid:"-"`
+	// Parent is the comment replied to, if any
+	Parent bson.ObjectId `bson:"parent,omitempty" json:"parent,omitempty" valid:"optional,hexadecimal"`
+	// Thread is the first comment of the thread, the comment itself if it
+	// replies to none
+	Thread  bson.ObjectId `bson:"thread" json:"-" valid:"-"`
+	Author  string        `bson:"author" json:"author" valid:"-"`
+	Text    string        `bson:"text" json:"text" valid:"required,stringlength(1|2000)"`
+	Created time.Time     `bson:"created" json:"created" valid:"-"`
+	Edited  *time.Time    `bson:"edited,omitempty" json:"edited,omitempty" valid:"-"`
+	// Deleted comments having replies keep their place in the thread,
+	// their text removed
+	Deleted bool `bson:"deleted,omitempty" json:"deleted,omitempty" valid:"-"`
+}
+
+// Thread is a comment replying to none and all the replies under it, the
+// oldest first
+type Thread struct {
+	Comment `bson:",inline" valid:"required"`
+	Replies []Comment `bson:"-" json:"replies,omitempty" valid:"-"`
+}
+
+// EnsureIndexes creates the indexes of the comment and the reaction
+// collections, a user reacts with an emoji at most once
+func EnsureIndexes(comments, reactions *mgo.Collection) error {
+	indexes := []struct {
+		col   *mgo.Collection
+		index mgo.Index
+	}{
+		{comments, mgo.Index{Key: []string{"_goal", "_achievable", "created"}}},
+		{comments, mgo.Index{Key: []string{"thread", "created"}}},
+		{reactions, mgo.Index{Key: []string{"_goal", "_achievable", "username", "emoji"}, Unique: true}},
+	}
+	for _, i := range indexes {
+		if err := i.col.EnsureIndex(i.index); err != nil {
+			return fmt.Errorf("ensure %s index %v: %s", i.col.Name, i.index.Key, err)
+		}
+	}
+	return nil
+}
+
+// on selects the documents about the goal, or about its achievable if
+// achievable is not empty
+func on(goal, achievable bson.ObjectId) bson.M {
+	q := bson.M{"_goal": goal}
+	if len(achievable) == 0 {
+		q["_achievable"] = bson.M{"$exists": false}
+	} else {
+		q["_achievable"] = achievable
+	}
+	return q
+}
+
+// Add adds the comment of the author on the goal or its achievable, a reply
+// must be about the same goal or achievable as its parent
+func Add(col *mgo.Collection, c *Comment, goal, achievable bson.ObjectId, author string) (bson.ObjectId, error) {
+	id := bson.NewObjectId()
+	c.ID, c.Goal, c.Achievable, c.Author = id, goal, achievable, author
+	c.Created, c.Edited, c.Deleted = time.Now(), nil, false
+	c.Thread = id
+	if len(c.Parent) != 0 {
+		parent, err := Retrieve(col, goal, c.Parent)
+		if err != nil {
+			return id, err
+		}
+		if parent.Achievable != achievable {
+			return id, errors.NewValidateFields([]errors.Field{{
+				Name:    "parent",
+				Rule:    "sameTarget",
+				Message: "the reply must be about what the comment replied to is about",
+			}})
+		}
+		if parent.Deleted {
+			return id, errors.NewValidateFields([]errors.Field{{
+				Name:    "parent",
+				Rule:    "notDeleted",
+				Message: "the comment replied to is deleted",
+			}})
+		}
+		c.Thread = parent.Thread
+	}
+	if err := col.Insert(c); err != nil {
+		return id, err
+	}
+	return id, nil
+}
+
+// Retrieve gets a comment on the goal or on one of its achievables
+func Retrieve(col *mgo.Collection, goal, id bson.ObjectId) (Comment, error) {
+	var c Comment
+	err := col.Find(bson.M{
+		"_goal": goal,
+		"_id":   id,
+	}).One(&c)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return c, errors.NewNotFound("comment", fmt.Sprintf("%s,%s", goal.Hex(), id.Hex()))
+		}
+		return c, err
+	}
+	return c, nil
+}
+
+// Update changes the text of the comment, only its author can
+func Update(col *mgo.Collection, goal, id bson.ObjectId, author, text string) error {
+	c, err := Retrieve(col, goal, id)
+	if err != nil {
+		return err
+	}
+	if c.Author != author {
+		return errors.ErrForbidden
+	}
+	if c.Deleted {
+		return errors.NewNotFound("comment", fmt.Sprintf("%s,%s", goal.Hex(), id.Hex()))
+	}
+	err = col.UpdateId(id, bson.M{
+		"$set": bson.M{
+			"text":   text,
+			"edited": time.Now(),
+		},
+	})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return errors.NewNotFound("comment", fmt.Sprintf("%s,%s", goal.Hex(), id.Hex()))
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete deletes the comment on behalf of the user, who is either its author
+// or the owner of the goal moderating it. A comment having replies is kept
+// without its text, and the deleted comments left without replies are
+// removed
+func Delete(col *mgo.Collection, goal, id bson.ObjectId, username, owner string) error {
+	c, err := Retrieve(col, goal, id)
+	if err != nil {
+		return err
+	}
+	if c.Author != username && owner != username {
+		return errors.ErrForbidden
+	}
+	if c.Deleted {
+		return errors.NewNotFound("comment", fmt.Sprintf("%s,%s", goal.Hex(), id.Hex()))
+	}
+	for {
+		replies, err := col.Find(bson.M{"parent": c.ID}).Count()
+		if err != nil {
+			return err
+		}
+		if replies != 0 {
+			return col.UpdateId(c.ID, bson.M{
+				"$set": bson.M{
+					"text":    "",
+					"deleted": true,
+				},
+			})
+		}
+		if err = col.RemoveId(c.ID); err != nil && err != mgo.ErrNotFound {
+			return err
+		}
+		if len(c.Parent) == 0 {
+			return nil
+		}
+		// the parent may be a deleted comment left without replies
+		if c, err = Retrieve(col, goal, c.Parent); err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		if !c.Deleted {
+			return nil
+		}
+	}
+}
+
+// Threads lists the threads about the goal, or about its achievable if
+// achievable is not empty, the oldest first
+func Threads(col *mgo.Collection, goal, achievable bson.ObjectId, limit, offset int) ([]Thread, error) {
+	q := on(goal, achievable)
+	q["parent"] = bson.M{"$exists": false}
+	query := col.Find(q).Sort("created", "_id")
+	if limit > 0 {
+		query.Limit(limit)
+	}
+	if offset > 0 {
+		query.Skip(offset)
+	}
+	var roots []Comment
+	if err := query.All(&roots); err != nil {
+		return nil, err
+	}
+	threads := make([]Thread, len(roots))
+	ids := make([]bson.ObjectId, len(roots))
+	index := make(map[bson.ObjectId]int, len(roots))
+	for i, c := range roots {
+		threads[i].Comment = c
+		ids[i] = c.ID
+		index[c.ID] = i
+	}
+	if len(ids) == 0 {
+		return threads, nil
+	}
+	var replies []Comment
+	err := col.Find(bson.M{
+		"thread": bson.M{"$in": ids},
+		"parent": bson.M{"$exists": true},
+	}).Sort("created", "_id").All(&replies)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range replies {
+		if i, ok := index[r.Thread]; ok {
+			threads[i].Replies = append(threads[i].Replies, r)
+		}
+	}
+	return threads, nil
+}
+
+// RemoveAll removes the comments and the reactions about the goal, or about
+// its achievable if achievable is not empty
+func RemoveAll(comments, reactions *mgo.Collection, goal, achievable bson.ObjectId) error {
+	q := bson.M{"_goal": goal}
+	if len(achievable) != 0 {
+		q["_achievable"] = achievable
+	}
+	if _, err := comments.RemoveAll(q); err != nil {
+		return err
+	}
+	if _, err := reactions.RemoveAll(q); err != nil {
+		return err
+	}
+	return nil
+}
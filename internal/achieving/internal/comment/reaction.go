@@ -0,0 +1,171 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package comment
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/iocat/donit/internal/achieving/errors"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ReactionCollection is the name of the collection of the reactions
+const ReactionCollection = "reactions"
+
+// maxEmojiLength is the length in bytes of the longest emoji sequence
+// accepted, long enough for the flags and the families
+const maxEmojiLength = 32
+
+// Reaction is the emoji a user reacted with on a goal or on one of its
+// achievables
+type Reaction struct {
+	ID         bson.ObjectId `bson:"_id"`
+	Goal       bson.ObjectId `bson:"_goal"`
+	Achievable bson.ObjectId `bson:"_achievable,omitempty"`
+	Username   string        `bson:"username"`
+	Emoji      string        `bson:"emoji"`
+	Created    time.Time     `bson:"created"`
+}
+
+// Count is the number of the reactions with an emoji
+type Count struct {
+	Emoji string `bson:"_id" json:"emoji"`
+	Count int    `bson:"count" json:"count"`
+	// Reacted tells whether the user viewing the reactions reacted with
+	// the emoji
+	Reacted bool `bson:"-" json:"reacted"`
+}
+
+// byCount sorts the counts, the most used emoji first
+type byCount []Count
+
+func (c byCount) Len() int      { return len(c) }
+func (c byCount) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byCount) Less(i, j int) bool {
+	if c[i].Count != c[j].Count {
+		return c[i].Count > c[j].Count
+	}
+	return c[i].Emoji < c[j].Emoji
+}
+
+// ValidEmoji returns whether the string is a single emoji: symbols, possibly
+// joined and modified
+func ValidEmoji(s string) bool {
+	if len(s) == 0 || len(s) > maxEmojiLength || !utf8.ValidString(s) {
+		return false
+	}
+	symbol := false
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.So, r),
+			r == '\u20e3': // combining keycap
+			symbol = true
+		case r == '\u200d', // zero width joiner
+			r == '\ufe0e', r == '\ufe0f', // variation selectors
+			r >= 0x1f3fb && r <= 0x1f3ff, // skin tones
+			r >= 0xe0020 && r <= 0xe007f, // tag sequences
+			r >= '0' && r <= '9', r == '#', r == '*':
+		default:
+			return false
+		}
+	}
+	return symbol
+}
+
+// checkEmoji reports the invalid emoji
+func checkEmoji(emoji string) error {
+	if !ValidEmoji(emoji) {
+		return errors.NewValidateFields([]errors.Field{{
+			Name:    "emoji",
+			Rule:    "emoji",
+			Message: fmt.Sprintf("%q is not an emoji", emoji),
+		}})
+	}
+	return nil
+}
+
+// React makes the user react with the emoji on the goal, or on its
+// achievable if achievable is not empty. Reacting twice with the same emoji
+// is reported as a duplicate
+func React(col *mgo.Collection, goal, achievable bson.ObjectId, username, emoji string) error {
+	if err := checkEmoji(emoji); err != nil {
+		return err
+	}
+	err := col.Insert(Reaction{
+		ID:         bson.NewObjectId(),
+		Goal:       goal,
+		Achievable: achievable,
+		Username:   username,
+		Emoji:      emoji,
+		Created:    time.Now(),
+	})
+	if err != nil {
+		if mgo.IsDup(err) {
+			return errors.NewDuplicated("reaction", fmt.Sprintf("%s,%s", username, emoji))
+		}
+		return err
+	}
+	return nil
+}
+
+// Unreact removes the reaction of the user with the emoji
+func Unreact(col *mgo.Collection, goal, achievable bson.ObjectId, username, emoji string) error {
+	q := on(goal, achievable)
+	q["username"], q["emoji"] = username, emoji
+	if err := col.Remove(q); err != nil {
+		if err == mgo.ErrNotFound {
+			return errors.NewNotFound("reaction", fmt.Sprintf("%s,%s", username, emoji))
+		}
+		return err
+	}
+	return nil
+}
+
+// Counts counts the reactions on the goal, or on its achievable if
+// achievable is not empty, per emoji. The emojis the viewer reacted with are
+// flagged
+func Counts(col *mgo.Collection, goal, achievable bson.ObjectId, viewer string) ([]Count, error) {
+	cs := []Count{}
+	err := col.Pipe([]bson.M{
+		{"$match": on(goal, achievable)},
+		{"$group": bson.M{"_id": "$emoji", "count": bson.M{"$sum": 1}}},
+	}).All(&cs)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(byCount(cs))
+	if len(viewer) == 0 || len(cs) == 0 {
+		return cs, nil
+	}
+	q := on(goal, achievable)
+	q["username"] = viewer
+	var own []Reaction
+	if err = col.Find(q).All(&own); err != nil {
+		return nil, err
+	}
+	reacted := make(map[string]bool, len(own))
+	for _, r := range own {
+		reacted[r.Emoji] = true
+	}
+	for i := range cs {
+		cs[i].Reacted = reacted[cs[i].Emoji]
+	}
+	return cs, nil
+}
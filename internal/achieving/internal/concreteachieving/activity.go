@@ -0,0 +1,155 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package concreteachieving
+
+import (
+	"fmt"
+
+	"github.com/iocat/donit/internal/achieving"
+	"github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/achieving/internal/activity"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Activity represents a concrete achieving.Activity
+type Activity struct {
+	activity.Entry `valid:"required"`
+}
+
+// ActivityAction implements achieving.Activity's ActivityAction
+func (a *Activity) ActivityAction() string {
+	return a.Action
+}
+
+// ActivityID implements achieving.Activity's ActivityID
+func (a *Activity) ActivityID() string {
+	return a.ID.Hex()
+}
+
+// activities wraps the entries of the log
+func activities(es []activity.Entry) []achieving.Activity {
+	as := make([]achieving.Activity, 0, len(es))
+	for _, e := range es {
+		as = append(as, &Activity{Entry: e})
+	}
+	return as
+}
+
+// Acting returns the store recording the user as the actor of the changes
+func (s Store) Acting(username string) achieving.UserStore {
+	s.actor = username
+	return &s
+}
+
+// record logs the change of the user's data
+func (s Store) record(action, username string, before, after *activity.Revision) error {
+	actor := s.actor
+	if len(actor) == 0 {
+		actor = username
+	}
+	e := activity.Entry{
+		Owner:    username,
+		Actor:    actor,
+		Action:   action,
+		Resource: activity.ResourceUser,
+		Before:   before,
+		After:    after,
+	}
+	undo(&e, s.undoing)
+	return activity.Record(s.activityCollection, &e)
+}
+
+// RetrieveActivity lists the changes of the user's resources and the changes
+// the user made, the latest first
+func (s Store) RetrieveActivity(username string, limit, offset int) ([]achieving.Activity, error) {
+	es, err := activity.OfUser(s.activityCollection, username, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return activities(es), nil
+}
+
+// changed returns the action of an update, a revert if the resource is
+// reverted to a revision
+func changed(revision bson.ObjectId) string {
+	if len(revision) != 0 {
+		return activity.Reverted
+	}
+	return activity.Updated
+}
+
+// undo marks the entry as undoing the change if the changes undo one
+func undo(e *activity.Entry, undoing bson.ObjectId) {
+	if len(undoing) != 0 {
+		e.Action, e.Undo = activity.Undone, undoing
+	}
+}
+
+// record logs the change of the user's goal
+func (c User) record(e activity.Entry) error {
+	e.Owner, e.Actor, e.Resource = c.Username, c.actor, activity.ResourceGoal
+	undo(&e, c.undoing)
+	return activity.Record(c.activityCollection, &e)
+}
+
+// record logs the change of the goal's achievable
+func (cg *Goal) record(e activity.Entry) error {
+	e.Owner, e.Actor, e.Resource = cg.Username, cg.actor, activity.ResourceAchievable
+	e.Goal = cg.ID
+	undo(&e, cg.undoing)
+	return activity.Record(cg.activityCollection, &e)
+}
+
+// RetrieveActivity lists the changes of the goal and of its achievables, the
+// latest first
+func (cg *Goal) RetrieveActivity(limit, offset int) ([]achieving.Activity, error) {
+	es, err := activity.OfGoal(cg.activityCollection, cg.ID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return activities(es), nil
+}
+
+// RevertGoal reverts the goal, or its achievable, to the revision the entry
+// of its log made
+func (c User) RevertGoal(id, entry string) error {
+	gid, err := goalID(id)
+	if err != nil {
+		return err
+	}
+	if !bson.IsObjectIdHex(entry) {
+		return errors.NewValidate(fmt.Sprintf("%s is not a valid resource id", entry))
+	}
+	e, err := activity.Retrieve(c.activityCollection, gid, bson.ObjectIdHex(entry))
+	if err != nil {
+		return err
+	}
+	if e.After == nil {
+		return errors.NewValidate(fmt.Sprintf("the change %s deleted the resource", entry))
+	}
+	switch {
+	case e.After.Goal != nil:
+		return c.updateGoal(&Goal{Goal: *e.After.Goal}, id, e.ID)
+	case e.After.Achievable != nil:
+		g, err := c.RetrieveGoal(id)
+		if err != nil {
+			return err
+		}
+		return g.(*Goal).updateAchievable(&Achievable{Achievable: *e.After.Achievable},
+			e.Achievable.Hex(), e.ID)
+	default:
+		return errors.NewValidate(fmt.Sprintf("the change %s cannot be reverted", entry))
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package concreteachieving
+
+import (
+	"fmt"
+
+	"github.com/iocat/donit/internal/achieving"
+	"github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/achieving/internal/block"
+	"github.com/iocat/donit/internal/achieving/internal/comment"
+	"github.com/iocat/donit/internal/events"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Comment represents a concrete achieving.Comment, a thread of comments
+type Comment struct {
+	comment.Thread `valid:"required"`
+}
+
+// CommentText implements achieving.Comment's CommentText
+func (c *Comment) CommentText() string {
+	return c.Text
+}
+
+// Reaction represents a concrete achieving.Reaction
+type Reaction struct {
+	comment.Count
+}
+
+// ReactionEmoji implements achieving.Reaction's ReactionEmoji
+func (r *Reaction) ReactionEmoji() string {
+	return r.Emoji
+}
+
+// commentID checks the comment id
+func commentID(id string) (bson.ObjectId, error) {
+	if !bson.IsObjectIdHex(id) {
+		return "", errors.NewValidate(fmt.Sprintf("%s is not a valid resource id", id))
+	}
+	return bson.ObjectIdHex(id), nil
+}
+
+// target checks that the user can comment or react on the goal, or on its
+// achievable if id is not empty, and returns the ID and the name of what is
+// commented on. The goals of the users blocking the user or blocked by them
+// are not found
+func (cg *Goal) target(username, id string) (bson.ObjectId, string, error) {
+	if !cg.VisibleTo(username) {
+		return "", "", errors.NewNotFound("goal", fmt.Sprintf("%s,%s", cg.Username, cg.ID.Hex()))
+	}
+	if username != cg.Username {
+		blocked, err := block.Between(cg.blockCollection, username, cg.Username)
+		if err != nil {
+			return "", "", err
+		}
+		if blocked {
+			return "", "", errors.NewNotFound("goal", fmt.Sprintf("%s,%s", cg.Username, cg.ID.Hex()))
+		}
+	}
+	if len(id) == 0 {
+		return "", cg.Name, nil
+	}
+	aid, err := commentID(id)
+	if err != nil {
+		return "", "", err
+	}
+	a, err := cg.Goal.RetrieveAchievable(cg.achievableCollection, aid)
+	if err != nil {
+		return "", "", err
+	}
+	return aid, a.Name, nil
+}
+
+// AddComment adds the author's comment on the goal or on its achievable
+func (cg *Goal) AddComment(author string, c achieving.Comment, achievable string) (string, error) {
+	if c, ok := c.(*Comment); ok {
+		aid, name, err := cg.target(author, achievable)
+		if err != nil {
+			return "", err
+		}
+		id, err := comment.Add(cg.commentCollection, &(c.Comment), cg.ID, aid, author)
+		if err != nil {
+			return "", err
+		}
+		e := cg.event(events.Commented, achievable, name)
+		e.Username = author
+		if len(c.Parent) != 0 {
+			parent, err := comment.Retrieve(cg.commentCollection, cg.ID, c.Parent)
+			if err == nil {
+				e.Target = parent.Author
+			}
+		}
+		events.Publish(cg.events, e)
+		return id.Hex(), nil
+	}
+	return "", fmt.Errorf("wrong data type, expect Comment, got %T", c)
+}
+
+// UpdateComment changes the text of the author's comment
+func (cg *Goal) UpdateComment(author string, c achieving.Comment, id string) error {
+	if c, ok := c.(*Comment); ok {
+		cid, err := commentID(id)
+		if err != nil {
+			return err
+		}
+		return comment.Update(cg.commentCollection, cg.ID, cid, author, c.Text)
+	}
+	return fmt.Errorf("wrong data type, expect Comment, got %T", c)
+}
+
+// DeleteComment deletes the comment on behalf of its author or of the goal's
+// owner
+func (cg *Goal) DeleteComment(username, id string) error {
+	cid, err := commentID(id)
+	if err != nil {
+		return err
+	}
+	return comment.Delete(cg.commentCollection, cg.ID, cid, username, cg.Username)
+}
+
+// RetrieveComments lists the threads of comments on the goal or on its
+// achievable
+func (cg *Goal) RetrieveComments(achievable string, limit, offset int) ([]achieving.Comment, error) {
+	var aid bson.ObjectId
+	if len(achievable) != 0 {
+		var err error
+		if aid, err = commentID(achievable); err != nil {
+			return nil, err
+		}
+	}
+	ts, err := comment.Threads(cg.commentCollection, cg.ID, aid, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	cs := make([]achieving.Comment, 0, len(ts))
+	for _, t := range ts {
+		cs = append(cs, &Comment{Thread: t})
+	}
+	return cs, nil
+}
+
+// React makes the user react with the emoji on the goal or on its achievable
+func (cg *Goal) React(username, emoji, achievable string) error {
+	aid, name, err := cg.target(username, achievable)
+	if err != nil {
+		return err
+	}
+	if err = comment.React(cg.reactionCollection, cg.ID, aid, username, emoji); err != nil {
+		return err
+	}
+	e := cg.event(events.Reacted, achievable, name)
+	e.Username = username
+	events.Publish(cg.events, e)
+	return nil
+}
+
+// Unreact removes the user's reaction with the emoji
+func (cg *Goal) Unreact(username, emoji, achievable string) error {
+	var aid bson.ObjectId
+	if len(achievable) != 0 {
+		var err error
+		if aid, err = commentID(achievable); err != nil {
+			return err
+		}
+	}
+	return comment.Unreact(cg.reactionCollection, cg.ID, aid, username, emoji)
+}
+
+// RetrieveReactions counts the reactions on the goal or on its achievable
+func (cg *Goal) RetrieveReactions(achievable, viewer string) ([]achieving.Reaction, error) {
+	var aid bson.ObjectId
+	if len(achievable) != 0 {
+		var err error
+		if aid, err = commentID(achievable); err != nil {
+			return nil, err
+		}
+	}
+	cs, err := comment.Counts(cg.reactionCollection, cg.ID, aid, viewer)
+	if err != nil {
+		return nil, err
+	}
+	rs := make([]achieving.Reaction, 0, len(cs))
+	for _, c := range cs {
+		rs = append(rs, &Reaction{Count: c})
+	}
+	return rs, nil
+}
@@ -16,10 +16,18 @@ package concreteachieving
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/iocat/donit/internal/achieving"
 	"github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+	"github.com/iocat/donit/internal/achieving/internal/activity"
+	"github.com/iocat/donit/internal/achieving/internal/comment"
+	"github.com/iocat/donit/internal/achieving/internal/follow"
 	"github.com/iocat/donit/internal/achieving/internal/goal"
+	"github.com/iocat/donit/internal/achieving/internal/tag"
+	"github.com/iocat/donit/internal/achieving/internal/workspace"
+	"github.com/iocat/donit/internal/events"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -27,7 +35,75 @@ import (
 // Goal implements the achieving.Goal interface
 type Goal struct {
 	goal.Goal            `valid:"required"`
-	achievableCollection *mgo.Collection `valid:"-"`
+	achievableCollection *mgo.Collection  `valid:"-"`
+	tagCollection        *mgo.Collection  `valid:"-"`
+	blockCollection      *mgo.Collection  `valid:"-"`
+	followCollection     *mgo.Collection  `valid:"-"`
+	commentCollection    *mgo.Collection  `valid:"-"`
+	reactionCollection   *mgo.Collection  `valid:"-"`
+	memberCollection     *mgo.Collection  `valid:"-"`
+	workspaceCollection  *mgo.Collection  `valid:"-"`
+	userCollection       *mgo.Collection  `valid:"-"`
+	activityCollection   *mgo.Collection  `valid:"-"`
+	events               events.Publisher `valid:"-"`
+	// actor is the user the changes are made by
+	actor string `valid:"-"`
+	// undoing is the change the changes undo, if any
+	undoing bson.ObjectId `valid:"-"`
+	// location is the owner's time zone, nil for UTC
+	location *time.Location `valid:"-"`
+}
+
+// Location returns the owner's time zone
+func (cg *Goal) Location() *time.Location {
+	if cg.location == nil {
+		return time.UTC
+	}
+	return cg.location
+}
+
+// localize localizes the task in the owner's time zone. The habits stored
+// before the recurrence rules get the rule of their cycle
+func (cg *Goal) localize(a *achievable.Achievable) {
+	if a.RepeatReminder != nil && len(a.RepeatReminder.Rule) == 0 {
+		// NOTE: a broken cycle is returned as is
+		_ = a.RepeatReminder.Normalize()
+	}
+	a.Localize(time.Now(), cg.Location())
+}
+
+// VisibleTo returns whether the user can see the goal, the FOR_FOLLOWERS
+// goals show to the followers of their owner and the WORKSPACE goals to the
+// members of their workspace
+func (cg *Goal) VisibleTo(username string) bool {
+	if cg.Goal.VisibleTo(username) {
+		return true
+	}
+	if len(username) == 0 {
+		return false
+	}
+	var ok bool
+	var err error
+	switch cg.Accessibility {
+	case goal.AccessForFollowers:
+		ok, err = follow.IsFollowing(cg.followCollection, username, cg.Username)
+	case goal.AccessWorkspace:
+		ok, err = workspace.IsMember(cg.workspaceCollection, cg.Workspace, username)
+	}
+	// NOTE: the goal is hidden if the relationship cannot be checked
+	return err == nil && ok
+}
+
+// event creates an event about the goal's task
+func (cg *Goal) event(kind events.Kind, id, name string) events.Event {
+	return events.Event{
+		Kind:       kind,
+		Username:   cg.Username,
+		Owner:      cg.Username,
+		Goal:       cg.ID.Hex(),
+		Achievable: id,
+		Name:       name,
+	}
 }
 
 // NewGoal creates a new goal
@@ -40,11 +116,19 @@ func NewGoal(taskCol *mgo.Collection) *Goal {
 // AddAchievable adds a new achievable task
 func (cg *Goal) AddAchievable(a achieving.Achievable) (string, error) {
 	if a, ok := a.(*Achievable); ok {
+		if err := tag.Check(cg.tagCollection, cg.Username, a.Tags); err != nil {
+			return "", err
+		}
 		id, err := cg.Goal.AddAchievable(cg.achievableCollection, &(a.Achievable))
 		if err != nil {
 			return "", err
 		}
-		return id.Hex(), nil
+		events.Publish(cg.events, cg.event(events.AchievableCreated, id.Hex(), a.Name))
+		return id.Hex(), cg.record(activity.Entry{
+			Action:     activity.Created,
+			Achievable: id,
+			After:      activity.AchievableRevision(a.Achievable),
+		})
 	}
 	return "", fmt.Errorf("wrong data type, expect Achievable, got %T", a)
 }
@@ -55,33 +139,147 @@ func (cg *Goal) RemoveAchievable(id string) error {
 	if !ok {
 		return errors.NewValidate(fmt.Sprintf("%s is not a valid resource id", id))
 	}
-	return cg.Goal.RemoveAchievable(cg.achievableCollection, bson.ObjectIdHex(id))
+	old, err := cg.Goal.RemoveAchievable(cg.achievableCollection, bson.ObjectIdHex(id))
+	if err != nil {
+		return err
+	}
+	if err = comment.RemoveAll(cg.commentCollection, cg.reactionCollection, cg.ID, bson.ObjectIdHex(id)); err != nil {
+		return err
+	}
+	events.Publish(cg.events, cg.event(events.AchievableDeleted, id, ""))
+	return cg.record(activity.Entry{
+		Action:     activity.Deleted,
+		Achievable: old.ID,
+		Before:     activity.AchievableRevision(old),
+	})
 }
 
 // UpdateAchievable updates the task
 func (cg *Goal) UpdateAchievable(a achieving.Achievable, id string) error {
+	return cg.updateAchievable(a, id, "")
+}
+
+// updateAchievable updates the task, or reverts it to the revision of the
+// entry of the goal's log if any
+func (cg *Goal) updateAchievable(a achieving.Achievable, id string, revision bson.ObjectId) error {
 	if a, ok := a.(*Achievable); ok {
 		ok := bson.IsObjectIdHex(id)
 		if !ok {
 			return errors.NewValidate(fmt.Sprintf("%s is not a valid resource id", id))
 		}
-		return cg.Goal.UpdateAchievable(cg.achievableCollection, &(a.Achievable),
+		if err := tag.Check(cg.tagCollection, cg.Username, a.Tags); err != nil {
+			return err
+		}
+		old, err := cg.Goal.UpdateAchievable(cg.achievableCollection, &(a.Achievable),
 			bson.ObjectIdHex(id))
+		if err != nil {
+			return err
+		}
+		events.Publish(cg.events, cg.event(events.AchievableUpdated, id, a.Name))
+		if !old.HasAchieved() && a.HasAchieved() {
+			events.Publish(cg.events, cg.event(events.AchievableCompleted, id, a.Name))
+		}
+		return cg.record(activity.Entry{
+			Action:     changed(revision),
+			Achievable: a.ID,
+			Before:     activity.AchievableRevision(old),
+			After:      activity.AchievableRevision(a.Achievable),
+			Revision:   revision,
+		})
 	}
 	return fmt.Errorf("wrong data type, expect Achievable, got %T", a)
 }
 
-// RetrieveAchievables retrieves the task list
-func (cg *Goal) RetrieveAchievables(limit, offset int) ([]achieving.Achievable, error) {
-	as, err := cg.Goal.RetrieveAchievables(cg.achievableCollection, limit, offset)
+// linked retrieves every task of the goal, the flags computed from their
+// relationships set
+func (cg *Goal) linked() ([]achievable.Achievable, error) {
+	as, err := cg.Goal.RetrieveAchievables(cg.achievableCollection, 0, 0)
 	if err != nil {
 		return nil, err
 	}
+	goal.Link(as)
+	return as, nil
+}
+
+// page wraps the page of the tasks
+func (cg *Goal) page(as []achievable.Achievable, limit, offset int) []achieving.Achievable {
+	if offset < 0 {
+		offset = 0
+	} else if offset > len(as) {
+		offset = len(as)
+	}
+	as = as[offset:]
+	if limit > 0 && limit < len(as) {
+		as = as[:limit]
+	}
 	var res []achieving.Achievable
 	for _, a := range as {
+		cg.localize(&a)
 		res = append(res, &Achievable{
 			Achievable: a,
 		})
 	}
-	return res, nil
+	return res
+}
+
+// RetrieveAchievables retrieves the task list
+func (cg *Goal) RetrieveAchievables(limit, offset int) ([]achieving.Achievable, error) {
+	as, err := cg.linked()
+	if err != nil {
+		return nil, err
+	}
+	return cg.page(as, limit, offset), nil
+}
+
+// RetrieveOrderedAchievables retrieves the task list in dependency order
+func (cg *Goal) RetrieveOrderedAchievables(limit, offset int) ([]achieving.Achievable, error) {
+	as, err := cg.linked()
+	if err != nil {
+		return nil, err
+	}
+	return cg.page(goal.Order(as), limit, offset), nil
+}
+
+// RetrieveTaggedAchievables retrieves the tasks tagged with the tag
+func (cg *Goal) RetrieveTaggedAchievables(id string, limit, offset int) ([]achieving.Achievable, error) {
+	tid, err := tagID(id)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := tag.Aliases(cg.tagCollection, cg.Username, tid)
+	if err != nil {
+		return nil, err
+	}
+	as, err := cg.linked()
+	if err != nil {
+		return nil, err
+	}
+	var tagged []achievable.Achievable
+	for _, a := range as {
+		if a.TaggedWith(ids) {
+			tagged = append(tagged, a)
+		}
+	}
+	return cg.page(tagged, limit, offset), nil
+}
+
+// RetrieveAchievable retrieves a task
+func (cg *Goal) RetrieveAchievable(id string) (achieving.Achievable, error) {
+	ok := bson.IsObjectIdHex(id)
+	if !ok {
+		return nil, errors.NewValidate(fmt.Sprintf("%s is not a valid resource id", id))
+	}
+	as, err := cg.linked()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range as {
+		if a.ID == bson.ObjectIdHex(id) {
+			cg.localize(&a)
+			return &Achievable{
+				Achievable: a,
+			}, nil
+		}
+	}
+	return nil, errors.NewNotFound("achievable", fmt.Sprintf("%s,%s", cg.ID.Hex(), id))
 }
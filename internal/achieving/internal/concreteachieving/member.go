@@ -0,0 +1,175 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package concreteachieving
+
+import (
+	"fmt"
+
+	"github.com/iocat/donit/internal/achieving"
+	"github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/achieving/internal/block"
+	"github.com/iocat/donit/internal/achieving/internal/goal"
+	"github.com/iocat/donit/internal/achieving/internal/member"
+	"github.com/iocat/donit/internal/achieving/internal/user"
+	"github.com/iocat/donit/internal/achieving/internal/workspace"
+	"github.com/iocat/donit/internal/events"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Member represents a concrete achieving.Member
+type Member struct {
+	member.Member `valid:"required"`
+}
+
+// MemberRole implements achieving.Member's MemberRole
+func (m *Member) MemberRole() string {
+	return m.Role
+}
+
+// SetMember invites the user to the goal with the role of the member, or
+// changes the role of the user if the user is a member already. Only the
+// owners can
+func (cg *Goal) SetMember(by string, m achieving.Member, username string) error {
+	if m, ok := m.(*Member); ok {
+		if !cg.CanManage(by) {
+			return errors.ErrForbidden
+		}
+		if cg.Member(username) != nil {
+			return member.SetRole(cg.memberCollection, cg.ID, username, m.Role)
+		}
+		var u user.User
+		if err := u.Retrieve(cg.userCollection, username); err != nil {
+			return err
+		}
+		if len(cg.Workspace) != 0 {
+			// the goals of a workspace stay in it
+			ok, err := workspace.IsMember(cg.workspaceCollection, cg.Workspace, username)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return errors.NewValidate(fmt.Sprintf("%s is not a member of the goal's workspace", username))
+			}
+		}
+		blocked, err := block.Between(cg.blockCollection, cg.Username, username)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return errors.NewNotFound("user", username)
+		}
+		if err = member.Invite(cg.memberCollection, cg.ID, cg.Username, username, m.Role, by); err != nil {
+			return err
+		}
+		events.Publish(cg.events, events.Event{
+			Kind:     events.Invited,
+			Username: by,
+			Owner:    cg.Username,
+			Goal:     cg.ID.Hex(),
+			Target:   username,
+			Name:     cg.Name,
+		})
+		return nil
+	}
+	return fmt.Errorf("wrong data type, expect Member, got %T", m)
+}
+
+// RemoveMember removes the member or the invite of the user on behalf of an
+// owner or of the user leaving. The achievables assigned to the user are
+// unassigned
+func (cg *Goal) RemoveMember(by, username string) error {
+	if by != username && !cg.CanManage(by) {
+		return errors.ErrForbidden
+	}
+	if err := member.Remove(cg.memberCollection, cg.ID, username); err != nil {
+		return err
+	}
+	return cg.Goal.Unassign(cg.achievableCollection, username)
+}
+
+// RetrieveMembers lists the members of the goal and the users invited to it
+func (cg *Goal) RetrieveMembers() []achieving.Member {
+	ms := make([]achieving.Member, 0, len(cg.Members))
+	for _, m := range cg.Members {
+		ms = append(ms, &Member{Member: m})
+	}
+	return ms
+}
+
+// goalID checks the goal id
+func goalID(id string) (bson.ObjectId, error) {
+	if !bson.IsObjectIdHex(id) {
+		return "", errors.NewValidate(fmt.Sprintf("%s is not a valid resource id", id))
+	}
+	return bson.ObjectIdHex(id), nil
+}
+
+// AcceptMembership accepts the user's invite to the goal
+func (s Store) AcceptMembership(username, id string) error {
+	gid, err := goalID(id)
+	if err != nil {
+		return err
+	}
+	m, err := member.Accept(s.memberCollection, gid, username)
+	if err != nil {
+		return err
+	}
+	var g goal.Goal
+	if err = s.goalCollection.FindId(gid).Select(bson.M{"name": 1}).One(&g); err != nil {
+		// the membership is accepted, the event goes without the name
+		g.Name = ""
+	}
+	events.Publish(s.events, events.Event{
+		Kind:     events.Joined,
+		Username: username,
+		Owner:    m.Owner,
+		Goal:     id,
+		Target:   m.InvitedBy,
+		Name:     g.Name,
+	})
+	return nil
+}
+
+// LeaveMembership declines the user's invite to the goal or makes the user
+// leave it, the achievables assigned to the user are unassigned
+func (s Store) LeaveMembership(username, id string) error {
+	gid, err := goalID(id)
+	if err != nil {
+		return err
+	}
+	if err = member.Remove(s.memberCollection, gid, username); err != nil {
+		return err
+	}
+	g := goal.Goal{ID: gid}
+	return g.Unassign(s.achievableCollection, username)
+}
+
+// RetrieveMemberships lists the goals the user is a member of or invited to
+func (s Store) RetrieveMemberships(username, status string, limit, offset int) ([]achieving.Member, error) {
+	switch status {
+	case "", member.StatusInvited, member.StatusActive:
+	default:
+		return nil, errors.NewValidate(fmt.Sprintf("%s is not a membership status", status))
+	}
+	ms, err := member.Memberships(s.memberCollection, username, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]achieving.Member, 0, len(ms))
+	for _, m := range ms {
+		members = append(members, &Member{Member: m})
+	}
+	return members, nil
+}
@@ -18,23 +18,72 @@ import (
 	"fmt"
 
 	"github.com/iocat/donit/internal/achieving"
+	"github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+	"github.com/iocat/donit/internal/achieving/internal/activity"
+	"github.com/iocat/donit/internal/achieving/internal/block"
+	"github.com/iocat/donit/internal/achieving/internal/follow"
 	"github.com/iocat/donit/internal/achieving/internal/user"
+	"github.com/iocat/donit/internal/events"
 	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
 )
 
 // Store implements the achieving.UserStore
 type Store struct {
-	userCollection       *mgo.Collection `valid:"-"`
-	goalCollection       *mgo.Collection `valid:"-"`
-	achievableCollection *mgo.Collection `valid:"-"`
+	userCollection       *mgo.Collection  `valid:"-"`
+	goalCollection       *mgo.Collection  `valid:"-"`
+	achievableCollection *mgo.Collection  `valid:"-"`
+	followCollection     *mgo.Collection  `valid:"-"`
+	tagCollection        *mgo.Collection  `valid:"-"`
+	blockCollection      *mgo.Collection  `valid:"-"`
+	commentCollection    *mgo.Collection  `valid:"-"`
+	reactionCollection   *mgo.Collection  `valid:"-"`
+	memberCollection     *mgo.Collection  `valid:"-"`
+	workspaceCollection  *mgo.Collection  `valid:"-"`
+	templateCollection   *mgo.Collection  `valid:"-"`
+	activityCollection   *mgo.Collection  `valid:"-"`
+	events               events.Publisher `valid:"-"`
+	// actor is the user the changes are made by, the owner of the
+	// resources changed if empty
+	actor string `valid:"-"`
+	// undoing is the change the changes undo, if any
+	undoing bson.ObjectId `valid:"-"`
 }
 
-// NewStore creates a new UserStore
-func NewStore(user, goal, task *mgo.Collection) *Store {
+// Collections are the collections the store keeps its data in
+type Collections struct {
+	Users       *mgo.Collection
+	Goals       *mgo.Collection
+	Achievables *mgo.Collection
+	Follows     *mgo.Collection
+	Tags        *mgo.Collection
+	Blocks      *mgo.Collection
+	Comments    *mgo.Collection
+	Reactions   *mgo.Collection
+	Members     *mgo.Collection
+	Workspaces  *mgo.Collection
+	Templates   *mgo.Collection
+	Activities  *mgo.Collection
+}
+
+// NewStore creates a new UserStore publishing the events of the mutations to
+// pub, which may be nil
+func NewStore(c Collections, pub events.Publisher) *Store {
 	return &Store{
-		userCollection:       user,
-		goalCollection:       goal,
-		achievableCollection: task,
+		userCollection:       c.Users,
+		goalCollection:       c.Goals,
+		achievableCollection: c.Achievables,
+		followCollection:     c.Follows,
+		tagCollection:        c.Tags,
+		blockCollection:      c.Blocks,
+		commentCollection:    c.Comments,
+		reactionCollection:   c.Reactions,
+		memberCollection:     c.Members,
+		workspaceCollection:  c.Workspaces,
+		templateCollection:   c.Templates,
+		activityCollection:   c.Activities,
+		events:               pub,
 	}
 }
 
@@ -45,12 +94,28 @@ func (s Store) RetrieveUser(username string) (achieving.User, error) {
 	if err != nil {
 		return nil, err
 	}
-	cu := User{
+	return s.user(u), nil
+}
+
+// user wraps the user
+func (s Store) user(u user.User) *User {
+	return &User{
 		User:                 u,
 		goalCollection:       s.goalCollection,
 		achievableCollection: s.achievableCollection,
+		tagCollection:        s.tagCollection,
+		blockCollection:      s.blockCollection,
+		followCollection:     s.followCollection,
+		commentCollection:    s.commentCollection,
+		reactionCollection:   s.reactionCollection,
+		memberCollection:     s.memberCollection,
+		workspaceCollection:  s.workspaceCollection,
+		userCollection:       s.userCollection,
+		activityCollection:   s.activityCollection,
+		events:               s.events,
+		actor:                s.actor,
+		undoing:              s.undoing,
 	}
-	return &cu, nil
 }
 
 // CreateNewUser creates a new user using the provided username and password
@@ -60,14 +125,30 @@ func (s Store) CreateNewUser(u achieving.User, password string) (string, error)
 		if err != nil {
 			return "", err
 		}
-		return u.Username, nil
+		return u.Username, s.record(activity.Created, u.Username, nil, activity.UserRevision(u.User))
 	}
 	return "", fmt.Errorf("wrong data type, expect *concreteachieving.User, got %T", u)
 }
 
 // DeleteUser deletes a user using the provided username and password
 func (s Store) DeleteUser(username, password string) error {
-	return user.Delete(s.userCollection, username, password)
+	// the password is checked first so that the callers cannot tell
+	// whether the user exists
+	ok, err := user.Authenticate(s.userCollection, username, password)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.ErrAuthentication
+	}
+	var old user.User
+	if err = old.Retrieve(s.userCollection, username); err != nil {
+		return err
+	}
+	if err = user.Delete(s.userCollection, username, password); err != nil {
+		return err
+	}
+	return s.record(activity.Deleted, username, activity.UserRevision(old), nil)
 }
 
 // Authenticate authenticates the username and password
@@ -82,12 +163,119 @@ func (s Store) ChangePassword(username, oldpass, password string) error {
 
 // UpdateUser updates a user data
 func (s Store) UpdateUser(u achieving.User, username string) error {
-	if u, ok := u.(*User); !ok {
+	if u, ok := u.(*User); ok {
+		var old user.User
+		if err := old.Retrieve(s.userCollection, username); err != nil {
+			return err
+		}
 		err := user.Update(&(u.User), s.userCollection, username)
 		if err != nil {
 			return err
 		}
-		return nil
+		if old.Timezone != u.Timezone {
+			// the habits are reminded at the same local time in the new
+			// time zone
+			var goals []bson.ObjectId
+			if err := s.goalCollection.Find(bson.M{"username": username}).Distinct("_id", &goals); err != nil {
+				return err
+			}
+			if err := achievable.Unschedule(s.achievableCollection, goals); err != nil {
+				return err
+			}
+		}
+		return s.record(activity.Updated, username, activity.UserRevision(old), activity.UserRevision(u.User))
 	}
 	return fmt.Errorf("wrong data type, expect *concreteachieving.User, got %T", u)
 }
+
+// Follow makes the follower follow the followee
+func (s Store) Follow(follower, followee string) error {
+	var u user.User
+	if err := u.Retrieve(s.userCollection, followee); err != nil {
+		return err
+	}
+	// the users blocked do not learn it
+	blocked, err := block.Between(s.blockCollection, follower, followee)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return errors.NewNotFound("user", followee)
+	}
+	if err := follow.Create(s.followCollection, follower, followee); err != nil {
+		return err
+	}
+	events.Publish(s.events, events.Event{
+		Kind:     events.Followed,
+		Username: follower,
+		Target:   followee,
+	})
+	return nil
+}
+
+// Unfollow makes the follower stop following the followee
+func (s Store) Unfollow(follower, followee string) error {
+	return follow.Delete(s.followCollection, follower, followee)
+}
+
+// RetrieveFollowers lists the usernames of the user's followers
+func (s Store) RetrieveFollowers(username string, limit, offset int) ([]string, error) {
+	fs, err := follow.Followers(s.followCollection, username, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	usernames := make([]string, 0, len(fs))
+	for _, f := range fs {
+		usernames = append(usernames, f.Follower)
+	}
+	return usernames, nil
+}
+
+// RetrieveFollowing lists the usernames of the users the user follows
+func (s Store) RetrieveFollowing(username string, limit, offset int) ([]string, error) {
+	fs, err := follow.Following(s.followCollection, username, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	usernames := make([]string, 0, len(fs))
+	for _, f := range fs {
+		usernames = append(usernames, f.Followee)
+	}
+	return usernames, nil
+}
+
+// Block makes the blocker block the blocked user, they stop following each
+// other
+func (s Store) Block(blocker, blocked string) error {
+	var u user.User
+	if err := u.Retrieve(s.userCollection, blocked); err != nil {
+		return err
+	}
+	if err := block.Create(s.blockCollection, blocker, blocked); err != nil {
+		return err
+	}
+	for _, f := range [][2]string{{blocker, blocked}, {blocked, blocker}} {
+		if err := follow.Delete(s.followCollection, f[0], f[1]); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unblock makes the blocker stop blocking the blocked user
+func (s Store) Unblock(blocker, blocked string) error {
+	return block.Delete(s.blockCollection, blocker, blocked)
+}
+
+// RetrieveBlocked lists the usernames of the users the user blocks
+func (s Store) RetrieveBlocked(username string, limit, offset int) ([]string, error) {
+	bs, err := block.Blocked(s.blockCollection, username, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	usernames := make([]string, 0, len(bs))
+	for _, b := range bs {
+		usernames = append(usernames, b.Blocked)
+	}
+	return usernames, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package concreteachieving
+
+import (
+	"fmt"
+
+	"github.com/iocat/donit/internal/achieving"
+	"github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/achieving/internal/tag"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Tag represents a concrete achieving.Tag
+type Tag struct {
+	tag.Tag `valid:"required"`
+}
+
+// TagName implements achieving.Tag's TagName
+func (t *Tag) TagName() string {
+	return t.Name
+}
+
+// tagID checks the tag id
+func tagID(id string) (bson.ObjectId, error) {
+	if !bson.IsObjectIdHex(id) {
+		return "", errors.NewValidate(fmt.Sprintf("%s is not a valid resource id", id))
+	}
+	return bson.ObjectIdHex(id), nil
+}
+
+// CreateTag adds the tag to the user's catalogue
+func (s Store) CreateTag(username string, t achieving.Tag) (string, error) {
+	if t, ok := t.(*Tag); ok {
+		id, err := tag.Create(s.tagCollection, username, &(t.Tag))
+		if err != nil {
+			return "", err
+		}
+		return id.Hex(), nil
+	}
+	return "", fmt.Errorf("wrong data type, expect *concreteachieving.Tag, got %T", t)
+}
+
+// UpdateTag renames and recolors the tag
+func (s Store) UpdateTag(username string, t achieving.Tag, id string) error {
+	if t, ok := t.(*Tag); ok {
+		tid, err := tagID(id)
+		if err != nil {
+			return err
+		}
+		return tag.Update(s.tagCollection, username, &(t.Tag), tid)
+	}
+	return fmt.Errorf("wrong data type, expect *concreteachieving.Tag, got %T", t)
+}
+
+// DeleteTag deletes the tag, the goals and the achievables lose it
+func (s Store) DeleteTag(username, id string) error {
+	tid, err := tagID(id)
+	if err != nil {
+		return err
+	}
+	return tag.Delete(s.tagCollection, username, tid, s.goalCollection, s.achievableCollection)
+}
+
+// MergeTag merges the tag into another one
+func (s Store) MergeTag(username, from, into string) error {
+	fid, err := tagID(from)
+	if err != nil {
+		return err
+	}
+	iid, err := tagID(into)
+	if err != nil {
+		return err
+	}
+	return tag.Merge(s.tagCollection, username, fid, iid, s.goalCollection, s.achievableCollection)
+}
+
+// RetrieveTags lists the user's catalogue
+func (s Store) RetrieveTags(username string, limit, offset int) ([]achieving.Tag, error) {
+	ts, err := tag.List(s.tagCollection, username, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]achieving.Tag, 0, len(ts))
+	for _, t := range ts {
+		tags = append(tags, &Tag{Tag: t})
+	}
+	return tags, nil
+}
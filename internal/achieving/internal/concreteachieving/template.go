@@ -0,0 +1,275 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package concreteachieving
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving"
+	"github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+	"github.com/iocat/donit/internal/achieving/internal/goal"
+	"github.com/iocat/donit/internal/achieving/internal/template"
+	"github.com/iocat/donit/internal/achieving/internal/user"
+	"github.com/iocat/donit/internal/achieving/internal/workspace"
+	"github.com/iocat/donit/internal/rrule"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Template represents a concrete achieving.Template
+type Template struct {
+	template.Template `valid:"required"`
+}
+
+// TemplateName implements achieving.Template's TemplateName
+func (t *Template) TemplateName() string {
+	return t.Name
+}
+
+// templateID checks the template id
+func templateID(id string) (bson.ObjectId, error) {
+	if !bson.IsObjectIdHex(id) {
+		return "", errors.NewValidate(fmt.Sprintf("%s is not a valid resource id", id))
+	}
+	return bson.ObjectIdHex(id), nil
+}
+
+// retrieveUser retrieves the wrapped user
+func (s Store) retrieveUser(username string) (*User, error) {
+	var u user.User
+	if err := u.Retrieve(s.userCollection, username); err != nil {
+		return nil, err
+	}
+	return s.user(u), nil
+}
+
+// create creates the goal and its achievables, which keep their former IDs
+// until the relationships between them are mapped to the new ones
+func (c User) create(g goal.Goal, as []achievable.Achievable) (string, error) {
+	cg := &Goal{Goal: g}
+	id, err := c.CreateGoal(cg)
+	if err != nil {
+		return "", err
+	}
+	created := c.goal(cg.Goal, nil)
+	// the tasks are added first and related to each other once they all
+	// have their new IDs
+	ids := make(map[bson.ObjectId]bson.ObjectId, len(as))
+	var related []achievable.Achievable
+	for _, a := range as {
+		former := a.ID
+		if len(a.Parent) != 0 || len(a.BlockedBy) != 0 {
+			related = append(related, a)
+		}
+		a.ID, a.Parent, a.BlockedBy = "", "", nil
+		tid, err := created.AddAchievable(&Achievable{Achievable: a})
+		if err != nil {
+			return id, err
+		}
+		ids[former] = bson.ObjectIdHex(tid)
+	}
+	for _, a := range related {
+		tid := ids[a.ID]
+		a.ID = ""
+		a.Parent = ids[a.Parent]
+		var blockers []bson.ObjectId
+		for _, b := range a.BlockedBy {
+			if nb, ok := ids[b]; ok {
+				blockers = append(blockers, nb)
+			}
+		}
+		a.BlockedBy = blockers
+		if err := created.UpdateAchievable(&Achievable{Achievable: a}, tid.Hex()); err != nil {
+			return id, err
+		}
+	}
+	return id, nil
+}
+
+// SaveTemplate saves the user's goal and its achievables as a template
+func (s Store) SaveTemplate(username string, t achieving.Template, id string) (string, error) {
+	if t, ok := t.(*Template); ok {
+		gid, err := goalID(id)
+		if err != nil {
+			return "", err
+		}
+		u, err := s.retrieveUser(username)
+		if err != nil {
+			return "", err
+		}
+		g, err := u.User.RetrieveGoal(s.goalCollection, s.achievableCollection, gid)
+		if err != nil {
+			return "", err
+		}
+		if len(t.Description) == 0 {
+			t.Description = g.Description
+		}
+		template.New(&(t.Template), g.ToDo, u.Location())
+		tid, err := template.Create(s.templateCollection, &(t.Template), username)
+		if err != nil {
+			return "", err
+		}
+		return tid.Hex(), nil
+	}
+	return "", fmt.Errorf("wrong data type, expect Template, got %T", t)
+}
+
+// RetrieveTemplate retrieves a template on behalf of the user, the templates
+// of the others are only found if they are public
+func (s Store) RetrieveTemplate(username, id string) (achieving.Template, error) {
+	tid, err := templateID(id)
+	if err != nil {
+		return nil, err
+	}
+	t, err := template.Retrieve(s.templateCollection, tid, username)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{Template: t}, nil
+}
+
+// templates wraps the templates
+func templates(ts []template.Template) []achieving.Template {
+	res := make([]achieving.Template, 0, len(ts))
+	for _, t := range ts {
+		res = append(res, &Template{Template: t})
+	}
+	return res
+}
+
+// RetrieveTemplates lists the author's templates on behalf of the user, the
+// others only see the public ones
+func (s Store) RetrieveTemplates(author, username string, limit, offset int) ([]achieving.Template, error) {
+	ts, err := template.List(s.templateCollection, author, username, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return templates(ts), nil
+}
+
+// RetrievePublicTemplates lists the public templates whose name contains
+// the text, the most used first
+func (s Store) RetrievePublicTemplates(text string, limit, offset int) ([]achieving.Template, error) {
+	ts, err := template.Catalogue(s.templateCollection, text, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return templates(ts), nil
+}
+
+// UpdateTemplate renames the user's template, changes its description and
+// publishes or unpublishes it
+func (s Store) UpdateTemplate(username string, t achieving.Template, id string) error {
+	if t, ok := t.(*Template); ok {
+		tid, err := templateID(id)
+		if err != nil {
+			return err
+		}
+		return template.Update(s.templateCollection, tid, username, &(t.Template))
+	}
+	return fmt.Errorf("wrong data type, expect Template, got %T", t)
+}
+
+// DeleteTemplate deletes the user's template
+func (s Store) DeleteTemplate(username, id string) error {
+	tid, err := templateID(id)
+	if err != nil {
+		return err
+	}
+	return template.Delete(s.templateCollection, tid, username)
+}
+
+// InstantiateTemplate creates a goal of the user from the template, its
+// reminders are rebased on the start date, today in the user's time zone if
+// empty. The goal has the user's default accessibility
+func (s Store) InstantiateTemplate(username, id, start string) (string, error) {
+	tid, err := templateID(id)
+	if err != nil {
+		return "", err
+	}
+	u, err := s.retrieveUser(username)
+	if err != nil {
+		return "", err
+	}
+	t, err := template.Retrieve(s.templateCollection, tid, username)
+	if err != nil {
+		return "", err
+	}
+	loc := u.Location()
+	date := rrule.DateOf(time.Now().In(loc))
+	if len(start) != 0 {
+		if date, err = rrule.ParseDate(start); err != nil {
+			return "", errors.NewValidate(fmt.Sprintf("%s is not a date formatted as %s", start, rrule.DateLayout))
+		}
+	}
+	access := u.DefaultAccessibility
+	if access == goal.AccessWorkspace || len(access) == 0 {
+		// the goal is in no workspace
+		access = goal.AccessPrivate
+	}
+	gid, err := u.create(t.Goal(access), t.Achievables(date, loc))
+	if err != nil {
+		return gid, err
+	}
+	return gid, template.Used(s.templateCollection, tid)
+}
+
+// CloneGoal copies the owner's goal and its achievables to the user's goals,
+// everything is left to be done. The user needs to see the goal, and the
+// goals of the others lose their tags which belong to their owner. The
+// copy of a workspace goal by a user out of the workspace is private
+func (s Store) CloneGoal(username, owner, id string) (string, error) {
+	gid, err := goalID(id)
+	if err != nil {
+		return "", err
+	}
+	o, err := s.retrieveUser(owner)
+	if err != nil {
+		return "", err
+	}
+	g, err := o.User.RetrieveGoal(s.goalCollection, s.achievableCollection, gid)
+	if err != nil {
+		return "", err
+	}
+	source, err := o.goals([]goal.Goal{g})
+	if err != nil {
+		return "", err
+	}
+	if !source[0].VisibleTo(username) {
+		return "", errors.NewNotFound("goal", fmt.Sprintf("%s,%s", owner, id))
+	}
+	clone, as := goal.Clone(g)
+	u := o
+	if username != owner {
+		if u, err = s.retrieveUser(username); err != nil {
+			return "", err
+		}
+		clone.Tags = nil
+		for i := range as {
+			as[i].Tags = nil
+		}
+	}
+	if len(clone.Workspace) != 0 {
+		ok, err := workspace.IsMember(s.workspaceCollection, clone.Workspace, username)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			clone.Workspace, clone.Accessibility = "", goal.AccessPrivate
+		}
+	}
+	return u.create(clone, as)
+}
@@ -0,0 +1,143 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package concreteachieving
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving"
+	"github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+	"github.com/iocat/donit/internal/achieving/internal/activity"
+	"github.com/iocat/donit/internal/achieving/internal/goal"
+	"github.com/iocat/donit/internal/achieving/internal/member"
+	"github.com/iocat/donit/internal/achieving/internal/tag"
+	"github.com/iocat/donit/internal/achieving/internal/workspace"
+	"github.com/iocat/donit/internal/events"
+)
+
+// Undo undoes the count latest changes the user made within the undo window
+// and returns them, the latest first. The changes are undone one after the
+// other, the ones undone before a failure stay undone and are returned
+// along with the error
+func (s Store) Undo(username string, count int) ([]achieving.Activity, error) {
+	if count < 1 || count > activity.MaxUndo {
+		return nil, errors.NewValidate(fmt.Sprintf("the number of changes to undo must be between 1 and %d", activity.MaxUndo))
+	}
+	es, err := activity.Undoable(s.activityCollection, username, count, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if len(es) == 0 {
+		return nil, errors.NewNotFound("change to undo", username)
+	}
+	s.actor = username
+	for i, e := range es {
+		if err = s.undo(e); err != nil {
+			return activities(es[:i]), err
+		}
+	}
+	return activities(es), nil
+}
+
+// undo puts the resource of the change back as it was before the change
+func (s Store) undo(e activity.Entry) error {
+	s.undoing = e.ID
+	if e.Resource == activity.ResourceUser {
+		return s.UpdateUser(&User{User: *e.Before.User}, e.Owner)
+	}
+	owner, err := s.retrieveUser(e.Owner)
+	if err != nil {
+		return err
+	}
+	if e.Resource == activity.ResourceGoal && e.Action == activity.Deleted {
+		return owner.restoreGoal(*e.Before.Goal, e.Before.Members)
+	}
+	g, err := owner.RetrieveGoal(e.Goal.Hex())
+	if err != nil {
+		return err
+	}
+	cg := g.(*Goal)
+	// the members undo their changes as long as they can edit the goal
+	if !cg.CanEdit(s.actor) {
+		return errors.ErrForbidden
+	}
+	switch {
+	case e.Resource == activity.ResourceGoal && e.Action == activity.Created:
+		return owner.DeleteGoal(e.Goal.Hex())
+	case e.Resource == activity.ResourceGoal:
+		return owner.updateGoal(&Goal{Goal: *e.Before.Goal}, e.Goal.Hex(), "")
+	case e.Action == activity.Created:
+		return cg.RemoveAchievable(e.Achievable.Hex())
+	case e.Action == activity.Deleted:
+		return cg.restoreAchievable(*e.Before.Achievable)
+	default:
+		return cg.updateAchievable(&Achievable{Achievable: *e.Before.Achievable}, e.Achievable.Hex(), "")
+	}
+}
+
+// restoreGoal puts back the goal deleted and its members, the comments on
+// it are not
+func (c User) restoreGoal(g goal.Goal, members []member.Member) error {
+	if err := tag.Check(c.tagCollection, c.Username, g.Tags); err != nil {
+		return err
+	}
+	if len(g.Workspace) != 0 {
+		// the workspace may be left or deleted since
+		ok, err := workspace.IsMember(c.workspaceCollection, g.Workspace, c.Username)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.NewConflict("workspace", g.Workspace.Hex())
+		}
+	}
+	if err := c.User.RestoreGoal(c.goalCollection, &g); err != nil {
+		return err
+	}
+	if err := member.Restore(c.memberCollection, members); err != nil {
+		return err
+	}
+	events.Publish(c.events, events.Event{
+		Kind:     events.GoalCreated,
+		Username: c.Username,
+		Owner:    c.Username,
+		Goal:     g.ID.Hex(),
+		Name:     g.Name,
+	})
+	return c.record(activity.Entry{
+		Action: activity.Created,
+		Goal:   g.ID,
+		After:  activity.GoalRevision(g),
+	})
+}
+
+// restoreAchievable puts back the achievable removed, the comments on it are
+// not
+func (cg *Goal) restoreAchievable(a achievable.Achievable) error {
+	if err := tag.Check(cg.tagCollection, cg.Username, a.Tags); err != nil {
+		return err
+	}
+	if err := cg.Goal.RestoreAchievable(cg.achievableCollection, &a); err != nil {
+		return err
+	}
+	events.Publish(cg.events, cg.event(events.AchievableCreated, a.ID.Hex(), a.Name))
+	return cg.record(activity.Entry{
+		Action:     activity.Created,
+		Achievable: a.ID,
+		After:      activity.AchievableRevision(a),
+	})
+}
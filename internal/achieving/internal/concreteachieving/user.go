@@ -16,10 +16,19 @@ package concreteachieving
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/iocat/donit/internal/achieving"
 	"github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/achieving/internal/activity"
+	"github.com/iocat/donit/internal/achieving/internal/comment"
+	"github.com/iocat/donit/internal/achieving/internal/follow"
+	"github.com/iocat/donit/internal/achieving/internal/goal"
+	"github.com/iocat/donit/internal/achieving/internal/member"
+	"github.com/iocat/donit/internal/achieving/internal/tag"
 	"github.com/iocat/donit/internal/achieving/internal/user"
+	"github.com/iocat/donit/internal/achieving/internal/workspace"
+	"github.com/iocat/donit/internal/events"
 
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -36,18 +45,102 @@ func NewUser(goal, task *mgo.Collection) *User {
 // User represents the concrete user
 type User struct {
 	user.User            `valid:"required"`
-	achievableCollection *mgo.Collection `valid:"-"`
-	goalCollection       *mgo.Collection `valid:"-"`
+	achievableCollection *mgo.Collection  `valid:"-"`
+	goalCollection       *mgo.Collection  `valid:"-"`
+	tagCollection        *mgo.Collection  `valid:"-"`
+	blockCollection      *mgo.Collection  `valid:"-"`
+	followCollection     *mgo.Collection  `valid:"-"`
+	commentCollection    *mgo.Collection  `valid:"-"`
+	reactionCollection   *mgo.Collection  `valid:"-"`
+	memberCollection     *mgo.Collection  `valid:"-"`
+	workspaceCollection  *mgo.Collection  `valid:"-"`
+	userCollection       *mgo.Collection  `valid:"-"`
+	activityCollection   *mgo.Collection  `valid:"-"`
+	events               events.Publisher `valid:"-"`
+	// actor is the user the changes are made by
+	actor string `valid:"-"`
+	// undoing is the change the changes undo, if any
+	undoing bson.ObjectId `valid:"-"`
+}
+
+// goal wraps the goal of the user, its tasks are localized in the user's
+// time zone and its progress is tracked
+func (c User) goal(g goal.Goal, members []member.Member) *Goal {
+	g.Members = members
+	loc, now := c.Location(), time.Now()
+	for i := range g.ToDo {
+		g.ToDo[i].Localize(now, loc)
+	}
+	goal.Link(g.ToDo)
+	g.Track(now)
+	return &Goal{
+		Goal:                 g,
+		achievableCollection: c.achievableCollection,
+		tagCollection:        c.tagCollection,
+		blockCollection:      c.blockCollection,
+		followCollection:     c.followCollection,
+		commentCollection:    c.commentCollection,
+		reactionCollection:   c.reactionCollection,
+		memberCollection:     c.memberCollection,
+		workspaceCollection:  c.workspaceCollection,
+		userCollection:       c.userCollection,
+		activityCollection:   c.activityCollection,
+		events:               c.events,
+		actor:                c.actor,
+		undoing:              c.undoing,
+		location:             loc,
+	}
+}
+
+// goals wraps the goals of the user along with their members
+func (c User) goals(gs []goal.Goal) ([]achieving.Goal, error) {
+	ids := make([]bson.ObjectId, 0, len(gs))
+	for _, g := range gs {
+		ids = append(ids, g.ID)
+	}
+	members, err := member.Of(c.memberCollection, ids)
+	if err != nil {
+		return nil, err
+	}
+	var goals []achieving.Goal
+	for _, g := range gs {
+		goals = append(goals, c.goal(g, members[g.ID]))
+	}
+	return goals, nil
 }
 
 // CreateGoal creates a new goal
 func (c User) CreateGoal(g achieving.Goal) (string, error) {
 	if g, ok := g.(*Goal); ok {
+		if err := tag.Check(c.tagCollection, c.Username, g.Tags); err != nil {
+			return "", err
+		}
+		if len(g.Workspace) != 0 {
+			// the workspaces the user is not a member of are not found
+			ok, err := workspace.IsMember(c.workspaceCollection, g.Workspace, c.Username)
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				return "", errors.NewNotFound("workspace", g.Workspace.Hex())
+			}
+		}
 		id, err := c.User.CreateGoal(c.goalCollection, &(g.Goal))
 		if err != nil {
 			return "", err
 		}
-		return id.Hex(), nil
+		events.Publish(c.events, events.Event{
+			Kind:     events.GoalCreated,
+			Username: c.Username,
+			Owner:    c.Username,
+			Goal:     id.Hex(),
+			Name:     g.Name,
+		})
+		return id.Hex(), c.record(activity.Entry{
+			Action: activity.Created,
+			Goal:   id,
+			After:  activity.GoalRevision(g.Goal),
+		})
 	}
 	return "", fmt.Errorf("invalid data type, expect Goal, got %T", g)
 }
@@ -58,17 +151,92 @@ func (c User) DeleteGoal(id string) error {
 	if !ok {
 		return errors.NewValidate(fmt.Sprintf("%s is not a valid resource id", id))
 	}
-	return c.User.DeleteGoal(c.goalCollection, bson.ObjectIdHex(id))
+	old, err := c.User.DeleteGoal(c.goalCollection, bson.ObjectIdHex(id))
+	if err != nil {
+		return err
+	}
+	if err := comment.RemoveAll(c.commentCollection, c.reactionCollection, bson.ObjectIdHex(id), ""); err != nil {
+		return err
+	}
+	// the members are kept in the log to be put back if the deletion is
+	// undone
+	members, err := member.Of(c.memberCollection, []bson.ObjectId{old.ID})
+	if err != nil {
+		return err
+	}
+	if err = member.RemoveAll(c.memberCollection, bson.ObjectIdHex(id)); err != nil {
+		return err
+	}
+	events.Publish(c.events, events.Event{
+		Kind:     events.GoalDeleted,
+		Username: c.Username,
+		Owner:    c.Username,
+		Goal:     id,
+	})
+	before := activity.GoalRevision(old)
+	before.Members = members[old.ID]
+	return c.record(activity.Entry{
+		Action: activity.Deleted,
+		Goal:   old.ID,
+		Before: before,
+	})
 }
 
 // UpdateGoal updates a goal
 func (c User) UpdateGoal(g achieving.Goal, id string) error {
+	return c.updateGoal(g, id, "")
+}
+
+// updateGoal updates a goal, or reverts it to the revision of the entry of
+// its log if any
+func (c User) updateGoal(g achieving.Goal, id string, revision bson.ObjectId) error {
 	ok := bson.IsObjectIdHex(id)
 	if !ok {
 		return errors.NewValidate(fmt.Sprintf("%s is not a valid resource id", id))
 	}
 	if g, ok := g.(*Goal); ok {
-		return c.User.UpdateGoal(c.goalCollection, &(g.Goal), bson.ObjectIdHex(id))
+		if err := tag.Check(c.tagCollection, c.Username, g.Tags); err != nil {
+			return err
+		}
+		// the goal stays in its workspace
+		var stored goal.Goal
+		err := c.goalCollection.Find(bson.M{
+			"username": c.Username,
+			"_id":      bson.ObjectIdHex(id),
+		}).Select(bson.M{"workspace": 1}).One(&stored)
+		if err != nil {
+			if err == mgo.ErrNotFound {
+				return errors.NewNotFound("goal", fmt.Sprintf("%s,%s", c.Username, id))
+			}
+			return err
+		}
+		g.Workspace = stored.Workspace
+		old, err := c.User.UpdateGoal(c.goalCollection, &(g.Goal), bson.ObjectIdHex(id))
+		if err != nil {
+			return err
+		}
+		if err = g.DetachMilestones(c.achievableCollection); err != nil {
+			return err
+		}
+		e := events.Event{
+			Kind:     events.GoalUpdated,
+			Username: c.Username,
+			Owner:    c.Username,
+			Goal:     id,
+			Name:     g.Name,
+		}
+		events.Publish(c.events, e)
+		if !old.HasAchieved() && g.HasAchieved() {
+			e.Kind = events.GoalCompleted
+			events.Publish(c.events, e)
+		}
+		return c.record(activity.Entry{
+			Action:   changed(revision),
+			Goal:     g.ID,
+			Before:   activity.GoalRevision(old),
+			After:    activity.GoalRevision(g.Goal),
+			Revision: revision,
+		})
 	}
 	return fmt.Errorf("invalid data type, expect Goal, got %T", g)
 }
@@ -83,10 +251,11 @@ func (c User) RetrieveGoal(id string) (achieving.Goal, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Goal{
-		Goal:                 g,
-		achievableCollection: c.achievableCollection,
-	}, nil
+	gs, err := c.goals([]goal.Goal{g})
+	if err != nil {
+		return nil, err
+	}
+	return gs[0], nil
 }
 
 // RetrieveGoals retrieves a goal
@@ -95,12 +264,90 @@ func (c User) RetrieveGoals(limit, offset int) ([]achieving.Goal, error) {
 	if err != nil {
 		return nil, err
 	}
-	var goals []achieving.Goal
-	for _, g := range gs {
-		goals = append(goals, &Goal{
-			Goal:                 g,
-			achievableCollection: c.achievableCollection,
-		})
+	return c.goals(gs)
+}
+
+// RetrieveGoalsVisibleTo retrieves the goals the user can see, tagged with
+// the tag if not empty
+func (c User) RetrieveGoalsVisibleTo(username, tagid string, limit, offset int) ([]achieving.Goal, error) {
+	var tags []bson.ObjectId
+	if len(tagid) != 0 {
+		tid, err := tagID(tagid)
+		if err != nil {
+			return nil, err
+		}
+		if tags, err = tag.Aliases(c.tagCollection, c.Username, tid); err != nil {
+			return nil, err
+		}
 	}
-	return goals, nil
+	visible := bson.M{}
+	if username != c.Username {
+		shared, following, workspaces, err := c.visibility(username)
+		if err != nil {
+			return nil, err
+		}
+		var followees []string
+		if following {
+			followees = []string{c.Username}
+		}
+		visible = goal.Visible(shared, followees, workspaces)
+	}
+	gs, err := c.User.RetrieveVisibleGoals(c.goalCollection, c.achievableCollection, visible, tags, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return c.goals(gs)
+}
+
+// VisibilityTo returns what tells the goals the user can see apart
+func (c User) VisibilityTo(username string) (achieving.Visibility, error) {
+	var v achieving.Visibility
+	shared, following, workspaces, err := c.visibility(username)
+	if err != nil {
+		return v, err
+	}
+	v.Following = following
+	for _, id := range shared {
+		v.Shared = append(v.Shared, id.Hex())
+	}
+	for _, id := range workspaces {
+		v.Workspaces = append(v.Workspaces, id.Hex())
+	}
+	return v, nil
+}
+
+// visibility looks up the goals shared with the user, whether the user
+// follows the owner and the user's workspaces. The anonymous users have
+// none of them
+func (c User) visibility(username string) (shared []bson.ObjectId, following bool, workspaces []bson.ObjectId, err error) {
+	if len(username) == 0 {
+		return nil, false, nil, nil
+	}
+	if shared, err = member.Goals(c.memberCollection, username); err != nil {
+		return nil, false, nil, err
+	}
+	if following, err = follow.IsFollowing(c.followCollection, username, c.Username); err != nil {
+		return nil, false, nil, err
+	}
+	if workspaces, err = workspace.IDs(c.workspaceCollection, username); err != nil {
+		return nil, false, nil, err
+	}
+	return shared, following, workspaces, nil
+}
+
+// RetrieveTaggedGoals retrieves the goals tagged with the tag
+func (c User) RetrieveTaggedGoals(id string, limit, offset int) ([]achieving.Goal, error) {
+	tid, err := tagID(id)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := tag.Aliases(c.tagCollection, c.Username, tid)
+	if err != nil {
+		return nil, err
+	}
+	gs, err := c.User.RetrieveTaggedGoals(c.goalCollection, c.achievableCollection, ids, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return c.goals(gs)
 }
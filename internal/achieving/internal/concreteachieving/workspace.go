@@ -0,0 +1,213 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package concreteachieving
+
+import (
+	"fmt"
+
+	"github.com/iocat/donit/internal/achieving"
+	"github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/achieving/internal/goal"
+	"github.com/iocat/donit/internal/achieving/internal/member"
+	"github.com/iocat/donit/internal/achieving/internal/user"
+	"github.com/iocat/donit/internal/achieving/internal/workspace"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Workspace implements the achieving.Workspace interface, it is retrieved on
+// behalf of one of its members
+type Workspace struct {
+	workspace.Workspace `valid:"required"`
+	viewer              string `valid:"-"`
+	store               Store  `valid:"-"`
+}
+
+// WorkspaceName implements achieving.Workspace's WorkspaceName
+func (cw *Workspace) WorkspaceName() string {
+	return cw.Name
+}
+
+// WorkspaceMember represents a concrete achieving.WorkspaceMember
+type WorkspaceMember struct {
+	workspace.Member `valid:"required"`
+}
+
+// WorkspaceRole implements achieving.WorkspaceMember's WorkspaceRole
+func (m *WorkspaceMember) WorkspaceRole() string {
+	return m.Role
+}
+
+// workspaceID checks the workspace id
+func workspaceID(id string) (bson.ObjectId, error) {
+	if !bson.IsObjectIdHex(id) {
+		return "", errors.NewValidate(fmt.Sprintf("%s is not a valid resource id", id))
+	}
+	return bson.ObjectIdHex(id), nil
+}
+
+// CreateWorkspace creates a workspace, the user is its first admin
+func (s Store) CreateWorkspace(username string, w achieving.Workspace) (string, error) {
+	if w, ok := w.(*Workspace); ok {
+		id, err := workspace.Create(s.workspaceCollection, &(w.Workspace), username)
+		if err != nil {
+			return "", err
+		}
+		return id.Hex(), nil
+	}
+	return "", fmt.Errorf("wrong data type, expect Workspace, got %T", w)
+}
+
+// RetrieveWorkspace retrieves the workspace on behalf of the user, the
+// workspaces the user is not a member of are not found
+func (s Store) RetrieveWorkspace(username, id string) (achieving.Workspace, error) {
+	wid, err := workspaceID(id)
+	if err != nil {
+		return nil, err
+	}
+	w, err := workspace.Retrieve(s.workspaceCollection, wid, username)
+	if err != nil {
+		return nil, err
+	}
+	return &Workspace{Workspace: w, viewer: username, store: s}, nil
+}
+
+// RetrieveWorkspaces lists the workspaces of the user
+func (s Store) RetrieveWorkspaces(username string, limit, offset int) ([]achieving.Workspace, error) {
+	ws, err := workspace.List(s.workspaceCollection, username, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]achieving.Workspace, 0, len(ws))
+	for _, w := range ws {
+		res = append(res, &Workspace{Workspace: w, viewer: username, store: s})
+	}
+	return res, nil
+}
+
+// admin checks that the viewer is an admin of the workspace
+func (cw *Workspace) admin() error {
+	if cw.Role(cw.viewer) != workspace.RoleAdmin {
+		return errors.ErrForbidden
+	}
+	return nil
+}
+
+// Update renames the workspace and changes its description, only its admins
+// can
+func (cw *Workspace) Update(w achieving.Workspace) error {
+	if w, ok := w.(*Workspace); ok {
+		if err := cw.admin(); err != nil {
+			return err
+		}
+		return workspace.Update(cw.store.workspaceCollection, cw.ID, &(w.Workspace))
+	}
+	return fmt.Errorf("wrong data type, expect Workspace, got %T", w)
+}
+
+// Delete deletes the workspace once its goals are gone, only its admins can
+func (cw *Workspace) Delete() error {
+	if err := cw.admin(); err != nil {
+		return err
+	}
+	return workspace.Delete(cw.store.workspaceCollection, cw.store.goalCollection, cw.ID)
+}
+
+// SetMember adds the user to the workspace with the role of the member, or
+// changes the role of a member, only its admins can
+func (cw *Workspace) SetMember(m achieving.WorkspaceMember, username string) error {
+	if m, ok := m.(*WorkspaceMember); ok {
+		if err := cw.admin(); err != nil {
+			return err
+		}
+		var u user.User
+		if err := u.Retrieve(cw.store.userCollection, username); err != nil {
+			return err
+		}
+		return workspace.SetMember(cw.store.workspaceCollection, &(cw.Workspace), username, m.Role)
+	}
+	return fmt.Errorf("wrong data type, expect WorkspaceMember, got %T", m)
+}
+
+// RemoveMember removes the user from the workspace on behalf of an admin or
+// of the user leaving. The user leaves the goals of the workspace shared with
+// it and its achievables assigned to the user are unassigned, the user's own
+// goals stay
+func (cw *Workspace) RemoveMember(username string) error {
+	if cw.viewer != username {
+		if err := cw.admin(); err != nil {
+			return err
+		}
+	}
+	if err := workspace.RemoveMember(cw.store.workspaceCollection, &(cw.Workspace), username); err != nil {
+		return err
+	}
+	ids, err := workspace.GoalIDs(cw.store.goalCollection, cw.ID)
+	if err != nil {
+		return err
+	}
+	if err = member.RemoveFrom(cw.store.memberCollection, ids, username); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		g := goal.Goal{ID: id}
+		if err = g.Unassign(cw.store.achievableCollection, username); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RetrieveWorkspaceMembers lists the members of the workspace
+func (cw *Workspace) RetrieveWorkspaceMembers() []achieving.WorkspaceMember {
+	ms := make([]achieving.WorkspaceMember, 0, len(cw.Members))
+	for _, m := range cw.Members {
+		ms = append(ms, &WorkspaceMember{Member: m})
+	}
+	return ms
+}
+
+// RetrieveGoals lists the goals of the workspace the viewer can see: the
+// goals shared with the workspace, the viewer's and the ones shared with the
+// viewer. Each goal is localized in the time zone of its owner
+func (cw *Workspace) RetrieveGoals(limit, offset int) ([]achieving.Goal, error) {
+	shared, err := member.Goals(cw.store.memberCollection, cw.viewer)
+	if err != nil {
+		return nil, err
+	}
+	gs, err := workspace.Goals(cw.store.goalCollection, cw.store.achievableCollection,
+		cw.ID, cw.viewer, shared, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[string]*User)
+	goals := make([]achieving.Goal, 0, len(gs))
+	for _, g := range gs {
+		owner, ok := owners[g.Username]
+		if !ok {
+			var u user.User
+			if err = u.Retrieve(cw.store.userCollection, g.Username); err != nil {
+				return nil, err
+			}
+			owner = cw.store.user(u)
+			owners[g.Username] = owner
+		}
+		wrapped, err := owner.goals([]goal.Goal{g})
+		if err != nil {
+			return nil, err
+		}
+		goals = append(goals, wrapped...)
+	}
+	return goals, nil
+}
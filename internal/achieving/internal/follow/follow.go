@@ -0,0 +1,124 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package follow contains the follow relationships between the users
+package follow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/errors"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Collection is the name of the collection of the follow relationships
+const Collection = "follows"
+
+// Follow represents a user following another one
+type Follow struct {
+	ID       bson.ObjectId `bson:"_id" json:"-"`
+	Follower string        `bson:"follower" json:"follower"`
+	Followee string        `bson:"followee" json:"followee"`
+	Created  time.Time     `bson:"created" json:"created"`
+}
+
+// EnsureIndexes creates the indexes of the follow collection, a user
+// follows another one at most once
+func EnsureIndexes(col *mgo.Collection) error {
+	indexes := []mgo.Index{
+		{Key: []string{"follower", "followee"}, Unique: true},
+		{Key: []string{"followee", "-created"}},
+	}
+	for _, index := range indexes {
+		if err := col.EnsureIndex(index); err != nil {
+			return fmt.Errorf("ensure follow index %v: %s", index.Key, err)
+		}
+	}
+	return nil
+}
+
+// Create makes the follower follow the followee. Following someone twice is
+// reported as a duplicate
+func Create(col *mgo.Collection, follower, followee string) error {
+	if follower == followee {
+		return errors.NewValidate("users cannot follow themselves")
+	}
+	err := col.Insert(Follow{
+		ID:       bson.NewObjectId(),
+		Follower: follower,
+		Followee: followee,
+		Created:  time.Now(),
+	})
+	if err != nil {
+		if mgo.IsDup(err) {
+			return errors.NewDuplicated("follow", fmt.Sprintf("%s,%s", follower, followee))
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete makes the follower stop following the followee
+func Delete(col *mgo.Collection, follower, followee string) error {
+	err := col.Remove(bson.M{
+		"follower": follower,
+		"followee": followee,
+	})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return errors.NewNotFound("follow", fmt.Sprintf("%s,%s", follower, followee))
+		}
+		return err
+	}
+	return nil
+}
+
+// IsFollowing returns whether the follower follows the followee
+func IsFollowing(col *mgo.Collection, follower, followee string) (bool, error) {
+	n, err := col.Find(bson.M{
+		"follower": follower,
+		"followee": followee,
+	}).Count()
+	if err != nil {
+		return false, err
+	}
+	return n != 0, nil
+}
+
+func list(col *mgo.Collection, query bson.M, limit, offset int) ([]Follow, error) {
+	q := col.Find(query).Sort("-created")
+	if limit > 0 {
+		q.Limit(limit)
+	}
+	if offset > 0 {
+		q.Skip(offset)
+	}
+	var fs []Follow
+	if err := q.All(&fs); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// Followers lists the followers of the user, the latest first
+func Followers(col *mgo.Collection, username string, limit, offset int) ([]Follow, error) {
+	return list(col, bson.M{"followee": username}, limit, offset)
+}
+
+// Following lists the users the user follows, the latest first
+func Following(col *mgo.Collection, username string, limit, offset int) ([]Follow, error) {
+	return list(col, bson.M{"follower": username}, limit, offset)
+}
@@ -0,0 +1,48 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goal
+
+import "github.com/iocat/donit/internal/achieving/internal/achievable"
+
+// Clone copies the goal and its achievables to be created anew, everything
+// left to be done again. The achievables keep their IDs for their
+// relationships to be mapped to the IDs of the copies, the milestones keep
+// theirs. The members and the assignees are left out
+func Clone(g Goal) (Goal, []achievable.Achievable) {
+	as := make([]achievable.Achievable, 0, len(g.ToDo))
+	for _, a := range g.ToDo {
+		a.Goal, a.Status = "", achievable.NotDone
+		a.Assignee, a.ExternalID = "", ""
+		a.Actionable, a.NextOccurrence = false, nil
+		as = append(as, a)
+	}
+	milestones := make([]Milestone, 0, len(g.Milestones))
+	for _, m := range g.Milestones {
+		m.Progress = Progress{}
+		milestones = append(milestones, m)
+	}
+	return Goal{
+		Name:                   g.Name,
+		Description:            g.Description,
+		Status:                 achievable.NotDone,
+		PictureURL:             g.PictureURL,
+		Accessibility:          g.Accessibility,
+		Workspace:              g.Workspace,
+		Tags:                   g.Tags,
+		Deadline:               g.Deadline,
+		Milestones:             milestones,
+		AllowBlockedCompletion: g.AllowBlockedCompletion,
+	}, as
+}
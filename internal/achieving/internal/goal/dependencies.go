@@ -0,0 +1,257 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// The tasks of a goal form a graph: a task comes after its blockers and a
+// parent task after its subtasks
+
+// after returns the tasks the task comes after
+func after(t achievable.Achievable, children map[bson.ObjectId][]bson.ObjectId) []bson.ObjectId {
+	return append(append([]bson.ObjectId(nil), t.BlockedBy...), children[t.ID]...)
+}
+
+// index indexes the tasks by ID and lists the subtasks of every task
+func index(tasks []achievable.Achievable) (map[bson.ObjectId]*achievable.Achievable, map[bson.ObjectId][]bson.ObjectId) {
+	byID := make(map[bson.ObjectId]*achievable.Achievable, len(tasks))
+	children := make(map[bson.ObjectId][]bson.ObjectId)
+	for i := range tasks {
+		byID[tasks[i].ID] = &tasks[i]
+		if len(tasks[i].Parent) != 0 {
+			children[tasks[i].Parent] = append(children[tasks[i].Parent], tasks[i].ID)
+		}
+	}
+	return byID, children
+}
+
+// Link computes whether the tasks of a goal are actionable now: a task is
+// actionable if it is not done, it has no open subtask and neither it nor
+// its parents are blocked by an open task
+func Link(tasks []achievable.Achievable) {
+	byID, children := index(tasks)
+	open := func(id bson.ObjectId) bool {
+		t, ok := byID[id]
+		return ok && !t.HasAchieved()
+	}
+	for i := range tasks {
+		t := &tasks[i]
+		t.Actionable = !t.HasAchieved()
+		for _, c := range children[t.ID] {
+			if open(c) {
+				t.Actionable = false
+			}
+		}
+		// walk up the parents, a cycle was refused on write but the
+		// walk is bounded anyway
+		for p, n := t, 0; p != nil && t.Actionable && n <= len(tasks); n++ {
+			for _, b := range p.BlockedBy {
+				if open(b) {
+					t.Actionable = false
+				}
+			}
+			p = byID[p.Parent]
+		}
+	}
+}
+
+// Order orders the tasks so that every task comes after its blockers and
+// its subtasks, the tasks keep their order otherwise
+func Order(tasks []achievable.Achievable) []achievable.Achievable {
+	byID, children := index(tasks)
+	pending := make(map[bson.ObjectId]int, len(tasks))
+	next := make(map[bson.ObjectId][]bson.ObjectId)
+	for _, t := range tasks {
+		for _, b := range after(t, children) {
+			if _, ok := byID[b]; ok {
+				pending[t.ID]++
+				next[b] = append(next[b], t.ID)
+			}
+		}
+	}
+	position := make(map[bson.ObjectId]int, len(tasks))
+	for i, t := range tasks {
+		position[t.ID] = i
+	}
+	var ready []bson.ObjectId
+	for _, t := range tasks {
+		if pending[t.ID] == 0 {
+			ready = append(ready, t.ID)
+		}
+	}
+	ordered := make([]achievable.Achievable, 0, len(tasks))
+	done := make(map[bson.ObjectId]bool, len(tasks))
+	for len(ready) != 0 {
+		// take the ready task coming first
+		first := 0
+		for i := range ready {
+			if position[ready[i]] < position[ready[first]] {
+				first = i
+			}
+		}
+		id := ready[first]
+		ready = append(ready[:first], ready[first+1:]...)
+		ordered = append(ordered, *byID[id])
+		done[id] = true
+		for _, n := range next[id] {
+			if pending[n]--; pending[n] == 0 {
+				ready = append(ready, n)
+			}
+		}
+	}
+	// NOTE: the tasks of a cycle, refused on write, keep their order
+	for _, t := range tasks {
+		if !done[t.ID] {
+			ordered = append(ordered, t)
+		}
+	}
+	return ordered
+}
+
+// cycle returns a cycle of the tasks' graph, if any
+func cycle(tasks []achievable.Achievable) []bson.ObjectId {
+	byID, children := index(tasks)
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[bson.ObjectId]int, len(tasks))
+	var path []bson.ObjectId
+	var visit func(id bson.ObjectId) []bson.ObjectId
+	visit = func(id bson.ObjectId) []bson.ObjectId {
+		state[id] = visiting
+		path = append(path, id)
+		for _, b := range after(*byID[id], children) {
+			if _, ok := byID[b]; !ok {
+				continue
+			}
+			switch state[b] {
+			case visiting:
+				for i := range path {
+					if path[i] == b {
+						return append(append([]bson.ObjectId(nil), path[i:]...), b)
+					}
+				}
+			case unvisited:
+				if c := visit(b); c != nil {
+					return c
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = visited
+		return nil
+	}
+	for _, t := range tasks {
+		if state[t.ID] == unvisited {
+			if c := visit(t.ID); c != nil {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+func relationField(name, rule, format string, args ...interface{}) errors.Field {
+	return errors.Field{
+		Name:    name,
+		Rule:    rule,
+		Message: fmt.Sprintf("%s: %s", name, fmt.Sprintf(format, args...)),
+	}
+}
+
+// checkRelations checks the parent and the blockers of the achievable,
+// about to be stored with the ID: they are tasks of the goal, they do not
+// make a cycle and, unless the goal allows it, the achievable is not done
+// while its blockers are open
+func (g *Goal) checkRelations(ac *mgo.Collection, a *achievable.Achievable, id bson.ObjectId) error {
+	if len(a.Parent) == 0 && len(a.BlockedBy) == 0 {
+		return nil
+	}
+	tasks, err := g.RetrieveAchievables(ac, 0, 0)
+	if err != nil {
+		return err
+	}
+	updated := *a
+	updated.ID = id
+	replaced := false
+	for i := range tasks {
+		if tasks[i].ID == id {
+			tasks[i], replaced = updated, true
+		}
+	}
+	if !replaced {
+		tasks = append(tasks, updated)
+	}
+	byID, _ := index(tasks)
+
+	var invalid []errors.Field
+	if len(a.Parent) != 0 {
+		if _, ok := byID[a.Parent]; !ok || a.Parent == id {
+			invalid = append(invalid, relationField("parent", "parent", "the goal has no other task %s", a.Parent.Hex()))
+		}
+	}
+	seen := make(map[bson.ObjectId]bool, len(a.BlockedBy))
+	var open []string
+	for _, b := range a.BlockedBy {
+		blocker, ok := byID[b]
+		switch {
+		case !ok || b == id:
+			invalid = append(invalid, relationField("blockedBy", "blockedBy", "the goal has no other task %s", b.Hex()))
+		case seen[b]:
+			invalid = append(invalid, relationField("blockedBy", "unique", "task %s is repeated", b.Hex()))
+		case !blocker.HasAchieved():
+			open = append(open, b.Hex())
+		}
+		seen[b] = true
+	}
+	if len(invalid) != 0 {
+		return errors.NewValidateFields(invalid)
+	}
+	if c := cycle(tasks); c != nil {
+		ids := make([]string, 0, len(c))
+		for _, id := range c {
+			ids = append(ids, id.Hex())
+		}
+		return errors.NewValidateFields([]errors.Field{relationField("blockedBy", "acyclic",
+			"the tasks would depend on each other: %s", strings.Join(ids, " -> "))})
+	}
+	if a.HasAchieved() && len(open) != 0 && !g.AllowBlockedCompletion {
+		return errors.NewValidateFields([]errors.Field{relationField("status", "blocked",
+			"the task is blocked by the open tasks %s", strings.Join(open, ", "))})
+	}
+	return nil
+}
+
+// unlink removes the references to the removed task: its subtasks lose their
+// parent and the tasks it blocked are not blocked by it anymore
+func (g *Goal) unlink(ac *mgo.Collection, id bson.ObjectId) error {
+	if _, err := ac.UpdateAll(bson.M{"_goal": g.ID, "parent": id},
+		bson.M{"$unset": bson.M{"parent": ""}}); err != nil {
+		return err
+	}
+	_, err := ac.UpdateAll(bson.M{"_goal": g.ID, "blockedBy": id},
+		bson.M{"$pull": bson.M{"blockedBy": id}})
+	return err
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/iocat/donit/internal/achieving/errors"
 	"github.com/iocat/donit/internal/achieving/internal/achievable"
+	"github.com/iocat/donit/internal/achieving/internal/member"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -32,20 +33,44 @@ const (
 	AccessForFollowers = "FOR_FOLLOWERS"
 	// AccessPublic is the accessibility for public user
 	AccessPublic = "PUBLIC"
+	// AccessWorkspace is the accessibility for the members of the goal's
+	// workspace
+	AccessWorkspace = "WORKSPACE"
 )
 
 // Goal represents an achievable Goal
 type Goal struct {
 	ID       bson.ObjectId `bson:"_id,omitempty" json:"id,omitempty" valid:"optional,hexadecimal"`
 	Username string        `bson:"username" json:"-" valid:"optional,alphanum,length(1|30)"`
+	// Workspace is the workspace the goal was created in, if any. A goal
+	// never moves between workspaces
+	Workspace bson.ObjectId `bson:"workspace,omitempty" json:"workspace,omitempty" valid:"optional,hexadecimal"`
 
-	Name          string                  `bson:"name" json:"name" valid:"name" valid:"required,utfletternum,stringlength(1|100)"`
+	Name          string                  `bson:"name" json:"name" valid:"required,utfletternum,stringlength(1|100)"`
 	Description   string                  `bson:"description,omitempty" json:"description,omitempty" valid:"optional,stringlength(1|400)"`
 	LastUpdated   time.Time               `bson:"lastUpdated" json:"lastUpdated" valid:"-"`
 	Status        string                  `bson:"status" json:"status" valid:"validateStatus"`
 	PictureURL    string                  `bson:"pictureUrl,omitempty" json:"pictureUrl,omitempty" valid:"optional,url"`
-	Accessibility string                  `bson:"accessibility" json:"accessibility,omitempty" valid:"required,goalAccessValidator"`
+	Accessibility string                  `bson:"accessibility" json:"accessibility,omitempty" valid:"required,goalAccessField"`
 	ToDo          []achievable.Achievable `bson:"-" json:"achievables" valid:"-"`
+	// Tags are the IDs of the tags of the user's catalogue the goal is
+	// tagged with
+	Tags []bson.ObjectId `bson:"tags,omitempty" json:"tags,omitempty" valid:"-"`
+
+	// Deadline is the date the goal is to be achieved by, if any
+	Deadline   *time.Time  `bson:"deadline,omitempty" json:"deadline,omitempty" valid:"-"`
+	Milestones []Milestone `bson:"milestones,omitempty" json:"milestones,omitempty" valid:"optional"`
+	// AllowBlockedCompletion lets the tasks be done while their
+	// blockers are open
+	AllowBlockedCompletion bool `bson:"allowBlockedCompletion,omitempty" json:"allowBlockedCompletion,omitempty" valid:"-"`
+	// Members are the users the goal is shared with, they are stored apart
+	// from the goal
+	Members []member.Member `bson:"-" json:"members,omitempty" valid:"-"`
+	// Progress is computed from the achievables by Track
+	Progress `bson:"-" valid:"-"`
+	// MemberProgress is the progress of the achievables assigned to the
+	// owner and to each member, computed by Track
+	MemberProgress []MemberProgress `bson:"-" json:"memberProgress,omitempty" valid:"-"`
 }
 
 // AccessValidatorFunc validates the accessibility field of the Goal model
@@ -53,7 +78,7 @@ func AccessValidatorFunc(value, _ interface{}) bool {
 	switch value := value.(type) {
 	case string:
 		switch value {
-		case AccessPrivate, AccessPublic, AccessForFollowers:
+		case AccessPrivate, AccessPublic, AccessForFollowers, AccessWorkspace:
 			return true
 		default:
 			return false
@@ -63,33 +88,134 @@ func AccessValidatorFunc(value, _ interface{}) bool {
 	}
 }
 
-// RemoveAchievable removes a habit
-func (g *Goal) RemoveAchievable(ac *mgo.Collection, id bson.ObjectId) error {
-	err := ac.Remove(bson.M{
+// VisibleTo returns whether the user can see the goal: the owner and the
+// members, invited or not, see every goal and the others only see the
+// public ones. The followers of the owner of a FOR_FOLLOWERS goal and the
+// members of the workspace of a WORKSPACE goal are left to the store which
+// knows them
+func (g *Goal) VisibleTo(username string) bool {
+	if len(username) != 0 && username == g.Username {
+		return true
+	}
+	if g.Member(username) != nil {
+		return true
+	}
+	return g.Accessibility == AccessPublic
+}
+
+// Visible returns the query condition of the goals of another user the user
+// can see, the one VisibleTo checks: the public ones, the ones shared with
+// the user, the FOR_FOLLOWERS ones of the users the user follows and the
+// WORKSPACE ones of the user's workspaces
+func Visible(shared []bson.ObjectId, followees []string, workspaces []bson.ObjectId) bson.M {
+	visible := []bson.M{{"accessibility": AccessPublic}}
+	if len(shared) != 0 {
+		visible = append(visible, bson.M{"_id": bson.M{"$in": shared}})
+	}
+	if len(followees) != 0 {
+		visible = append(visible, bson.M{
+			"accessibility": AccessForFollowers,
+			"username":      bson.M{"$in": followees},
+		})
+	}
+	if len(workspaces) != 0 {
+		visible = append(visible, bson.M{
+			"accessibility": AccessWorkspace,
+			"workspace":     bson.M{"$in": workspaces},
+		})
+	}
+	return bson.M{"$or": visible}
+}
+
+// HasAchieved returns whether the goal is achieved
+func (g Goal) HasAchieved() bool {
+	return g.Status == achievable.Done
+}
+
+// RemoveAchievable removes a habit and returns it, the other tasks stop
+// referring to it
+func (g *Goal) RemoveAchievable(ac *mgo.Collection, id bson.ObjectId) (achievable.Achievable, error) {
+	var old achievable.Achievable
+	_, err := ac.Find(bson.M{
 		"_goal": g.ID,
 		"_id":   id,
-	})
+	}).Apply(mgo.Change{Remove: true}, &old)
 	if err != nil {
 		if err == mgo.ErrNotFound {
-			return errors.NewNotFound("achievable", fmt.Sprintf("%s,%s", g.ID.Hex(), id))
+			return old, errors.NewNotFound("achievable", fmt.Sprintf("%s,%s", g.ID.Hex(), id))
 		}
+		return old, err
+	}
+	return old, g.unlink(ac, id)
+}
+
+// RestoreAchievable puts back an achievable removed, with its former ID.
+// The tasks which referred to it do not refer to it again
+func (g *Goal) RestoreAchievable(ac *mgo.Collection, a *achievable.Achievable) error {
+	if err := normalize(a); err != nil {
+		return err
+	}
+	if err := g.checkMilestone(a); err != nil {
+		return err
+	}
+	if err := g.checkRelations(ac, a, a.ID); err != nil {
+		return err
+	}
+	if err := g.checkAssignee(a); err != nil {
+		return err
+	}
+	a.Goal = g.ID
+	if err := ac.Insert(a); err != nil {
+		if mgo.IsDup(err) {
+			return errors.NewDuplicated("achievable", fmt.Sprintf("%s,%s", g.ID.Hex(), a.ID.Hex()))
+		}
+		return err
 	}
 	return nil
 }
 
-// UpdateAchievable updates an achievable task
-func (g *Goal) UpdateAchievable(ac *mgo.Collection, a *achievable.Achievable, id bson.ObjectId) error {
+// normalize checks the recurrence of the habits before they are stored and
+// leaves their next reminder to the scheduler
+func normalize(a *achievable.Achievable) error {
+	a.NextReminder = time.Time{}
+	if a.RepeatReminder == nil {
+		return nil
+	}
+	if err := a.RepeatReminder.Normalize(); err != nil {
+		return errors.NewValidate(err.Error())
+	}
+	a.NextReminder = achievable.Unscheduled
+	return nil
+}
+
+// UpdateAchievable updates an achievable task and returns the task as it was
+// before
+func (g *Goal) UpdateAchievable(ac *mgo.Collection, a *achievable.Achievable, id bson.ObjectId) (achievable.Achievable, error) {
+	if err := normalize(a); err != nil {
+		return achievable.Achievable{}, err
+	}
+	if err := g.checkMilestone(a); err != nil {
+		return achievable.Achievable{}, err
+	}
+	if err := g.checkRelations(ac, a, id); err != nil {
+		return achievable.Achievable{}, err
+	}
+	if err := g.checkAssignee(a); err != nil {
+		return achievable.Achievable{}, err
+	}
 	a.Goal, a.ID = g.ID, id
-	err := ac.Update(bson.M{
+	var old achievable.Achievable
+	_, err := ac.Find(bson.M{
 		"_goal": g.ID,
 		"_id":   id,
-	}, a)
+	}).Apply(mgo.Change{Update: a}, &old)
 	if err != nil {
 		if err == mgo.ErrNotFound {
-			return errors.NewNotFound("habit", fmt.Sprintf("%s,%s", g.ID, id))
+			return old, errors.NewNotFound("habit", fmt.Sprintf("%s,%s", g.ID, id))
 		}
+		return old, err
 	}
-	return nil
+	return old, nil
 }
 
 func (g *Goal) retrieveAchievables(list *[]achievable.Achievable, a *mgo.Collection, limit, offset int) error {
@@ -119,9 +245,37 @@ func (g *Goal) RetrieveAchievables(ac *mgo.Collection, limit, offset int) ([]ach
 	return h, nil
 }
 
+// RetrieveAchievable gets an achievable task of the goal
+func (g *Goal) RetrieveAchievable(ac *mgo.Collection, id bson.ObjectId) (achievable.Achievable, error) {
+	var a achievable.Achievable
+	err := ac.Find(bson.M{
+		"_goal": g.ID,
+		"_id":   id,
+	}).One(&a)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return a, errors.NewNotFound("achievable", fmt.Sprintf("%s,%s", g.ID.Hex(), id.Hex()))
+		}
+		return a, err
+	}
+	return a, nil
+}
+
 // AddAchievable adds a habit
 func (g *Goal) AddAchievable(ac *mgo.Collection, a *achievable.Achievable) (bson.ObjectId, error) {
 	id := bson.NewObjectId()
+	if err := normalize(a); err != nil {
+		return id, err
+	}
+	if err := g.checkMilestone(a); err != nil {
+		return id, err
+	}
+	if err := g.checkRelations(ac, a, id); err != nil {
+		return id, err
+	}
+	if err := g.checkAssignee(a); err != nil {
+		return id, err
+	}
 	a.Goal, a.ID = g.ID, id
 	err := ac.Insert(a)
 	if err != nil {
@@ -0,0 +1,129 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+	"github.com/iocat/donit/internal/achieving/internal/member"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// MemberProgress is the progress of the achievables assigned to a user
+type MemberProgress struct {
+	Username string `json:"username"`
+	Progress
+}
+
+// Member returns the membership of the user, nil if the user is neither a
+// member nor invited
+func (g *Goal) Member(username string) *member.Member {
+	if len(username) == 0 {
+		return nil
+	}
+	for i := range g.Members {
+		if g.Members[i].Username == username {
+			return &g.Members[i]
+		}
+	}
+	return nil
+}
+
+// Role returns the role of the user on the goal: the owner has the owner
+// role, the members who accepted their invite have theirs and the others
+// have none
+func (g *Goal) Role(username string) string {
+	if len(username) != 0 && username == g.Username {
+		return member.RoleOwner
+	}
+	if m := g.Member(username); m != nil && m.Active() {
+		return m.Role
+	}
+	return ""
+}
+
+// CanEdit returns whether the user can change the goal and its achievables
+func (g *Goal) CanEdit(username string) bool {
+	switch g.Role(username) {
+	case member.RoleOwner, member.RoleEditor:
+		return true
+	}
+	return false
+}
+
+// CanManage returns whether the user can delete the goal and manage its
+// members
+func (g *Goal) CanManage(username string) bool {
+	return g.Role(username) == member.RoleOwner
+}
+
+// participants lists the owner and the active members
+func (g *Goal) participants() []string {
+	users := []string{g.Username}
+	for _, m := range g.Members {
+		if m.Active() {
+			users = append(users, m.Username)
+		}
+	}
+	return users
+}
+
+// trackMembers computes the progress of the achievables assigned to each
+// participant
+func (g *Goal) trackMembers(now time.Time) {
+	g.MemberProgress = nil
+	if len(g.Members) == 0 {
+		return
+	}
+	for _, username := range g.participants() {
+		var tasks []achievable.Achievable
+		for _, t := range g.ToDo {
+			if t.Assignee == username {
+				tasks = append(tasks, t)
+			}
+		}
+		p := MemberProgress{Username: username}
+		p.track(tasks, g.ID.Time(), g.Deadline, now)
+		g.MemberProgress = append(g.MemberProgress, p)
+	}
+}
+
+// checkAssignee checks that the achievable is assigned to the owner or to an
+// active member, if anyone
+func (g *Goal) checkAssignee(a *achievable.Achievable) error {
+	if len(a.Assignee) == 0 || len(g.Role(a.Assignee)) != 0 {
+		return nil
+	}
+	return errors.NewValidateFields([]errors.Field{{
+		Name:    "assignee",
+		Rule:    "member",
+		Message: fmt.Sprintf("assignee: %s is not a member of the goal", a.Assignee),
+	}})
+}
+
+// Unassign unassigns the achievables assigned to the user
+func (g *Goal) Unassign(ac *mgo.Collection, username string) error {
+	_, err := ac.UpdateAll(bson.M{
+		"_goal":    g.ID,
+		"assignee": username,
+	}, bson.M{
+		"$unset": bson.M{"assignee": ""},
+	})
+	return err
+}
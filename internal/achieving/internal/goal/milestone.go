@@ -0,0 +1,195 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Milestone is a step of a goal grouping some of its achievables, the
+// milestones of a goal are in the order they are reached
+type Milestone struct {
+	ID          bson.ObjectId `bson:"id" json:"id,omitempty" valid:"optional,hexadecimal"`
+	Name        string        `bson:"name" json:"name" valid:"required,utfletternum,stringlength(1|100)"`
+	Description string        `bson:"description,omitempty" json:"description,omitempty" valid:"optional,stringlength(1|400)"`
+	Deadline    *time.Time    `bson:"deadline,omitempty" json:"deadline,omitempty" valid:"-"`
+	Progress    `bson:"-" valid:"-"`
+}
+
+// Progress is the progress of a goal or a milestone, computed from the state
+// of its tasks
+type Progress struct {
+	// Done is the number of the tasks done out of Total
+	Done  int `json:"done"`
+	Total int `json:"total"`
+	// Completed tells whether every task is done
+	Completed bool `json:"completed"`
+	// AtRisk tells whether the deadline is likely to be missed: the
+	// share of the tasks remaining is greater than the share of the time
+	// left
+	AtRisk bool `json:"atRisk"`
+}
+
+// track computes the progress of the tasks started at start and due by
+// the deadline, if any
+func (p *Progress) track(tasks []achievable.Achievable, start time.Time, deadline *time.Time, now time.Time) {
+	p.Done, p.Total = 0, 0
+	for _, t := range tasks {
+		if t.IsHabit() {
+			// the habits are never done once for all
+			continue
+		}
+		p.Total++
+		if t.HasAchieved() {
+			p.Done++
+		}
+	}
+	p.Completed = p.Total > 0 && p.Done == p.Total
+	p.AtRisk = false
+	if deadline == nil || p.Completed || p.Total == 0 {
+		return
+	}
+	if !now.Before(*deadline) {
+		p.AtRisk = true
+		return
+	}
+	span := deadline.Sub(start)
+	if span <= 0 {
+		return
+	}
+	remaining := float64(p.Total-p.Done) / float64(p.Total)
+	timeLeft := float64(deadline.Sub(now)) / float64(span)
+	p.AtRisk = remaining > timeLeft
+}
+
+// Track computes the progress of the goal and of its milestones from the
+// achievables in ToDo. The goal starts when it is created, a milestone when
+// the previous milestone is due
+func (g *Goal) Track(now time.Time) {
+	g.Progress.track(g.ToDo, g.ID.Time(), g.Deadline, now)
+	start := g.ID.Time()
+	for i := range g.Milestones {
+		m := &g.Milestones[i]
+		var tasks []achievable.Achievable
+		for _, t := range g.ToDo {
+			if t.Milestone == m.ID {
+				tasks = append(tasks, t)
+			}
+		}
+		m.Progress.track(tasks, start, m.Deadline, now)
+		if m.Deadline != nil && m.Deadline.After(start) {
+			start = *m.Deadline
+		}
+	}
+	g.trackMembers(now)
+}
+
+// milestoneField is an invalid field of the goal's milestones
+func milestoneField(i int, rule, format string, args ...interface{}) errors.Field {
+	name := fmt.Sprintf("milestones[%d]", i)
+	return errors.Field{
+		Name:    name,
+		Rule:    rule,
+		Message: fmt.Sprintf("%s: %s", name, fmt.Sprintf(format, args...)),
+	}
+}
+
+// Normalize gives an ID to the new milestones and checks that the milestones
+// are unique and due by the goal's deadline, and that the accessibility fits
+// the workspace
+func (g *Goal) Normalize() error {
+	var invalid []errors.Field
+	seen := make(map[bson.ObjectId]bool, len(g.Milestones))
+	for i := range g.Milestones {
+		m := &g.Milestones[i]
+		if len(m.ID) == 0 {
+			m.ID = bson.NewObjectId()
+		}
+		if seen[m.ID] {
+			invalid = append(invalid, milestoneField(i, "unique", "milestone %s is repeated", m.ID.Hex()))
+		}
+		seen[m.ID] = true
+		if m.Deadline != nil && g.Deadline != nil && m.Deadline.After(*g.Deadline) {
+			invalid = append(invalid, milestoneField(i, "deadline", "the milestone is due after the goal"))
+		}
+	}
+	if f, ok := g.checkAccessibility(); !ok {
+		invalid = append(invalid, f)
+	}
+	if len(invalid) != 0 {
+		return errors.NewValidateFields(invalid)
+	}
+	return nil
+}
+
+// checkAccessibility checks that the goals of a workspace are either private
+// or shared with the workspace, which the other goals cannot be
+func (g *Goal) checkAccessibility() (errors.Field, bool) {
+	switch inWorkspace := len(g.Workspace) != 0; {
+	case inWorkspace && g.Accessibility != AccessPrivate && g.Accessibility != AccessWorkspace:
+		return errors.Field{
+			Name:    "accessibility",
+			Rule:    "goalAccessField",
+			Message: fmt.Sprintf("accessibility: a workspace goal is either %s or %s", AccessPrivate, AccessWorkspace),
+		}, false
+	case !inWorkspace && g.Accessibility == AccessWorkspace:
+		return errors.Field{
+			Name:    "accessibility",
+			Rule:    "goalAccessField",
+			Message: "accessibility: the goal is in no workspace",
+		}, false
+	}
+	return errors.Field{}, true
+}
+
+// checkMilestone checks that the achievable belongs to a milestone of the
+// goal, if any
+func (g *Goal) checkMilestone(a *achievable.Achievable) error {
+	if len(a.Milestone) == 0 {
+		return nil
+	}
+	for _, m := range g.Milestones {
+		if m.ID == a.Milestone {
+			return nil
+		}
+	}
+	return errors.NewValidateFields([]errors.Field{{
+		Name:    "milestone",
+		Rule:    "milestone",
+		Message: fmt.Sprintf("milestone: the goal has no milestone %s", a.Milestone.Hex()),
+	}})
+}
+
+// DetachMilestones detaches the achievables from the milestones the goal has
+// not anymore
+func (g *Goal) DetachMilestones(ac *mgo.Collection) error {
+	ids := make([]bson.ObjectId, 0, len(g.Milestones))
+	for _, m := range g.Milestones {
+		ids = append(ids, m.ID)
+	}
+	_, err := ac.UpdateAll(bson.M{
+		"_goal":     g.ID,
+		"milestone": bson.M{"$exists": true, "$nin": ids},
+	}, bson.M{
+		"$unset": bson.M{"milestone": ""},
+	})
+	return err
+}
@@ -0,0 +1,264 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package member contains the members a goal is shared with
+package member
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/errors"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Collection is the name of the collection of the members
+const Collection = "members"
+
+const (
+	// RoleOwner lets the member change the goal, its achievables and its
+	// members, and delete it
+	RoleOwner = "OWNER"
+	// RoleEditor lets the member change the goal and its achievables
+	RoleEditor = "EDITOR"
+	// RoleViewer lets the member see the goal and its achievables
+	RoleViewer = "VIEWER"
+)
+
+const (
+	// StatusInvited is the status of the members who have not accepted
+	// their invite yet
+	StatusInvited = "INVITED"
+	// StatusActive is the status of the members who accepted their invite
+	StatusActive = "ACTIVE"
+)
+
+// Member is a user a goal is shared with. The user the goal belongs to is
+// its owner without being a member
+type Member struct {
+	ID   bson.ObjectId `bson:"_id,omitempty" json:"-" valid:"-"`
+	Goal bson.ObjectId `bson:"_goal" json:"goal" valid:"-"`
+	// Owner is the user the goal belongs to
+	Owner     string     `bson:"owner" json:"owner" valid:"-"`
+	Username  string     `bson:"username" json:"username" valid:"-"`
+	Role      string     `bson:"role" json:"role" valid:"required,memberRole"`
+	Status    string     `bson:"status" json:"status" valid:"-"`
+	InvitedBy string     `bson:"invitedBy" json:"invitedBy" valid:"-"`
+	Invited   time.Time  `bson:"invited" json:"invited" valid:"-"`
+	Joined    *time.Time `bson:"joined,omitempty" json:"joined,omitempty" valid:"-"`
+}
+
+// ValidateRole validates the role field
+func ValidateRole(value, _ interface{}) bool {
+	switch value := value.(type) {
+	case string:
+		switch value {
+		case RoleOwner, RoleEditor, RoleViewer:
+			return true
+		default:
+			return false
+		}
+	default:
+		panic("the role field must be a string")
+	}
+}
+
+// Active returns whether the member accepted the invite
+func (m *Member) Active() bool {
+	return m.Status == StatusActive
+}
+
+// EnsureIndexes creates the indexes of the member collection, a user is a
+// member of a goal at most once
+func EnsureIndexes(col *mgo.Collection) error {
+	indexes := []mgo.Index{
+		{Key: []string{"_goal", "username"}, Unique: true},
+		{Key: []string{"username", "status", "invited"}},
+	}
+	for _, index := range indexes {
+		if err := col.EnsureIndex(index); err != nil {
+			return fmt.Errorf("ensure member index %v: %s", index.Key, err)
+		}
+	}
+	return nil
+}
+
+// notFound reports the member missing from the goal
+func notFound(goal bson.ObjectId, username string) error {
+	return errors.NewNotFound("member", fmt.Sprintf("%s,%s", goal.Hex(), username))
+}
+
+// Invite invites the user to the goal of the owner with the role. Inviting
+// a member again is reported as a duplicate
+func Invite(col *mgo.Collection, goal bson.ObjectId, owner, username, role, by string) error {
+	if username == owner {
+		return errors.NewValidate("the owner of a goal cannot be invited to it")
+	}
+	err := col.Insert(Member{
+		ID:        bson.NewObjectId(),
+		Goal:      goal,
+		Owner:     owner,
+		Username:  username,
+		Role:      role,
+		Status:    StatusInvited,
+		InvitedBy: by,
+		Invited:   time.Now(),
+	})
+	if err != nil {
+		if mgo.IsDup(err) {
+			return errors.NewDuplicated("member", fmt.Sprintf("%s,%s", goal.Hex(), username))
+		}
+		return err
+	}
+	return nil
+}
+
+// SetRole changes the role of the member
+func SetRole(col *mgo.Collection, goal bson.ObjectId, username, role string) error {
+	err := col.Update(bson.M{
+		"_goal":    goal,
+		"username": username,
+	}, bson.M{
+		"$set": bson.M{"role": role},
+	})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return notFound(goal, username)
+		}
+		return err
+	}
+	return nil
+}
+
+// Accept makes the invited user a member of the goal and returns the
+// membership
+func Accept(col *mgo.Collection, goal bson.ObjectId, username string) (Member, error) {
+	var m Member
+	_, err := col.Find(bson.M{
+		"_goal":    goal,
+		"username": username,
+		"status":   StatusInvited,
+	}).Apply(mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{
+				"status": StatusActive,
+				"joined": time.Now(),
+			},
+		},
+		ReturnNew: true,
+	}, &m)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return m, errors.NewNotFound("invite", fmt.Sprintf("%s,%s", goal.Hex(), username))
+		}
+		return m, err
+	}
+	return m, nil
+}
+
+// Remove removes the member or the invite of the user from the goal
+func Remove(col *mgo.Collection, goal bson.ObjectId, username string) error {
+	err := col.Remove(bson.M{
+		"_goal":    goal,
+		"username": username,
+	})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return notFound(goal, username)
+		}
+		return err
+	}
+	return nil
+}
+
+// RemoveAll removes the members of the goal
+func RemoveAll(col *mgo.Collection, goal bson.ObjectId) error {
+	_, err := col.RemoveAll(bson.M{"_goal": goal})
+	return err
+}
+
+// Restore puts back the members of a goal deleted, as they were
+func Restore(col *mgo.Collection, ms []Member) error {
+	for _, m := range ms {
+		if err := col.Insert(m); err != nil {
+			if mgo.IsDup(err) {
+				return errors.NewDuplicated("member", fmt.Sprintf("%s,%s", m.Goal.Hex(), m.Username))
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveFrom removes the user from the goals, invited or not
+func RemoveFrom(col *mgo.Collection, goals []bson.ObjectId, username string) error {
+	if len(goals) == 0 {
+		return nil
+	}
+	_, err := col.RemoveAll(bson.M{
+		"_goal":    bson.M{"$in": goals},
+		"username": username,
+	})
+	return err
+}
+
+// Goals lists the IDs of the goals the user is a member of or invited to
+func Goals(col *mgo.Collection, username string) ([]bson.ObjectId, error) {
+	var ids []bson.ObjectId
+	if err := col.Find(bson.M{"username": username}).Distinct("_goal", &ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Of lists the members of the goals, invited or not, per goal
+func Of(col *mgo.Collection, goals []bson.ObjectId) (map[bson.ObjectId][]Member, error) {
+	members := make(map[bson.ObjectId][]Member, len(goals))
+	if len(goals) == 0 {
+		return members, nil
+	}
+	var ms []Member
+	err := col.Find(bson.M{
+		"_goal": bson.M{"$in": goals},
+	}).Sort("invited").All(&ms)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range ms {
+		members[m.Goal] = append(members[m.Goal], m)
+	}
+	return members, nil
+}
+
+// Memberships lists the goals the user is a member of or invited to, the
+// latest invite first. An empty status lists both
+func Memberships(col *mgo.Collection, username, status string, limit, offset int) ([]Member, error) {
+	q := bson.M{"username": username}
+	if len(status) != 0 {
+		q["status"] = status
+	}
+	query := col.Find(q).Sort("-invited")
+	if limit > 0 {
+		query.Limit(limit)
+	}
+	if offset > 0 {
+		query.Skip(offset)
+	}
+	var ms []Member
+	if err := query.All(&ms); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
@@ -0,0 +1,255 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tag contains the tags the users attach to their goals and
+// achievables
+package tag
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/errors"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Collection is the name of the collection of the tags
+const Collection = "tags"
+
+// Tag represents a tag of the user's catalogue. The goals and the
+// achievables refer to their tags by ID, renaming a tag is a single write
+type Tag struct {
+	ID       bson.ObjectId `bson:"_id,omitempty" json:"id,omitempty" valid:"optional,hexadecimal"`
+	Username string        `bson:"username" json:"-" valid:"optional,alphanum,length(1|30)"`
+	Name     string        `bson:"name" json:"name" valid:"required,stringlength(1|30)"`
+	// Color is the hexadecimal RGB color of the tag, for example #ff8800
+	Color   string    `bson:"color" json:"color" valid:"required,hexcolor"`
+	Created time.Time `bson:"created" json:"created" valid:"-"`
+	// MergedInto is the ID of the tag the tag is being merged into. The
+	// merged tag is left out of the catalogue and its references count as
+	// references to the other one until they are all rewritten
+	MergedInto bson.ObjectId `bson:"mergedInto,omitempty" json:"-" valid:"-"`
+}
+
+// EnsureIndexes creates the indexes of the tag collection, the names of the
+// tags of a user are unique
+func EnsureIndexes(col *mgo.Collection) error {
+	index := mgo.Index{Key: []string{"username", "name"}, Unique: true}
+	if err := col.EnsureIndex(index); err != nil {
+		return fmt.Errorf("ensure tag index %v: %s", index.Key, err)
+	}
+	return nil
+}
+
+func notFound(username string, id bson.ObjectId) error {
+	return errors.NewNotFound("tag", fmt.Sprintf("%s,%s", username, id.Hex()))
+}
+
+func duplicated(username, name string) error {
+	return errors.NewDuplicated("tag", fmt.Sprintf("%s,%s", username, name))
+}
+
+// Create adds the tag to the user's catalogue
+func Create(col *mgo.Collection, username string, t *Tag) (bson.ObjectId, error) {
+	t.ID, t.Username = bson.NewObjectId(), username
+	t.Created, t.MergedInto = time.Now(), ""
+	if err := col.Insert(t); err != nil {
+		if mgo.IsDup(err) {
+			return t.ID, duplicated(username, t.Name)
+		}
+		return t.ID, err
+	}
+	return t.ID, nil
+}
+
+// Update renames and recolors the tag
+func Update(col *mgo.Collection, username string, t *Tag, id bson.ObjectId) error {
+	err := col.Update(bson.M{
+		"_id":        id,
+		"username":   username,
+		"mergedInto": bson.M{"$exists": false},
+	}, bson.M{
+		"$set": bson.M{"name": t.Name, "color": t.Color},
+	})
+	if err != nil {
+		switch {
+		case err == mgo.ErrNotFound:
+			return notFound(username, id)
+		case mgo.IsDup(err):
+			return duplicated(username, t.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+// Retrieve gets a tag of the user's catalogue
+func Retrieve(col *mgo.Collection, username string, id bson.ObjectId) (Tag, error) {
+	var t Tag
+	err := col.Find(bson.M{
+		"_id":        id,
+		"username":   username,
+		"mergedInto": bson.M{"$exists": false},
+	}).One(&t)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return t, notFound(username, id)
+		}
+		return t, err
+	}
+	return t, nil
+}
+
+// List lists the user's catalogue by name
+func List(col *mgo.Collection, username string, limit, offset int) ([]Tag, error) {
+	q := col.Find(bson.M{
+		"username":   username,
+		"mergedInto": bson.M{"$exists": false},
+	}).Sort("name")
+	if limit > 0 {
+		q.Limit(limit)
+	}
+	if offset > 0 {
+		q.Skip(offset)
+	}
+	var ts []Tag
+	if err := q.All(&ts); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// Aliases returns the ID of the tag and the IDs of the tags being merged
+// into it, the references to any of them are references to the tag
+func Aliases(col *mgo.Collection, username string, id bson.ObjectId) ([]bson.ObjectId, error) {
+	if _, err := Retrieve(col, username, id); err != nil {
+		return nil, err
+	}
+	var merged []Tag
+	err := col.Find(bson.M{
+		"username":   username,
+		"mergedInto": id,
+	}).Select(bson.M{"_id": 1}).All(&merged)
+	if err != nil {
+		return nil, err
+	}
+	ids := []bson.ObjectId{id}
+	for _, t := range merged {
+		ids = append(ids, t.ID)
+	}
+	return ids, nil
+}
+
+// Check checks that the tags attached to a goal or an achievable are tags
+// of the user's catalogue, attached once
+func Check(col *mgo.Collection, username string, ids []bson.ObjectId) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	n, err := col.Find(bson.M{
+		"_id":        bson.M{"$in": ids},
+		"username":   username,
+		"mergedInto": bson.M{"$exists": false},
+	}).Count()
+	if err != nil {
+		return err
+	}
+	seen := make(map[bson.ObjectId]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return errors.NewValidateFields([]errors.Field{{
+				Name:    "tags",
+				Rule:    "unique",
+				Message: fmt.Sprintf("tags: tag %s is repeated", id.Hex()),
+			}})
+		}
+		seen[id] = true
+	}
+	if n != len(ids) {
+		return errors.NewValidateFields([]errors.Field{{
+			Name:    "tags",
+			Rule:    "tags",
+			Message: "tags: the catalogue of the user misses some of the tags",
+		}})
+	}
+	return nil
+}
+
+// Delete deletes the tag, the tags being merged into it and their
+// references from the collections of the tagged documents
+func Delete(col *mgo.Collection, username string, id bson.ObjectId, tagged ...*mgo.Collection) error {
+	ids, err := Aliases(col, username, id)
+	if err != nil {
+		return err
+	}
+	for _, c := range tagged {
+		_, err := c.UpdateAll(bson.M{"tags": bson.M{"$in": ids}},
+			bson.M{"$pullAll": bson.M{"tags": ids}})
+		if err != nil {
+			return err
+		}
+	}
+	// the references are removed first, a failure leaves the tag to be
+	// deleted again
+	_, err = col.RemoveAll(bson.M{"_id": bson.M{"$in": ids}, "username": username})
+	return err
+}
+
+// Merge merges the tag into another one of the user's catalogue. Marking
+// the tag as merged is the single write which switches its references to
+// the other tag, the references are then rewritten and the tag deleted. A
+// merge which failed half way is completed by merging the tag again
+func Merge(col *mgo.Collection, username string, from, into bson.ObjectId, tagged ...*mgo.Collection) error {
+	if from == into {
+		return errors.NewValidate("a tag cannot be merged into itself")
+	}
+	if _, err := Retrieve(col, username, into); err != nil {
+		return err
+	}
+	err := col.Update(bson.M{
+		"_id":      from,
+		"username": username,
+		"$or": []bson.M{
+			{"mergedInto": bson.M{"$exists": false}},
+			{"mergedInto": into},
+		},
+	}, bson.M{
+		"$set": bson.M{"mergedInto": into},
+	})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return notFound(username, from)
+		}
+		return err
+	}
+	// the tags being merged into the merged tag follow it
+	_, err = col.UpdateAll(bson.M{"username": username, "mergedInto": from},
+		bson.M{"$set": bson.M{"mergedInto": into}})
+	if err != nil {
+		return err
+	}
+	for _, c := range tagged {
+		_, err := c.UpdateAll(bson.M{"tags": from},
+			bson.M{"$addToSet": bson.M{"tags": into}})
+		if err != nil {
+			return err
+		}
+		if _, err = c.UpdateAll(bson.M{"tags": from},
+			bson.M{"$pull": bson.M{"tags": from}}); err != nil {
+			return err
+		}
+	}
+	return col.Remove(bson.M{"_id": from, "username": username})
+}
@@ -0,0 +1,332 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package template contains the goal templates: the achievables of a goal
+// saved to create goals alike
+package template
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+	"github.com/iocat/donit/internal/achieving/internal/goal"
+	"github.com/iocat/donit/internal/rrule"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Collection is the name of the collection of the templates
+const Collection = "templates"
+
+// Template is a goal saved with its achievables. The reminders are kept
+// relative to the first day of the goal, they are rebased on the start date
+// of the goals created from the template
+type Template struct {
+	ID bson.ObjectId `bson:"_id,omitempty" json:"id,omitempty" valid:"optional,hexadecimal"`
+	// Username is the author of the template
+	Username    string `bson:"username" json:"username,omitempty" valid:"-"`
+	Name        string `bson:"name" json:"name" valid:"required,utfletternum,stringlength(1|100)"`
+	Description string `bson:"description,omitempty" json:"description,omitempty" valid:"optional,stringlength(1|400)"`
+	// Public templates are listed in the catalogue and anyone can create
+	// goals from them
+	Public  bool      `bson:"public" json:"public" valid:"-"`
+	Created time.Time `bson:"created" json:"created" valid:"-"`
+	// Uses counts the goals created from the template
+	Uses  int    `bson:"uses" json:"uses" valid:"-"`
+	Tasks []Task `bson:"tasks" json:"achievables,omitempty" valid:"-"`
+}
+
+// Task is an achievable of a template
+type Task struct {
+	// Key identifies the task in the template, the subtasks and the
+	// blocked tasks refer to it
+	Key         bson.ObjectId `bson:"key" json:"key"`
+	Name        string        `bson:"name" json:"name"`
+	Description string        `bson:"description,omitempty" json:"description,omitempty"`
+	Reminder    *Offset       `bson:"reminder,omitempty" json:"reminder,omitempty"`
+	// RepeatReminder is the recurrence of the habits, its start date and
+	// its skipped dates are left out
+	RepeatReminder *achievable.RepeatReminder `bson:"repeatedReminder,omitempty" json:"repeatedReminder,omitempty"`
+	// StartDay is the day the habit starts on, counted from the start
+	// date, for the rules with a COUNT or an INTERVAL
+	StartDay  *int            `bson:"startDay,omitempty" json:"startDay,omitempty"`
+	Parent    bson.ObjectId   `bson:"parent,omitempty" json:"parent,omitempty"`
+	BlockedBy []bson.ObjectId `bson:"blockedBy,omitempty" json:"blockedBy,omitempty"`
+}
+
+// Offset is a reminder relative to the start date: the wall clock time on
+// the day counted from the start date
+type Offset struct {
+	Day       int           `bson:"day" json:"day"`
+	TimeInDay time.Duration `bson:"remindAt" json:"remindAt"`
+	Duration  time.Duration `bson:"duration" json:"duration"`
+}
+
+// EnsureIndexes creates the indexes of the template collection
+func EnsureIndexes(col *mgo.Collection) error {
+	for _, index := range []mgo.Index{
+		{Key: []string{"username", "-created"}},
+		{Key: []string{"public", "-created"}},
+	} {
+		if err := col.EnsureIndex(index); err != nil {
+			return fmt.Errorf("ensure template index %v: %s", index.Key, err)
+		}
+	}
+	return nil
+}
+
+// days counts the days from a to b
+func days(a, b rrule.Date) int {
+	from := time.Date(a.Year, a.Month, a.Day, 0, 0, 0, 0, time.UTC)
+	to := time.Date(b.Year, b.Month, b.Day, 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from) / (24 * time.Hour))
+}
+
+// origin returns the first day of the tasks in the location: the day of the
+// earliest reminder or habit start, if any
+func origin(as []achievable.Achievable, loc *time.Location) (rrule.Date, bool) {
+	var (
+		first rrule.Date
+		found bool
+	)
+	see := func(d rrule.Date) {
+		if !found || d.Before(first) {
+			first, found = d, true
+		}
+	}
+	for _, a := range as {
+		if a.Reminder != nil {
+			see(rrule.DateOf(a.Reminder.At.In(loc)))
+		}
+		if a.RepeatReminder != nil {
+			if d, err := rrule.ParseDate(a.RepeatReminder.StartDate); err == nil {
+				see(d)
+			}
+		}
+	}
+	return first, found
+}
+
+// New makes the template of the goal's achievables, their reminders are made
+// relative to the earliest one in the owner's time zone. The tags, the
+// milestones and the assignees are left out
+func New(t *Template, as []achievable.Achievable, loc *time.Location) {
+	first, _ := origin(as, loc)
+	t.Tasks = make([]Task, 0, len(as))
+	for _, a := range as {
+		task := Task{
+			Key:         a.ID,
+			Name:        a.Name,
+			Description: a.Description,
+			Parent:      a.Parent,
+			BlockedBy:   a.BlockedBy,
+		}
+		if a.Reminder != nil {
+			at := a.Reminder.At.In(loc)
+			h, m, s := at.Clock()
+			task.Reminder = &Offset{
+				Day:       days(first, rrule.DateOf(at)),
+				TimeInDay: time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second,
+				Duration:  a.Reminder.Duration,
+			}
+		}
+		if a.RepeatReminder != nil {
+			r := *a.RepeatReminder
+			if d, err := rrule.ParseDate(r.StartDate); err == nil {
+				day := days(first, d)
+				task.StartDay = &day
+			}
+			r.StartDate, r.ExDates = "", nil
+			task.RepeatReminder = &r
+		}
+		t.Tasks = append(t.Tasks, task)
+	}
+}
+
+// Achievables makes the achievables of the template for a goal starting on
+// the date in the location. They keep the keys of their tasks as IDs for the
+// relationships to be mapped to the IDs of the achievables created
+func (t *Template) Achievables(start rrule.Date, loc *time.Location) []achievable.Achievable {
+	as := make([]achievable.Achievable, 0, len(t.Tasks))
+	for _, task := range t.Tasks {
+		a := achievable.Achievable{
+			ID:          task.Key,
+			Name:        task.Name,
+			Description: task.Description,
+			Status:      achievable.NotDone,
+			Parent:      task.Parent,
+			BlockedBy:   task.BlockedBy,
+		}
+		if o := task.Reminder; o != nil {
+			d := start.AddDays(o.Day)
+			a.Reminder = &achievable.Reminder{
+				At: achievable.LocalTime(d.Year, d.Month, d.Day, int(o.TimeInDay/time.Hour),
+					int(o.TimeInDay%time.Hour/time.Minute), int(o.TimeInDay%time.Minute/time.Second), loc),
+				Duration: o.Duration,
+			}
+		}
+		if task.RepeatReminder != nil {
+			r := *task.RepeatReminder
+			if task.StartDay != nil {
+				r.StartDate = start.AddDays(*task.StartDay).String()
+			}
+			a.RepeatReminder = &r
+		}
+		as = append(as, a)
+	}
+	return as
+}
+
+// Goal makes the goal of the template, its accessibility is the one given
+func (t *Template) Goal(accessibility string) goal.Goal {
+	return goal.Goal{
+		Name:          t.Name,
+		Description:   t.Description,
+		Status:        achievable.NotDone,
+		Accessibility: accessibility,
+	}
+}
+
+// notFound reports the template missing or hidden from the user
+func notFound(id bson.ObjectId) error {
+	return errors.NewNotFound("template", id.Hex())
+}
+
+// Create saves the template of the user
+func Create(col *mgo.Collection, t *Template, username string) (bson.ObjectId, error) {
+	t.ID, t.Username, t.Created, t.Uses = bson.NewObjectId(), username, time.Now(), 0
+	if err := col.Insert(t); err != nil {
+		return t.ID, err
+	}
+	return t.ID, nil
+}
+
+// Retrieve gets the template on behalf of the user, the templates of the
+// others are only found if they are public
+func Retrieve(col *mgo.Collection, id bson.ObjectId, username string) (Template, error) {
+	var t Template
+	err := col.Find(bson.M{
+		"_id": id,
+		"$or": []bson.M{
+			{"username": username},
+			{"public": true},
+		},
+	}).One(&t)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return t, notFound(id)
+		}
+		return t, err
+	}
+	return t, nil
+}
+
+// List lists the templates of the author, the latest first. The others
+// only see the public ones
+func List(col *mgo.Collection, author, username string, limit, offset int) ([]Template, error) {
+	q := bson.M{"username": author}
+	if author != username {
+		q["public"] = true
+	}
+	return list(col, q, limit, offset)
+}
+
+// Catalogue lists the public templates whose name contains the text, the
+// most used first
+func Catalogue(col *mgo.Collection, text string, limit, offset int) ([]Template, error) {
+	q := bson.M{"public": true}
+	if len(text) != 0 {
+		q["name"] = bson.RegEx{Pattern: regexp.QuoteMeta(text), Options: "i"}
+	}
+	return list(col, q, limit, offset, "-uses")
+}
+
+func list(col *mgo.Collection, query bson.M, limit, offset int, order ...string) ([]Template, error) {
+	q := col.Find(query).Sort(append(order, "-created")...)
+	if limit > 0 {
+		q.Limit(limit)
+	}
+	if offset > 0 {
+		q.Skip(offset)
+	}
+	var ts []Template
+	if err := q.All(&ts); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// author checks that the user wrote the template: the templates the user
+// cannot see are not found, the public ones of the others are forbidden
+func author(col *mgo.Collection, id bson.ObjectId, username string) error {
+	t, err := Retrieve(col, id, username)
+	if err != nil {
+		return err
+	}
+	if t.Username != username {
+		return errors.ErrForbidden
+	}
+	return nil
+}
+
+// Update renames the user's template, changes its description and publishes
+// or unpublishes it. Its tasks stay
+func Update(col *mgo.Collection, id bson.ObjectId, username string, t *Template) error {
+	if err := author(col, id, username); err != nil {
+		return err
+	}
+	err := col.Update(bson.M{
+		"_id":      id,
+		"username": username,
+	}, bson.M{
+		"$set": bson.M{
+			"name":        t.Name,
+			"description": t.Description,
+			"public":      t.Public,
+		},
+	})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return notFound(id)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete deletes the user's template, the goals created from it stay
+func Delete(col *mgo.Collection, id bson.ObjectId, username string) error {
+	if err := author(col, id, username); err != nil {
+		return err
+	}
+	err := col.Remove(bson.M{
+		"_id":      id,
+		"username": username,
+	})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return notFound(id)
+		}
+		return err
+	}
+	return nil
+}
+
+// Used counts a goal created from the template
+func Used(col *mgo.Collection, id bson.ObjectId) error {
+	return col.UpdateId(id, bson.M{"$inc": bson.M{"uses": 1}})
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/iocat/donit/internal/achieving/errors"
 	"github.com/iocat/donit/internal/achieving/internal/goal"
+	"github.com/iocat/donit/internal/notify"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -49,6 +50,28 @@ func ValidateUserStatus(value, _ interface{}) bool {
 	}
 }
 
+// ValidateNotificationChannels validates the notification channel list
+func ValidateNotificationChannels(value, _ interface{}) bool {
+	switch value := value.(type) {
+	case []string:
+		seen := make(map[string]bool)
+		for _, c := range value {
+			switch c {
+			case notify.ChannelWebhook, notify.ChannelEmail, notify.ChannelInApp:
+			default:
+				return false
+			}
+			if seen[c] {
+				return false
+			}
+			seen[c] = true
+		}
+		return true
+	default:
+		panic("the notification channels field must be a string slice")
+	}
+}
+
 // User represents a user, implements the User interface{}
 type User struct {
 	Username string `bson:"username" json:"username" valid:"required,alphanum,length(1|30)"`
@@ -65,11 +88,77 @@ type Data struct {
 	PictureURL           *string   `bson:"pictureUrl,omitempty" json:"pictureUrl,omitempty" valid:"optional,url"`
 	LastUpdated          time.Time `bson:"lastUpdated" json:"lastUpdated" valid:"-"`
 	HasUpdate            bool      `bson:"hasUpdated" json:"hasUpdated" valid:"-"`
+
+	Notifications *Notifications `bson:"notifications,omitempty" json:"notifications,omitempty" valid:"optional"`
+	// Timezone is the IANA time zone (for example "Europe/Paris") the
+	// user's habits are reminded in, UTC if empty
+	Timezone string `bson:"timezone,omitempty" json:"timezone,omitempty" valid:"optional,timezone"`
+	// Private accounts are left out of the explore feed and the user
+	// search, their profile only shows to their followers
+	Private bool `bson:"private" json:"private" valid:"-"`
+}
+
+// ValidateWebhookURL validates the webhook URL, an https URL of a public host
+func ValidateWebhookURL(value, _ interface{}) bool {
+	switch value := value.(type) {
+	case string:
+		return notify.CheckWebhookURL(value) == nil
+	default:
+		panic("the webhook URL field must be a string")
+	}
+}
+
+// ValidateTimezone validates the IANA time zone name
+func ValidateTimezone(value, _ interface{}) bool {
+	switch value := value.(type) {
+	case string:
+		// Local is the server's zone, not the user's
+		if value == "Local" {
+			return false
+		}
+		_, err := time.LoadLocation(value)
+		return err == nil
+	default:
+		panic("the timezone field must be a string")
+	}
+}
+
+// Location returns the location of the user's time zone, UTC if the user
+// has not set any
+func (d *Data) Location() *time.Location {
+	if len(d.Timezone) == 0 {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(d.Timezone)
+	if err != nil {
+		// the time zone was validated, the time zone database of the
+		// server misses it
+		return time.UTC
+	}
+	return loc
+}
+
+// Notifications tells how the user wants to be notified
+type Notifications struct {
+	Channels   []string `bson:"channels" json:"channels" valid:"notificationChannels"`
+	WebhookURL string   `bson:"webhookUrl,omitempty" json:"webhookUrl,omitempty" valid:"optional,webhookURL"`
+}
+
+// NotificationChannels returns the channels the user is notified through,
+// the in-app inbox if the user has not chosen any
+func (d *Data) NotificationChannels() []string {
+	if d.Notifications == nil {
+		return []string{notify.ChannelInApp}
+	}
+	return d.Notifications.Channels
 }
 
 // CreateGoal creates a new goal
 func (c *User) CreateGoal(goalCol *mgo.Collection, g *goal.Goal) (bson.ObjectId, error) {
 	nid := bson.NewObjectId()
+	if err := g.Normalize(); err != nil {
+		return nid, err
+	}
 	g.Username, g.ID = c.Username, nid
 	g.LastUpdated = time.Now()
 	err := goalCol.Insert(g)
@@ -79,42 +168,63 @@ func (c *User) CreateGoal(goalCol *mgo.Collection, g *goal.Goal) (bson.ObjectId,
 	return nid, nil
 }
 
-// DeleteGoal deletes a goal
-func (c *User) DeleteGoal(goalCol *mgo.Collection, id bson.ObjectId) error {
-	err := goalCol.Remove(bson.M{
+// DeleteGoal deletes a goal and returns it
+func (c *User) DeleteGoal(goalCol *mgo.Collection, id bson.ObjectId) (goal.Goal, error) {
+	var old goal.Goal
+	_, err := goalCol.Find(bson.M{
 		"username": c.Username,
 		"_id":      id,
-	})
+	}).Apply(mgo.Change{Remove: true}, &old)
 	if err != nil {
 		if err == mgo.ErrNotFound {
-			return errors.NewNotFound("goal", fmt.Sprintf("%s,%s", c.Username, id))
+			return old, errors.NewNotFound("goal", fmt.Sprintf("%s,%s", c.Username, id))
 		}
-		return err
+		return old, err
+	}
+	return old, nil
+}
+
+// RestoreGoal puts back a goal deleted, with its former ID
+func (c *User) RestoreGoal(goalCol *mgo.Collection, g *goal.Goal) error {
+	g.Username = c.Username
+	g.LastUpdated = time.Now()
+	if err := goalCol.Insert(g); err != nil {
+		if mgo.IsDup(err) {
+			return errors.NewDuplicated("goal", fmt.Sprintf("%s,%s", c.Username, g.ID.Hex()))
+		}
+		return fmt.Errorf("restore goal: %s", err)
 	}
 	return nil
 }
 
-// UpdateGoal updates a goal
-func (c *User) UpdateGoal(goalCol *mgo.Collection, g *goal.Goal, id bson.ObjectId) error {
+// UpdateGoal updates a goal and returns the goal as it was before
+func (c *User) UpdateGoal(goalCol *mgo.Collection, g *goal.Goal, id bson.ObjectId) (goal.Goal, error) {
+	if err := g.Normalize(); err != nil {
+		return goal.Goal{}, err
+	}
 	g.Username, g.ID = c.Username, id
 	g.LastUpdated = time.Now()
-	err := goalCol.Update(bson.M{
+	var old goal.Goal
+	_, err := goalCol.Find(bson.M{
 		"username": c.Username,
 		"_id":      id,
-	}, g)
+	}).Apply(mgo.Change{Update: g}, &old)
 	if err != nil {
 		if err == mgo.ErrNotFound {
-			return errors.NewNotFound("goal", fmt.Sprintf("%s,%s", c.Username, id))
+			return old, errors.NewNotFound("goal", fmt.Sprintf("%s,%s", c.Username, id))
 		}
-		return err
+		return old, err
 	}
-	return nil
+	return old, nil
 }
 
 // RetrieveGoal gets the goal
 func (c *User) RetrieveGoal(goalCol *mgo.Collection, achC *mgo.Collection, id bson.ObjectId) (goal.Goal, error) {
 	var g goal.Goal
-	err := goalCol.FindId(id).One(&g)
+	err := goalCol.Find(bson.M{
+		"username": c.Username,
+		"_id":      id,
+	}).One(&g)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			return goal.Goal{}, errors.NewNotFound("goal", fmt.Sprintf("%s,%s", c.Username, id.Hex()))
@@ -128,12 +238,10 @@ func (c *User) RetrieveGoal(goalCol *mgo.Collection, achC *mgo.Collection, id bs
 	return g, nil
 }
 
-// RetriveGoals retrieves all the goals
-func (c *User) RetriveGoals(goalCol *mgo.Collection, achC *mgo.Collection, limit, offset int) ([]goal.Goal, error) {
+func (c *User) retrieveGoals(goalCol *mgo.Collection, achC *mgo.Collection, query bson.M, limit, offset int) ([]goal.Goal, error) {
 	var gs []goal.Goal
-	q := goalCol.Find(bson.M{
-		"username": c.Username,
-	})
+	query["username"] = c.Username
+	q := goalCol.Find(query)
 	if limit > 0 {
 		q.Limit(limit)
 	}
@@ -153,6 +261,29 @@ func (c *User) RetriveGoals(goalCol *mgo.Collection, achC *mgo.Collection, limit
 	return gs, nil
 }
 
+// RetriveGoals retrieves all the goals
+func (c *User) RetriveGoals(goalCol *mgo.Collection, achC *mgo.Collection, limit, offset int) ([]goal.Goal, error) {
+	return c.retrieveGoals(goalCol, achC, bson.M{}, limit, offset)
+}
+
+// RetrieveTaggedGoals retrieves the goals tagged with any of the tags
+func (c *User) RetrieveTaggedGoals(goalCol *mgo.Collection, achC *mgo.Collection, tags []bson.ObjectId, limit, offset int) ([]goal.Goal, error) {
+	return c.retrieveGoals(goalCol, achC, bson.M{"tags": bson.M{"$in": tags}}, limit, offset)
+}
+
+// RetrieveVisibleGoals retrieves the goals matching the visibility condition,
+// see goal.Visible, tagged with any of the tags if there are
+func (c *User) RetrieveVisibleGoals(goalCol *mgo.Collection, achC *mgo.Collection, visible bson.M, tags []bson.ObjectId, limit, offset int) ([]goal.Goal, error) {
+	query := bson.M{}
+	for k, v := range visible {
+		query[k] = v
+	}
+	if len(tags) != 0 {
+		query["tags"] = bson.M{"$in": tags}
+	}
+	return c.retrieveGoals(goalCol, achC, query, limit, offset)
+}
+
 // StoredUser encapsulates user's password
 type storedUser struct {
 	User           `bson:"user,inline" valid:"required"`
@@ -202,14 +333,27 @@ func Create(c *User, userC *mgo.Collection, password string) error {
 	return nil
 }
 
-// Update updates a user data
+// Update updates a user data, the optional settings the user left out are
+// removed
 func Update(u *User, userC *mgo.Collection, username string) error {
 	u.Username = username
+	change := bson.M{
+		"$set": u,
+	}
+	// the empty optional settings are omitted from $set
+	unset := bson.M{}
+	if u.Notifications == nil {
+		unset["notifications"] = ""
+	}
+	if len(u.Timezone) == 0 {
+		unset["timezone"] = ""
+	}
+	if len(unset) != 0 {
+		change["$unset"] = unset
+	}
 	err := userC.Update(bson.M{
 		"username": username,
-	}, bson.M{
-		"$set": u,
-	})
+	}, change)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			return errors.NewNotFound("user", username)
@@ -240,7 +384,9 @@ func Delete(userC *mgo.Collection, username, password string) error {
 	return errors.ErrAuthentication
 }
 
-// Authenticate authenticates the user
+// Authenticate authenticates the user, it returns errors.ErrAuthentication
+// if the user does not exist or the password is wrong so that the callers
+// cannot tell which one is
 func Authenticate(userCol *mgo.Collection, username, password string) (bool, error) {
 	var auth Authentication
 	err := userCol.Find(bson.M{
@@ -251,12 +397,12 @@ func Authenticate(userCol *mgo.Collection, username, password string) (bool, err
 	}).One(&auth)
 	if err != nil {
 		if err == mgo.ErrNotFound {
-			return false, errors.NewNotFound("user", username)
+			return false, errors.ErrAuthentication
 		}
 		return false, err
 	}
 	if auth.Password != encryptPassword(auth.Salt, password) {
-		return false, nil
+		return false, errors.ErrAuthentication
 	}
 	return true, nil
 }
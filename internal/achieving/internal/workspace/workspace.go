@@ -0,0 +1,308 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package workspace contains the workspaces: groups of users sharing goals
+// apart from the other groups of the deployment
+package workspace
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/achieving/internal/goal"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Collection is the name of the collection of the workspaces
+const Collection = "workspaces"
+
+const (
+	// RoleAdmin lets the member change the workspace and its members
+	RoleAdmin = "ADMIN"
+	// RoleMember lets the member create goals in the workspace and see the
+	// goals its members share with the workspace
+	RoleMember = "MEMBER"
+)
+
+// Workspace is a group of users and the goals they create in it. The goals
+// of a workspace are only ever looked up along with its ID
+type Workspace struct {
+	ID          bson.ObjectId `bson:"_id,omitempty" json:"id,omitempty" valid:"optional,hexadecimal"`
+	Name        string        `bson:"name" json:"name" valid:"required,stringlength(1|100)"`
+	Description string        `bson:"description,omitempty" json:"description,omitempty" valid:"optional,stringlength(1|400)"`
+	Created     time.Time     `bson:"created" json:"created" valid:"-"`
+	Members     []Member      `bson:"members" json:"members,omitempty" valid:"-"`
+}
+
+// Member is a member of a workspace
+type Member struct {
+	Username string    `bson:"username" json:"username" valid:"-"`
+	Role     string    `bson:"role" json:"role" valid:"required,workspaceRole"`
+	Joined   time.Time `bson:"joined" json:"joined" valid:"-"`
+}
+
+// ValidateRole validates the role field
+func ValidateRole(value, _ interface{}) bool {
+	switch value := value.(type) {
+	case string:
+		switch value {
+		case RoleAdmin, RoleMember:
+			return true
+		default:
+			return false
+		}
+	default:
+		panic("the role field must be a string")
+	}
+}
+
+// Role returns the role of the user in the workspace, empty if the user is
+// not a member
+func (w *Workspace) Role(username string) string {
+	for _, m := range w.Members {
+		if m.Username == username {
+			return m.Role
+		}
+	}
+	return ""
+}
+
+// admins counts the admins of the workspace but the user
+func (w *Workspace) admins(but string) int {
+	n := 0
+	for _, m := range w.Members {
+		if m.Role == RoleAdmin && m.Username != but {
+			n++
+		}
+	}
+	return n
+}
+
+// EnsureIndexes creates the indexes of the workspace collection
+func EnsureIndexes(col *mgo.Collection) error {
+	index := mgo.Index{Key: []string{"members.username"}}
+	if err := col.EnsureIndex(index); err != nil {
+		return fmt.Errorf("ensure workspace index %v: %s", index.Key, err)
+	}
+	return nil
+}
+
+// notFound reports the workspace missing, or hidden from the user
+func notFound(id bson.ObjectId) error {
+	return errors.NewNotFound("workspace", id.Hex())
+}
+
+// Create creates the workspace, its creator is its first admin
+func Create(col *mgo.Collection, w *Workspace, creator string) (bson.ObjectId, error) {
+	now := time.Now()
+	w.ID, w.Created = bson.NewObjectId(), now
+	w.Members = []Member{{Username: creator, Role: RoleAdmin, Joined: now}}
+	if err := col.Insert(w); err != nil {
+		return w.ID, err
+	}
+	return w.ID, nil
+}
+
+// Retrieve gets the workspace on behalf of the user, the workspaces the user
+// is not a member of are not found
+func Retrieve(col *mgo.Collection, id bson.ObjectId, username string) (Workspace, error) {
+	var w Workspace
+	err := col.Find(bson.M{
+		"_id":              id,
+		"members.username": username,
+	}).One(&w)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return w, notFound(id)
+		}
+		return w, err
+	}
+	return w, nil
+}
+
+// IsMember returns whether the user is a member of the workspace
+func IsMember(col *mgo.Collection, id bson.ObjectId, username string) (bool, error) {
+	if len(id) == 0 || len(username) == 0 {
+		return false, nil
+	}
+	n, err := col.Find(bson.M{
+		"_id":              id,
+		"members.username": username,
+	}).Count()
+	if err != nil {
+		return false, err
+	}
+	return n != 0, nil
+}
+
+// List lists the workspaces of the user, the oldest first
+func List(col *mgo.Collection, username string, limit, offset int) ([]Workspace, error) {
+	q := col.Find(bson.M{"members.username": username}).Sort("created")
+	if limit > 0 {
+		q.Limit(limit)
+	}
+	if offset > 0 {
+		q.Skip(offset)
+	}
+	var ws []Workspace
+	if err := q.All(&ws); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
+// IDs lists the IDs of the workspaces of the user
+func IDs(col *mgo.Collection, username string) ([]bson.ObjectId, error) {
+	var ids []bson.ObjectId
+	if err := col.Find(bson.M{"members.username": username}).Distinct("_id", &ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Update renames the workspace and changes its description
+func Update(col *mgo.Collection, id bson.ObjectId, w *Workspace) error {
+	err := col.UpdateId(id, bson.M{
+		"$set": bson.M{
+			"name":        w.Name,
+			"description": w.Description,
+		},
+	})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return notFound(id)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete deletes the workspace, the workspaces still having goals are kept
+func Delete(col, goalCol *mgo.Collection, id bson.ObjectId) error {
+	n, err := goalCol.Find(bson.M{"workspace": id}).Count()
+	if err != nil {
+		return err
+	}
+	if n != 0 {
+		return errors.NewValidate(fmt.Sprintf("the workspace still has %d goals", n))
+	}
+	if err = col.RemoveId(id); err != nil {
+		if err == mgo.ErrNotFound {
+			return notFound(id)
+		}
+		return err
+	}
+	return nil
+}
+
+// errLastAdmin reports a change leaving the workspace without admins
+var errLastAdmin = errors.NewValidate("a workspace keeps at least one admin")
+
+// SetMember adds the user to the workspace with the role, or changes the
+// role of the member. The last admin cannot be demoted
+func SetMember(col *mgo.Collection, w *Workspace, username, role string) error {
+	switch current := w.Role(username); {
+	case len(current) == 0:
+		err := col.Update(bson.M{
+			"_id":              w.ID,
+			"members.username": bson.M{"$ne": username},
+		}, bson.M{
+			"$push": bson.M{"members": Member{Username: username, Role: role, Joined: time.Now()}},
+		})
+		if err == mgo.ErrNotFound {
+			// added meanwhile
+			return errors.NewDuplicated("workspace member", fmt.Sprintf("%s,%s", w.ID.Hex(), username))
+		}
+		return err
+	case current == RoleAdmin && role != RoleAdmin && w.admins(username) == 0:
+		return errLastAdmin
+	}
+	err := col.Update(bson.M{
+		"_id":              w.ID,
+		"members.username": username,
+	}, bson.M{
+		"$set": bson.M{"members.$.role": role},
+	})
+	if err == mgo.ErrNotFound {
+		// removed meanwhile
+		return errors.NewNotFound("workspace member", fmt.Sprintf("%s,%s", w.ID.Hex(), username))
+	}
+	return err
+}
+
+// RemoveMember removes the user from the workspace, the last admin cannot
+// leave. The goals of the user stay in the workspace
+func RemoveMember(col *mgo.Collection, w *Workspace, username string) error {
+	switch role := w.Role(username); {
+	case len(role) == 0:
+		return errors.NewNotFound("workspace member", fmt.Sprintf("%s,%s", w.ID.Hex(), username))
+	case role == RoleAdmin && w.admins(username) == 0:
+		return errLastAdmin
+	}
+	err := col.Update(bson.M{
+		"_id":     w.ID,
+		"members": bson.M{"$elemMatch": bson.M{"role": RoleAdmin, "username": bson.M{"$ne": username}}},
+	}, bson.M{
+		"$pull": bson.M{"members": bson.M{"username": username}},
+	})
+	if err == mgo.ErrNotFound {
+		return errLastAdmin
+	}
+	return err
+}
+
+// GoalIDs lists the IDs of the goals of the workspace
+func GoalIDs(goalCol *mgo.Collection, id bson.ObjectId) ([]bson.ObjectId, error) {
+	var ids []bson.ObjectId
+	if err := goalCol.Find(bson.M{"workspace": id}).Distinct("_id", &ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Goals lists the goals of the workspace the user can see, the latest
+// updated first: the goals shared with the workspace, the user's and the
+// ones the user is a member of. The query is always bound to the workspace
+func Goals(goalCol, achC *mgo.Collection, id bson.ObjectId, username string, shared []bson.ObjectId, limit, offset int) ([]goal.Goal, error) {
+	visible := []bson.M{
+		{"accessibility": goal.AccessWorkspace},
+		{"username": username},
+	}
+	if len(shared) != 0 {
+		visible = append(visible, bson.M{"_id": bson.M{"$in": shared}})
+	}
+	q := goalCol.Find(bson.M{
+		"workspace": id,
+		"$or":       visible,
+	}).Sort("-lastUpdated")
+	if limit > 0 {
+		q.Limit(limit)
+	}
+	if offset > 0 {
+		q.Skip(offset)
+	}
+	var gs []goal.Goal
+	if err := q.All(&gs); err != nil {
+		return nil, err
+	}
+	for i := range gs {
+		var err error
+		if gs[i].ToDo, err = gs[i].RetrieveAchievables(achC, 0, 0); err != nil {
+			return nil, err
+		}
+	}
+	return gs, nil
+}
@@ -25,6 +25,15 @@ import (
 
 	"github.com/iocat/donit/internal/achieving"
 	concr "github.com/iocat/donit/internal/achieving/internal/concreteachieving"
+	"github.com/iocat/donit/internal/achieving/internal/activity"
+	"github.com/iocat/donit/internal/achieving/internal/block"
+	"github.com/iocat/donit/internal/achieving/internal/comment"
+	"github.com/iocat/donit/internal/achieving/internal/follow"
+	"github.com/iocat/donit/internal/achieving/internal/member"
+	"github.com/iocat/donit/internal/achieving/internal/tag"
+	"github.com/iocat/donit/internal/achieving/internal/template"
+	"github.com/iocat/donit/internal/achieving/internal/workspace"
+	"github.com/iocat/donit/internal/events"
 	"gopkg.in/mgo.v2"
 )
 
@@ -53,11 +62,60 @@ type Interpreter interface {
 	Encode(io.Writer, interface{}) error
 }
 
+// Collections are the collections the store keeps its data in
+type Collections concr.Collections
+
+// NewCollections returns the collections of the store in the database, the
+// users, the goals and the achievables are the given ones
+func NewCollections(db *mgo.Database, users, goals, achievables *mgo.Collection) Collections {
+	return Collections{
+		Users:       users,
+		Goals:       goals,
+		Achievables: achievables,
+		Follows:     db.C(follow.Collection),
+		Tags:        db.C(tag.Collection),
+		Blocks:      db.C(block.Collection),
+		Comments:    db.C(comment.Collection),
+		Reactions:   db.C(comment.ReactionCollection),
+		Members:     db.C(member.Collection),
+		Workspaces:  db.C(workspace.Collection),
+		Templates:   db.C(template.Collection),
+		Activities:  db.C(activity.Collection),
+	}
+}
+
 // NewStore creates a new user store
 // TODO(iocat): move this function to another package
 // (not related to jsoninterpreter tho)
-func NewStore(user, goal, achievable *mgo.Collection) achieving.UserStore {
-	return concr.NewStore(user, goal, achievable)
+func NewStore(c Collections, pub events.Publisher) achieving.UserStore {
+	return concr.NewStore(concr.Collections(c), pub)
+}
+
+// EnsureIndexes creates the indexes the store relies on
+// TODO(iocat): move along with NewStore
+func EnsureIndexes(c Collections) error {
+	if err := follow.EnsureIndexes(c.Follows); err != nil {
+		return err
+	}
+	if err := tag.EnsureIndexes(c.Tags); err != nil {
+		return err
+	}
+	if err := block.EnsureIndexes(c.Blocks); err != nil {
+		return err
+	}
+	if err := comment.EnsureIndexes(c.Comments, c.Reactions); err != nil {
+		return err
+	}
+	if err := member.EnsureIndexes(c.Members); err != nil {
+		return err
+	}
+	if err := workspace.EnsureIndexes(c.Workspaces); err != nil {
+		return err
+	}
+	if err := template.EnsureIndexes(c.Templates); err != nil {
+		return err
+	}
+	return activity.EnsureIndexes(c.Activities)
 }
 
 // UserJSONInterpreter implements Interpreter
@@ -186,3 +244,173 @@ func (a AchievableJSONInterpreter) Encode(w io.Writer, ach interface{}) error {
 	}
 	return a.encode(w, casted)
 }
+
+// NewTag creates a new interpreter for tags
+func NewTag() Interpreter {
+	return TagJSONInterpreter{}
+}
+
+// TagJSONInterpreter represents a JSON decoder/encoder for tags
+type TagJSONInterpreter struct{}
+
+// Decode implements Interpreter's Decode
+func (TagJSONInterpreter) Decode(r io.Reader) (interface{}, error) {
+	var t = concr.Tag{}
+	if err := json.NewDecoder(r).Decode(&(t.Tag)); err != nil {
+		return nil, newErrInvalidJSONType(err.Error())
+	}
+	return &t, nil
+}
+
+// Encode implements Interpreter's Encode
+func (TagJSONInterpreter) Encode(w io.Writer, t interface{}) error {
+	casted, ok := t.(achieving.Tag)
+	if !ok {
+		return newErrInvalidJSONType(fmt.Sprintf("the provided type is not Tag, got %T", t))
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(casted)
+}
+
+// NewComment creates a new interpreter for comments
+func NewComment() Interpreter {
+	return CommentJSONInterpreter{}
+}
+
+// CommentJSONInterpreter represents a JSON decoder/encoder for comments
+type CommentJSONInterpreter struct{}
+
+// Decode implements Interpreter's Decode
+func (CommentJSONInterpreter) Decode(r io.Reader) (interface{}, error) {
+	var c = concr.Comment{}
+	if err := json.NewDecoder(r).Decode(&(c.Comment)); err != nil {
+		return nil, newErrInvalidJSONType(err.Error())
+	}
+	return &c, nil
+}
+
+// Encode implements Interpreter's Encode
+func (CommentJSONInterpreter) Encode(w io.Writer, c interface{}) error {
+	casted, ok := c.(achieving.Comment)
+	if !ok {
+		return newErrInvalidJSONType(fmt.Sprintf("the provided type is not Comment, got %T", c))
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(casted)
+}
+
+// NewMember creates a new interpreter for the members of the shared goals
+func NewMember() Interpreter {
+	return MemberJSONInterpreter{}
+}
+
+// MemberJSONInterpreter represents a JSON decoder/encoder for members
+type MemberJSONInterpreter struct{}
+
+// Decode implements Interpreter's Decode
+func (MemberJSONInterpreter) Decode(r io.Reader) (interface{}, error) {
+	var m = concr.Member{}
+	if err := json.NewDecoder(r).Decode(&(m.Member)); err != nil {
+		return nil, newErrInvalidJSONType(err.Error())
+	}
+	return &m, nil
+}
+
+// Encode implements Interpreter's Encode
+func (MemberJSONInterpreter) Encode(w io.Writer, m interface{}) error {
+	casted, ok := m.(achieving.Member)
+	if !ok {
+		return newErrInvalidJSONType(fmt.Sprintf("the provided type is not Member, got %T", m))
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(casted)
+}
+
+// NewWorkspace creates a new interpreter for the workspaces
+func NewWorkspace() Interpreter {
+	return WorkspaceJSONInterpreter{}
+}
+
+// WorkspaceJSONInterpreter represents a JSON decoder/encoder for workspaces
+type WorkspaceJSONInterpreter struct{}
+
+// Decode implements Interpreter's Decode
+func (WorkspaceJSONInterpreter) Decode(r io.Reader) (interface{}, error) {
+	var w = concr.Workspace{}
+	if err := json.NewDecoder(r).Decode(&(w.Workspace)); err != nil {
+		return nil, newErrInvalidJSONType(err.Error())
+	}
+	return &w, nil
+}
+
+// Encode implements Interpreter's Encode
+func (WorkspaceJSONInterpreter) Encode(w io.Writer, ws interface{}) error {
+	casted, ok := ws.(achieving.Workspace)
+	if !ok {
+		return newErrInvalidJSONType(fmt.Sprintf("the provided type is not Workspace, got %T", ws))
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(casted)
+}
+
+// NewWorkspaceMember creates a new interpreter for the members of the
+// workspaces
+func NewWorkspaceMember() Interpreter {
+	return WorkspaceMemberJSONInterpreter{}
+}
+
+// WorkspaceMemberJSONInterpreter represents a JSON decoder/encoder for the
+// members of the workspaces
+type WorkspaceMemberJSONInterpreter struct{}
+
+// Decode implements Interpreter's Decode
+func (WorkspaceMemberJSONInterpreter) Decode(r io.Reader) (interface{}, error) {
+	var m = concr.WorkspaceMember{}
+	if err := json.NewDecoder(r).Decode(&(m.Member)); err != nil {
+		return nil, newErrInvalidJSONType(err.Error())
+	}
+	return &m, nil
+}
+
+// Encode implements Interpreter's Encode
+func (WorkspaceMemberJSONInterpreter) Encode(w io.Writer, m interface{}) error {
+	casted, ok := m.(achieving.WorkspaceMember)
+	if !ok {
+		return newErrInvalidJSONType(fmt.Sprintf("the provided type is not WorkspaceMember, got %T", m))
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(casted)
+}
+
+// NewTemplate creates a new interpreter for the goal templates
+func NewTemplate() Interpreter {
+	return TemplateJSONInterpreter{}
+}
+
+// TemplateJSONInterpreter represents a JSON decoder/encoder for templates
+type TemplateJSONInterpreter struct{}
+
+// Decode implements Interpreter's Decode
+func (TemplateJSONInterpreter) Decode(r io.Reader) (interface{}, error) {
+	var t = concr.Template{}
+	if err := json.NewDecoder(r).Decode(&(t.Template)); err != nil {
+		return nil, newErrInvalidJSONType(err.Error())
+	}
+	return &t, nil
+}
+
+// Encode implements Interpreter's Encode
+func (TemplateJSONInterpreter) Encode(w io.Writer, t interface{}) error {
+	casted, ok := t.(achieving.Template)
+	if !ok {
+		return newErrInvalidJSONType(fmt.Sprintf("the provided type is not Template, got %T", t))
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(casted)
+}
@@ -0,0 +1,281 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notifier turns the events of the store into notifications in the
+// inboxes of the users concerned
+package notifier
+
+import (
+	"fmt"
+
+	"github.com/iocat/donit/internal/achieving/internal/follow"
+	"github.com/iocat/donit/internal/achieving/internal/goal"
+	"github.com/iocat/donit/internal/achieving/internal/workspace"
+	"github.com/iocat/donit/internal/events"
+	"github.com/iocat/donit/internal/logger"
+	"github.com/iocat/donit/internal/notify"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// KindFollower is the kind of the notifications of a new follower
+	KindFollower = "follower"
+	// KindGoalCompleted is the kind of the notifications of a followed user
+	// completing a goal
+	KindGoalCompleted = "goal_completed"
+	// KindComment is the kind of the notifications of a comment on a goal
+	// or on one of its achievables
+	KindComment = "comment"
+	// KindReply is the kind of the notifications of a reply to a comment
+	KindReply = "reply"
+	// KindReaction is the kind of the notifications of a reaction on a goal
+	// or on one of its achievables
+	KindReaction = "reaction"
+	// KindInvite is the kind of the notifications of an invite to a goal
+	KindInvite = "invite"
+	// KindJoined is the kind of the notifications of a user accepting an
+	// invite to a goal
+	KindJoined = "joined"
+)
+
+// backlog is the number of events waiting to be handled, the events
+// published while it is full are dropped
+const backlog = 256
+
+// followersBatch is the number of followers notified per query
+const followersBatch = 100
+
+// Notifier notifies the users of the events concerning them
+type Notifier struct {
+	inbox      *notify.Inbox
+	goals      *mgo.Collection
+	follows    *mgo.Collection
+	workspaces *mgo.Collection
+	log        *logger.Logger
+
+	events chan events.Event
+}
+
+// New creates a notifier delivering to the inbox, the goals, the follow
+// relationships and the workspaces are looked up in their collection
+func New(inbox *notify.Inbox, goals, follows, workspaces *mgo.Collection, log *logger.Logger) *Notifier {
+	return &Notifier{
+		inbox:      inbox,
+		goals:      goals,
+		follows:    follows,
+		workspaces: workspaces,
+		log:        log,
+		events:     make(chan events.Event, backlog),
+	}
+}
+
+// Handle queues the event to be handled by Run, it never blocks so that it
+// can subscribe to an events.Bus
+func (n *Notifier) Handle(e events.Event) {
+	select {
+	case n.events <- e:
+	default:
+		n.log.Error("notifier backlog is full, event dropped", "kind", string(e.Kind), "user", e.Username)
+	}
+}
+
+// Run handles the queued events until stop is closed
+func (n *Notifier) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case e := <-n.events:
+			if err := n.handle(e); err != nil {
+				n.log.Error("notify event", "kind", string(e.Kind), "user", e.Username, "err", err)
+			}
+		}
+	}
+}
+
+func (n *Notifier) handle(e events.Event) error {
+	switch e.Kind {
+	case events.Followed:
+		return n.deliver(e.Target, notify.Notification{
+			Kind:    KindFollower,
+			Title:   fmt.Sprintf("%s started following you", e.Username),
+			Subject: e.Username,
+			Data:    map[string]string{"user": e.Username},
+			At:      e.At,
+		})
+	case events.Commented:
+		return n.commented(e)
+	case events.Reacted:
+		if e.Username == e.Owner {
+			return nil
+		}
+		return n.deliver(e.Owner, notify.Notification{
+			Kind:    KindReaction,
+			Title:   fmt.Sprintf("%s reacted on %s", e.Username, about(e)),
+			Subject: e.Goal,
+			Data:    data(e),
+			At:      e.At,
+		})
+	case events.Invited:
+		return n.deliver(e.Target, notify.Notification{
+			Kind:    KindInvite,
+			Title:   fmt.Sprintf("%s invited you to the goal %q", e.Username, e.Name),
+			Subject: e.Goal,
+			Data:    map[string]string{"user": e.Username, "owner": e.Owner, "goal": e.Goal},
+			At:      e.At,
+		})
+	case events.Joined:
+		return n.joined(e)
+	case events.GoalCompleted:
+		return n.goalCompleted(e)
+	}
+	return nil
+}
+
+// about describes the goal or the achievable the event is about to its owner
+func about(e events.Event) string {
+	if len(e.Achievable) != 0 {
+		return fmt.Sprintf("your task %q", e.Name)
+	}
+	return fmt.Sprintf("your goal %q", e.Name)
+}
+
+// data is the data of the notifications of the comments and the reactions
+func data(e events.Event) map[string]string {
+	d := map[string]string{"user": e.Username, "goal": e.Goal}
+	if len(e.Achievable) != 0 {
+		d["achievable"] = e.Achievable
+	}
+	return d
+}
+
+// commented notifies the owner of the goal and the author of the comment
+// replied to, unless they commented themselves
+func (n *Notifier) commented(e events.Event) error {
+	if e.Username != e.Owner {
+		err := n.deliver(e.Owner, notify.Notification{
+			Kind:    KindComment,
+			Title:   fmt.Sprintf("%s commented on %s", e.Username, about(e)),
+			Subject: e.Goal,
+			Data:    data(e),
+			At:      e.At,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(e.Target) == 0 || e.Target == e.Username || e.Target == e.Owner {
+		return nil
+	}
+	return n.deliver(e.Target, notify.Notification{
+		Kind:    KindReply,
+		Title:   fmt.Sprintf("%s replied to your comment on %q", e.Username, e.Name),
+		Subject: e.Goal,
+		Data:    data(e),
+		At:      e.At,
+	})
+}
+
+// joined notifies the owner of the goal and the user who sent the invite
+func (n *Notifier) joined(e events.Event) error {
+	notification := notify.Notification{
+		Kind:    KindJoined,
+		Title:   fmt.Sprintf("%s joined the goal %q", e.Username, e.Name),
+		Subject: e.Goal,
+		Data:    map[string]string{"user": e.Username, "owner": e.Owner, "goal": e.Goal},
+		At:      e.At,
+	}
+	if err := n.deliver(e.Owner, notification); err != nil {
+		return err
+	}
+	if len(e.Target) == 0 || e.Target == e.Owner || e.Target == e.Username {
+		return nil
+	}
+	return n.deliver(e.Target, notification)
+}
+
+// goalCompleted notifies the followers of the owner of a goal they can see,
+// or the other members of the workspace of a workspace goal
+func (n *Notifier) goalCompleted(e events.Event) error {
+	if !bson.IsObjectIdHex(e.Goal) {
+		return fmt.Errorf("invalid goal id %q", e.Goal)
+	}
+	var g goal.Goal
+	if err := n.goals.FindId(bson.ObjectIdHex(e.Goal)).One(&g); err != nil {
+		if err == mgo.ErrNotFound {
+			// deleted since
+			return nil
+		}
+		return err
+	}
+	notification := notify.Notification{
+		Kind:    KindGoalCompleted,
+		Title:   fmt.Sprintf("%s completed the goal %q", e.Owner, g.Name),
+		Subject: e.Goal,
+		Data:    map[string]string{"user": e.Owner, "goal": e.Goal},
+		At:      e.At,
+	}
+	// the users notified are the ones who can see the goal, the way
+	// Goal.VisibleTo of the store tells
+	switch g.Accessibility {
+	case goal.AccessPublic, goal.AccessForFollowers:
+	case goal.AccessWorkspace:
+		return n.notifyWorkspace(g.Workspace, e.Owner, notification)
+	default:
+		return nil
+	}
+	for offset := 0; ; offset += followersBatch {
+		fs, err := follow.Followers(n.follows, e.Owner, followersBatch, offset)
+		if err != nil {
+			return err
+		}
+		for _, f := range fs {
+			if err := n.deliver(f.Follower, notification); err != nil {
+				return err
+			}
+		}
+		if len(fs) < followersBatch {
+			return nil
+		}
+	}
+}
+
+// notifyWorkspace notifies the members of the workspace but the user
+func (n *Notifier) notifyWorkspace(id bson.ObjectId, but string, notification notify.Notification) error {
+	if len(id) == 0 {
+		return nil
+	}
+	var w workspace.Workspace
+	if err := n.workspaces.FindId(id).One(&w); err != nil {
+		if err == mgo.ErrNotFound {
+			// deleted since
+			return nil
+		}
+		return err
+	}
+	for _, m := range w.Members {
+		if m.Username == but {
+			continue
+		}
+		if err := n.deliver(m.Username, notification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *Notifier) deliver(username string, notification notify.Notification) error {
+	return n.inbox.Deliver(notify.Recipient{Username: username}, notification)
+}
@@ -0,0 +1,260 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package reminders notifies the users of the reminders of their tasks and
+// habits when they come due
+package reminders
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/errors"
+	"github.com/iocat/donit/internal/achieving/internal/achievable"
+	"github.com/iocat/donit/internal/achieving/internal/goal"
+	"github.com/iocat/donit/internal/achieving/internal/user"
+	"github.com/iocat/donit/internal/logger"
+	"github.com/iocat/donit/internal/notify"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Kind is the kind of the reminder notifications
+const Kind = "reminder"
+
+// recheck is how long the scheduler waits before looking again at a habit
+// which has no reminder coming or no owner to remind
+const recheck = 24 * time.Hour
+
+// Scheduler periodically enqueues the due reminders in the notification
+// queue and processes the queue
+type Scheduler struct {
+	users       *mgo.Collection
+	goals       *mgo.Collection
+	achievables *mgo.Collection
+	queue       *notify.Queue
+
+	interval time.Duration
+	lookback time.Duration
+	log      *logger.Logger
+
+	// last is the time up to which the reminders have been enqueued
+	last time.Time
+}
+
+// New creates a scheduler checking the reminders every interval. The
+// reminders which came due more than lookback ago, while the scheduler was
+// not running, are skipped
+func New(users, goals, achievables *mgo.Collection, queue *notify.Queue,
+	interval, lookback time.Duration, log *logger.Logger) (*Scheduler, error) {
+	err := achievables.EnsureIndex(mgo.Index{Key: []string{"nextReminder"}, Sparse: true})
+	if err != nil {
+		return nil, fmt.Errorf("index the next reminders: %s", err)
+	}
+	// the habits stored before the next reminders were are due right away
+	_, err = achievables.UpdateAll(bson.M{
+		"repreatedReminder": bson.M{"$exists": true},
+		"nextReminder":      bson.M{"$exists": false},
+	}, bson.M{"$set": bson.M{"nextReminder": achievable.Unscheduled}})
+	if err != nil {
+		return nil, fmt.Errorf("schedule the habits: %s", err)
+	}
+	return &Scheduler{
+		users:       users,
+		goals:       goals,
+		achievables: achievables,
+		queue:       queue,
+		interval:    interval,
+		lookback:    lookback,
+		log:         log,
+	}, nil
+}
+
+// Run runs the scheduler until stop is closed
+func (s *Scheduler) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.Tick(time.Now())
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick enqueues the reminders which came due since the previous tick and
+// attempts the pending deliveries
+func (s *Scheduler) Tick(now time.Time) {
+	from := s.last
+	if from.IsZero() || now.Sub(from) > s.lookback {
+		from = now.Add(-s.lookback)
+	}
+	if err := s.enqueue(from, now); err != nil {
+		// keep the window open so that the next tick retries it
+		s.log.Error("enqueue reminders", "err", err)
+	} else {
+		s.last = now
+	}
+	n, err := s.queue.Process(now)
+	if err != nil {
+		s.log.Error("process deliveries", "err", err)
+	}
+	if n > 0 {
+		s.log.Debug("attempted deliveries", "count", n)
+	}
+}
+
+// owner is the recipient of the reminders of a goal
+type owner struct {
+	recipient notify.Recipient
+	channels  []string
+	// location is the owner's time zone
+	location *time.Location
+}
+
+// enqueue enqueues every reminder in (from, to]
+func (s *Scheduler) enqueue(from, to time.Time) error {
+	owners := make(map[bson.ObjectId]owner)
+
+	var tasks []achievable.Achievable
+	err := s.achievables.Find(bson.M{
+		"reminder.remindAt": bson.M{"$gt": from, "$lte": to},
+	}).All(&tasks)
+	if err != nil {
+		return fmt.Errorf("find the due tasks: %s", err)
+	}
+	for _, a := range tasks {
+		if a.HasAchieved() {
+			continue
+		}
+		if err := s.remind(owners, a, a.Reminder.At); err != nil {
+			return err
+		}
+	}
+
+	var habits []achievable.Achievable
+	err = s.achievables.Find(bson.M{
+		"nextReminder": bson.M{"$lte": to},
+	}).All(&habits)
+	if err != nil {
+		return fmt.Errorf("find the due habits: %s", err)
+	}
+	for _, a := range habits {
+		if a.RepeatReminder == nil {
+			continue
+		}
+		o, err := s.cachedOwner(owners, a.Goal)
+		if err != nil {
+			return err
+		}
+		next := to.Add(recheck)
+		if o.location != nil {
+			for _, at := range a.RepeatReminder.Occurrences(from, to, o.location) {
+				if err := s.remind(owners, a, at); err != nil {
+					return err
+				}
+			}
+			if t, ok := a.Next(to, o.location); ok {
+				next = t
+			}
+		}
+		if err := s.reschedule(a, next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reschedule sets the next reminder of the habit unless it was changed,
+// and so unscheduled, since it was found due
+func (s *Scheduler) reschedule(a achievable.Achievable, next time.Time) error {
+	err := s.achievables.Update(bson.M{
+		"_id":          a.ID,
+		"nextReminder": a.NextReminder,
+	}, bson.M{"$set": bson.M{"nextReminder": next}})
+	if err != nil && err != mgo.ErrNotFound {
+		return fmt.Errorf("reschedule the habit %s: %s", a.ID.Hex(), err)
+	}
+	return nil
+}
+
+// cachedOwner looks up the owner of the goal once per enqueue
+func (s *Scheduler) cachedOwner(owners map[bson.ObjectId]owner, id bson.ObjectId) (owner, error) {
+	o, ok := owners[id]
+	if !ok {
+		var err error
+		if o, err = s.owner(id); err != nil {
+			return owner{}, err
+		}
+		owners[id] = o
+	}
+	return o, nil
+}
+
+// remind enqueues the reminder of the achievable due at the time
+func (s *Scheduler) remind(owners map[bson.ObjectId]owner, a achievable.Achievable, at time.Time) error {
+	o, err := s.cachedOwner(owners, a.Goal)
+	if err != nil {
+		return err
+	}
+	if len(o.channels) == 0 {
+		return nil
+	}
+	key := fmt.Sprintf("%s:%s:%d", Kind, a.ID.Hex(), at.Unix())
+	return s.queue.Enqueue(key, o.recipient, o.channels, notify.Notification{
+		Kind:    Kind,
+		Title:   fmt.Sprintf("Reminder: %s", a.Name),
+		Body:    a.Description,
+		Subject: a.ID.Hex(),
+		Data: map[string]string{
+			"goal":       a.Goal.Hex(),
+			"achievable": a.ID.Hex(),
+			"localTime":  at.In(o.location).Format(time.RFC3339),
+		},
+		At: at,
+	})
+}
+
+// owner looks up the owner of the goal and its notification preferences.
+// The tasks left behind by a deleted goal or user have no owner to notify
+func (s *Scheduler) owner(id bson.ObjectId) (owner, error) {
+	var g goal.Goal
+	if err := s.goals.FindId(id).One(&g); err != nil {
+		if err == mgo.ErrNotFound {
+			return owner{}, nil
+		}
+		return owner{}, fmt.Errorf("find goal %s: %s", id.Hex(), err)
+	}
+	var u user.User
+	if err := u.Retrieve(s.users, g.Username); err != nil {
+		if errors.IsNotFound(err) {
+			return owner{}, nil
+		}
+		return owner{}, fmt.Errorf("find the owner of goal %s: %s", id.Hex(), err)
+	}
+	o := owner{
+		recipient: notify.Recipient{
+			Username: u.Username,
+			Email:    u.Email,
+		},
+		channels: u.NotificationChannels(),
+		location: u.Location(),
+	}
+	if u.Notifications != nil {
+		o.recipient.WebhookURL = u.Notifications.WebhookURL
+	}
+	return o, nil
+}
@@ -19,11 +19,15 @@ package validator
 import (
 	"fmt"
 	"io"
+	"reflect"
+	"strings"
 
 	"github.com/iocat/donit/internal/achieving/errors"
 	"github.com/iocat/donit/internal/achieving/internal/achievable"
 	"github.com/iocat/donit/internal/achieving/internal/goal"
+	"github.com/iocat/donit/internal/achieving/internal/member"
 	"github.com/iocat/donit/internal/achieving/internal/user"
+	"github.com/iocat/donit/internal/achieving/internal/workspace"
 	json "github.com/iocat/donit/internal/achieving/jsoninterpreter"
 	valid "gopkg.in/asaskevich/govalidator.v4"
 )
@@ -39,6 +43,22 @@ func init() {
 		valid.CustomTypeValidator(achievable.ValidateCycle))
 	valid.CustomTypeTagMap.Set("daysInWeekOrMonth",
 		valid.CustomTypeValidator(achievable.ValidateDaysInWeekOrMonth))
+	valid.CustomTypeTagMap.Set("notificationChannels",
+		valid.CustomTypeValidator(user.ValidateNotificationChannels))
+	valid.CustomTypeTagMap.Set("webhookURL",
+		valid.CustomTypeValidator(user.ValidateWebhookURL))
+	valid.CustomTypeTagMap.Set("timezone",
+		valid.CustomTypeValidator(user.ValidateTimezone))
+	valid.CustomTypeTagMap.Set("rrule",
+		valid.CustomTypeValidator(achievable.ValidateRule))
+	valid.CustomTypeTagMap.Set("date",
+		valid.CustomTypeValidator(achievable.ValidateDate))
+	valid.CustomTypeTagMap.Set("dates",
+		valid.CustomTypeValidator(achievable.ValidateDates))
+	valid.CustomTypeTagMap.Set("memberRole",
+		valid.CustomTypeValidator(member.ValidateRole))
+	valid.CustomTypeTagMap.Set("workspaceRole",
+		valid.CustomTypeValidator(workspace.ValidateRole))
 }
 
 // Validate decodes the json body and returns an object corresponding to the json
@@ -53,32 +73,106 @@ func Validate(r io.Reader, interpreter json.Interpreter) (interface{}, error) {
 		return nil, err
 	}
 	// Run the validator
-	err = validate(obj)
+	fields, err := Fields(obj)
 	if err != nil {
-		return nil, errors.NewValidate(err.Error())
+		return nil, err
+	}
+	if len(fields) > 0 {
+		return nil, errors.NewValidateFields(fields)
 	}
 	return obj, nil
 }
 
-// Validate dispatches the validator on the object. Returns any error reported from the validator
-func validate(obj interface{}) error {
-	if ok, err := valid.ValidateStruct(obj); !ok {
-		var got error
-	loop:
-		for {
-			switch e := err.(type) {
-			case valid.Error:
-				got = e
-				break loop
-			case valid.Errors:
-				err = e.Errors()[0]
-			default:
-				panic(fmt.Errorf("unexpected type %T", e))
-			}
+// Fields validates the object and returns its invalid fields
+func Fields(obj interface{}) ([]errors.Field, error) {
+	fields, err := validate(obj)
+	if err != nil {
+		return nil, errors.NewValidate(err.Error())
+	}
+	return fields, nil
+}
+
+// Validate dispatches the validator on the object. Returns every invalid
+// field reported from the validator
+func validate(obj interface{}) ([]errors.Field, error) {
+	ok, err := valid.ValidateStruct(obj)
+	if ok {
+		if err != nil {
+			return nil, fmt.Errorf("validate %T error: %s", obj, err)
+		}
+		return nil, nil
+	}
+	names := make(map[string]string)
+	jsonNames(reflect.TypeOf(obj), names, make(map[reflect.Type]bool))
+	var fields []errors.Field
+	collect(err, names, &fields)
+	return fields, nil
+}
+
+// collect flattens the (possibly nested) validator errors into fields
+func collect(err error, names map[string]string, fields *[]errors.Field) {
+	switch e := err.(type) {
+	case valid.Errors:
+		for _, e := range e.Errors() {
+			collect(e, names, fields)
+		}
+	case valid.Error:
+		name, ok := names[e.Name]
+		if !ok {
+			name = e.Name
+		}
+		*fields = append(*fields, errors.Field{
+			Name:    name,
+			Rule:    rule(e.Err),
+			Message: fmt.Sprintf("%s: %s", name, e.Err),
+		})
+	default:
+		panic(fmt.Errorf("unexpected type %T", e))
+	}
+}
+
+// rule extracts the name of the broken rule from the validator message
+func rule(err error) string {
+	const doesNotValidate = " does not validate as "
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "non zero value required"):
+		return "required"
+	case strings.Contains(msg, doesNotValidate):
+		r := msg[strings.LastIndex(msg, doesNotValidate)+len(doesNotValidate):]
+		if i := strings.Index(r, "("); i >= 0 {
+			r = r[:i]
+		}
+		return strings.TrimSpace(r)
+	default:
+		return "invalid"
+	}
+}
+
+// jsonNames maps the Go field names of the struct type to their JSON names,
+// embedded structs included, so that errors name the fields as the API
+// user knows them
+func jsonNames(t reflect.Type, names map[string]string, seen map[reflect.Type]bool) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")[0]
+		if f.Anonymous && len(tag) == 0 {
+			jsonNames(f.Type, names, seen)
+			continue
+		}
+		if len(tag) == 0 || tag == "-" {
+			tag = f.Name
+		}
+		if _, ok := names[f.Name]; !ok {
+			names[f.Name] = tag
 		}
-		return got
-	} else if err != nil {
-		return fmt.Errorf("validate %T error: %s", obj, err)
+		jsonNames(f.Type, names, seen)
 	}
-	return nil
 }
@@ -0,0 +1,119 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package auth carries the authenticated caller of a request and throttles
+// the failed authentication attempts
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Realm is the protection space announced in the WWW-Authenticate header
+const Realm = "donit"
+
+// Challenge is the WWW-Authenticate header value of the API
+const Challenge = `Basic realm="` + Realm + `"`
+
+type contextKey struct{}
+
+// NewContext returns a copy of the context carrying the authenticated
+// caller's username
+func NewContext(ctx context.Context, username string) context.Context {
+	return context.WithValue(ctx, contextKey{}, username)
+}
+
+// Caller returns the username of the authenticated caller, ok is false for
+// anonymous requests
+func Caller(ctx context.Context) (username string, ok bool) {
+	username, ok = ctx.Value(contextKey{}).(string)
+	return username, ok
+}
+
+// pruneThreshold is the number of tracked keys above which the expired ones
+// are dropped
+const pruneThreshold = 1024
+
+// Limiter allows a maximum number of failed attempts per key in a fixed time
+// window. A Limiter is safe for concurrent use
+type Limiter struct {
+	max    int
+	window time.Duration
+
+	mu       sync.Mutex
+	attempts map[string]*attempts
+}
+
+type attempts struct {
+	count int
+	since time.Time
+}
+
+// NewLimiter creates a limiter allowing max failures per key in the window
+func NewLimiter(max int, window time.Duration) *Limiter {
+	return &Limiter{
+		max:      max,
+		window:   window,
+		attempts: make(map[string]*attempts),
+	}
+}
+
+// Allow returns whether another attempt is allowed for the key, if not, it
+// returns how long the caller should wait before retrying
+func (l *Limiter) Allow(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	a, ok := l.attempts[key]
+	if !ok {
+		return 0, true
+	}
+	elapsed := time.Since(a.since)
+	if elapsed >= l.window {
+		delete(l.attempts, key)
+		return 0, true
+	}
+	if a.count < l.max {
+		return 0, true
+	}
+	return l.window - elapsed, false
+}
+
+// Fail records a failed attempt for the key
+func (l *Limiter) Fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if len(l.attempts) > pruneThreshold {
+		for k, a := range l.attempts {
+			if now.Sub(a.since) >= l.window {
+				delete(l.attempts, k)
+			}
+		}
+	}
+	a, ok := l.attempts[key]
+	if !ok || now.Sub(a.since) >= l.window {
+		l.attempts[key] = &attempts{count: 1, since: now}
+		return
+	}
+	a.count++
+}
+
+// Reset forgets the failed attempts of the key
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.attempts, key)
+}
@@ -0,0 +1,121 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package events carries the events produced by the mutations of the
+// achieving store to the parts of the server reacting to them
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Kind is the kind of an event
+type Kind string
+
+const (
+	// GoalCreated is published when a user creates a goal
+	GoalCreated Kind = "goal.created"
+	// GoalUpdated is published when a user updates a goal
+	GoalUpdated Kind = "goal.updated"
+	// GoalCompleted is published when the status of a goal becomes DONE
+	GoalCompleted Kind = "goal.completed"
+	// GoalDeleted is published when a user deletes a goal
+	GoalDeleted Kind = "goal.deleted"
+	// AchievableCreated is published when a task is added to a goal
+	AchievableCreated Kind = "achievable.created"
+	// AchievableUpdated is published when a task is updated
+	AchievableUpdated Kind = "achievable.updated"
+	// AchievableCompleted is published when the status of a task becomes
+	// DONE
+	AchievableCompleted Kind = "achievable.completed"
+	// AchievableDeleted is published when a task is removed from its goal
+	AchievableDeleted Kind = "achievable.deleted"
+	// Followed is published when a user follows another one
+	Followed Kind = "user.followed"
+	// Commented is published when a user comments on a goal or on one of
+	// its achievables, the target is the author of the comment replied to
+	Commented Kind = "goal.commented"
+	// Reacted is published when a user reacts on a goal or on one of its
+	// achievables
+	Reacted Kind = "goal.reacted"
+	// Invited is published when a user invites another one to a goal, the
+	// target is the invited user
+	Invited Kind = "goal.invited"
+	// Joined is published when a user accepts an invite to a goal, the
+	// target is the user who invited them
+	Joined Kind = "goal.joined"
+)
+
+// Event is something which happened in the store
+type Event struct {
+	Kind Kind
+	// Username is the user who caused the event
+	Username string
+	// Owner owns the goal the event is about, if any
+	Owner      string
+	Goal       string
+	Achievable string
+	// Target is the user the event is aimed at, for example the followed
+	// user
+	Target string
+	// Name is the name of the goal or the task, so that the subscribers
+	// can describe the event without looking it up
+	Name string
+	At   time.Time
+}
+
+// Publisher publishes the events
+type Publisher interface {
+	Publish(Event)
+}
+
+// Publish publishes the event through p, a nil publisher discards it
+func Publish(p Publisher, e Event) {
+	if p == nil {
+		return
+	}
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	p.Publish(e)
+}
+
+// Bus hands every published event to all its subscribers. The subscribers
+// are called synchronously by the publisher, so they must not block
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []func(Event)
+}
+
+// NewBus creates a bus without subscribers
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe adds a subscriber to the bus
+func (b *Bus) Subscribe(f func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, f)
+}
+
+// Publish implements Publisher
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, f := range b.subscribers {
+		f(e)
+	}
+}
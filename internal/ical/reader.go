@@ -0,0 +1,267 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Property is a content line of a component
+type Property struct {
+	Name string
+	// Params are the parameters of the property by their upper-cased name,
+	// without the quotes
+	Params map[string]string
+	Value  string
+}
+
+// Component is a BEGIN/END block and its properties
+type Component struct {
+	Name string
+	// Line is the line the component begins on
+	Line       int
+	Props      []Property
+	Components []*Component
+}
+
+// Get returns the first property named name
+func (c *Component) Get(name string) (Property, bool) {
+	for _, p := range c.Props {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Property{}, false
+}
+
+// All returns every property named name
+func (c *Component) All(name string) []Property {
+	var ps []Property
+	for _, p := range c.Props {
+		if p.Name == name {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+// Parse parses an iCalendar document and returns its top level component,
+// usually a VCALENDAR
+func Parse(r io.Reader) (*Component, error) {
+	var (
+		stack []*Component
+		root  *Component
+	)
+	err := lines(r, func(n int, line string) error {
+		p, err := parseLine(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %s", n, err)
+		}
+		switch p.Name {
+		case "BEGIN":
+			c := &Component{Name: strings.ToUpper(p.Value), Line: n}
+			if len(stack) != 0 {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, c)
+			} else if root != nil {
+				return fmt.Errorf("line %d: a second top level component", n)
+			} else {
+				root = c
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(p.Value) {
+				return fmt.Errorf("line %d: unexpected END:%s", n, p.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return fmt.Errorf("line %d: %s out of any component", n, p.Name)
+			}
+			c := stack[len(stack)-1]
+			c.Props = append(c.Props, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, fmt.Errorf("no component")
+	}
+	if len(stack) != 0 {
+		return nil, fmt.Errorf("%s is not ended", stack[len(stack)-1].Name)
+	}
+	return root, nil
+}
+
+// lines calls f with the unfolded content lines and the number of the line
+// they begin on
+func lines(r io.Reader, f func(int, string) error) error {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var (
+		line  string
+		start int
+	)
+	for n := 1; s.Scan(); n++ {
+		l := strings.TrimRight(s.Text(), "\r")
+		if len(l) != 0 && (l[0] == ' ' || l[0] == '\t') {
+			line += l[1:]
+			continue
+		}
+		if len(line) != 0 {
+			if err := f(start, line); err != nil {
+				return err
+			}
+		}
+		line, start = l, n
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	if len(line) != 0 {
+		return f(start, line)
+	}
+	return nil
+}
+
+// parseLine parses name *(";" param) ":" value
+func parseLine(line string) (Property, error) {
+	p := Property{Params: make(map[string]string)}
+	quoted := false
+	colon := -1
+	for i := 0; i < len(line) && colon < 0; i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				colon = i
+			}
+		}
+	}
+	if colon < 0 {
+		return p, fmt.Errorf("missing the colon")
+	}
+	p.Value = line[colon+1:]
+	parts := splitUnquoted(line[:colon], ';')
+	p.Name = strings.ToUpper(parts[0])
+	if len(p.Name) == 0 {
+		return p, fmt.Errorf("missing the property name")
+	}
+	for _, param := range parts[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			return p, fmt.Errorf("invalid parameter %q", param)
+		}
+		p.Params[strings.ToUpper(kv[0])] = strings.Replace(kv[1], `"`, "", -1)
+	}
+	return p, nil
+}
+
+// splitUnquoted splits s around sep out of the quoted strings
+func splitUnquoted(s string, sep byte) []string {
+	var parts []string
+	quoted, last := false, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				parts = append(parts, s[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(parts, s[last:])
+}
+
+var textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+// UnescapeText unescapes a TEXT value
+func UnescapeText(s string) string {
+	return textUnescaper.Replace(s)
+}
+
+// Times parses the DATE or DATE-TIME values of the property. The floating
+// times and the dates are in loc. allDay tells whether they are dates
+func (p Property) Times(loc *time.Location) (ts []time.Time, allDay bool, err error) {
+	if tzid, ok := p.Params["TZID"]; ok {
+		if loc, err = time.LoadLocation(tzid); err != nil {
+			return nil, false, fmt.Errorf("unknown time zone %q", tzid)
+		}
+	}
+	allDay = p.Params["VALUE"] == "DATE"
+	for _, v := range strings.Split(p.Value, ",") {
+		var t time.Time
+		switch {
+		case allDay || len(v) == len(dateLayout):
+			allDay = true
+			t, err = time.ParseInLocation(dateLayout, v, loc)
+		case strings.HasSuffix(v, "Z"):
+			t, err = time.Parse(utcLayout, v)
+		default:
+			t, err = time.ParseInLocation(localLayout, v, loc)
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid %s %q", p.Name, v)
+		}
+		ts = append(ts, t)
+	}
+	return ts, allDay, nil
+}
+
+// Time parses the DATE or DATE-TIME value of the property
+func (p Property) Time(loc *time.Location) (time.Time, bool, error) {
+	ts, allDay, err := p.Times(loc)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return ts[0], allDay, nil
+}
+
+var durationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// ParseDuration parses a DURATION value, for example PT1H30M
+func ParseDuration(s string) (time.Duration, error) {
+	m := durationPattern.FindStringSubmatch(s)
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	var d time.Duration
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	for i, unit := range units {
+		if len(m[i+2]) == 0 {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+2])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		d += time.Duration(n) * unit
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
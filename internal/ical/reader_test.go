@@ -0,0 +1,231 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ical
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	doc := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VTODO",
+		"SUMMARY:Water the plants and then sweep the porch before the guests a",
+		" rrive",
+		`DESCRIPTION;LANGUAGE=en:first line\nsecond\, and \;third`,
+		"\t folded with a tab",
+		`ATTENDEE;CN="Doe; John";ROLE=REQ-PARTICIPANT:mailto:john@example.com`,
+		`X-NOTE;X-LABEL="a:b":value: with a colon`,
+		"END:VTODO",
+		"BEGIN:VEVENT",
+		"DTSTART:20160105T090000Z",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+	root, err := Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.Name != "VCALENDAR" || len(root.Components) != 2 {
+		t.Fatalf("root = %s with %d components, want VCALENDAR with 2", root.Name, len(root.Components))
+	}
+	todo, event := root.Components[0], root.Components[1]
+	if todo.Name != "VTODO" || todo.Line != 3 || event.Name != "VEVENT" || event.Line != 11 {
+		t.Errorf("components = %s on line %d and %s on line %d, want VTODO on line 3 and VEVENT on line 11",
+			todo.Name, todo.Line, event.Name, event.Line)
+	}
+	tests := []struct {
+		name   string
+		value  string
+		params map[string]string
+	}{
+		{"SUMMARY", "Water the plants and then sweep the porch before the guests arrive", map[string]string{}},
+		{"DESCRIPTION", `first line\nsecond\, and \;third folded with a tab`, map[string]string{"LANGUAGE": "en"}},
+		{"ATTENDEE", "mailto:john@example.com", map[string]string{"CN": "Doe; John", "ROLE": "REQ-PARTICIPANT"}},
+		{"X-NOTE", "value: with a colon", map[string]string{"X-LABEL": "a:b"}},
+	}
+	for _, test := range tests {
+		p, ok := todo.Get(test.name)
+		if !ok {
+			t.Errorf("%s is missing", test.name)
+			continue
+		}
+		if p.Value != test.value || !reflect.DeepEqual(p.Params, test.params) {
+			t.Errorf("%s = %q %v, want %q %v", test.name, p.Value, p.Params, test.value, test.params)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{"empty", ""},
+		{"no colon", "BEGIN:VCALENDAR\nVERSION\nEND:VCALENDAR"},
+		{"no name", "BEGIN:VCALENDAR\n:2.0\nEND:VCALENDAR"},
+		{"malformed parameter", "BEGIN:VCALENDAR\nVERSION;X:2.0\nEND:VCALENDAR"},
+		{"property out of any component", "VERSION:2.0\nBEGIN:VCALENDAR\nEND:VCALENDAR"},
+		{"unexpected END", "BEGIN:VCALENDAR\nBEGIN:VTODO\nEND:VCALENDAR\nEND:VTODO"},
+		{"not ended", "BEGIN:VCALENDAR\nBEGIN:VTODO\nEND:VTODO"},
+		{"second top level component", "BEGIN:VCALENDAR\nEND:VCALENDAR\nBEGIN:VCALENDAR\nEND:VCALENDAR"},
+	}
+	for _, test := range tests {
+		if _, err := Parse(strings.NewReader(test.doc)); err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+}
+
+func TestUnescapeText(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{`plain`, "plain"},
+		{`a\, b\; c`, "a, b; c"},
+		{`line\nline\Nline`, "line\nline\nline"},
+		{`back\\slash\\n`, `back\slash\n`},
+	}
+	for _, test := range tests {
+		if got := UnescapeText(test.in); got != test.out {
+			t.Errorf("UnescapeText(%q) = %q, want %q", test.in, got, test.out)
+		}
+		if got := UnescapeText(EscapeText(test.out)); got != test.out {
+			t.Errorf("UnescapeText(EscapeText(%q)) = %q", test.out, got)
+		}
+	}
+}
+
+func TestTimes(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		line   string
+		want   []time.Time
+		allDay bool
+	}{
+		{"DUE:20160105T090000Z", []time.Time{time.Date(2016, 1, 5, 9, 0, 0, 0, time.UTC)}, false},
+		{"DUE:20160105T090000", []time.Time{time.Date(2016, 1, 5, 9, 0, 0, 0, loc)}, false},
+		{"DUE;TZID=America/New_York:20160705T090000", []time.Time{time.Date(2016, 7, 5, 9, 0, 0, 0, ny)}, false},
+		{"DUE:20160105", []time.Time{time.Date(2016, 1, 5, 0, 0, 0, 0, loc)}, true},
+		{"DUE;VALUE=DATE:20160105", []time.Time{time.Date(2016, 1, 5, 0, 0, 0, 0, loc)}, true},
+		{"EXDATE:20160105T090000Z,20160112T090000Z", []time.Time{
+			time.Date(2016, 1, 5, 9, 0, 0, 0, time.UTC),
+			time.Date(2016, 1, 12, 9, 0, 0, 0, time.UTC),
+		}, false},
+	}
+	for _, test := range tests {
+		p, err := parseLine(test.line)
+		if err != nil {
+			t.Fatalf("%s: %s", test.line, err)
+		}
+		ts, allDay, err := p.Times(loc)
+		if err != nil {
+			t.Errorf("%s: %s", test.line, err)
+			continue
+		}
+		if allDay != test.allDay || len(ts) != len(test.want) {
+			t.Errorf("%s = %v (all day %t), want %v (all day %t)", test.line, ts, allDay, test.want, test.allDay)
+			continue
+		}
+		for i := range ts {
+			if !ts[i].Equal(test.want[i]) {
+				t.Errorf("%s = %v, want %v", test.line, ts, test.want)
+				break
+			}
+		}
+	}
+	for _, line := range []string{"DUE:2016-01-05", "DUE:20160105T9", "DUE;TZID=Nowhere/Else:20160105T090000"} {
+		p, err := parseLine(line)
+		if err != nil {
+			t.Fatalf("%s: %s", line, err)
+		}
+		if _, _, err := p.Times(loc); err == nil {
+			t.Errorf("%s: no error", line)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"PT1H30M", 90 * time.Minute, true},
+		{"P1D", 24 * time.Hour, true},
+		{"P2W", 14 * 24 * time.Hour, true},
+		{"P1DT2H3M4S", 26*time.Hour + 3*time.Minute + 4*time.Second, true},
+		{"-PT15M", -15 * time.Minute, true},
+		{"+PT15M", 15 * time.Minute, true},
+		{"P", 0, false},
+		{"PT", 0, false},
+		{"P1DT", 0, false},
+		{"1H", 0, false},
+		{"PT1.5H", 0, false},
+	}
+	for _, test := range tests {
+		d, err := ParseDuration(test.in)
+		switch {
+		case test.ok && err != nil:
+			t.Errorf("ParseDuration(%q): %s", test.in, err)
+		case !test.ok && err == nil:
+			t.Errorf("ParseDuration(%q) = %s, want an error", test.in, d)
+		case d != test.want:
+			t.Errorf("ParseDuration(%q) = %s, want %s", test.in, d, test.want)
+		}
+		if test.ok && test.want >= 0 {
+			if back, err := ParseDuration(FormatDuration(test.want)); err != nil || back != test.want {
+				t.Errorf("ParseDuration(FormatDuration(%s)) = %s, %v", test.want, back, err)
+			}
+		}
+	}
+}
+
+// TestFoldRoundTrip checks that the lines the writer folds are read back
+// whole, the multi-byte characters included
+func TestFoldRoundTrip(t *testing.T) {
+	summary := strings.Repeat("Arrosez les plantes, balayez le porche à l'arrivée ", 5)
+	var b bytes.Buffer
+	w := NewWriter(&b)
+	w.Begin("VCALENDAR")
+	w.Text("SUMMARY", summary)
+	w.End("VCALENDAR")
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(b.String(), "\r\n") {
+		if len(line) > maxLine {
+			t.Errorf("%q is longer than %d octets", line, maxLine)
+		}
+	}
+	root, err := Parse(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := root.Get("SUMMARY")
+	if got := UnescapeText(p.Value); got != summary {
+		t.Errorf("SUMMARY = %q, want %q", got, summary)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ical reads and writes the iCalendar (RFC 5545) content lines
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ContentType is the media type of the iCalendar documents
+const ContentType = "text/calendar; charset=utf-8"
+
+// maxLine is the maximum length in octets of a content line, the longer
+// lines are folded
+const maxLine = 75
+
+const (
+	utcLayout   = "20060102T150405Z"
+	localLayout = "20060102T150405"
+	dateLayout  = "20060102"
+)
+
+// Writer writes the content lines of an iCalendar document. The first write
+// error is kept and returned by Flush, the writes after it do nothing
+type Writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+// NewWriter creates a writer writing to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// Begin begins a component, for example VEVENT
+func (w *Writer) Begin(component string) {
+	w.Line("BEGIN", component)
+}
+
+// End ends a component
+func (w *Writer) End(component string) {
+	w.Line("END", component)
+}
+
+// Line writes a property with its raw value and parameters such as
+// "TZID=Europe/Paris"
+func (w *Writer) Line(name, value string, params ...string) {
+	if w.err != nil {
+		return
+	}
+	line := name
+	if len(params) != 0 {
+		line += ";" + strings.Join(params, ";")
+	}
+	line += ":" + value
+	_, w.err = w.w.WriteString(fold(line) + "\r\n")
+}
+
+// Text writes a property with a TEXT value, which gets escaped
+func (w *Writer) Text(name, value string, params ...string) {
+	w.Line(name, EscapeText(value), params...)
+}
+
+// Flush flushes the written lines and returns the first error met
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// EscapeText escapes a TEXT value
+func EscapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// fold folds the line into lines of at most maxLine octets, without
+// splitting the UTF-8 sequences
+func fold(line string) string {
+	if len(line) <= maxLine {
+		return line
+	}
+	var b bytes.Buffer
+	limit := maxLine
+	for len(line) > limit {
+		cut := limit
+		// back up to the start of a UTF-8 sequence
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// the continuation lines start with a space
+		limit = maxLine - 1
+	}
+	b.WriteString(line)
+	return b.String()
+}
+
+// FormatUTC formats the time as a UTC DATE-TIME
+func FormatUTC(t time.Time) string {
+	return t.UTC().Format(utcLayout)
+}
+
+// FormatLocal formats the wall clock of the time as a local DATE-TIME, to
+// be used along with a TZID parameter
+func FormatLocal(t time.Time) string {
+	return t.Format(localLayout)
+}
+
+// FormatDate formats the date of the time as a DATE
+func FormatDate(t time.Time) string {
+	return t.Format(dateLayout)
+}
+
+// FormatDuration formats a non negative duration as a DURATION value, for
+// example PT1H30M
+func FormatDuration(d time.Duration) string {
+	if d <= 0 {
+		return "PT0S"
+	}
+	d -= d % time.Second
+	var b bytes.Buffer
+	b.WriteString("P")
+	if days := d / (24 * time.Hour); days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+		d -= days * 24 * time.Hour
+	}
+	if d > 0 {
+		b.WriteString("T")
+		if h := d / time.Hour; h > 0 {
+			fmt.Fprintf(&b, "%dH", h)
+			d -= h * time.Hour
+		}
+		if m := d / time.Minute; m > 0 {
+			fmt.Fprintf(&b, "%dM", m)
+			d -= m * time.Minute
+		}
+		if s := d / time.Second; s > 0 {
+			fmt.Fprintf(&b, "%dS", s)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,193 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package idempotency keeps the first response of the requests sent with an
+// idempotency key so that their retries are answered with it rather than
+// served again
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// Header is the HTTP header carrying the idempotency key
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set on the responses replayed to a retry
+	ReplayedHeader = "Idempotent-Replayed"
+	// Collection is the name of the collection of the kept responses
+	Collection = "idempotency"
+	// MaxKeyLength bounds the length of an idempotency key
+	MaxKeyLength = 255
+)
+
+// abandoned is how long a request is waited for before its key is taken
+// over by a retry, the server serving it most likely stopped
+const abandoned = time.Minute
+
+// maxAttempts bounds the attempts of Begin racing with the other requests
+// using the key
+const maxAttempts = 3
+
+var (
+	// ErrInProgress is returned when the first request with the key is
+	// still being served
+	ErrInProgress = errors.New("a request with the idempotency key is in progress")
+	// ErrMismatch is returned when the key was used for another request
+	ErrMismatch = errors.New("the idempotency key was used for another request")
+)
+
+// Response is a kept response
+type Response struct {
+	Status int         `bson:"status"`
+	Header http.Header `bson:"header"`
+	Body   []byte      `bson:"body"`
+}
+
+// record is the key of a user, it has no response while the request is
+// being served
+type record struct {
+	ID          bson.ObjectId `bson:"_id"`
+	Username    string        `bson:"username"`
+	Key         string        `bson:"key"`
+	Fingerprint string        `bson:"fingerprint"`
+	Response    *Response     `bson:"response"`
+	Created     time.Time     `bson:"created"`
+}
+
+// Store stores the idempotency keys of the users and the responses of the
+// requests sent with them
+type Store struct {
+	col       *mgo.Collection
+	retention time.Duration
+}
+
+// NewStore creates a store keeping the keys in the collection for the
+// retention, after which a key can be used again
+func NewStore(col *mgo.Collection, retention time.Duration) (*Store, error) {
+	indexes := []mgo.Index{
+		{Key: []string{"username", "key"}, Unique: true},
+		{Key: []string{"created"}, ExpireAfter: retention},
+	}
+	for _, index := range indexes {
+		if err := col.EnsureIndex(index); err != nil {
+			return nil, fmt.Errorf("ensure idempotency index %v: %s", index.Key, err)
+		}
+	}
+	return &Store{
+		col:       col,
+		retention: retention,
+	}, nil
+}
+
+// ValidKey checks the key is 1 to MaxKeyLength printable ASCII characters
+func ValidKey(key string) bool {
+	if len(key) == 0 || len(key) > MaxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < ' ' || key[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// Fingerprint identifies a request by its method, its URI and its body, the
+// retries of a request have the same fingerprint
+func Fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.RequestURI())
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Begin claims the key of the user for the request with the fingerprint. It
+// returns the kept response of a request already served with the key, or nil
+// when the request is to be served and then completed or released. A key used
+// for another request gives ErrMismatch, a request still in progress gives
+// ErrInProgress
+func (s *Store) Begin(username, key, fingerprint string, now time.Time) (*Response, error) {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		err := s.col.Insert(record{
+			ID:          bson.NewObjectId(),
+			Username:    username,
+			Key:         key,
+			Fingerprint: fingerprint,
+			Created:     now,
+		})
+		if err == nil {
+			return nil, nil
+		}
+		if !mgo.IsDup(err) {
+			return nil, err
+		}
+		var rec record
+		err = s.col.Find(bson.M{"username": username, "key": key}).One(&rec)
+		if err == mgo.ErrNotFound {
+			// released or expired since
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case now.Sub(rec.Created) >= s.retention:
+			// expired but not removed yet, the TTL monitor only runs every
+			// minute
+		case rec.Fingerprint != fingerprint:
+			return nil, ErrMismatch
+		case rec.Response != nil:
+			return rec.Response, nil
+		case now.Sub(rec.Created) < abandoned:
+			return nil, ErrInProgress
+		}
+		// Only this record is removed, a concurrent retry may have taken the
+		// key over already
+		if err = s.col.RemoveId(rec.ID); err != nil && err != mgo.ErrNotFound {
+			return nil, err
+		}
+	}
+	return nil, ErrInProgress
+}
+
+// Complete keeps the response of the request the key of the user was claimed
+// for
+func (s *Store) Complete(username, key string, res *Response) error {
+	err := s.col.Update(bson.M{"username": username, "key": key, "response": nil},
+		bson.M{"$set": bson.M{"response": res}})
+	if err == mgo.ErrNotFound {
+		// taken over by a retry
+		return nil
+	}
+	return err
+}
+
+// Release frees the key of the user claimed for a request which was not
+// served, so that it can be retried
+func (s *Store) Release(username, key string) error {
+	err := s.col.Remove(bson.M{"username": username, "key": key, "response": nil})
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
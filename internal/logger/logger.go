@@ -0,0 +1,209 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logger contains a leveled structured logger which writes one
+// record per line either in logfmt or in JSON
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log record
+type Level int
+
+const (
+	// LevelDebug is the level for verbose diagnostic records
+	LevelDebug Level = iota
+	// LevelInfo is the level for normal operation records
+	LevelInfo
+	// LevelError is the level for failures
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+}
+
+// ParseLevel parses the level name (debug, info or error)
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q", s)
+	}
+}
+
+const (
+	// FormatLogfmt writes records as key=value pairs
+	FormatLogfmt = "logfmt"
+	// FormatJSON writes records as JSON objects
+	FormatJSON = "json"
+)
+
+// Default is the logger used when the context does not carry any
+var Default = New(os.Stdout, FormatLogfmt, LevelInfo)
+
+// Logger writes structured records. A Logger is safe for concurrent use and
+// the loggers derived from it with With share its output
+type Logger struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	json   bool
+	level  Level
+	fields []interface{}
+}
+
+// New creates a logger writing the records at or above the level to out in
+// the format, which is either FormatLogfmt or FormatJSON
+func New(out io.Writer, format string, level Level) *Logger {
+	return &Logger{
+		mu:    &sync.Mutex{},
+		out:   out,
+		json:  format == FormatJSON,
+		level: level,
+	}
+}
+
+// With returns a logger which adds the key value pairs to every record
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	nl := *l
+	nl.fields = append(append([]interface{}(nil), l.fields...), keyvals...)
+	return &nl
+}
+
+// Debug logs the message with the key value pairs at the debug level
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.log(LevelDebug, msg, keyvals)
+}
+
+// Info logs the message with the key value pairs at the info level
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.log(LevelInfo, msg, keyvals)
+}
+
+// Error logs the message with the key value pairs at the error level
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.log(LevelError, msg, keyvals)
+}
+
+func (l *Logger) log(level Level, msg string, keyvals []interface{}) {
+	if level < l.level {
+		return
+	}
+	kvs := make([]interface{}, 0, 6+len(l.fields)+len(keyvals))
+	kvs = append(kvs, "ts", time.Now().UTC().Format(time.RFC3339Nano), "level", level.String(), "msg", msg)
+	kvs = append(kvs, l.fields...)
+	kvs = append(kvs, keyvals...)
+	if len(kvs)%2 != 0 {
+		kvs = append(kvs, "(MISSING)")
+	}
+
+	var buf bytes.Buffer
+	if l.json {
+		writeJSON(&buf, kvs)
+	} else {
+		writeLogfmt(&buf, kvs)
+	}
+	buf.WriteByte('\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// NOTE: there is nowhere to report a failing log output to
+	_, _ = l.out.Write(buf.Bytes())
+}
+
+// value converts the errors and the stringers into their string form
+func value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.Seconds()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return v
+	}
+}
+
+func writeJSON(buf *bytes.Buffer, kvs []interface{}) {
+	buf.WriteByte('{')
+	for i := 0; i < len(kvs); i += 2 {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(fmt.Sprint(kvs[i]))
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(value(kvs[i+1]))
+		if err != nil {
+			v, _ = json.Marshal(fmt.Sprint(kvs[i+1]))
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+}
+
+func writeLogfmt(buf *bytes.Buffer, kvs []interface{}) {
+	for i := 0; i < len(kvs); i += 2 {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(fmt.Sprint(kvs[i]))
+		buf.WriteByte('=')
+		v := fmt.Sprint(value(kvs[i+1]))
+		if len(v) == 0 || strings.ContainsAny(v, " =\"\t\r\n") {
+			v = fmt.Sprintf("%q", v)
+		}
+		buf.WriteString(v)
+	}
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of the context carrying the logger
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by the context or the Default
+// logger
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
+	}
+	return Default
+}
@@ -0,0 +1,71 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import "sync"
+
+// hubBuffer is the number of notifications buffered for a subscriber, the
+// notifications arriving while its buffer is full are dropped: the
+// subscriber reads them from the inbox when it reconnects
+const hubBuffer = 16
+
+// Hub pushes the new inbox items to the connected users. It only reaches the
+// users connected to this process
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan Item]struct{}
+}
+
+// NewHub creates a hub without subscribers
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[string]map[chan Item]struct{}),
+	}
+}
+
+// Subscribe subscribes to the items of the user's inbox. The returned
+// function cancels the subscription, it must be called once done
+func (h *Hub) Subscribe(username string) (<-chan Item, func()) {
+	ch := make(chan Item, hubBuffer)
+	h.mu.Lock()
+	subs, ok := h.subscribers[username]
+	if !ok {
+		subs = make(map[chan Item]struct{})
+		h.subscribers[username] = subs
+	}
+	subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		subs := h.subscribers[username]
+		delete(subs, ch)
+		if len(subs) == 0 {
+			delete(h.subscribers, username)
+		}
+	}
+}
+
+// Publish pushes the item to the subscribers of its user, it never blocks
+func (h *Hub) Publish(item Item) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers[item.Username] {
+		select {
+		case ch <- item:
+		default:
+		}
+	}
+}
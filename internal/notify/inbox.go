@@ -0,0 +1,172 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iocat/donit/internal/achieving/errors"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// InboxCollection is the name of the collection of the in-app notifications
+const InboxCollection = "notifications"
+
+// Item is a notification stored in a user's inbox
+type Item struct {
+	ID           bson.ObjectId `bson:"_id" json:"id"`
+	Username     string        `bson:"username" json:"-"`
+	Notification `bson:"notification,inline"`
+	Read         bool      `bson:"read" json:"read"`
+	Created      time.Time `bson:"created" json:"created"`
+}
+
+// Inbox stores the notifications so that the users read them in the app,
+// and pushes them to the users connected to the hub
+type Inbox struct {
+	col *mgo.Collection
+	hub *Hub
+}
+
+// NewInbox creates an inbox channel storing the notifications in the
+// collection. The notifications are removed once older than retention, zero
+// keeps them forever. The hub, which may be nil, gets every new notification
+func NewInbox(col *mgo.Collection, hub *Hub, retention time.Duration) (*Inbox, error) {
+	indexes := []mgo.Index{
+		{Key: []string{"username", "-_id"}},
+		{Key: []string{"username", "read"}},
+	}
+	if retention > 0 {
+		indexes = append(indexes, mgo.Index{Key: []string{"created"}, ExpireAfter: retention})
+	}
+	for _, index := range indexes {
+		if err := col.EnsureIndex(index); err != nil {
+			return nil, fmt.Errorf("ensure inbox index %v: %s", index.Key, err)
+		}
+	}
+	return &Inbox{
+		col: col,
+		hub: hub,
+	}, nil
+}
+
+// Deliver implements Channel
+func (in *Inbox) Deliver(r Recipient, n Notification) error {
+	item := Item{
+		ID:           bson.NewObjectId(),
+		Username:     r.Username,
+		Notification: n,
+		Created:      time.Now(),
+	}
+	if err := in.col.Insert(item); err != nil {
+		return err
+	}
+	if in.hub != nil {
+		in.hub.Publish(item)
+	}
+	return nil
+}
+
+// List lists the notifications of the user, the latest first. Only the
+// unread ones are listed if unread is set
+func (in *Inbox) List(username string, unread bool, limit, offset int) ([]Item, error) {
+	query := bson.M{"username": username}
+	if unread {
+		query["read"] = false
+	}
+	q := in.col.Find(query).Sort("-_id")
+	if limit > 0 {
+		q.Limit(limit)
+	}
+	if offset > 0 {
+		q.Skip(offset)
+	}
+	var items []Item
+	if err := q.All(&items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Since lists the notifications of the user which arrived after the one
+// with the id, the oldest first
+func (in *Inbox) Since(username string, id bson.ObjectId) ([]Item, error) {
+	var items []Item
+	err := in.col.Find(bson.M{
+		"username": username,
+		"_id":      bson.M{"$gt": id},
+	}).Sort("_id").All(&items)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Unread counts the unread notifications of the user
+func (in *Inbox) Unread(username string) (int, error) {
+	return in.col.Find(bson.M{
+		"username": username,
+		"read":     false,
+	}).Count()
+}
+
+// MarkRead sets whether the notification of the user has been read
+func (in *Inbox) MarkRead(username string, id bson.ObjectId, read bool) error {
+	err := in.col.Update(bson.M{
+		"username": username,
+		"_id":      id,
+	}, bson.M{
+		"$set": bson.M{"read": read},
+	})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return errors.NewNotFound("notification", fmt.Sprintf("%s,%s", username, id.Hex()))
+		}
+		return err
+	}
+	return nil
+}
+
+// MarkAllRead marks every notification of the user as read and returns how
+// many were unread
+func (in *Inbox) MarkAllRead(username string) (int, error) {
+	info, err := in.col.UpdateAll(bson.M{
+		"username": username,
+		"read":     false,
+	}, bson.M{
+		"$set": bson.M{"read": true},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return info.Updated, nil
+}
+
+// Remove removes the notification from the user's inbox
+func (in *Inbox) Remove(username string, id bson.ObjectId) error {
+	err := in.col.Remove(bson.M{
+		"username": username,
+		"_id":      id,
+	})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return errors.NewNotFound("notification", fmt.Sprintf("%s,%s", username, id.Hex()))
+		}
+		return err
+	}
+	return nil
+}
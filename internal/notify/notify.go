@@ -0,0 +1,66 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notify delivers notifications to the users through pluggable
+// channels (webhook, email, in-app inbox...), retrying the failed deliveries
+// and keeping a log of every attempt
+package notify
+
+import (
+	"time"
+)
+
+const (
+	// ChannelWebhook delivers the notifications to the user's webhook
+	ChannelWebhook = "WEBHOOK"
+	// ChannelEmail emails the notifications to the user
+	ChannelEmail = "EMAIL"
+	// ChannelInApp stores the notifications in the user's inbox
+	ChannelInApp = "IN_APP"
+)
+
+// Notification is a message for a user
+type Notification struct {
+	// Kind is the kind of event notified, for example "reminder"
+	Kind  string `bson:"kind" json:"kind"`
+	Title string `bson:"title" json:"title"`
+	Body  string `bson:"body,omitempty" json:"body,omitempty"`
+	// Subject identifies the resource the notification is about, the
+	// deliveries are looked up by subject
+	Subject string            `bson:"subject,omitempty" json:"subject,omitempty"`
+	Data    map[string]string `bson:"data,omitempty" json:"data,omitempty"`
+	At      time.Time         `bson:"at" json:"at"`
+}
+
+// Recipient tells where the notifications of a user are delivered
+type Recipient struct {
+	Username   string `bson:"username" json:"username"`
+	Email      string `bson:"email,omitempty" json:"-"`
+	WebhookURL string `bson:"webhookUrl,omitempty" json:"-"`
+}
+
+// Channel delivers the notifications through a medium
+type Channel interface {
+	// Deliver delivers the notification to the recipient. The error is
+	// a failed attempt which may be retried
+	Deliver(Recipient, Notification) error
+}
+
+// ChannelFunc adapts a function to the Channel interface
+type ChannelFunc func(Recipient, Notification) error
+
+// Deliver implements Channel
+func (f ChannelFunc) Deliver(r Recipient, n Notification) error {
+	return f(r, n)
+}
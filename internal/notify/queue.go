@@ -0,0 +1,195 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// DeliveryCollection is the name of the collection of the deliveries
+const DeliveryCollection = "deliveries"
+
+const (
+	// StatusPending is the status of a delivery waiting for an attempt
+	StatusPending = "PENDING"
+	// StatusDelivered is the status of a successful delivery
+	StatusDelivered = "DELIVERED"
+	// StatusDead is the status of a delivery which failed every attempt, it
+	// is kept as a dead letter and never retried
+	StatusDead = "DEAD"
+)
+
+// lease is how long a delivery is held by the processor attempting it, a
+// crashed processor releases its deliveries after it
+const lease = time.Minute
+
+// batchSize is the maximum number of deliveries attempted by Process
+const batchSize = 100
+
+// Attempt is a delivery attempt
+type Attempt struct {
+	At    time.Time `bson:"at" json:"at"`
+	Error string    `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// Delivery is a notification to deliver through a channel, along with the
+// log of its attempts
+type Delivery struct {
+	ID bson.ObjectId `bson:"_id" json:"id"`
+	// Key identifies the notified event, a notification is enqueued once
+	// per key and channel
+	Key          string       `bson:"key" json:"-"`
+	Channel      string       `bson:"channel" json:"channel"`
+	Recipient    Recipient    `bson:"recipient" json:"-"`
+	Notification Notification `bson:"notification" json:"notification"`
+	Status       string       `bson:"status" json:"status"`
+	Attempts     []Attempt    `bson:"attempts" json:"attempts"`
+	NextAttempt  time.Time    `bson:"nextAttempt" json:"nextAttempt"`
+	Created      time.Time    `bson:"created" json:"created"`
+}
+
+// Queue stores the deliveries and attempts them until they succeed or run
+// out of attempts
+type Queue struct {
+	col         *mgo.Collection
+	channels    map[string]Channel
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// NewQueue creates a queue storing the deliveries in the collection. A failed
+// delivery is retried after backoff, doubling every attempt, until it fails
+// maxAttempts times
+func NewQueue(col *mgo.Collection, channels map[string]Channel, maxAttempts int, backoff time.Duration) (*Queue, error) {
+	indexes := []mgo.Index{
+		{Key: []string{"key", "channel"}, Unique: true},
+		{Key: []string{"status", "nextAttempt"}},
+		{Key: []string{"notification.subject", "created"}},
+	}
+	for _, index := range indexes {
+		if err := col.EnsureIndex(index); err != nil {
+			return nil, fmt.Errorf("set up delivery queue: %s", err)
+		}
+	}
+	return &Queue{
+		col:         col,
+		channels:    channels,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+	}, nil
+}
+
+// Enqueue queues the notification for delivery through every channel. The
+// notification is only queued once per key and channel, so enqueuing the
+// same event again is harmless
+func (q *Queue) Enqueue(key string, r Recipient, channels []string, n Notification) error {
+	now := time.Now()
+	for _, channel := range channels {
+		err := q.col.Insert(Delivery{
+			ID:           bson.NewObjectId(),
+			Key:          key,
+			Channel:      channel,
+			Recipient:    r,
+			Notification: n,
+			Status:       StatusPending,
+			NextAttempt:  now,
+			Created:      now,
+		})
+		if err != nil && !mgo.IsDup(err) {
+			return fmt.Errorf("enqueue %s through %s: %s", key, channel, err)
+		}
+	}
+	return nil
+}
+
+// Process attempts the pending deliveries which are due and returns how many
+// it attempted
+func (q *Queue) Process(now time.Time) (int, error) {
+	n := 0
+	for ; n < batchSize; n++ {
+		var d Delivery
+		// Claim the delivery so that the other processors skip it
+		_, err := q.col.Find(bson.M{
+			"status":      StatusPending,
+			"nextAttempt": bson.M{"$lte": now},
+		}).Sort("nextAttempt").Apply(mgo.Change{
+			Update:    bson.M{"$set": bson.M{"nextAttempt": now.Add(lease)}},
+			ReturnNew: true,
+		}, &d)
+		if err == mgo.ErrNotFound {
+			return n, nil
+		}
+		if err != nil {
+			return n, fmt.Errorf("claim delivery: %s", err)
+		}
+		if err := q.attempt(&d, now); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// attempt delivers the notification and records the attempt
+func (q *Queue) attempt(d *Delivery, now time.Time) error {
+	var err error
+	if channel, ok := q.channels[d.Channel]; ok {
+		err = channel.Deliver(d.Recipient, d.Notification)
+	} else {
+		err = fmt.Errorf("channel %s is not configured", d.Channel)
+	}
+	a := Attempt{At: now}
+	set := bson.M{}
+	switch {
+	case err == nil:
+		set["status"] = StatusDelivered
+	case len(d.Attempts)+1 >= q.maxAttempts:
+		a.Error = err.Error()
+		set["status"] = StatusDead
+	default:
+		a.Error = err.Error()
+		set["nextAttempt"] = now.Add(q.backoff << uint(len(d.Attempts)))
+	}
+	err = q.col.UpdateId(d.ID, bson.M{
+		"$set":  set,
+		"$push": bson.M{"attempts": a},
+	})
+	if err != nil {
+		return fmt.Errorf("record delivery attempt: %s", err)
+	}
+	return nil
+}
+
+// History returns the deliveries of the notifications about the subject,
+// newest first
+func History(col *mgo.Collection, subject string, limit, offset int) ([]Delivery, error) {
+	q := col.Find(bson.M{
+		"notification.subject": subject,
+	}).Sort("-created")
+	if limit > 0 {
+		q.Limit(limit)
+	}
+	if offset > 0 {
+		q.Skip(offset)
+	}
+	var ds []Delivery
+	if err := q.All(&ds); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
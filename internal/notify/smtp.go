@@ -0,0 +1,71 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTP emails the notifications to the recipient's address
+type SMTP struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTP creates an email channel sending through the SMTP server at addr
+// (host:port) from the address. The username and the password are only
+// used if the username is not empty
+func NewSMTP(addr, from, username, password string) *SMTP {
+	var auth smtp.Auth
+	if len(username) != 0 {
+		host, _, _ := net.SplitHostPort(addr)
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTP{
+		addr: addr,
+		from: from,
+		auth: auth,
+	}
+}
+
+// Deliver implements Channel
+func (s *SMTP) Deliver(r Recipient, n Notification) error {
+	if len(r.Email) == 0 {
+		return errors.New("smtp: no email address")
+	}
+	if strings.ContainsAny(r.Email, "\r\n") {
+		return fmt.Errorf("smtp: invalid email address %q", r.Email)
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", r.Email)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", n.Title))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n")
+	fmt.Fprintf(&msg, "\r\n%s\r\n", strings.Replace(n.Body, "\n", "\r\n", -1))
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{r.Email}, msg.Bytes()); err != nil {
+		return fmt.Errorf("smtp: %s", err)
+	}
+	return nil
+}
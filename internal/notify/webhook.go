@@ -0,0 +1,174 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// EventHeader is the header of the webhook requests carrying the kind of the
+// notification
+const EventHeader = "X-Donit-Event"
+
+// privateNetworks are the networks the webhooks cannot target besides the
+// loopback, link-local, multicast and unspecified addresses
+var privateNetworks = []*net.IPNet{
+	cidr("0.0.0.0/8"),
+	cidr("10.0.0.0/8"),
+	cidr("100.64.0.0/10"),
+	cidr("172.16.0.0/12"),
+	cidr("192.168.0.0/16"),
+	cidr("fc00::/7"),
+}
+
+func cidr(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// publicIP returns whether the IP address is reachable on the internet
+func publicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckWebhookURL checks the webhook URL is an https URL of a public host,
+// so that the server is not made to post to its own network. The host names
+// are checked again once resolved when the webhook is delivered
+func CheckWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("webhook: %s", err)
+	}
+	if u.Scheme != "https" {
+		return errors.New("webhook: the URL is not https")
+	}
+	host := u.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	switch {
+	case len(host) == 0:
+		return errors.New("webhook: the URL has no host")
+	case host == "localhost" || strings.HasSuffix(host, ".localhost"):
+		return fmt.Errorf("webhook: %s is not a public host", host)
+	}
+	if ip := net.ParseIP(host); ip != nil && !publicIP(ip) {
+		return fmt.Errorf("webhook: %s is not a public address", ip)
+	}
+	return nil
+}
+
+// publicDialer only connects to the public addresses of the host, checking
+// them when they are resolved rather than trusting an earlier lookup
+type publicDialer struct {
+	net.Dialer
+}
+
+func (d *publicDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if !publicIP(ip) {
+			return nil, fmt.Errorf("%s resolves to %s which is not a public address", host, ip)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("%s resolves to no address", host)
+	}
+	return d.Dialer.DialContext(ctx, network, net.JoinHostPort(ips[0].String(), port))
+}
+
+// Webhook posts the notifications as JSON to the recipient's webhook URL
+type Webhook struct {
+	client *http.Client
+}
+
+// NewWebhook creates a webhook channel whose requests time out after the
+// timeout. The webhooks only reach public hosts and are not redirected
+func NewWebhook(timeout time.Duration) *Webhook {
+	dialer := &publicDialer{net.Dialer{Timeout: timeout}}
+	return &Webhook{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+			// a redirect could lead to a private host over http
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Deliver implements Channel, any response but a 2xx is a failure
+func (wh *Webhook) Deliver(r Recipient, n Notification) error {
+	if len(r.WebhookURL) == 0 {
+		return errors.New("webhook: no URL configured")
+	}
+	// the URLs stored before they were checked
+	if err := CheckWebhookURL(r.WebhookURL); err != nil {
+		return err
+	}
+	body, err := json.Marshal(struct {
+		Username string `json:"username"`
+		Notification
+	}{r.Username, n})
+	if err != nil {
+		return fmt.Errorf("webhook: encode: %s", err)
+	}
+	req, err := http.NewRequest("POST", r.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, n.Kind)
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %s", err)
+	}
+	// NOTE: nothing is read from the body, closing it is all we need
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook: %s responded %s", r.WebhookURL, resp.Status)
+	}
+	return nil
+}
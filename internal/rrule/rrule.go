@@ -0,0 +1,555 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rrule parses, formats and expands the RFC 5545 recurrence rules
+// (RRULE). The rules are expanded over calendar dates, the caller decides
+// the time of the day and the time zone of the occurrences
+package rrule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the FREQ of a rule
+type Frequency string
+
+const (
+	// Daily repeats every INTERVAL days
+	Daily Frequency = "DAILY"
+	// Weekly repeats every INTERVAL weeks
+	Weekly Frequency = "WEEKLY"
+	// Monthly repeats every INTERVAL months
+	Monthly Frequency = "MONTHLY"
+	// Yearly repeats every INTERVAL years
+	Yearly Frequency = "YEARLY"
+)
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+var weekdayNames = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Weekday is a BYDAY element: a day of the week and, in the monthly and
+// yearly rules, its ordinal in the month or the year. -1FR is the last
+// Friday, 2MO the second Monday and N zero is every such day
+type Weekday struct {
+	N   int
+	Day time.Weekday
+}
+
+func (w Weekday) String() string {
+	if w.N == 0 {
+		return weekdayNames[w.Day]
+	}
+	return strconv.Itoa(w.N) + weekdayNames[w.Day]
+}
+
+// Rule is a recurrence rule. The BYxxx parts which are not listed here
+// (BYSETPOS, BYYEARDAY, BYWEEKNO, BYHOUR...) are not supported
+type Rule struct {
+	Freq Frequency
+	// Interval is the number of periods between the occurrences, at
+	// least 1
+	Interval int
+	// Count is the number of occurrences, zero is unlimited
+	Count int
+	// Until is the last possible occurrence, zero is unlimited. It is a
+	// date if UntilDate is set and a UTC date-time otherwise
+	Until      time.Time
+	UntilDate  bool
+	ByMonth    []time.Month
+	ByMonthDay []int
+	ByDay      []Weekday
+	// WeekStart is the first day of the weeks counted by the weekly rules
+	WeekStart time.Weekday
+}
+
+const (
+	untilDateTimeLayout = "20060102T150405Z"
+	untilDateLayout     = "20060102"
+)
+
+// Parse parses the value of a RRULE property, with or without its "RRULE:"
+// name
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	r := &Rule{Interval: 1, WeekStart: time.Monday}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || len(kv[1]) == 0 {
+			return nil, fmt.Errorf("rrule: malformed part %q", part)
+		}
+		name, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		if seen[name] {
+			return nil, fmt.Errorf("rrule: %s is repeated", name)
+		}
+		seen[name] = true
+		var err error
+		switch name {
+		case "FREQ":
+			switch f := Frequency(value); f {
+			case Daily, Weekly, Monthly, Yearly:
+				r.Freq = f
+			default:
+				return nil, fmt.Errorf("rrule: unsupported frequency %s", value)
+			}
+		case "INTERVAL":
+			if r.Interval, err = positive(value); err != nil {
+				return nil, fmt.Errorf("rrule: INTERVAL %s", err)
+			}
+		case "COUNT":
+			if r.Count, err = positive(value); err != nil {
+				return nil, fmt.Errorf("rrule: COUNT %s", err)
+			}
+		case "UNTIL":
+			if r.Until, err = time.Parse(untilDateTimeLayout, value); err == nil {
+				break
+			}
+			if r.Until, err = time.Parse(untilDateLayout, value); err != nil {
+				return nil, fmt.Errorf("rrule: malformed UNTIL %s", value)
+			}
+			r.UntilDate = true
+		case "BYMONTH":
+			for _, v := range strings.Split(value, ",") {
+				m, err := strconv.Atoi(v)
+				if err != nil || m < 1 || m > 12 {
+					return nil, fmt.Errorf("rrule: invalid BYMONTH %s", v)
+				}
+				r.ByMonth = append(r.ByMonth, time.Month(m))
+			}
+		case "BYMONTHDAY":
+			for _, v := range strings.Split(value, ",") {
+				d, err := strconv.Atoi(v)
+				if err != nil || d == 0 || d < -31 || d > 31 {
+					return nil, fmt.Errorf("rrule: invalid BYMONTHDAY %s", v)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, d)
+			}
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				w, err := parseWeekday(v)
+				if err != nil {
+					return nil, err
+				}
+				r.ByDay = append(r.ByDay, w)
+			}
+		case "WKST":
+			d, ok := weekdays[value]
+			if !ok {
+				return nil, fmt.Errorf("rrule: invalid WKST %s", value)
+			}
+			r.WeekStart = d
+		default:
+			return nil, fmt.Errorf("rrule: unsupported part %s", name)
+		}
+	}
+	if err := r.check(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func positive(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s is not a positive integer", s)
+	}
+	return n, nil
+}
+
+func parseWeekday(s string) (Weekday, error) {
+	if len(s) < 2 {
+		return Weekday{}, fmt.Errorf("rrule: invalid BYDAY %s", s)
+	}
+	day, ok := weekdays[s[len(s)-2:]]
+	if !ok {
+		return Weekday{}, fmt.Errorf("rrule: invalid BYDAY %s", s)
+	}
+	w := Weekday{Day: day}
+	if ordinal := s[:len(s)-2]; len(ordinal) != 0 {
+		n, err := strconv.Atoi(ordinal)
+		if err != nil || n == 0 || n < -53 || n > 53 {
+			return Weekday{}, fmt.Errorf("rrule: invalid BYDAY %s", s)
+		}
+		w.N = n
+	}
+	return w, nil
+}
+
+// check checks the consistency of the rule
+func (r *Rule) check() error {
+	if len(r.Freq) == 0 {
+		return fmt.Errorf("rrule: FREQ is required")
+	}
+	if r.Interval < 1 {
+		return fmt.Errorf("rrule: INTERVAL must be positive")
+	}
+	if r.Count != 0 && !r.Until.IsZero() {
+		return fmt.Errorf("rrule: COUNT and UNTIL are exclusive")
+	}
+	if r.Freq == Weekly && len(r.ByMonthDay) != 0 {
+		return fmt.Errorf("rrule: BYMONTHDAY is not allowed in a weekly rule")
+	}
+	for _, w := range r.ByDay {
+		if w.N == 0 {
+			continue
+		}
+		switch {
+		case r.Freq == Daily || r.Freq == Weekly:
+			return fmt.Errorf("rrule: BYDAY %s cannot have an ordinal in a %s rule",
+				w, strings.ToLower(string(r.Freq)))
+		case r.Freq == Monthly && (w.N < -5 || w.N > 5):
+			return fmt.Errorf("rrule: BYDAY %s is out of the month", w)
+		}
+	}
+	return nil
+}
+
+// String formats the rule in a canonical order, without the "RRULE:" name
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count != 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		if r.UntilDate {
+			parts = append(parts, "UNTIL="+r.Until.Format(untilDateLayout))
+		} else {
+			parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilDateTimeLayout))
+		}
+	}
+	if len(r.ByMonth) != 0 {
+		ms := make([]string, len(r.ByMonth))
+		for i, m := range r.ByMonth {
+			ms[i] = strconv.Itoa(int(m))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(ms, ","))
+	}
+	if len(r.ByMonthDay) != 0 {
+		ds := make([]string, len(r.ByMonthDay))
+		for i, d := range r.ByMonthDay {
+			ds[i] = strconv.Itoa(d)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(ds, ","))
+	}
+	if len(r.ByDay) != 0 {
+		ws := make([]string, len(r.ByDay))
+		for i, w := range r.ByDay {
+			ws[i] = w.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(ws, ","))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayNames[r.WeekStart])
+	}
+	return strings.Join(parts, ";")
+}
+
+// Date is a calendar date
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// DateLayout is the layout of the formatted dates
+const DateLayout = "2006-01-02"
+
+// DateOf returns the date of the time in its location
+func DateOf(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{y, m, d}
+}
+
+// ParseDate parses a date formatted as 2006-01-02
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(DateLayout, s)
+	if err != nil {
+		return Date{}, err
+	}
+	return DateOf(t), nil
+}
+
+func (d Date) String() string {
+	return d.time().Format(DateLayout)
+}
+
+// time returns the date at midnight UTC, where every day lasts 24 hours
+func (d Date) time() time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, time.UTC)
+}
+
+// AddDays adds n days to the date
+func (d Date) AddDays(n int) Date {
+	return DateOf(d.time().AddDate(0, 0, n))
+}
+
+// Before returns whether the date is before the other
+func (d Date) Before(o Date) bool {
+	return d.time().Before(o.time())
+}
+
+// Weekday returns the day of the week of the date
+func (d Date) Weekday() time.Weekday {
+	return d.time().Weekday()
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// maxEmptyPeriods is the number of consecutive periods without any
+// occurrence after which a rule is considered exhausted, so that the rules
+// which never occur (the 30th of February) end
+const maxEmptyPeriods = 3000
+
+// Each calls f with the occurrences of the rule starting on start (DTSTART),
+// in order, until f returns false or the rule ends. at gives the instant of
+// an occurrence on a date, UNTIL is compared to it. The occurrences before
+// from may be skipped, unless the rule has a COUNT which has to count them
+func (r *Rule) Each(start, from Date, at func(Date) time.Time, f func(Date, time.Time) bool) {
+	k := 0
+	if r.Count == 0 && start.Before(from) {
+		// Skip to the period before the one containing from
+		if k = r.periodsBetween(start, from)/r.Interval - 1; k < 0 {
+			k = 0
+		}
+	}
+	n, empty := 0, 0
+	for ; empty < maxEmptyPeriods; k++ {
+		dates := r.period(start, k*r.Interval)
+		if len(dates) == 0 {
+			empty++
+			continue
+		}
+		empty = 0
+		for _, d := range dates {
+			if d.Before(start) {
+				continue
+			}
+			t := at(d)
+			if !r.Until.IsZero() {
+				if r.UntilDate && r.Until.Before(d.time()) || !r.UntilDate && t.After(r.Until) {
+					return
+				}
+			}
+			if !f(d, t) {
+				return
+			}
+			if n++; r.Count != 0 && n >= r.Count {
+				return
+			}
+		}
+	}
+}
+
+// periodsBetween returns the number of periods of the rule's frequency
+// between the dates
+func (r *Rule) periodsBetween(a, b Date) int {
+	switch r.Freq {
+	case Daily:
+		return int(b.time().Sub(a.time()).Hours() / 24)
+	case Weekly:
+		return int(r.weekOf(b).time().Sub(r.weekOf(a).time()).Hours() / (24 * 7))
+	case Monthly:
+		return (b.Year-a.Year)*12 + int(b.Month) - int(a.Month)
+	default:
+		return b.Year - a.Year
+	}
+}
+
+// weekOf returns the first day of the week of the date
+func (r *Rule) weekOf(d Date) Date {
+	return d.AddDays(-((int(d.Weekday()) - int(r.WeekStart) + 7) % 7))
+}
+
+// period returns the sorted dates of the rule in the k-th period after the
+// one of start
+func (r *Rule) period(start Date, k int) []Date {
+	var dates []Date
+	switch r.Freq {
+	case Daily:
+		d := start.AddDays(k)
+		if r.inMonths(d.Month) && r.onMonthDay(d) && r.onWeekday(d) {
+			dates = append(dates, d)
+		}
+	case Weekly:
+		week := r.weekOf(start).AddDays(7 * k)
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []Weekday{{Day: start.Weekday()}}
+		}
+		for _, w := range days {
+			d := week.AddDays((int(w.Day) - int(r.WeekStart) + 7) % 7)
+			if r.inMonths(d.Month) {
+				dates = append(dates, d)
+			}
+		}
+	case Monthly:
+		month := time.Date(start.Year, start.Month+time.Month(k), 1, 0, 0, 0, 0, time.UTC)
+		if r.inMonths(month.Month()) {
+			dates = r.monthDates(start, month.Year(), month.Month())
+		}
+	case Yearly:
+		year := start.Year + k
+		switch {
+		case len(r.ByMonth) != 0:
+			for _, m := range r.ByMonth {
+				dates = append(dates, r.monthDates(start, year, m)...)
+			}
+		case len(r.ByMonthDay) != 0:
+			for m := time.January; m <= time.December; m++ {
+				dates = append(dates, r.monthDates(start, year, m)...)
+			}
+		case len(r.ByDay) != 0:
+			first := Date{year, time.January, 1}
+			dates = weekdaysIn(first, first.AddDays(daysInYear(year)-1), r.ByDay)
+		default:
+			if start.Day <= daysIn(year, start.Month) {
+				dates = append(dates, Date{year, start.Month, start.Day})
+			}
+		}
+	}
+	sort.Sort(byDate(dates))
+	return unique(dates)
+}
+
+// monthDates returns the dates of the rule in the month
+func (r *Rule) monthDates(start Date, year int, month time.Month) []Date {
+	n := daysIn(year, month)
+	first, last := Date{year, month, 1}, Date{year, month, n}
+	switch {
+	case len(r.ByMonthDay) != 0:
+		var dates []Date
+		for _, md := range r.ByMonthDay {
+			day := md
+			if md < 0 {
+				day = n + md + 1
+			}
+			if day < 1 || day > n {
+				continue
+			}
+			d := Date{year, month, day}
+			// BYDAY limits BYMONTHDAY, regardless of the ordinals
+			if r.onWeekday(d) {
+				dates = append(dates, d)
+			}
+		}
+		return dates
+	case len(r.ByDay) != 0:
+		return weekdaysIn(first, last, r.ByDay)
+	case start.Day <= n:
+		return []Date{{year, month, start.Day}}
+	default:
+		return nil
+	}
+}
+
+// weekdaysIn returns the dates in [first, last] matching the weekdays, the
+// ordinals count in the span
+func weekdaysIn(first, last Date, ws []Weekday) []Date {
+	var dates []Date
+	for _, w := range ws {
+		var matching []Date
+		d := first.AddDays((int(w.Day) - int(first.Weekday()) + 7) % 7)
+		for ; !last.Before(d); d = d.AddDays(7) {
+			matching = append(matching, d)
+		}
+		switch {
+		case w.N == 0:
+			dates = append(dates, matching...)
+		case w.N > 0 && w.N <= len(matching):
+			dates = append(dates, matching[w.N-1])
+		case w.N < 0 && -w.N <= len(matching):
+			dates = append(dates, matching[len(matching)+w.N])
+		}
+	}
+	return dates
+}
+
+func daysInYear(year int) int {
+	return int(Date{year + 1, time.January, 1}.time().Sub(Date{year, time.January, 1}.time()).Hours() / 24)
+}
+
+func (r *Rule) inMonths(m time.Month) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, bm := range r.ByMonth {
+		if bm == m {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) onMonthDay(d Date) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	n := daysIn(d.Year, d.Month)
+	for _, md := range r.ByMonthDay {
+		if md == d.Day || md < 0 && n+md+1 == d.Day {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) onWeekday(d Date) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, w := range r.ByDay {
+		if w.Day == d.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+type byDate []Date
+
+func (ds byDate) Len() int           { return len(ds) }
+func (ds byDate) Less(i, j int) bool { return ds[i].Before(ds[j]) }
+func (ds byDate) Swap(i, j int)      { ds[i], ds[j] = ds[j], ds[i] }
+
+// unique removes the repeated dates of the sorted list
+func unique(ds []Date) []Date {
+	if len(ds) < 2 {
+		return ds
+	}
+	res := ds[:1]
+	for _, d := range ds[1:] {
+		if d != res[len(res)-1] {
+			res = append(res, d)
+		}
+	}
+	return res
+}
@@ -0,0 +1,209 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rrule
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in string
+		// out is the canonical form, empty if the rule is invalid
+		out string
+	}{
+		{"RRULE:FREQ=DAILY", "FREQ=DAILY"},
+		{"freq=weekly;interval=2;byday=mo,th", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH"},
+		{"FREQ=MONTHLY;BYDAY=-1FR", "FREQ=MONTHLY;BYDAY=-1FR"},
+		{"BYDAY=2MO;FREQ=MONTHLY;INTERVAL=1", "FREQ=MONTHLY;BYDAY=2MO"},
+		{"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29", "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29"},
+		{"FREQ=DAILY;UNTIL=20160131", "FREQ=DAILY;UNTIL=20160131"},
+		{"FREQ=DAILY;UNTIL=20160131T120000Z", "FREQ=DAILY;UNTIL=20160131T120000Z"},
+		{"FREQ=WEEKLY;COUNT=3;WKST=SU", "FREQ=WEEKLY;COUNT=3;WKST=SU"},
+		{"", ""},
+		{"INTERVAL=2", ""},
+		{"FREQ=HOURLY", ""},
+		{"FREQ=DAILY;FREQ=WEEKLY", ""},
+		{"FREQ=DAILY;INTERVAL=0", ""},
+		{"FREQ=DAILY;COUNT=3;UNTIL=20160131", ""},
+		{"FREQ=DAILY;UNTIL=2016-01-31", ""},
+		{"FREQ=WEEKLY;BYDAY=1MO", ""},
+		{"FREQ=WEEKLY;BYMONTHDAY=1", ""},
+		{"FREQ=MONTHLY;BYDAY=6FR", ""},
+		{"FREQ=MONTHLY;BYDAY=0FR", ""},
+		{"FREQ=MONTHLY;BYMONTHDAY=32", ""},
+		{"FREQ=YEARLY;BYMONTH=13", ""},
+		{"FREQ=DAILY;BYSETPOS=1", ""},
+		{"FREQ=DAILY;WKST=XX", ""},
+	}
+	for _, test := range tests {
+		r, err := Parse(test.in)
+		switch {
+		case len(test.out) == 0 && err == nil:
+			t.Errorf("Parse(%q) = %s, want an error", test.in, r)
+		case len(test.out) != 0 && err != nil:
+			t.Errorf("Parse(%q): %s", test.in, err)
+		case len(test.out) != 0 && r.String() != test.out:
+			t.Errorf("Parse(%q) = %s, want %s", test.in, r, test.out)
+		}
+	}
+}
+
+func TestParseUntil(t *testing.T) {
+	r, err := Parse("FREQ=DAILY;UNTIL=20160131")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.UntilDate {
+		t.Errorf("UNTIL=20160131 is not a date")
+	}
+	r, err = Parse("FREQ=DAILY;UNTIL=20160131T120000Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2016, time.January, 31, 12, 0, 0, 0, time.UTC); r.UntilDate || !r.Until.Equal(want) {
+		t.Errorf("UNTIL=20160131T120000Z = %s (date %t), want the date-time %s", r.Until, r.UntilDate, want)
+	}
+}
+
+func date(t *testing.T, s string) Date {
+	d, err := ParseDate(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestEach(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start string
+		from  string
+		// hour is the UTC time of the occurrences
+		hour int
+		// want are the first occurrences on or after from, at most 5
+		want []string
+	}{
+		{
+			name: "every other week on Monday and Thursday",
+			rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", start: "2016-01-04", from: "2016-01-04",
+			want: []string{"2016-01-04", "2016-01-07", "2016-01-18", "2016-01-21", "2016-02-01"},
+		},
+		{
+			name: "last Friday of the month",
+			rule: "FREQ=MONTHLY;BYDAY=-1FR", start: "2016-01-01", from: "2016-01-01",
+			want: []string{"2016-01-29", "2016-02-26", "2016-03-25", "2016-04-29", "2016-05-27"},
+		},
+		{
+			name: "second Monday of the month",
+			rule: "FREQ=MONTHLY;BYDAY=2MO", start: "2016-01-01", from: "2016-01-01",
+			want: []string{"2016-01-11", "2016-02-08", "2016-03-14", "2016-04-11", "2016-05-09"},
+		},
+		{
+			name: "every Friday of the month",
+			rule: "FREQ=MONTHLY;BYDAY=FR;COUNT=5", start: "2016-01-01", from: "2016-01-01",
+			want: []string{"2016-01-01", "2016-01-08", "2016-01-15", "2016-01-22", "2016-01-29"},
+		},
+		{
+			name: "last Monday of May",
+			rule: "FREQ=YEARLY;BYMONTH=5;BYDAY=-1MO", start: "2016-01-01", from: "2016-01-01",
+			want: []string{"2016-05-30", "2017-05-29", "2018-05-28", "2019-05-27", "2020-05-25"},
+		},
+		{
+			name: "the 31st skips the shorter months",
+			rule: "FREQ=MONTHLY;BYMONTHDAY=31", start: "2016-01-01", from: "2016-01-01",
+			want: []string{"2016-01-31", "2016-03-31", "2016-05-31", "2016-07-31", "2016-08-31"},
+		},
+		{
+			name: "the last day of the month",
+			rule: "FREQ=MONTHLY;BYMONTHDAY=-1", start: "2016-01-01", from: "2016-01-01",
+			want: []string{"2016-01-31", "2016-02-29", "2016-03-31", "2016-04-30", "2016-05-31"},
+		},
+		{
+			name: "the 29th of February",
+			rule: "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29", start: "2016-01-01", from: "2016-01-01",
+			want: []string{"2016-02-29", "2020-02-29", "2024-02-29", "2028-02-29", "2032-02-29"},
+		},
+		{
+			name: "the 30th of February never occurs",
+			rule: "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", start: "2016-01-01", from: "2016-01-01",
+		},
+		{
+			name: "UNTIL a date includes the occurrences of that day",
+			rule: "FREQ=DAILY;UNTIL=20160103", start: "2016-01-01", from: "2016-01-01", hour: 23,
+			want: []string{"2016-01-01", "2016-01-02", "2016-01-03"},
+		},
+		{
+			name: "UNTIL a date-time excludes the later occurrences of that day",
+			rule: "FREQ=DAILY;UNTIL=20160103T120000Z", start: "2016-01-01", from: "2016-01-01", hour: 18,
+			want: []string{"2016-01-01", "2016-01-02"},
+		},
+		{
+			name: "UNTIL a date-time includes the earlier occurrences of that day",
+			rule: "FREQ=DAILY;UNTIL=20160103T120000Z", start: "2016-01-01", from: "2016-01-01", hour: 9,
+			want: []string{"2016-01-01", "2016-01-02", "2016-01-03"},
+		},
+		{
+			name: "COUNT counts the occurrences before from",
+			rule: "FREQ=DAILY;COUNT=3", start: "2016-01-01", from: "2016-01-02",
+			want: []string{"2016-01-02", "2016-01-03"},
+		},
+		{
+			name: "COUNT is exhausted before from",
+			rule: "FREQ=WEEKLY;COUNT=3", start: "2016-01-01", from: "2016-06-01",
+		},
+		{
+			name: "from long after the start",
+			rule: "FREQ=WEEKLY;BYDAY=WE", start: "2016-01-01", from: "2016-06-01",
+			want: []string{"2016-06-01", "2016-06-08", "2016-06-15", "2016-06-22", "2016-06-29"},
+		},
+		{
+			name: "from long after the start of an interval",
+			rule: "FREQ=DAILY;INTERVAL=10", start: "2016-01-01", from: "2016-12-25",
+			want: []string{"2016-12-26", "2017-01-05", "2017-01-15", "2017-01-25", "2017-02-04"},
+		},
+		{
+			name: "no occurrence before the start",
+			rule: "FREQ=MONTHLY;BYMONTHDAY=1,15", start: "2016-01-10", from: "2016-01-01",
+			want: []string{"2016-01-15", "2016-02-01", "2016-02-15", "2016-03-01", "2016-03-15"},
+		},
+	}
+	for _, test := range tests {
+		r, err := Parse(test.rule)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		from := date(t, test.from)
+		var got []string
+		at := func(d Date) time.Time {
+			return time.Date(d.Year, d.Month, d.Day, test.hour, 0, 0, 0, time.UTC)
+		}
+		r.Each(date(t, test.start), from, at, func(d Date, at time.Time) bool {
+			if d.Before(from) {
+				return true
+			}
+			got = append(got, d.String())
+			return len(got) < 5
+		})
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: %s from %s = %s, want %s", test.name, test.rule, test.from,
+				strings.Join(got, " "), strings.Join(test.want, " "))
+		}
+	}
+}
@@ -0,0 +1,324 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The ranking parameters: the name weighs more than the description, a
+// word beginning with a query word counts less than the query word itself
+// and the frequency of a word saturates as in BM25
+const (
+	nameBoost   = 2.0
+	prefixMatch = 0.5
+	saturation  = 1.2
+	lengthNorm  = 0.75
+)
+
+// fields are the searched fields and their boost
+var fields = []struct {
+	name  string
+	boost float64
+}{
+	{FieldName, nameBoost},
+	{FieldDescription, 1},
+}
+
+// entry is an indexed document
+type entry struct {
+	doc Document
+	// frequencies are the frequencies of the words of every field
+	frequencies map[string]map[string]int
+	// lengths are the number of words of every field
+	lengths map[string]int
+}
+
+func newEntry(doc Document) *entry {
+	e := &entry{
+		doc: doc,
+		frequencies: map[string]map[string]int{
+			FieldName:        make(map[string]int),
+			FieldDescription: make(map[string]int),
+		},
+		lengths: make(map[string]int),
+	}
+	for field, text := range map[string]string{
+		FieldName:        doc.Name,
+		FieldDescription: doc.Description,
+	} {
+		for _, t := range tokenize(text) {
+			e.frequencies[field][t.word]++
+			e.lengths[field]++
+		}
+	}
+	return e
+}
+
+// corpus holds the documents of a user
+type corpus struct {
+	docs map[string]*entry
+	// postings lists the documents every word is in
+	postings map[string]map[string]bool
+	// lengths are the number of words of every field in all the documents
+	lengths map[string]int
+}
+
+func (c *corpus) add(e *entry) {
+	c.docs[e.doc.ID] = e
+	for field, fs := range e.frequencies {
+		for w := range fs {
+			if c.postings[w] == nil {
+				c.postings[w] = make(map[string]bool)
+			}
+			c.postings[w][e.doc.ID] = true
+		}
+		c.lengths[field] += e.lengths[field]
+	}
+}
+
+func (c *corpus) remove(e *entry) {
+	delete(c.docs, e.doc.ID)
+	for field, fs := range e.frequencies {
+		for w := range fs {
+			delete(c.postings[w], e.doc.ID)
+			if len(c.postings[w]) == 0 {
+				delete(c.postings, w)
+			}
+		}
+		c.lengths[field] -= e.lengths[field]
+	}
+}
+
+// Memory is an Index held in memory. It is built from the store when the
+// process starts and kept in sync with the mutations, each process of the
+// server holds its own
+type Memory struct {
+	mu      sync.RWMutex
+	docs    map[string]*entry
+	corpora map[string]*corpus
+}
+
+// NewMemory creates an empty index
+func NewMemory() *Memory {
+	return &Memory{
+		docs:    make(map[string]*entry),
+		corpora: make(map[string]*corpus),
+	}
+}
+
+// Put implements Index
+func (m *Memory) Put(doc Document) error {
+	e := newEntry(doc)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.delete(doc.ID)
+	c, ok := m.corpora[doc.Owner]
+	if !ok {
+		c = &corpus{
+			docs:     make(map[string]*entry),
+			postings: make(map[string]map[string]bool),
+			lengths:  make(map[string]int),
+		}
+		m.corpora[doc.Owner] = c
+	}
+	c.add(e)
+	m.docs[doc.ID] = e
+	return nil
+}
+
+// Replace implements Index, the new index is built before it is swapped in
+func (m *Memory) Replace(docs []Document) error {
+	fresh := NewMemory()
+	for _, doc := range docs {
+		if err := fresh.Put(doc); err != nil {
+			return err
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.docs, m.corpora = fresh.docs, fresh.corpora
+	return nil
+}
+
+// Delete implements Index
+func (m *Memory) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.delete(id)
+	return nil
+}
+
+// DeleteGoal implements Index
+func (m *Memory) DeleteGoal(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.docs[id]
+	if !ok {
+		return nil
+	}
+	for _, a := range m.corpora[e.doc.Owner].docs {
+		if a.doc.Goal == id {
+			m.delete(a.doc.ID)
+		}
+	}
+	return nil
+}
+
+// delete removes the document, m.mu is held
+func (m *Memory) delete(id string) {
+	e, ok := m.docs[id]
+	if !ok {
+		return
+	}
+	c := m.corpora[e.doc.Owner]
+	c.remove(e)
+	if len(c.docs) == 0 {
+		delete(m.corpora, e.doc.Owner)
+	}
+	delete(m.docs, id)
+}
+
+// Search implements Index
+func (m *Memory) Search(q Query) (*Results, error) {
+	query := words(q.Text)
+	res := &Results{Hits: []Hit{}}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	c, ok := m.corpora[q.Owner]
+	if !ok || len(query) == 0 {
+		return res, nil
+	}
+	// scores are the scores of the documents, matched counts the query
+	// words every document matched
+	scores := make(map[string]float64)
+	matched := make(map[string]int)
+	n := float64(len(c.docs))
+	for _, qw := range query {
+		scored := make(map[string]bool)
+		for w, docs := range c.postings {
+			if !strings.HasPrefix(w, qw) {
+				continue
+			}
+			weight := 1.0
+			if w != qw {
+				weight = prefixMatch
+			}
+			idf := math.Log(1 + (n-float64(len(docs))+0.5)/(float64(len(docs))+0.5))
+			for id := range docs {
+				e := c.docs[id]
+				if !q.accepts(e.doc) {
+					continue
+				}
+				scores[id] += weight * idf * c.score(e, w)
+				if !scored[id] {
+					scored[id] = true
+					matched[id]++
+				}
+			}
+		}
+	}
+	for id, count := range matched {
+		if count != len(query) {
+			continue
+		}
+		e := c.docs[id]
+		h := Hit{
+			Kind:       e.doc.Kind,
+			ID:         e.doc.ID,
+			Goal:       e.doc.Goal,
+			Type:       e.doc.Type,
+			Status:     e.doc.Status,
+			Name:       e.doc.Name,
+			Score:      scores[id],
+			Highlights: make(map[string]string),
+		}
+		if s, ok := highlight(e.doc.Name, query); ok {
+			h.Highlights[FieldName] = s
+		}
+		if s, ok := highlight(e.doc.Description, query); ok {
+			h.Highlights[FieldDescription] = s
+		}
+		res.Hits = append(res.Hits, h)
+	}
+	sort.Sort(byScore(res.Hits))
+	res.Total = len(res.Hits)
+	res.Hits = page(res.Hits, q.Limit, q.Offset)
+	return res, nil
+}
+
+// score scores the frequency of the word in the fields of the document
+func (c *corpus) score(e *entry, w string) float64 {
+	var s float64
+	for _, f := range fields {
+		tf := float64(e.frequencies[f.name][w])
+		if tf == 0 {
+			continue
+		}
+		avg := float64(c.lengths[f.name]) / float64(len(c.docs))
+		norm := 1 - lengthNorm
+		if avg > 0 {
+			norm += lengthNorm * float64(e.lengths[f.name]) / avg
+		}
+		s += f.boost * tf * (saturation + 1) / (tf + saturation*norm)
+	}
+	return s
+}
+
+// accepts returns whether the document passes the filters of the query
+func (q Query) accepts(doc Document) bool {
+	switch {
+	case q.Viewer != nil && !q.Viewer.Sees(doc):
+		return false
+	case len(q.Kind) != 0 && doc.Kind != q.Kind:
+		return false
+	case len(q.Status) != 0 && doc.Status != q.Status:
+		return false
+	case len(q.Type) != 0 && doc.Type != q.Type:
+		return false
+	}
+	return true
+}
+
+func page(hits []Hit, limit, offset int) []Hit {
+	if offset < 0 {
+		offset = 0
+	} else if offset > len(hits) {
+		offset = len(hits)
+	}
+	hits = hits[offset:]
+	if limit > 0 && limit < len(hits) {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// byScore sorts the hits by decreasing score, then by name
+type byScore []Hit
+
+func (h byScore) Len() int      { return len(h) }
+func (h byScore) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h byScore) Less(i, j int) bool {
+	if h[i].Score != h[j].Score {
+		return h[i].Score > h[j].Score
+	}
+	if h[i].Name != h[j].Name {
+		return h[i].Name < h[j].Name
+	}
+	return h[i].ID < h[j].ID
+}
@@ -0,0 +1,191 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// goal is a public goal of alice
+func goal(id, name, description string) Document {
+	return Document{ID: id, Kind: KindGoal, Owner: "alice", Goal: id, Access: AccessPublic,
+		Name: name, Description: description}
+}
+
+// ids lists the IDs of the hits in order
+func ids(res *Results) []string {
+	ids := []string{}
+	for _, h := range res.Hits {
+		ids = append(ids, h.ID)
+	}
+	return ids
+}
+
+func search(t *testing.T, idx Index, q Query) *Results {
+	res, err := idx.Search(q)
+	if err != nil {
+		t.Fatalf("Search(%+v) error = %v", q, err)
+	}
+	return res
+}
+
+func TestSearchRanking(t *testing.T) {
+	bob := goal("b", "Run", "")
+	bob.Owner = "bob"
+	tests := []struct {
+		name string
+		docs []Document
+		text string
+		want []string
+	}{
+		{"word over prefix", []Document{goal("p", "Running", ""), goal("w", "Run", "")}, "run", []string{"w", "p"}},
+		{"name over description", []Document{goal("d", "Pool", "Swim"), goal("n", "Swim", "Pool")}, "swim", []string{"n", "d"}},
+		{"shorter name", []Document{goal("l", "Run along the river", ""), goal("s", "Run daily", "")}, "run", []string{"s", "l"}},
+		{"every word", []Document{goal("a", "Run fast", ""), goal("b", "Run slow", ""), goal("c", "Walk fast", "")}, "run fast", []string{"a"}},
+		{"prefix of every word", []Document{goal("a", "Running shoes", ""), goal("b", "Shoes", "")}, "sho RUN", []string{"a"}},
+		{"word in the description", []Document{goal("a", "Marathon", "run 42km"), goal("b", "Swim", "")}, "42", []string{"a"}},
+		{"no prefix of the query", []Document{goal("a", "Run", "")}, "running", []string{}},
+		{"no words", []Document{goal("a", "Run", "")}, " -- ", []string{}},
+		{"ties by name then ID", []Document{goal("c", "Run", ""), goal("b", "Run", ""), goal("a", "Run", "")}, "run", []string{"a", "b", "c"}},
+		{"other owner", []Document{goal("a", "Swim", ""), bob}, "run", []string{}},
+	}
+	for _, test := range tests {
+		m := NewMemory()
+		for _, doc := range test.docs {
+			m.Put(doc)
+		}
+		res := search(t, m, Query{Owner: "alice", Text: test.text})
+		if got := ids(res); !reflect.DeepEqual(got, test.want) || res.Total != len(test.want) {
+			t.Errorf("%s: hits %v of %d, want %v", test.name, got, res.Total, test.want)
+		}
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	docs := []Document{
+		{ID: "g1", Kind: KindGoal, Owner: "alice", Goal: "g1", Access: AccessPublic, Name: "Run"},
+		{ID: "a1", Kind: KindAchievable, Owner: "alice", Goal: "g1", Access: AccessPublic, Type: TypeHabit, Status: "NOT_DONE", Name: "Run"},
+		{ID: "a2", Kind: KindAchievable, Owner: "alice", Goal: "g1", Access: AccessPublic, Type: TypeTask, Status: "DONE", Name: "Run"},
+		{ID: "g2", Kind: KindGoal, Owner: "alice", Goal: "g2", Access: AccessFollowers, Name: "Run"},
+		{ID: "g3", Kind: KindGoal, Owner: "alice", Goal: "g3", Access: AccessWorkspace, Workspace: "w1", Name: "Run"},
+		{ID: "g4", Kind: KindGoal, Owner: "alice", Goal: "g4", Name: "Run"},
+		{ID: "a4", Kind: KindAchievable, Owner: "alice", Goal: "g4", Type: TypeTask, Status: "DONE", Name: "Run"},
+	}
+	m := NewMemory()
+	for _, doc := range docs {
+		m.Put(doc)
+	}
+	tests := []struct {
+		name string
+		q    Query
+		want []string
+	}{
+		{"owner", Query{}, []string{"a1", "a2", "a4", "g1", "g2", "g3", "g4"}},
+		{"goals", Query{Kind: KindGoal}, []string{"g1", "g2", "g3", "g4"}},
+		{"habits", Query{Type: TypeHabit}, []string{"a1"}},
+		{"done", Query{Status: "DONE"}, []string{"a2", "a4"}},
+		{"done tasks", Query{Kind: KindAchievable, Type: TypeTask, Status: "DONE"}, []string{"a2", "a4"}},
+		{"anonymous", Query{Viewer: &Viewer{}}, []string{"a1", "a2", "g1"}},
+		{"follower", Query{Viewer: &Viewer{Following: true}}, []string{"a1", "a2", "g1", "g2"}},
+		{"member", Query{Viewer: &Viewer{Workspaces: []string{"w2", "w1"}}}, []string{"a1", "a2", "g1", "g3"}},
+		{"other workspace", Query{Viewer: &Viewer{Workspaces: []string{"w2"}}}, []string{"a1", "a2", "g1"}},
+		{"shared", Query{Viewer: &Viewer{Shared: []string{"g4"}}}, []string{"a1", "a2", "a4", "g1", "g4"}},
+		{"shared done", Query{Viewer: &Viewer{Shared: []string{"g4"}}, Status: "DONE"}, []string{"a2", "a4"}},
+	}
+	for _, test := range tests {
+		test.q.Owner, test.q.Text = "alice", "run"
+		got := ids(search(t, m, test.q))
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: hits %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSearchPaging(t *testing.T) {
+	m := NewMemory()
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		m.Put(goal(id, "Run", ""))
+	}
+	tests := []struct {
+		limit, offset int
+		want          []string
+	}{
+		{0, 0, []string{"a", "b", "c", "d", "e"}},
+		{2, 0, []string{"a", "b"}},
+		{2, 2, []string{"c", "d"}},
+		{2, 4, []string{"e"}},
+		{2, 9, []string{}},
+		{0, 3, []string{"d", "e"}},
+		{9, -1, []string{"a", "b", "c", "d", "e"}},
+	}
+	for _, test := range tests {
+		res := search(t, m, Query{Owner: "alice", Text: "run", Limit: test.limit, Offset: test.offset})
+		if got := ids(res); !reflect.DeepEqual(got, test.want) || res.Total != 5 {
+			t.Errorf("limit %d, offset %d: hits %v of %d, want %v of 5", test.limit, test.offset, got, res.Total, test.want)
+		}
+	}
+}
+
+func TestMemoryUpdates(t *testing.T) {
+	achievable := func(id, goal, name string) Document {
+		return Document{ID: id, Kind: KindAchievable, Owner: "alice", Goal: goal, Access: AccessPublic, Name: name}
+	}
+	m := NewMemory()
+	for _, doc := range []Document{
+		goal("g1", "Run", ""), achievable("a1", "g1", "Run"), achievable("a2", "g1", "Swim"),
+		goal("g2", "Swim", ""), achievable("a3", "g2", "Run"),
+	} {
+		m.Put(doc)
+	}
+	tests := []struct {
+		name   string
+		update func()
+		run    []string
+		swim   []string
+	}{
+		{"put", func() {}, []string{"a1", "a3", "g1"}, []string{"a2", "g2"}},
+		{"put again", func() { m.Put(achievable("a1", "g1", "Swim")) }, []string{"a3", "g1"}, []string{"a1", "a2", "g2"}},
+		{"delete", func() { m.Delete("a2") }, []string{"a3", "g1"}, []string{"a1", "g2"}},
+		{"delete a missing one", func() { m.Delete("a2") }, []string{"a3", "g1"}, []string{"a1", "g2"}},
+		{"delete a goal", func() { m.DeleteGoal("g1") }, []string{"a3"}, []string{"g2"}},
+		{"replace", func() { m.Replace([]Document{goal("g3", "Run", "")}) }, []string{"g3"}, []string{}},
+	}
+	for _, test := range tests {
+		test.update()
+		for text, want := range map[string][]string{"run": test.run, "swim": test.swim} {
+			got := ids(search(t, m, Query{Owner: "alice", Text: text}))
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: %q hits %v, want %v", test.name, text, got, want)
+			}
+		}
+	}
+}
+
+func TestSearchHighlights(t *testing.T) {
+	m := NewMemory()
+	m.Put(goal("g", "Run <fast>", "Running & walking"))
+	res := search(t, m, Query{Owner: "alice", Text: "run"})
+	want := map[string]string{
+		FieldName:        "<mark>Run</mark> &lt;fast&gt;",
+		FieldDescription: "<mark>Running</mark> &amp; walking",
+	}
+	if len(res.Hits) != 1 || !reflect.DeepEqual(res.Hits[0].Highlights, want) {
+		t.Errorf("hits %+v, want the highlights %v", res.Hits, want)
+	}
+}
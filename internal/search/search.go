@@ -0,0 +1,144 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package search indexes the text of the users' goals and achievables and
+// ranks them against queries
+package search
+
+// The kinds of the documents
+const (
+	KindGoal       = "goal"
+	KindAchievable = "achievable"
+)
+
+// The types of the achievables, the goals have none
+const (
+	TypeHabit = "habit"
+	TypeTask  = "task"
+)
+
+// The accesses of the documents, who can see them beside the owner and the
+// users their goal is shared with. The private documents have none
+const (
+	AccessPublic    = "public"
+	AccessFollowers = "followers"
+	AccessWorkspace = "workspace"
+)
+
+// The searched fields of the documents
+const (
+	FieldName        = "name"
+	FieldDescription = "description"
+)
+
+// Document is a goal or an achievable as it is indexed
+type Document struct {
+	// ID is the ID of the goal or the achievable, unique across kinds
+	ID    string
+	Kind  string
+	Owner string
+	// Goal is the ID of the goal of an achievable, the ID of a goal
+	Goal   string
+	Type   string
+	Status string
+	// Access tells who can see the document, the one of its goal
+	Access string
+	// Workspace is the ID of the workspace of the goal, if any
+	Workspace   string
+	Name        string
+	Description string
+}
+
+// Query is a search of a user's documents
+type Query struct {
+	Owner string
+	// Text is the searched text, every word of it must match a word of the
+	// documents or be the beginning of one
+	Text string
+	// Kind, Status and Type filter the documents, if set
+	Kind   string
+	Status string
+	Type   string
+	// Viewer leaves out the documents the user searching cannot see, if
+	// set. The owner sees every document
+	Viewer *Viewer
+	Limit  int
+	Offset int
+}
+
+// Viewer is a user searching the documents of another user, the anonymous
+// users are the viewers with nothing shared, followed or joined
+type Viewer struct {
+	// Shared are the IDs of the goals shared with the viewer
+	Shared []string
+	// Following tells whether the viewer follows the owner
+	Following bool
+	// Workspaces are the IDs of the viewer's workspaces
+	Workspaces []string
+}
+
+// Sees returns whether the viewer can see the document
+func (v *Viewer) Sees(doc Document) bool {
+	switch {
+	case doc.Access == AccessPublic,
+		doc.Access == AccessFollowers && v.Following,
+		doc.Access == AccessWorkspace && contains(v.Workspaces, doc.Workspace):
+		return true
+	}
+	return contains(v.Shared, doc.Goal)
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Hit is a document matching a query
+type Hit struct {
+	Kind   string  `json:"kind"`
+	ID     string  `json:"id"`
+	Goal   string  `json:"goal"`
+	Type   string  `json:"type,omitempty"`
+	Status string  `json:"status"`
+	Name   string  `json:"name"`
+	Score  float64 `json:"score"`
+	// Highlights are the matching fields, HTML escaped, with the matching
+	// words wrapped in <mark> elements
+	Highlights map[string]string `json:"highlights"`
+}
+
+// Results are the page of the hits of a query, the best first
+type Results struct {
+	// Total is the number of hits of every page
+	Total int   `json:"total"`
+	Hits  []Hit `json:"hits"`
+}
+
+// Index indexes the documents and searches them
+type Index interface {
+	// Put indexes the document, replacing the document with its ID
+	Put(Document) error
+	// Delete removes the document with the ID, if any
+	Delete(id string) error
+	// DeleteGoal removes the goal with the ID and its achievables
+	DeleteGoal(id string) error
+	// Replace replaces every indexed document with the documents at once
+	Replace([]Document) error
+	// Search searches the documents
+	Search(Query) (*Results, error)
+}
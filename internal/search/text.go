@@ -0,0 +1,98 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"bytes"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// token is a word of a text and its byte offsets
+type token struct {
+	word       string
+	start, end int
+}
+
+// isWordRune returns whether the rune belongs to words
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// tokenize splits the text in lower case words
+func tokenize(text string) []token {
+	var ts []token
+	start := -1
+	for i, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			ts = append(ts, token{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		ts = append(ts, token{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return ts
+}
+
+// words returns the distinct words of the query text
+func words(text string) []string {
+	var ws []string
+	seen := make(map[string]bool)
+	for _, t := range tokenize(text) {
+		if !seen[t.word] {
+			seen[t.word] = true
+			ws = append(ws, t.word)
+		}
+	}
+	return ws
+}
+
+// matches returns whether the word of a document matches a word of the
+// query: it is the word or begins with it
+func matches(word string, query []string) bool {
+	for _, q := range query {
+		if strings.HasPrefix(word, q) {
+			return true
+		}
+	}
+	return false
+}
+
+// highlight escapes the text and wraps its words matching the query in
+// <mark> elements. It returns whether any word matched
+func highlight(text string, query []string) (string, bool) {
+	var buf bytes.Buffer
+	last, marked := 0, false
+	for _, t := range tokenize(text) {
+		if !matches(t.word, query) {
+			continue
+		}
+		buf.WriteString(html.EscapeString(text[last:t.start]))
+		buf.WriteString("<mark>")
+		buf.WriteString(html.EscapeString(text[t.start:t.end]))
+		buf.WriteString("</mark>")
+		last, marked = t.end, true
+	}
+	buf.WriteString(html.EscapeString(text[last:]))
+	return buf.String(), marked
+}
@@ -17,15 +17,30 @@ package server
 import (
 	"fmt"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/iocat/donit/handler"
+	"github.com/iocat/donit/internal/achieving/apispec"
+	"github.com/iocat/donit/internal/achieving/indexer"
+	"github.com/iocat/donit/internal/achieving/notifier"
+	"github.com/iocat/donit/internal/achieving/reminders"
+	"github.com/iocat/donit/internal/events"
+	"github.com/iocat/donit/internal/idempotency"
+	"github.com/iocat/donit/internal/logger"
+	"github.com/iocat/donit/internal/metrics"
+	"github.com/iocat/donit/internal/notify"
+	"github.com/iocat/donit/internal/search"
+	"gopkg.in/mgo.v2"
 )
 
 type serverBuilder struct {
 	Server
 	conf Config
+	log  *logger.Logger
+	db   *mgo.Database
+	bus  *events.Bus
 	err  error
 }
 
@@ -36,40 +51,158 @@ func (sb *serverBuilder) build() (*Server, error) {
 	return &sb.Server, nil
 }
 
-// setupRouter sets up the router with a prefedefined path
+// logger sets up the structured logger of the server
+func (sb *serverBuilder) logger() *serverBuilder {
+	level := logger.LevelInfo
+	if len(sb.conf.LogLevel) != 0 {
+		var err error
+		if level, err = logger.ParseLevel(sb.conf.LogLevel); err != nil {
+			sb.err = err
+			return sb
+		}
+	}
+	switch sb.conf.LogFormat {
+	case "", logger.FormatLogfmt, logger.FormatJSON:
+	default:
+		sb.err = fmt.Errorf("unknown log format %q", sb.conf.LogFormat)
+		return sb
+	}
+	sb.log = logger.New(os.Stdout, sb.conf.LogFormat, level)
+	return sb
+}
+
+// database connects to the database and sets up the handlers' resources
+func (sb *serverBuilder) database() *serverBuilder {
+	if sb.err != nil {
+		return sb
+	}
+	session, err := mgo.DialWithTimeout(sb.conf.DBURL, 5*time.Second)
+	if err != nil {
+		sb.err = fmt.Errorf("connect to the database: %s", err)
+		return sb
+	}
+	sb.db = session.DB(sb.conf.DBName)
+	sb.bus = events.NewBus()
+	if err = handler.Setup(sb.db, sb.bus); err != nil {
+		sb.err = fmt.Errorf("set up the handlers: %s", err)
+	}
+	return sb
+}
+
+// notifications sets up the notification channels, the delivery queue and
+// the reminder scheduler feeding it, and the notifier filling the inboxes
+// with the events of the store
+func (sb *serverBuilder) notifications() *serverBuilder {
+	if sb.err != nil {
+		return sb
+	}
+	hub := notify.NewHub()
+	inbox, err := notify.NewInbox(sb.db.C(notify.InboxCollection), hub, sb.conf.NotificationRetention)
+	if err != nil {
+		sb.err = fmt.Errorf("set up the notification inbox: %s", err)
+		return sb
+	}
+	handler.SetupNotifications(inbox, hub)
+	sb.notifier = notifier.New(inbox, handler.Goal.Collection(), handler.Follows(), handler.Workspaces(),
+		sb.log.With("component", "notifier"))
+	sb.bus.Subscribe(sb.notifier.Handle)
+
+	channels := map[string]notify.Channel{
+		notify.ChannelWebhook: notify.NewWebhook(sb.conf.WebhookTimeout),
+		notify.ChannelInApp:   inbox,
+	}
+	// Without a SMTP server the email deliveries fail and end up dead
+	// lettered, which shows in their delivery log
+	if len(sb.conf.SMTPAddr) != 0 {
+		channels[notify.ChannelEmail] = notify.NewSMTP(sb.conf.SMTPAddr,
+			sb.conf.SMTPFrom, sb.conf.SMTPUsername, sb.conf.SMTPPassword)
+	}
+	queue, err := notify.NewQueue(sb.db.C(notify.DeliveryCollection), channels,
+		sb.conf.DeliveryAttempts, sb.conf.DeliveryBackoff)
+	if err != nil {
+		sb.err = fmt.Errorf("set up the notification queue: %s", err)
+		return sb
+	}
+	sb.reminders, err = reminders.New(handler.User.Collection(), handler.Goal.Collection(),
+		handler.Achievable.Collection(), queue,
+		sb.conf.ReminderInterval, sb.conf.ReminderLookback, sb.log.With("component", "reminders"))
+	if err != nil {
+		sb.err = fmt.Errorf("set up the reminders: %s", err)
+	}
+	return sb
+}
+
+// search sets up the search index and the indexer keeping it in sync with
+// the events of the store
+func (sb *serverBuilder) search() *serverBuilder {
+	if sb.err != nil {
+		return sb
+	}
+	index := search.NewMemory()
+	handler.SetupSearch(index)
+	sb.indexer = indexer.New(index, handler.Goal.Collection(), handler.Achievable.Collection(),
+		sb.conf.SearchRebuildInterval, sb.log.With("component", "indexer"))
+	sb.bus.Subscribe(sb.indexer.Handle)
+	return sb
+}
+
+// idempotencyKeys sets up the store of the idempotency keys replaying the
+// responses to the retried requests
+func (sb *serverBuilder) idempotencyKeys() *serverBuilder {
+	if sb.err != nil || sb.conf.IdempotencyRetention <= 0 {
+		return sb
+	}
+	keys, err := idempotency.NewStore(sb.db.C(idempotency.Collection), sb.conf.IdempotencyRetention)
+	if err != nil {
+		sb.err = fmt.Errorf("set up the idempotency keys: %s", err)
+		return sb
+	}
+	handler.SetupIdempotency(keys)
+	return sb
+}
+
+// setupRouter sets up the router and the OpenAPI document from the route
+// table
 func (sb *serverBuilder) router() *serverBuilder {
+	if sb.err != nil {
+		return sb
+	}
 	sb.r = mux.NewRouter()
 	// Set up a handler dispatcher
 	common := sb.r
-	common.NotFoundHandler = handler.NotFound
-	// Authentication
-	common.HandleFunc(fmt.Sprintf("%s/auth", handler.User.URL()), handler.Auth).Methods("GET")
-	common.HandleFunc(fmt.Sprintf("%s/auth", handler.User.URL()), handler.PasswordChange).Methods("PUT")
-	// User CRUD
-	common.HandleFunc(handler.User.BaseURL(), handler.CreateUser).Methods("POST")
-	common.HandleFunc(handler.User.URL(), handler.DeleteUser).Methods("DELETE")
-	common.HandleFunc(handler.User.URL(), handler.UpdateUser).Methods("PUT")
-	common.HandleFunc(handler.User.URL(), handler.ReadUser).Methods("GET")
-	// Goal CRUD
-	common.HandleFunc(handler.Goal.BaseURL(), handler.CreateGoal).Methods("POST")
-	common.HandleFunc(handler.Goal.BaseURL(), handler.AllGoals).Methods("GET")
-	common.HandleFunc(handler.Goal.URL(), handler.DeleteGoal).Methods("DELETE")
-	common.HandleFunc(handler.Goal.URL(), handler.UpdateGoal).Methods("PUT")
-	common.HandleFunc(handler.Goal.URL(), handler.ReadGoal).Methods("GET")
-	// Achievable CRUD
-	common.HandleFunc(handler.Achievable.BaseURL(), handler.CreateAchievable).Methods("POST")
-	common.HandleFunc(handler.Achievable.BaseURL(), handler.AllAchievables).Methods("GET")
-	common.HandleFunc(handler.Achievable.URL(), handler.DeleteAchievable).Methods("DELETE")
-	common.HandleFunc(handler.Achievable.URL(), handler.UpdateAchievable).Methods("PUT")
+	common.NotFoundHandler = instrumented(unmatchedRoute, accessLogged(unmatchedRoute, handler.NotFound))
 
+	doc := new(apispec.Document)
+	table := routes(doc)
+	specs := make([]apispec.Route, 0, len(table))
+	for _, rt := range table {
+		// register the handler instrumented and logged under its route
+		// template, the retries of the creations are answered with the
+		// response of the first request. Its panics are recovered within
+		// so that their internal server errors are measured and logged
+		h := rt.handler
+		if rt.Method == http.MethodPost {
+			h = handler.Idempotent(h)
+		}
+		common.Handle(rt.Path, instrumented(rt.Path, accessLogged(rt.Path, recovered(h)))).Methods(rt.Method)
+		specs = append(specs, rt.Route)
+	}
+	generated, err := apispec.Generate(apiInfo, specs)
+	if err != nil {
+		sb.err = err
+		return sb
+	}
+	*doc = *generated
 	return sb
 }
 
 // http sets up the http server
 // TODO: add TLS layer for production usage
 func (sb *serverBuilder) http() *serverBuilder {
+	// the routes recover their own panics, the outer recovery catches the
+	// ones of the authentication
 	sb.httpServer = &http.Server{
-		Handler:      sb.r,
+		Handler:      withRequestID(sb.log, recovered(handler.Authenticate(sb.r))),
 		Addr:         fmt.Sprintf("%s:%d", sb.conf.Domain, sb.conf.Port),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
@@ -77,3 +210,20 @@ func (sb *serverBuilder) http() *serverBuilder {
 	sb.httpServer.SetKeepAlivesEnabled(false)
 	return sb
 }
+
+// metrics sets up the listener exposing the metrics, it is kept apart from
+// the API so that it can be bound to a private interface
+func (sb *serverBuilder) metrics() *serverBuilder {
+	if len(sb.conf.MetricsAddr) == 0 {
+		return sb
+	}
+	serveMux := http.NewServeMux()
+	serveMux.Handle("/metrics", metrics.DefaultRegistry)
+	sb.metricsServer = &http.Server{
+		Handler:      serveMux,
+		Addr:         sb.conf.MetricsAddr,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	return sb
+}
@@ -14,12 +14,32 @@
 
 package server
 
+import "time"
+
 // DefaultConfig is server default configuration
 var DefaultConfig = Config{
 	Domain: "127.0.0.1",
 	Port:   5088,
 	DBURL:  "localhost",
 	DBName: "donit",
+
+	MetricsAddr: "127.0.0.1:9088",
+
+	LogFormat: "logfmt",
+	LogLevel:  "info",
+
+	ReminderInterval: 30 * time.Second,
+	ReminderLookback: time.Hour,
+	DeliveryAttempts: 5,
+	DeliveryBackoff:  time.Minute,
+	WebhookTimeout:   5 * time.Second,
+	SMTPFrom:         "donit@localhost",
+
+	NotificationRetention: 90 * 24 * time.Hour,
+
+	SearchRebuildInterval: 10 * time.Minute,
+
+	IdempotencyRetention: 24 * time.Hour,
 }
 
 // Config represents a server configuration structure
@@ -32,4 +52,50 @@ type Config struct {
 	DBURL string
 	// The database name
 	DBName string
+
+	// MetricsAddr is the address ([host]:port) of the separate listener
+	// serving the Prometheus metrics at /metrics. Empty disables it
+	MetricsAddr string
+
+	// LogFormat is the format of the log records, either logfmt or json
+	LogFormat string
+	// LogLevel is the minimum level (debug, info or error) of the logged
+	// records
+	LogLevel string
+
+	// ReminderInterval is how often the due reminders are checked
+	ReminderInterval time.Duration
+	// ReminderLookback is how late a reminder is still delivered, the
+	// reminders which came due earlier while the server was down are
+	// skipped
+	ReminderLookback time.Duration
+	// DeliveryAttempts is the number of attempts of a notification delivery
+	// before it is dead-lettered
+	DeliveryAttempts int
+	// DeliveryBackoff is the delay before retrying a failed delivery, it
+	// doubles every attempt
+	DeliveryBackoff time.Duration
+	// WebhookTimeout is the timeout of the webhook requests
+	WebhookTimeout time.Duration
+
+	// SMTPAddr is the address (host:port) of the SMTP server delivering the
+	// email notifications. Empty disables the email channel
+	SMTPAddr     string
+	SMTPFrom     string
+	SMTPUsername string
+	SMTPPassword string
+
+	// NotificationRetention is how long the notifications are kept in the
+	// inboxes, zero keeps them forever
+	NotificationRetention time.Duration
+
+	// SearchRebuildInterval is how often the search index is rebuilt from
+	// the database, which catches up with the changes made by the other
+	// processes. Zero only builds it when the server starts
+	SearchRebuildInterval time.Duration
+
+	// IdempotencyRetention is how long the responses of the POST requests
+	// sent with an Idempotency-Key header are replayed to their retries.
+	// Zero ignores the header
+	IdempotencyRetention time.Duration
 }
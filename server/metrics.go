@@ -0,0 +1,77 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/iocat/donit/internal/metrics"
+)
+
+// unmatchedRoute labels the requests that do not match any route
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequests = metrics.DefaultRegistry.NewCounterVec(
+		"donit_http_requests_total",
+		"Number of HTTP requests by route template, method and status code.",
+		"route", "method", "status")
+	httpDuration = metrics.DefaultRegistry.NewHistogramVec(
+		"donit_http_request_duration_seconds",
+		"Latency of HTTP requests by route template, method and status code.",
+		metrics.DefaultBuckets, "route", "method", "status")
+)
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// Write implies a 200 status if the handler did not write any
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(b)
+}
+
+// Flush lets the streaming handlers flush through the recorder
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// instrumented records the request count and latency of the handler under
+// the route template rather than the raw path so that the label set stays
+// bounded
+func instrumented(route string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sr, r)
+		status := strconv.Itoa(sr.status)
+		httpRequests.Inc(route, r.Method, status)
+		httpDuration.Observe(time.Since(start).Seconds(), route, r.Method, status)
+	})
+}
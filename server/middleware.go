@@ -0,0 +1,84 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/iocat/donit/handler"
+	"github.com/iocat/donit/internal/auth"
+	"github.com/iocat/donit/internal/logger"
+	"github.com/iocat/donit/internal/requestid"
+)
+
+// withRequestID tags the request with the ID sent by the client in the
+// X-Request-ID header or a new one, echoes it back to the client and puts
+// a logger carrying the ID in the request context
+func withRequestID(log *logger.Logger, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestid.FromRequest(r)
+		w.Header().Set(requestid.Header, id)
+		ctx := requestid.NewContext(r.Context(), id)
+		ctx = logger.NewContext(ctx, log.With("request_id", id))
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// accessLogged writes an access log record for every request served by the
+// handler of the route
+func accessLogged(route string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sr, r)
+		caller, _ := auth.Caller(r.Context())
+		logger.FromContext(r.Context()).Info("access",
+			"method", r.Method,
+			"route", route,
+			"path", r.URL.EscapedPath(),
+			"status", sr.status,
+			"duration", time.Since(start),
+			"user", caller,
+			"remote", r.RemoteAddr,
+		)
+	})
+}
+
+// recovered turns the panics of the handler into internal server errors and
+// logs them with the stack trace
+func recovered(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sr := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			logger.FromContext(r.Context()).Error("handler panicked",
+				"panic", v,
+				"stack", string(debug.Stack()),
+			)
+			// The response has already started, there is nothing left to
+			// tell the client
+			if sr.status != 0 {
+				return
+			}
+			handler.InternalError.ServeHTTP(w, r)
+		}()
+		h.ServeHTTP(sr, r)
+	})
+}
@@ -0,0 +1,549 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/iocat/donit/handler"
+	"github.com/iocat/donit/internal/achieving/apispec"
+)
+
+var apiInfo = apispec.Info{
+	Title:       "donit",
+	Description: "RESTful API of the realtime goal tracking application",
+	Version:     "1.0.0",
+}
+
+// route is an API route and its documentation, the router and the OpenAPI
+// document are both generated from the route table. TestRoutesDocumented
+// checks they serve and describe the same operations
+type route struct {
+	apispec.Route
+	handler http.Handler
+}
+
+// routes returns the route table of the API, doc is the document served by
+// the OpenAPI route
+func routes(doc *apispec.Document) []route {
+	return []route{
+		// Authentication
+		{apispec.Route{
+			Method: "GET", Path: fmt.Sprintf("%s/auth", handler.User.URL()),
+			ID: "authenticate", Summary: "Check the user's password", Tag: "users",
+			Query: []apispec.Param{apispec.Password}, Response: apispec.BooleanModel,
+		}, handler.Auth},
+		{apispec.Route{
+			Method: "PUT", Path: fmt.Sprintf("%s/auth", handler.User.URL()),
+			ID: "changePassword", Summary: "Change the user's password", Tag: "users",
+			Query: []apispec.Param{
+				{Name: "old", Description: "The current password", Required: true},
+				{Name: "new", Description: "The new password", Required: true},
+			},
+			Status: http.StatusNoContent,
+		}, handler.PasswordChange},
+		// User CRUD
+		{apispec.Route{
+			Method: "POST", Path: handler.User.BaseURL(),
+			ID: "createUser", Summary: "Create a user", Tag: "users",
+			Query: []apispec.Param{apispec.Password}, Request: apispec.UserModel,
+			Status: http.StatusCreated,
+		}, handler.CreateUser},
+		{apispec.Route{
+			Method: "DELETE", Path: handler.User.URL(),
+			ID: "deleteUser", Summary: "Delete a user", Tag: "users",
+			Query: []apispec.Param{apispec.Password}, Status: http.StatusNoContent,
+		}, handler.DeleteUser},
+		{apispec.Route{
+			Method: "PUT", Path: handler.User.URL(),
+			ID: "updateUser", Summary: "Update a user", Tag: "users", Auth: true,
+			Request: apispec.UserModel, Status: http.StatusNoContent,
+		}, handler.UpdateUser},
+		{apispec.Route{
+			Method: "GET", Path: handler.User.URL(),
+			ID: "readUser", Summary: "Read a user, the others than the user read the public profile", Tag: "users",
+			Response: apispec.UserModel,
+		}, handler.ReadUser},
+		{apispec.Route{
+			Method: "GET", Path: handler.ProfileURL,
+			ID: "readProfile", Summary: "Read the public profile of a user", Tag: "users",
+			Response: apispec.ProfileModel,
+		}, handler.ReadProfile},
+		{apispec.Route{
+			Method: "GET", Path: handler.User.BaseURL(),
+			ID: "searchUsers", Summary: "Search the users by username and name", Tag: "users",
+			Query: []apispec.Param{
+				{Name: "q", Description: "The searched words, each one matches the usernames and the names beginning with it", Required: true},
+				apispec.Limit, apispec.Offset,
+			},
+			Response: apispec.ProfileModel, List: true,
+		}, handler.SearchUsers},
+		// Account archive
+		{apispec.Route{
+			Method: "GET", Path: handler.ExportURL,
+			ID: "exportAccount", Summary: "Download the archive of the user's account", Tag: "users", Auth: true,
+			Response: apispec.ArchiveModel,
+		}, handler.Export},
+		{apispec.Route{
+			Method: "POST", Path: handler.AccountImportURL,
+			ID: "importAccount", Summary: "Recreate an account from its archive, the password creates a new account", Tag: "users",
+			Query:   []apispec.Param{{Name: "password", Description: "The password of the account created, when the user does not exist"}},
+			Request: apispec.ArchiveModel, Response: apispec.ArchiveSummaryModel,
+		}, handler.ImportAccount},
+		// Goal CRUD
+		{apispec.Route{
+			Method: "POST", Path: handler.Goal.BaseURL(),
+			ID: "createGoal", Summary: "Create a goal", Tag: "goals", Auth: true,
+			Request: apispec.GoalModel, Status: http.StatusCreated,
+		}, handler.CreateGoal},
+		{apispec.Route{
+			Method: "GET", Path: handler.Goal.BaseURL(),
+			ID: "listGoals", Summary: "List the goals the caller can see", Tag: "goals",
+			Query: []apispec.Param{apispec.Limit, apispec.Offset, apispec.TagFilter}, Response: apispec.GoalModel, List: true,
+		}, handler.AllGoals},
+		{apispec.Route{
+			Method: "DELETE", Path: handler.Goal.URL(),
+			ID: "deleteGoal", Summary: "Delete a goal, only its owners can", Tag: "goals", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.DeleteGoal},
+		{apispec.Route{
+			Method: "PUT", Path: handler.Goal.URL(),
+			ID: "updateGoal", Summary: "Update a goal", Tag: "goals", Auth: true,
+			Request: apispec.GoalModel, Status: http.StatusNoContent,
+		}, handler.UpdateGoal},
+		{apispec.Route{
+			Method: "GET", Path: handler.Goal.URL(),
+			ID: "readGoal", Summary: "Read a goal", Tag: "goals",
+			Response: apispec.GoalModel,
+		}, handler.ReadGoal},
+		{apispec.Route{
+			Method: "POST", Path: handler.BatchURL,
+			ID: "runBatch", Summary: "Create, update or delete many goals and achievables at once, each operation succeeds or fails on its own", Tag: "goals", Auth: true,
+			Request: apispec.BatchModel, Response: apispec.BatchReportModel,
+		}, handler.RunBatch},
+		// Followers
+		{apispec.Route{
+			Method: "PUT", Path: handler.FolloweeURL,
+			ID: "follow", Summary: "Follow a user", Tag: "users", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.Follow},
+		{apispec.Route{
+			Method: "DELETE", Path: handler.FolloweeURL,
+			ID: "unfollow", Summary: "Stop following a user", Tag: "users", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.Unfollow},
+		{apispec.Route{
+			Method: "GET", Path: handler.FollowingURL,
+			ID: "listFollowing", Summary: "List the users a user follows", Tag: "users",
+			Query: []apispec.Param{apispec.Limit, apispec.Offset}, Response: apispec.UsernameModel, List: true,
+		}, handler.Following},
+		{apispec.Route{
+			Method: "GET", Path: handler.FollowersURL,
+			ID: "listFollowers", Summary: "List the followers of a user", Tag: "users",
+			Query: []apispec.Param{apispec.Limit, apispec.Offset}, Response: apispec.UsernameModel, List: true,
+		}, handler.Followers},
+		// Blocks
+		{apispec.Route{
+			Method: "PUT", Path: handler.BlockeeURL,
+			ID: "block", Summary: "Block a user, the users stop following each other", Tag: "users", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.Block},
+		{apispec.Route{
+			Method: "DELETE", Path: handler.BlockeeURL,
+			ID: "unblock", Summary: "Stop blocking a user", Tag: "users", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.Unblock},
+		{apispec.Route{
+			Method: "GET", Path: handler.BlockedURL,
+			ID: "listBlocked", Summary: "List the users a user blocks", Tag: "users", Auth: true,
+			Query: []apispec.Param{apispec.Limit, apispec.Offset}, Response: apispec.UsernameModel, List: true,
+		}, handler.Blocked},
+		// Members
+		{apispec.Route{
+			Method: "GET", Path: handler.MembersURL,
+			ID: "listMembers", Summary: "List the members of a goal and the users invited to it", Tag: "members",
+			Response: apispec.MemberModel, List: true,
+		}, handler.AllMembers},
+		{apispec.Route{
+			Method: "PUT", Path: handler.MemberURL,
+			ID: "setMember", Summary: "Invite a user to a goal with a role, or change the role of a member", Tag: "members", Auth: true,
+			Request: apispec.MemberModel, Status: http.StatusNoContent,
+		}, handler.SetMember},
+		{apispec.Route{
+			Method: "DELETE", Path: handler.MemberURL,
+			ID: "removeMember", Summary: "Remove a member or an invite, the members can leave", Tag: "members", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.RemoveMember},
+		{apispec.Route{
+			Method: "GET", Path: handler.MembershipsURL,
+			ID: "listMemberships", Summary: "List the goals a user is a member of or invited to", Tag: "members", Auth: true,
+			Query: []apispec.Param{
+				{Name: "status", Description: "INVITED or ACTIVE, both by default"},
+				apispec.Limit, apispec.Offset,
+			},
+			Response: apispec.MemberModel, List: true,
+		}, handler.Memberships},
+		{apispec.Route{
+			Method: "PUT", Path: handler.MembershipURL,
+			ID: "acceptMembership", Summary: "Accept an invite to a goal", Tag: "members", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.AcceptMembership},
+		{apispec.Route{
+			Method: "DELETE", Path: handler.MembershipURL,
+			ID: "leaveMembership", Summary: "Decline an invite to a goal or leave it", Tag: "members", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.LeaveMembership},
+		// Workspaces
+		{apispec.Route{
+			Method: "POST", Path: handler.WorkspacesURL,
+			ID: "createWorkspace", Summary: "Create a workspace, the caller is its first admin", Tag: "workspaces", Auth: true,
+			Request: apispec.WorkspaceModel, Status: http.StatusCreated,
+		}, handler.CreateWorkspace},
+		{apispec.Route{
+			Method: "GET", Path: handler.WorkspacesURL,
+			ID: "listWorkspaces", Summary: "List the workspaces of the caller", Tag: "workspaces", Auth: true,
+			Query: []apispec.Param{apispec.Limit, apispec.Offset}, Response: apispec.WorkspaceModel, List: true,
+		}, handler.AllWorkspaces},
+		{apispec.Route{
+			Method: "GET", Path: handler.WorkspaceURL,
+			ID: "readWorkspace", Summary: "Read a workspace of the caller and its members", Tag: "workspaces", Auth: true,
+			Response: apispec.WorkspaceModel,
+		}, handler.ReadWorkspace},
+		{apispec.Route{
+			Method: "PUT", Path: handler.WorkspaceURL,
+			ID: "updateWorkspace", Summary: "Rename a workspace or change its description, the admins can", Tag: "workspaces", Auth: true,
+			Request: apispec.WorkspaceModel, Status: http.StatusNoContent,
+		}, handler.UpdateWorkspace},
+		{apispec.Route{
+			Method: "DELETE", Path: handler.WorkspaceURL,
+			ID: "deleteWorkspace", Summary: "Delete a workspace without goals, the admins can", Tag: "workspaces", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.DeleteWorkspace},
+		{apispec.Route{
+			Method: "PUT", Path: handler.WorkspaceMemberURL,
+			ID: "setWorkspaceMember", Summary: "Add a user to a workspace or change the role of a member, the admins can", Tag: "workspaces", Auth: true,
+			Request: apispec.WorkspaceMemberModel, Status: http.StatusNoContent,
+		}, handler.SetWorkspaceMember},
+		{apispec.Route{
+			Method: "DELETE", Path: handler.WorkspaceMemberURL,
+			ID: "removeWorkspaceMember", Summary: "Remove a member from a workspace, the members can leave", Tag: "workspaces", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.RemoveWorkspaceMember},
+		{apispec.Route{
+			Method: "GET", Path: handler.WorkspaceGoalsURL,
+			ID: "listWorkspaceGoals", Summary: "List the goals of a workspace the caller can see, the latest updated first", Tag: "workspaces", Auth: true,
+			Query: []apispec.Param{apispec.Limit, apispec.Offset}, Response: apispec.GoalModel, List: true,
+		}, handler.WorkspaceGoals},
+		// Templates
+		{apispec.Route{
+			Method: "POST", Path: handler.GoalTemplateURL,
+			ID: "saveTemplate", Summary: "Save a goal and its achievables as a template", Tag: "templates", Auth: true,
+			Request: apispec.TemplateModel, Status: http.StatusCreated,
+		}, handler.SaveTemplate},
+		{apispec.Route{
+			Method: "GET", Path: handler.UserTemplatesURL,
+			ID: "listUserTemplates", Summary: "List the templates of a user, the others see the public ones", Tag: "templates",
+			Query: []apispec.Param{apispec.Limit, apispec.Offset}, Response: apispec.TemplateModel, List: true,
+		}, handler.UserTemplates},
+		{apispec.Route{
+			Method: "GET", Path: handler.TemplatesURL,
+			ID: "listPublicTemplates", Summary: "List the public templates, the most used first", Tag: "templates",
+			Query: []apispec.Param{
+				{Name: "q", Description: "The text the names of the templates contain"},
+				apispec.Limit, apispec.Offset,
+			},
+			Response: apispec.TemplateModel, List: true,
+		}, handler.PublicTemplates},
+		{apispec.Route{
+			Method: "GET", Path: handler.TemplateURL,
+			ID: "readTemplate", Summary: "Read a template of the caller or a public one", Tag: "templates",
+			Response: apispec.TemplateModel,
+		}, handler.ReadTemplate},
+		{apispec.Route{
+			Method: "PUT", Path: handler.TemplateURL,
+			ID: "updateTemplate", Summary: "Rename, describe, publish or unpublish a template of the caller", Tag: "templates", Auth: true,
+			Request: apispec.TemplateModel, Status: http.StatusNoContent,
+		}, handler.UpdateTemplate},
+		{apispec.Route{
+			Method: "DELETE", Path: handler.TemplateURL,
+			ID: "deleteTemplate", Summary: "Delete a template of the caller", Tag: "templates", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.DeleteTemplate},
+		{apispec.Route{
+			Method: "POST", Path: handler.TemplateGoalsURL,
+			ID: "instantiateTemplate", Summary: "Create a goal of the caller from a template, its reminders rebased on the start date", Tag: "templates", Auth: true,
+			Query: []apispec.Param{
+				{Name: "start", Description: "The start date formatted as 2006-01-02 in the caller's time zone, today by default"},
+			},
+			Status: http.StatusCreated,
+		}, handler.InstantiateTemplate},
+		{apispec.Route{
+			Method: "POST", Path: handler.CloneURL,
+			ID: "cloneGoal", Summary: "Copy a goal the caller can see and its achievables to the caller's goals, all left to be done", Tag: "templates", Auth: true,
+			Status: http.StatusCreated,
+		}, handler.CloneGoal},
+		// Activity
+		{apispec.Route{
+			Method: "GET", Path: handler.GoalActivityURL,
+			ID: "listGoalActivity", Summary: "List the changes of a goal the caller can edit and of its achievables, the latest first", Tag: "activity", Auth: true,
+			Query: []apispec.Param{apispec.Limit, apispec.Offset}, Response: apispec.ActivityModel, List: true,
+		}, handler.GoalActivity},
+		{apispec.Route{
+			Method: "POST", Path: handler.RevertURL,
+			ID: "revertGoal", Summary: "Revert a goal or one of its achievables to the revision an activity left it in", Tag: "activity", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.RevertGoal},
+		{apispec.Route{
+			Method: "GET", Path: handler.UserActivityURL,
+			ID: "listUserActivity", Summary: "List the changes of the caller's data and goals and the changes the caller made, the latest first", Tag: "activity", Auth: true,
+			Query: []apispec.Param{apispec.Limit, apispec.Offset}, Response: apispec.ActivityModel, List: true,
+		}, handler.UserActivity},
+		{apispec.Route{
+			Method: "POST", Path: handler.UndoURL,
+			ID: "undo", Summary: "Undo the latest changes the caller made within the last 15 minutes, stopping at the first one refused because its resource was changed since. The error lists the changes undone before in undone", Tag: "activity", Auth: true,
+			Query: []apispec.Param{
+				{Name: "count", Description: "The number of changes to undo, 1 by default and 20 at most", Type: "integer"},
+			},
+			Response: apispec.ActivityModel, List: true,
+		}, handler.Undo},
+		// Comments and reactions
+		{apispec.Route{
+			Method: "POST", Path: handler.GoalCommentsURL,
+			ID: "commentGoal", Summary: "Comment on a goal the caller can see, or reply to a comment", Tag: "comments", Auth: true,
+			Request: apispec.CommentModel, Status: http.StatusCreated,
+		}, handler.AddGoalComment},
+		{apispec.Route{
+			Method: "GET", Path: handler.GoalCommentsURL,
+			ID: "listGoalComments", Summary: "List the threads of comments on a goal, the oldest first", Tag: "comments",
+			Query: []apispec.Param{apispec.Limit, apispec.Offset}, Response: apispec.CommentModel, List: true,
+		}, handler.GoalComments},
+		{apispec.Route{
+			Method: "PUT", Path: handler.GoalCommentURL,
+			ID: "updateGoalComment", Summary: "Edit a comment on a goal, only its author can", Tag: "comments", Auth: true,
+			Request: apispec.CommentModel, Status: http.StatusNoContent,
+		}, handler.UpdateGoalComment},
+		{apispec.Route{
+			Method: "DELETE", Path: handler.GoalCommentURL,
+			ID: "deleteGoalComment", Summary: "Delete a comment on a goal, its author and the goal's owner can", Tag: "comments", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.DeleteGoalComment},
+		{apispec.Route{
+			Method: "PUT", Path: handler.GoalReactionURL,
+			ID: "reactOnGoal", Summary: "React with an emoji on a goal the caller can see", Tag: "comments", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.ReactOnGoal},
+		{apispec.Route{
+			Method: "DELETE", Path: handler.GoalReactionURL,
+			ID: "unreactOnGoal", Summary: "Remove the caller's reaction with an emoji on a goal", Tag: "comments", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.UnreactOnGoal},
+		{apispec.Route{
+			Method: "GET", Path: handler.GoalReactionsURL,
+			ID: "listGoalReactions", Summary: "Count the reactions on a goal per emoji, the most used first", Tag: "comments",
+			Response: apispec.ReactionModel, List: true,
+		}, handler.GoalReactions},
+		{apispec.Route{
+			Method: "POST", Path: handler.AchievableCommentsURL,
+			ID: "commentAchievable", Summary: "Comment on an achievable the caller can see, or reply to a comment", Tag: "comments", Auth: true,
+			Request: apispec.CommentModel, Status: http.StatusCreated,
+		}, handler.AddAchievableComment},
+		{apispec.Route{
+			Method: "GET", Path: handler.AchievableCommentsURL,
+			ID: "listAchievableComments", Summary: "List the threads of comments on an achievable, the oldest first", Tag: "comments",
+			Query: []apispec.Param{apispec.Limit, apispec.Offset}, Response: apispec.CommentModel, List: true,
+		}, handler.AchievableComments},
+		{apispec.Route{
+			Method: "PUT", Path: handler.AchievableCommentURL,
+			ID: "updateAchievableComment", Summary: "Edit a comment on an achievable, only its author can", Tag: "comments", Auth: true,
+			Request: apispec.CommentModel, Status: http.StatusNoContent,
+		}, handler.UpdateAchievableComment},
+		{apispec.Route{
+			Method: "DELETE", Path: handler.AchievableCommentURL,
+			ID: "deleteAchievableComment", Summary: "Delete a comment on an achievable, its author and the goal's owner can", Tag: "comments", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.DeleteAchievableComment},
+		{apispec.Route{
+			Method: "PUT", Path: handler.AchievableReactionURL,
+			ID: "reactOnAchievable", Summary: "React with an emoji on an achievable the caller can see", Tag: "comments", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.ReactOnAchievable},
+		{apispec.Route{
+			Method: "DELETE", Path: handler.AchievableReactionURL,
+			ID: "unreactOnAchievable", Summary: "Remove the caller's reaction with an emoji on an achievable", Tag: "comments", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.UnreactOnAchievable},
+		{apispec.Route{
+			Method: "GET", Path: handler.AchievableReactionsURL,
+			ID: "listAchievableReactions", Summary: "Count the reactions on an achievable per emoji, the most used first", Tag: "comments",
+			Response: apispec.ReactionModel, List: true,
+		}, handler.AchievableReactions},
+		// Explore
+		{apispec.Route{
+			Method: "GET", Path: handler.ExploreURL,
+			ID: "explore", Summary: "List the public goals of the users the caller can see", Tag: "explore",
+			Query: []apispec.Param{
+				{Name: "sort", Description: "recent (the default) or popular"},
+				apispec.Limit, apispec.Offset,
+			},
+			Response: apispec.ExploredGoalModel, List: true,
+		}, handler.Explore},
+		// Search
+		{apispec.Route{
+			Method: "GET", Path: handler.SearchURL,
+			ID: "search", Summary: "Search the goals and the achievables of a user the caller can see", Tag: "search",
+			Query: []apispec.Param{
+				{Name: "q", Description: "The searched words, each one matches the words beginning with it", Required: true},
+				{Name: "status", Description: "The status of the goals and the achievables found"},
+				{Name: "type", Description: "goal, habit or task"},
+				apispec.Limit, apispec.Offset,
+			},
+			Response: apispec.SearchResultsModel,
+		}, handler.Search},
+		// Tags
+		{apispec.Route{
+			Method: "GET", Path: handler.TagsURL,
+			ID: "listTags", Summary: "List the catalogue of tags of a user", Tag: "tags", Auth: true,
+			Query: []apispec.Param{apispec.Limit, apispec.Offset}, Response: apispec.TagModel, List: true,
+		}, handler.AllTags},
+		{apispec.Route{
+			Method: "POST", Path: handler.TagsURL,
+			ID: "createTag", Summary: "Add a tag to the catalogue", Tag: "tags", Auth: true,
+			Request: apispec.TagModel, Status: http.StatusCreated,
+		}, handler.CreateTag},
+		{apispec.Route{
+			Method: "PUT", Path: handler.TagURL,
+			ID: "updateTag", Summary: "Rename or recolor a tag", Tag: "tags", Auth: true,
+			Request: apispec.TagModel, Status: http.StatusNoContent,
+		}, handler.UpdateTag},
+		{apispec.Route{
+			Method: "DELETE", Path: handler.TagURL,
+			ID: "deleteTag", Summary: "Delete a tag, the goals and the achievables lose it", Tag: "tags", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.DeleteTag},
+		{apispec.Route{
+			Method: "POST", Path: handler.TagMergeURL,
+			ID: "mergeTag", Summary: "Merge a tag into another one", Tag: "tags", Auth: true,
+			Query:  []apispec.Param{{Name: "into", Description: "The ID of the tag kept", Required: true}},
+			Status: http.StatusNoContent,
+		}, handler.MergeTag},
+		// Notification inbox
+		{apispec.Route{
+			Method: "GET", Path: handler.NotificationsURL,
+			ID: "listNotifications", Summary: "List the user's notifications, the latest first", Tag: "notifications", Auth: true,
+			Query: []apispec.Param{apispec.Limit, apispec.Offset,
+				{Name: "unread", Description: "Only list the unread notifications", Type: "boolean"},
+			},
+			Response: apispec.NotificationModel, List: true,
+		}, handler.Notifications},
+		{apispec.Route{
+			Method: "POST", Path: handler.ReadNotificationsURL,
+			ID: "readAllNotifications", Summary: "Mark all the user's notifications read", Tag: "notifications", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.ReadNotifications},
+		{apispec.Route{
+			Method: "GET", Path: handler.NotificationStreamURL,
+			ID: "streamNotifications", Summary: "Receive the user's new notifications as server-sent events", Tag: "notifications", Auth: true,
+			Response: apispec.EventStreamModel,
+		}, handler.NotificationStream},
+		{apispec.Route{
+			Method: "PUT", Path: handler.NotificationURL,
+			ID: "updateNotification", Summary: "Mark a notification read or unread", Tag: "notifications", Auth: true,
+			Request: apispec.NotificationStateModel, Status: http.StatusNoContent,
+		}, handler.UpdateNotification},
+		{apispec.Route{
+			Method: "DELETE", Path: handler.NotificationURL,
+			ID: "deleteNotification", Summary: "Remove a notification from the inbox", Tag: "notifications", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.DeleteNotification},
+		// Calendar feed
+		{apispec.Route{
+			Method: "GET", Path: handler.CalendarURL,
+			ID: "readCalendar", Summary: "Subscribe to the user's reminders as an iCalendar feed", Tag: "calendar",
+			Query: []apispec.Param{
+				{Name: "token", Description: "The token of the feed, the owner can authenticate instead"},
+				{Name: "tasks", Description: "How the tasks are rendered: todos (default) or events"},
+			},
+			Response: apispec.CalendarModel,
+		}, handler.Calendar},
+		{apispec.Route{
+			Method: "POST", Path: handler.CalendarTokenURL,
+			ID: "rotateCalendarToken", Summary: "Create a new token for the user's feed, revoking the previous one", Tag: "calendar", Auth: true,
+			Response: apispec.CalendarAccessModel,
+		}, handler.RotateCalendarToken},
+		{apispec.Route{
+			Method: "DELETE", Path: handler.CalendarTokenURL,
+			ID: "revokeCalendarToken", Summary: "Revoke the token of the user's feed", Tag: "calendar", Auth: true,
+			Status: http.StatusNoContent,
+		}, handler.RevokeCalendarToken},
+		// Achievable CRUD
+		{apispec.Route{
+			Method: "POST", Path: handler.Achievable.BaseURL(),
+			ID: "createAchievable", Summary: "Add an achievable task to a goal", Tag: "achievables", Auth: true,
+			Request: apispec.AchievableModel, Status: http.StatusCreated,
+		}, handler.CreateAchievable},
+		{apispec.Route{
+			Method: "GET", Path: handler.Achievable.BaseURL(),
+			ID: "listAchievables", Summary: "List the achievable tasks of a goal", Tag: "achievables",
+			Query: []apispec.Param{apispec.Limit, apispec.Offset, apispec.TagFilter,
+				{Name: "order", Description: "topological lists the blockers and the subtasks before the tasks they block, it cannot be combined with tag"},
+			}, Response: apispec.AchievableModel, List: true,
+		}, handler.AllAchievables},
+		{apispec.Route{
+			Method: "DELETE", Path: handler.Achievable.URL(),
+			ID: "deleteAchievable", Summary: "Delete an achievable task", Tag: "achievables", Auth: true,
+		}, handler.DeleteAchievable},
+		{apispec.Route{
+			Method: "PUT", Path: handler.Achievable.URL(),
+			ID: "updateAchievable", Summary: "Update an achievable task", Tag: "achievables", Auth: true,
+			Request: apispec.AchievableModel,
+		}, handler.UpdateAchievable},
+		{apispec.Route{
+			Method: "POST", Path: handler.ImportURL,
+			ID: "importAchievables", Summary: "Import the tasks and habits of an iCalendar or CSV file into a goal", Tag: "achievables", Auth: true,
+			Query: []apispec.Param{
+				{Name: "format", Description: "The format of the file, ics or csv, by default the one of its content type"},
+				{Name: "dryRun", Description: "Only report what would be imported", Type: "boolean"},
+			},
+			Request: apispec.ImportFileModel, Response: apispec.ImportReportModel,
+		}, handler.ImportAchievables},
+		{apispec.Route{
+			Method: "GET", Path: handler.DeliveriesURL,
+			ID: "listDeliveries", Summary: "List the deliveries of an achievable task's reminders", Tag: "achievables", Auth: true,
+			Query: []apispec.Param{apispec.Limit, apispec.Offset}, Response: apispec.DeliveryModel, List: true,
+		}, handler.Deliveries},
+		// Error catalog
+		{apispec.Route{
+			Method: "GET", Path: handler.ProblemsURL,
+			ID: "listProblemTypes", Summary: "List the error codes", Tag: "documentation",
+			Response: apispec.ProblemTypeModel, List: true,
+		}, handler.Problems},
+		{apispec.Route{
+			Method: "GET", Path: handler.ProblemsURL + "/{problem}",
+			ID: "readProblemType", Summary: "Read the documentation of an error code", Tag: "documentation",
+			Response: apispec.ProblemTypeModel,
+		}, handler.ProblemType},
+		// API documentation
+		{apispec.Route{
+			Method: "GET", Path: handler.OpenAPIURL,
+			ID: "readOpenAPI", Summary: "Read the OpenAPI document of the API", Tag: "documentation",
+			Response: apispec.DocumentModel,
+		}, handler.OpenAPI(doc)},
+		{apispec.Route{
+			Method: "GET", Path: handler.DocsURL,
+			ID: "readDocs", Summary: "Browse the interactive API documentation", Tag: "documentation",
+		}, handler.Docs},
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2016 Thanh Ngo <felix.infinite@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/iocat/donit/handler"
+)
+
+// methods are the methods a route may be registered for
+var methods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// templateVar matches the variables of a path template
+var templateVar = regexp.MustCompile(`\{[^}]+\}`)
+
+// request returns a request for the method on a path of the template
+func request(method, template string) *http.Request {
+	return httptest.NewRequest(method, templateVar.ReplaceAllString(template, "x"), nil)
+}
+
+// registered lists the method and path template pairs of the router
+func registered(t *testing.T, r *mux.Router) map[string]bool {
+	pairs := make(map[string]bool)
+	err := r.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, method := range methods {
+			var match mux.RouteMatch
+			if route.Match(request(method, template), &match) {
+				pairs[method+" "+template] = true
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s: matches none of the methods %v", template, methods)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk the router: %s", err)
+	}
+	return pairs
+}
+
+// documented lists the method and path pairs of the served OpenAPI document
+func documented(t *testing.T, r *mux.Router) map[string]bool {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", handler.OpenAPIURL, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s: status %d", handler.OpenAPIURL, w.Code)
+	}
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode the OpenAPI document: %s", err)
+	}
+	pairs := make(map[string]bool)
+	for path, item := range doc.Paths {
+		for method := range item {
+			pairs[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+	return pairs
+}
+
+func sorted(pairs map[string]bool) []string {
+	list := make([]string, 0, len(pairs))
+	for pair := range pairs {
+		list = append(list, pair)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// TestRoutesDocumented checks that the router serves every operation of the
+// OpenAPI document and that the document describes every route
+func TestRoutesDocumented(t *testing.T) {
+	sb := &serverBuilder{conf: DefaultConfig}
+	if _, err := sb.logger().router().build(); err != nil {
+		t.Fatalf("build the router: %s", err)
+	}
+	routes, docs := registered(t, sb.r), documented(t, sb.r)
+	if len(docs) == 0 {
+		t.Fatal("the OpenAPI document has no operation")
+	}
+	for _, pair := range sorted(routes) {
+		if !docs[pair] {
+			t.Errorf("%s: routed but not documented", pair)
+		}
+	}
+	for _, pair := range sorted(docs) {
+		if !routes[pair] {
+			t.Errorf("%s: documented but not routed", pair)
+			continue
+		}
+		// a route registered earlier with a more general template would
+		// serve the requests of the operation instead
+		i := strings.Index(pair, " ")
+		method, path := pair[:i], pair[i+1:]
+		var match mux.RouteMatch
+		if !sb.r.Match(request(method, path), &match) {
+			t.Errorf("%s: not matched by the router", pair)
+			continue
+		}
+		if template, _ := match.Route.GetPathTemplate(); template != path {
+			t.Errorf("%s: served by the route %s", pair, template)
+		}
+	}
+}
@@ -19,14 +19,29 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/iocat/donit/internal/achieving/indexer"
+	"github.com/iocat/donit/internal/achieving/notifier"
+	"github.com/iocat/donit/internal/achieving/reminders"
+	"github.com/iocat/donit/internal/logger"
 )
 
 // Server represents a RESTful server
 type Server struct {
 	httpServer *http.Server
+	// metricsServer serves the metrics, it is nil if disabled
+	metricsServer *http.Server
 
 	// The router for HTTP service
 	r *mux.Router
+
+	// reminders delivers the due reminders
+	reminders *reminders.Scheduler
+	// notifier fills the inboxes with the events of the store
+	notifier *notifier.Notifier
+	// indexer keeps the search index in sync with the store
+	indexer *indexer.Indexer
+
+	log *logger.Logger
 }
 
 // New creates a new server
@@ -35,14 +50,24 @@ func New(conf *Config) (*Server, error) {
 		conf = &DefaultConfig
 	}
 	sb := serverBuilder{conf: *conf}
-	server, err := sb.router().http().build()
+	server, err := sb.logger().database().notifications().search().idempotencyKeys().router().http().metrics().build()
 	if err != nil {
 		return nil, fmt.Errorf("set up server: %s", err)
 	}
+	server.log = sb.log
 	return server, nil
 }
 
 // Start starts the server on the current process
 func (s *Server) Start() {
-	fmt.Println(s.httpServer.ListenAndServe())
+	if s.metricsServer != nil {
+		go func() {
+			s.log.Error("metrics server stopped", "err", s.metricsServer.ListenAndServe())
+		}()
+	}
+	go s.reminders.Run(nil)
+	go s.notifier.Run(nil)
+	go s.indexer.Run(nil)
+	s.log.Info("server started", "addr", s.httpServer.Addr)
+	s.log.Error("server stopped", "err", s.httpServer.ListenAndServe())
 }